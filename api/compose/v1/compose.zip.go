@@ -24,6 +24,10 @@ type (
 type ComposeSpec struct {
 	Workdir     string `json:"workdir,omitempty"`
 	Composefile string `json:"composefile,omitempty"`
+
+	// Composefiles are all the compose files of the project, in the order in
+	// which they are merged.  Composefile is always the first of these.
+	Composefiles []string `json:"composefiles,omitempty"`
 }
 
 // ComposeStatus contains the complete status of the compose project.
@@ -0,0 +1,27 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import (
+	"context"
+	"time"
+)
+
+// pauseTimeoutKey is used to retrieve the pause timeout from the context.
+type pauseTimeoutKey struct{}
+
+// WithPauseTimeout returns a context which indicates to a MachineService how
+// long it may wait for a machine to quiesce before pausing it.
+func WithPauseTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, pauseTimeoutKey{}, timeout)
+}
+
+// PauseTimeoutFromContext returns the time a MachineService may wait for a
+// machine to quiesce before pausing it, after which it is paused regardless.
+// A zero duration indicates that the machine should be paused immediately.
+func PauseTimeoutFromContext(ctx context.Context) time.Duration {
+	timeout, _ := ctx.Value(pauseTimeoutKey{}).(time.Duration)
+	return timeout
+}
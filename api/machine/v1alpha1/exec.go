@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import (
+	"context"
+	"io"
+)
+
+// ExecOptions describe the command to execute within a running machine and
+// how its standard streams are connected.
+type ExecOptions struct {
+	// Command is the program and its arguments to execute.
+	Command []string
+
+	// Stdin is connected to the command's standard input if set.
+	Stdin io.Reader
+
+	// Stdout receives the command's standard output.
+	Stdout io.Writer
+
+	// Stderr receives the command's standard error.
+	Stderr io.Writer
+
+	// Tty indicates whether a pseudo-terminal should be allocated.
+	Tty bool
+}
+
+// MachineExecService is optionally implemented by a MachineService whose
+// platform is able to execute commands within a running machine.  Callers
+// should use a type assertion to determine whether exec is supported.
+type MachineExecService interface {
+	Exec(context.Context, *Machine, ExecOptions) error
+}
@@ -87,6 +87,10 @@ type MachineSpec struct {
 	// Environment variables associated with this machine
 	Env map[string]string `json:"env,omitempty"`
 
+	// WorkingDir is the working directory of the application, e.g. as set by
+	// its root filesystem.
+	WorkingDir string `json:"workdir,omitempty"`
+
 	// Resources describes the compute resources (requests and limits) required by
 	// this machine.
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
@@ -34,6 +34,10 @@ type NetworkSpec struct {
 	// range.
 	Netmask string `json:"netmask,omitempty"`
 
+	// The maximum transmission unit of the network.  If unset, the default of
+	// the implementing strategy is used.
+	MTU int `json:"mtu,omitempty"`
+
 	// Network interfaces associated with this network.
 	Interfaces []NetworkInterfaceTemplateSpec `json:"interfaces,omitempty"`
 }
@@ -114,6 +114,53 @@ func TarFileWriter(ctx context.Context, src, dst string, tw *tar.Writer, opts ..
 	return nil
 }
 
+// TarReaderWriter creates a tarball entry at dst containing the size bytes
+// read from r using the provided tw tarball writer.  The entry is written as a
+// regular file owned by root.
+func TarReaderWriter(ctx context.Context, r io.Reader, size int64, dst string, tw *tar.Writer, opts ...ArchiveOption) error {
+	dst = filepath.ToSlash(dst)
+
+	if dst == "" {
+		return fmt.Errorf("cannot tar reader with no specified destination")
+	}
+	dst = strings.TrimPrefix(dst, "/")
+
+	aopts := ArchiveOptions{}
+	for _, opt := range opts {
+		if err := opt(&aopts); err != nil {
+			return err
+		}
+	}
+
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     dst,
+		Mode:     0o644,
+		Size:     size,
+	}
+
+	if !aopts.stripTimes {
+		header.ModTime = time.Now()
+	}
+
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("tar: %w", err)
+	}
+
+	log.G(ctx).WithFields(logrus.Fields{
+		"dst": dst,
+	}).Trace("archive: tarring from reader")
+
+	buf := bufPool.Get().(*[]byte)
+	defer bufPool.Put(buf)
+
+	if _, err := io.CopyBuffer(tw, io.LimitReader(r, size), *buf); err != nil {
+		return fmt.Errorf("failed to copy to %s: %w", dst, err)
+	}
+
+	return nil
+}
+
 // TarFileTo accepts an input file `src` and places it exactly with the desired
 // location `dst` inside the resulting artifact which is located at `out`.
 func TarFileTo(ctx context.Context, src, dst, out string, opts ...ArchiveOption) error {
@@ -0,0 +1,408 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package compose
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/sirupsen/logrus"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/log"
+)
+
+// Defaults for the healthcheck attributes which are not set, which match
+// those of Docker.
+const (
+	DefaultHealthcheckInterval = 30 * time.Second
+	DefaultHealthcheckTimeout  = 30 * time.Second
+	DefaultHealthcheckRetries  = 3
+)
+
+// HasHealthcheck returns whether the service declares a healthcheck which has
+// not been disabled.
+func HasHealthcheck(service types.ServiceConfig) bool {
+	hc := service.HealthCheck
+	if hc == nil || hc.Disable || len(hc.Test) == 0 {
+		return false
+	}
+
+	return hc.Test[0] != "NONE"
+}
+
+// healthcheckConfig holds the healthcheck attributes of a service with the
+// defaults applied.
+type healthcheckConfig struct {
+	interval      time.Duration
+	timeout       time.Duration
+	retries       uint64
+	startPeriod   time.Duration
+	startInterval time.Duration
+}
+
+// serviceHealthcheckConfig returns the healthcheck attributes of the service,
+// using the defaults for those which are not set.
+func serviceHealthcheckConfig(service types.ServiceConfig) healthcheckConfig {
+	config := healthcheckConfig{
+		interval: DefaultHealthcheckInterval,
+		timeout:  DefaultHealthcheckTimeout,
+		retries:  DefaultHealthcheckRetries,
+	}
+
+	hc := service.HealthCheck
+	if hc == nil {
+		return config
+	}
+
+	if hc.Interval != nil {
+		config.interval = time.Duration(*hc.Interval)
+	}
+	if hc.Timeout != nil {
+		config.timeout = time.Duration(*hc.Timeout)
+	}
+	if hc.Retries != nil {
+		config.retries = *hc.Retries
+	}
+	if hc.StartPeriod != nil {
+		config.startPeriod = time.Duration(*hc.StartPeriod)
+	}
+	if hc.StartInterval != nil {
+		config.startInterval = time.Duration(*hc.StartInterval)
+	}
+
+	return config
+}
+
+// HealthyTimeout returns the maximum time to wait for the service to become
+// healthy, which is its `start_period` followed by each of its `retries`,
+// every one of which may take up to its `interval` and `timeout`.
+func HealthyTimeout(service types.ServiceConfig) time.Duration {
+	config := serviceHealthcheckConfig(service)
+
+	return config.startPeriod + time.Duration(config.retries+1)*(config.interval+config.timeout)
+}
+
+// WaitHealthy blocks until the service's healthcheck passes, the service is
+// deemed unhealthy or the context is cancelled.  Services without a
+// healthcheck are considered healthy as soon as all of their machines are
+// running.
+//
+// Since unikernels do not provide a facility to execute commands within the
+// machine, the healthcheck's test is never executed.  Instead, tests which
+// probe the service over the network, i.e. `curl`, `wget` and `nc -z`, are
+// performed directly against the address of each of the service's machines.
+// Failures within the `start_period` do not count towards the number of
+// `retries`.
+func (project *Project) WaitHealthy(ctx context.Context, controller machineapi.MachineService, service types.ServiceConfig) error {
+	config := serviceHealthcheckConfig(service)
+
+	var probe *healthProbe
+	if HasHealthcheck(service) {
+		var err error
+		probe, err = parseHealthcheck(service.HealthCheck.Test)
+		if err != nil {
+			return fmt.Errorf("service %s: %w", service.Name, err)
+		}
+	}
+
+	started := time.Now()
+	failures := uint64(0)
+
+	for {
+		machines, err := serviceMachines(ctx, controller, service)
+		if err != nil {
+			return err
+		}
+
+		wait := config.interval
+		inStartPeriod := time.Since(started) < config.startPeriod
+		if inStartPeriod && config.startInterval > 0 {
+			wait = config.startInterval
+		}
+
+		if machines != nil {
+			if probe == nil {
+				return nil
+			}
+
+			err := probe.runAll(ctx, machines, config.timeout)
+			if err == nil {
+				log.G(ctx).
+					WithField("service", service.Name).
+					Debug("healthy")
+				return nil
+			}
+
+			log.G(ctx).WithFields(logrus.Fields{
+				"service": service.Name,
+				"error":   err,
+			}).Debug("healthcheck failed")
+
+			if !inStartPeriod {
+				failures++
+				if failures >= config.retries {
+					return fmt.Errorf("service %s is unhealthy: %w", service.Name, err)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for service %s to become healthy: %w", service.Name, ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+// serviceMachines returns the machines of the service if all of them are
+// running, or nil otherwise.  An error is returned if any of them has stopped.
+func serviceMachines(ctx context.Context, controller machineapi.MachineService, service types.ServiceConfig) ([]machineapi.Machine, error) {
+	machines, err := controller.List(ctx, &machineapi.MachineList{})
+	if err != nil {
+		return nil, err
+	}
+
+	containers := ServiceContainerNames(service)
+	running := []machineapi.Machine{}
+
+	for _, machine := range machines.Items {
+		if !slices.Contains(containers, machine.Name) {
+			continue
+		}
+
+		switch machine.Status.State {
+		case machineapi.MachineStateRunning:
+			running = append(running, machine)
+
+		case machineapi.MachineStateExited,
+			machineapi.MachineStateFailed,
+			machineapi.MachineStateErrored:
+			return nil, fmt.Errorf("service %s is unhealthy: machine %s is %s", service.Name, machine.Name, machine.Status.State)
+		}
+	}
+
+	if len(running) != len(containers) {
+		return nil, nil
+	}
+
+	return running, nil
+}
+
+// machineAddress returns the first IPv4 address of the machine's network
+// interfaces, or an empty string if it has none.
+func machineAddress(machine machineapi.Machine) string {
+	for _, network := range machine.Spec.Networks {
+		for _, iface := range network.Interfaces {
+			if ip, _, err := net.ParseCIDR(iface.Spec.CIDR); err == nil {
+				return ip.String()
+			}
+		}
+	}
+
+	return ""
+}
+
+// healthProbe is a network probe derived from the test of a healthcheck.
+type healthProbe struct {
+	// url is requested via HTTP if set, in which case the probe fails if the
+	// response has an error status, akin to `curl -f`.
+	url *url.URL
+
+	// address is connected to via TCP if url is not set, akin to `nc -z`.
+	address string
+}
+
+// parseHealthcheck derives a network probe from the healthcheck test, which
+// is either of the form `["CMD", args...]` or `["CMD-SHELL", command]`.  Only
+// tests which invoke `curl` or `wget` with an HTTP URL, or `nc -z` with a host
+// and port, are supported, since any other command could only be executed on
+// the host and not within the unikernel.
+func parseHealthcheck(test types.HealthCheckTest) (*healthProbe, error) {
+	var args []string
+
+	switch test[0] {
+	case "CMD":
+		args = test[1:]
+	case "CMD-SHELL":
+		if len(test) != 2 {
+			return nil, fmt.Errorf("healthcheck test must have exactly one shell command")
+		}
+
+		command := strings.TrimSpace(test[1])
+
+		// A trailing `|| exit 1` is commonly used to normalize the exit code and
+		// has no bearing on the probe.
+		if before, after, ok := strings.Cut(command, "||"); ok && strings.TrimSpace(after) == "exit 1" {
+			command = before
+		}
+
+		if strings.ContainsAny(command, ";&|<>$`(){}\\") {
+			return nil, fmt.Errorf("unsupported healthcheck test '%s': shell syntax is not supported", test[1])
+		}
+
+		for _, field := range strings.Fields(command) {
+			args = append(args, strings.Trim(field, `"'`))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported healthcheck test: %s", test[0])
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("healthcheck test is missing a command")
+	}
+
+	switch filepath.Base(args[0]) {
+	case "curl", "wget":
+		for _, arg := range args[1:] {
+			if !strings.HasPrefix(arg, "http://") && !strings.HasPrefix(arg, "https://") {
+				continue
+			}
+
+			u, err := url.Parse(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid healthcheck URL '%s': %w", arg, err)
+			}
+
+			return &healthProbe{url: u}, nil
+		}
+
+		return nil, fmt.Errorf("healthcheck test '%s' has no HTTP URL to probe", strings.Join(args, " "))
+
+	case "nc", "ncat", "netcat":
+		var positional []string
+		scan := false
+
+		for i := 1; i < len(args); i++ {
+			arg := args[i]
+
+			switch {
+			case arg == "-w":
+				// The connection timeout is superseded by that of the healthcheck.
+				i++
+			case strings.HasPrefix(arg, "-w"):
+			case strings.HasPrefix(arg, "-"):
+				if strings.Trim(arg[1:], "46nvz") != "" {
+					return nil, fmt.Errorf("unsupported healthcheck test '%s': unsupported flag %s", strings.Join(args, " "), arg)
+				}
+
+				scan = scan || strings.Contains(arg, "z")
+			default:
+				positional = append(positional, arg)
+			}
+		}
+
+		if !scan || len(positional) != 2 {
+			return nil, fmt.Errorf("healthcheck test '%s' must be of the form 'nc -z <host> <port>'", strings.Join(args, " "))
+		}
+
+		return &healthProbe{address: net.JoinHostPort(positional[0], positional[1])}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported healthcheck test '%s': commands cannot be executed within unikernels, only curl, wget and nc network probes are supported", strings.Join(args, " "))
+}
+
+// isLoopback returns whether the host refers to the machine itself from the
+// point of view of the healthcheck, i.e. `localhost` or a loopback address.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
+
+// runAll performs the probe against each of the machines and returns the
+// first failure.
+func (probe *healthProbe) runAll(ctx context.Context, machines []machineapi.Machine, timeout time.Duration) error {
+	for _, machine := range machines {
+		if err := probe.run(ctx, machineAddress(machine), timeout); err != nil {
+			return fmt.Errorf("machine %s: %w", machine.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// run performs the probe, substituting the machine's address for loopback
+// hosts, which would otherwise refer to the host instead of the machine.
+func (probe *healthProbe) run(ctx context.Context, address string, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	target := func(host string) (string, error) {
+		if !isLoopback(host) {
+			return host, nil
+		}
+		if address == "" {
+			return "", fmt.Errorf("machine has no address to probe")
+		}
+		return address, nil
+	}
+
+	if probe.url == nil {
+		host, port, err := net.SplitHostPort(probe.address)
+		if err != nil {
+			return err
+		}
+
+		host, err = target(host)
+		if err != nil {
+			return err
+		}
+
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	}
+
+	u := *probe.url
+
+	host, err := target(u.Hostname())
+	if err != nil {
+		return err
+	}
+
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else {
+		u.Host = host
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s returned %s", u.String(), resp.Status)
+	}
+
+	return nil
+}
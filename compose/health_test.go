@@ -0,0 +1,246 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package compose
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/types"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+)
+
+func TestParseHealthcheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		test    types.HealthCheckTest
+		url     string
+		address string
+		err     bool
+	}{
+		{
+			name: "curl",
+			test: types.HealthCheckTest{"CMD", "curl", "-f", "http://localhost:8080/health"},
+			url:  "http://localhost:8080/health",
+		},
+		{
+			name: "wget",
+			test: types.HealthCheckTest{"CMD", "/usr/bin/wget", "-q", "--spider", "https://localhost/"},
+			url:  "https://localhost/",
+		},
+		{
+			name: "shell with exit",
+			test: types.HealthCheckTest{"CMD-SHELL", "curl -f 'http://localhost/' || exit 1"},
+			url:  "http://localhost/",
+		},
+		{
+			name:    "nc",
+			test:    types.HealthCheckTest{"CMD", "nc", "-zv", "-w", "1", "localhost", "6379"},
+			address: "localhost:6379",
+		},
+		{
+			name: "nc without scan",
+			test: types.HealthCheckTest{"CMD", "nc", "localhost", "6379"},
+			err:  true,
+		},
+		{
+			name: "arbitrary command",
+			test: types.HealthCheckTest{"CMD", "rm", "-rf", "/"},
+			err:  true,
+		},
+		{
+			name: "shell syntax",
+			test: types.HealthCheckTest{"CMD-SHELL", "curl -f http://localhost/ && touch /tmp/pwned"},
+			err:  true,
+		},
+		{
+			name: "curl without url",
+			test: types.HealthCheckTest{"CMD", "curl", "--version"},
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe, err := parseHealthcheck(tt.test)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", probe)
+				}
+				return
+			} else if err != nil {
+				t.Fatal("parseHealthcheck:", err)
+			}
+
+			url := ""
+			if probe.url != nil {
+				url = probe.url.String()
+			}
+
+			if url != tt.url || probe.address != tt.address {
+				t.Errorf("expected url '%s' and address '%s', got '%s' and '%s'", tt.url, tt.address, url, probe.address)
+			}
+		})
+	}
+}
+
+func TestHealthProbeRun(t *testing.T) {
+	ctx := context.Background()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	healthy, err := parseHealthcheck(types.HealthCheckTest{"CMD", "curl", "-f", "http://localhost:" + port + "/health"})
+	if err != nil {
+		t.Fatal("parseHealthcheck:", err)
+	}
+
+	if err := healthy.run(ctx, "127.0.0.1", time.Second); err != nil {
+		t.Errorf("expected the probe to pass, got: %v", err)
+	}
+
+	if err := healthy.run(ctx, "", time.Second); err == nil {
+		t.Error("expected the probe to fail without a machine address")
+	}
+
+	unhealthy, err := parseHealthcheck(types.HealthCheckTest{"CMD", "curl", "-f", "http://localhost:" + port + "/broken"})
+	if err != nil {
+		t.Fatal("parseHealthcheck:", err)
+	}
+
+	if err := unhealthy.run(ctx, "127.0.0.1", time.Second); err == nil {
+		t.Error("expected the probe to fail on an error status")
+	}
+
+	tcp, err := parseHealthcheck(types.HealthCheckTest{"CMD", "nc", "-z", "localhost", port})
+	if err != nil {
+		t.Fatal("parseHealthcheck:", err)
+	}
+
+	if err := tcp.run(ctx, "127.0.0.1", time.Second); err != nil {
+		t.Errorf("expected the probe to pass, got: %v", err)
+	}
+}
+
+// fakeMachineService lists a fixed set of machines.
+type fakeMachineService struct {
+	machineapi.MachineService
+	machines []machineapi.Machine
+}
+
+func (fake *fakeMachineService) List(_ context.Context, _ *machineapi.MachineList) (*machineapi.MachineList, error) {
+	return &machineapi.MachineList{Items: fake.machines}, nil
+}
+
+func testMachine(name, cidr string, state machineapi.MachineState) machineapi.Machine {
+	machine := machineapi.Machine{
+		Spec: machineapi.MachineSpec{
+			Networks: []networkapi.NetworkSpec{{
+				Interfaces: []networkapi.NetworkInterfaceTemplateSpec{{
+					Spec: networkapi.NetworkInterfaceSpec{CIDR: cidr},
+				}},
+			}},
+		},
+		Status: machineapi.MachineStatus{State: state},
+	}
+	machine.Name = name
+
+	return machine
+}
+
+func TestWaitHealthy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	interval := types.Duration(10 * time.Millisecond)
+	service := types.ServiceConfig{
+		Name:          "web",
+		ContainerName: "project-web",
+		HealthCheck: &types.HealthCheckConfig{
+			Test:     types.HealthCheckTest{"CMD", "curl", "-f", "http://localhost:" + port + "/"},
+			Interval: &interval,
+			Timeout:  &interval,
+		},
+	}
+
+	controller := &fakeMachineService{
+		machines: []machineapi.Machine{
+			testMachine("project-web", "127.0.0.1/8", machineapi.MachineStateRunning),
+		},
+	}
+
+	project := &Project{}
+
+	if err := project.WaitHealthy(context.Background(), controller, service); err != nil {
+		t.Errorf("expected service to be healthy, got: %v", err)
+	}
+}
+
+func TestWaitHealthyTimeout(t *testing.T) {
+	interval := types.Duration(10 * time.Millisecond)
+	retries := uint64(2)
+	service := types.ServiceConfig{
+		Name:          "db",
+		ContainerName: "project-db",
+		HealthCheck: &types.HealthCheckConfig{
+			Test:     types.HealthCheckTest{"CMD", "nc", "-z", "localhost", "5432"},
+			Interval: &interval,
+			Timeout:  &interval,
+			Retries:  &retries,
+		},
+	}
+
+	if timeout := HealthyTimeout(service); timeout != 60*time.Millisecond {
+		t.Errorf("expected a timeout of 60ms, got %s", timeout)
+	}
+
+	// The machine never starts, such that the healthcheck is never performed and
+	// only the timeout ends the wait.
+	controller := &fakeMachineService{
+		machines: []machineapi.Machine{
+			testMachine("project-db", "", machineapi.MachineStateCreated),
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), HealthyTimeout(service))
+	defer cancel()
+
+	project := &Project{}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- project.WaitHealthy(ctx, controller, service)
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the wait to time out, got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait did not time out")
+	}
+}
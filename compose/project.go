@@ -9,17 +9,28 @@ package compose
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/compose-spec/compose-go/v2/cli"
 	"github.com/compose-spec/compose-go/v2/types"
+	"golang.org/x/sys/unix"
+	"k8s.io/apimachinery/pkg/api/resource"
 
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/config"
 	"kraftkit.sh/log"
+	"kraftkit.sh/machine/network"
 	"kraftkit.sh/machine/network/iputils"
 	mplatform "kraftkit.sh/machine/platform"
 	ukarch "kraftkit.sh/unikraft/arch"
@@ -27,6 +38,16 @@ import (
 
 type Project struct {
 	*types.Project `json:"project"` // The underlying compose-go project
+
+	// ReplicaAddresses holds the IPv4 address of each replica of services
+	// which request more than one replica, indexed by the replica's container
+	// name and then by the network name.
+	ReplicaAddresses map[string]map[string]string `json:"replicaAddresses,omitempty"`
+
+	// Addresses records the IPv4 address held by each machine, indexed by its
+	// container name and then by the network name, such that machines keep
+	// their addresses across invocations until they are released.
+	Addresses map[string]map[string]string `json:"addresses,omitempty"`
 }
 
 // DefaultFileNames is a list of default compose file names to look for
@@ -38,30 +59,130 @@ var DefaultFileNames = []string{
 	"Composefile",
 }
 
+const (
+	// EnvComposeFile is the environment variable which, like with Docker
+	// Compose, sets the compose files to use when none are specified.  Multiple
+	// files are separated by EnvComposePathSeparator and are merged in order.
+	EnvComposeFile = "COMPOSE_FILE"
+
+	// EnvComposePathSeparator is the environment variable which sets the
+	// separator of the files in EnvComposeFile, defaulting to the OS's path
+	// list separator, i.e. `:` on Unix.
+	EnvComposePathSeparator = "COMPOSE_PATH_SEPARATOR"
+)
+
+// FileNames returns the compose file names to look for, i.e. the names set via
+// the `compose.file_names` configuration followed by DefaultFileNames.
+func FileNames(ctx context.Context) []string {
+	configured := config.G[config.KraftKit](ctx).Compose.FileNames
+
+	names := make([]string, 0, len(configured)+len(DefaultFileNames))
+	names = append(names, configured...)
+	names = append(names, DefaultFileNames...)
+
+	return names
+}
+
+// composeFilesFromEnv returns the compose files set via EnvComposeFile, if
+// any.
+func composeFilesFromEnv() []string {
+	value := os.Getenv(EnvComposeFile)
+	if value == "" {
+		return nil
+	}
+
+	separator := os.Getenv(EnvComposePathSeparator)
+	if separator == "" {
+		separator = string(os.PathListSeparator)
+	}
+
+	var files []string
+	for _, file := range strings.Split(value, separator) {
+		if file = strings.TrimSpace(file); file != "" {
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+// validProjectName matches names which are valid both as a Compose project
+// name and as the prefix of the resulting machine names.
+var validProjectName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// WithProjectName overrides the name of the project, which is otherwise
+// derived from the working directory.  An empty name has no effect.
+func WithProjectName(name string) cli.ProjectOptionsFn {
+	return func(o *cli.ProjectOptions) error {
+		if name == "" {
+			return nil
+		}
+
+		if !validProjectName.MatchString(name) {
+			return fmt.Errorf("invalid project name '%s': must contain only lowercase letters, digits, dashes and underscores, and start with a letter or digit", name)
+		}
+
+		return cli.WithName(name)(o)
+	}
+}
+
 // NewProjectFromComposeFile loads a compose file and returns a project. If no
 // compose file is specified, it will look for one in the current directory.
-func NewProjectFromComposeFile(ctx context.Context, workdir, composefile string) (*Project, error) {
-	if composefile == "" {
-		for _, file := range DefaultFileNames {
+// Additional options, e.g. environment files, are passed to the loader.
+func NewProjectFromComposeFile(ctx context.Context, workdir, composefile string, opts ...cli.ProjectOptionsFn) (*Project, error) {
+	var composefiles []string
+	if composefile != "" {
+		composefiles = []string{composefile}
+	}
+
+	return NewProjectFromComposeFiles(ctx, workdir, composefiles, opts...)
+}
+
+// NewProjectFromComposeFiles loads and merges the compose files in order,
+// such that later files override earlier ones, and returns a project.  If no
+// compose files are specified, the files set via EnvComposeFile are used,
+// otherwise it will look for one of FileNames in the current directory.
+// Additional options, e.g. environment files, are passed to the loader.
+func NewProjectFromComposeFiles(ctx context.Context, workdir string, composefiles []string, opts ...cli.ProjectOptionsFn) (*Project, error) {
+	if len(composefiles) == 0 {
+		composefiles = composeFilesFromEnv()
+		for _, file := range composefiles {
+			log.G(ctx).
+				WithField("composefile", file).
+				WithField("env", EnvComposeFile).
+				Debugf("using")
+		}
+	}
+
+	if len(composefiles) == 0 {
+		for _, file := range FileNames(ctx) {
 			fullpath := filepath.Join(workdir, file)
 			if _, err := os.Stat(fullpath); err == nil {
 				log.G(ctx).
 					WithField("composefile", fullpath).
 					Debugf("using")
-				composefile = file
+				composefiles = []string{file}
 				break
 			}
 		}
 	}
 
-	if composefile == "" {
-		return nil, fmt.Errorf("no compose file found")
+	if len(composefiles) == 0 {
+		return nil, fmt.Errorf("no compose file found: looked for %s", strings.Join(FileNames(ctx), ", "))
 	}
 
-	fullpath := filepath.Join(workdir, composefile)
+	fullpaths := make([]string, len(composefiles))
+	for i, composefile := range composefiles {
+		if filepath.IsAbs(composefile) {
+			fullpaths[i] = filepath.Clean(composefile)
+		} else {
+			fullpaths[i] = filepath.Join(workdir, composefile)
+		}
+	}
 
 	options, err := cli.NewProjectOptions(
-		[]string{fullpath},
+		fullpaths,
+		opts...,
 	)
 	if err != nil {
 		return nil, err
@@ -74,10 +195,323 @@ func NewProjectFromComposeFile(ctx context.Context, workdir, composefile string)
 
 	project = project.WithoutUnnecessaryResources()
 
-	project.ComposeFiles = []string{composefile}
+	// Record the absolute paths such that the project can be reloaded regardless
+	// of the current working directory.
+	project.ComposeFiles = fullpaths
 	project.WorkingDir = workdir
 
-	return &Project{project}, err
+	return &Project{Project: project}, err
+}
+
+// ServiceReplicas returns the number of machines requested for the service
+// via its `deploy.replicas` attribute, defaulting to one.
+func ServiceReplicas(service types.ServiceConfig) int {
+	if service.Deploy != nil && service.Deploy.Replicas != nil {
+		return *service.Deploy.Replicas
+	}
+
+	return 1
+}
+
+// ServiceContainerNames returns the names of the machines of a service, one
+// for each of its replicas.  A service with a single replica keeps its
+// container name, otherwise each replica is suffixed with its index, starting
+// from 1, e.g. `container-1`, `container-2`.
+func ServiceContainerNames(service types.ServiceConfig) []string {
+	replicas := ServiceReplicas(service)
+	if replicas == 1 {
+		return []string{service.ContainerName}
+	}
+
+	names := make([]string, 0, replicas)
+	for i := 1; i <= replicas; i++ {
+		names = append(names, fmt.Sprintf("%s-%d", service.ContainerName, i))
+	}
+
+	return names
+}
+
+// ServicePorts returns the ports which the service publishes via the machine
+// with the provided container name.  Only the first replica of a service
+// publishes its ports, as the host ports of every other replica would
+// otherwise conflict.
+func ServicePorts(service types.ServiceConfig, container string) (machineapi.MachinePorts, error) {
+	if names := ServiceContainerNames(service); len(names) == 0 || names[0] != container {
+		return nil, nil
+	}
+
+	var ports machineapi.MachinePorts
+	for _, port := range service.Ports {
+		// Ports without a host port are not published.
+		if port.Published == "" {
+			continue
+		}
+
+		mapping := fmt.Sprintf("%s:%s:%d", port.HostIP, port.Published, port.Target)
+		if port.Protocol != "" {
+			mapping += "/" + port.Protocol
+		}
+
+		parsed, err := machineapi.ParsePort(mapping)
+		if err != nil {
+			return nil, fmt.Errorf("service %s has an invalid port: %w", service.Name, err)
+		}
+
+		ports = append(ports, parsed...)
+	}
+
+	return ports, nil
+}
+
+// ServiceMemory returns the amount of memory requested by the service as a
+// quantity in bytes using binary SI suffixes, e.g. `64Mi`, which is the format
+// expected by `kraft run --memory`.  The service's `mem_limit` takes precedence
+// over `deploy.resources.limits.memory`, followed by `mem_reservation` and
+// `deploy.resources.reservations.memory`.  An empty string is returned if no
+// memory was requested.
+func ServiceMemory(service types.ServiceConfig) string {
+	candidates := []types.UnitBytes{
+		service.MemLimit,
+	}
+
+	if service.Deploy != nil && service.Deploy.Resources.Limits != nil {
+		candidates = append(candidates, service.Deploy.Resources.Limits.MemoryBytes)
+	}
+
+	candidates = append(candidates, service.MemReservation)
+
+	if service.Deploy != nil && service.Deploy.Resources.Reservations != nil {
+		candidates = append(candidates, service.Deploy.Resources.Reservations.MemoryBytes)
+	}
+
+	for _, candidate := range candidates {
+		if candidate > 0 {
+			return resource.NewQuantity(int64(candidate), resource.BinarySI).String()
+		}
+	}
+
+	return ""
+}
+
+// ServiceCPUs returns the number of vCPUs requested by the service via
+// `deploy.resources.limits.cpus`, followed by `deploy.resources.reservations.cpus`
+// and `cpus`.  Since vCPUs can only be assigned whole, fractional values are
+// rounded up.  An empty string is returned if no CPUs were requested.
+func ServiceCPUs(service types.ServiceConfig) (string, error) {
+	cpus := ""
+
+	if service.Deploy != nil && service.Deploy.Resources.Limits != nil {
+		cpus = service.Deploy.Resources.Limits.NanoCPUs
+	}
+
+	if cpus == "" && service.Deploy != nil && service.Deploy.Resources.Reservations != nil {
+		cpus = service.Deploy.Resources.Reservations.NanoCPUs
+	}
+
+	if cpus == "" && service.CPUS > 0 {
+		cpus = strconv.FormatFloat(float64(service.CPUS), 'f', -1, 32)
+	}
+
+	if cpus == "" {
+		return "", nil
+	}
+
+	value, err := strconv.ParseFloat(cpus, 64)
+	if err != nil {
+		return "", fmt.Errorf("service %s has an invalid cpus limit '%s': %w", service.Name, cpus, err)
+	}
+
+	if value <= 0 {
+		return "", fmt.Errorf("service %s has an invalid cpus limit '%s': must be positive", service.Name, cpus)
+	}
+
+	return strconv.Itoa(int(math.Ceil(value))), nil
+}
+
+// NetworkMTU returns the MTU requested for the network via the
+// `com.docker.network.driver.mtu` driver option.  Zero is returned if no MTU
+// was requested.
+func NetworkMTU(network types.NetworkConfig) (int, error) {
+	mtu, ok := network.DriverOpts["com.docker.network.driver.mtu"]
+	if !ok {
+		return 0, nil
+	}
+
+	value, err := strconv.Atoi(mtu)
+	if err != nil {
+		return 0, fmt.Errorf("network %s has an invalid mtu '%s': %w", network.Name, mtu, err)
+	}
+
+	// The minimum IPv4 MTU and the maximum MTU supported by Linux bridges.
+	if value < 68 || value > 65535 {
+		return 0, fmt.Errorf("network %s has an invalid mtu '%s': must be between 68 and 65535", network.Name, mtu)
+	}
+
+	return value, nil
+}
+
+// ServiceArgs returns the arguments of the machines of the service, which
+// replace the default command of its image, following the semantics of Docker
+// Compose: the service's `entrypoint` is followed by its `command`.  Since
+// overriding the entrypoint also discards the default command of the image,
+// only the entrypoint is used if no command is set.  Nil is returned if
+// neither is set such that the default command of the image is used.
+func ServiceArgs(service types.ServiceConfig) []string {
+	if len(service.Entrypoint) == 0 && len(service.Command) == 0 {
+		return nil
+	}
+
+	args := make([]string, 0, len(service.Entrypoint)+len(service.Command))
+	args = append(args, service.Entrypoint...)
+	args = append(args, service.Command...)
+
+	return args
+}
+
+const (
+	// LabelProject is the label of machines which carries the name of the
+	// project of their service.
+	LabelProject = "com.docker.compose.project"
+
+	// LabelService is the label of machines which carries the name of their
+	// service.
+	LabelService = "com.docker.compose.service"
+
+	// LabelContainerNumber is the label of machines which carries their replica
+	// number within their service, starting at 1.
+	LabelContainerNumber = "com.docker.compose.container-number"
+)
+
+// ServiceLabels returns the labels of the provided machine of the service,
+// i.e. the user-defined `labels` of the service followed by the standard
+// labels which identify its project, service and replica number.  The
+// standard labels take precedence such that machines can always be grouped
+// reliably.
+func (project *Project) ServiceLabels(service types.ServiceConfig, container string) map[string]string {
+	labels := make(map[string]string, len(service.Labels)+3)
+	for k, v := range service.Labels {
+		labels[k] = v
+	}
+
+	labels[LabelProject] = project.Name
+	labels[LabelService] = service.Name
+
+	for i, name := range ServiceContainerNames(service) {
+		if name == container {
+			labels[LabelContainerNumber] = strconv.Itoa(i + 1)
+			break
+		}
+	}
+
+	return labels
+}
+
+// DefaultStopGracePeriod is the period which the machines of a service are
+// given to exit after having received their stop signal before they are
+// forcefully stopped, unless the service sets `stop_grace_period`.
+const DefaultStopGracePeriod = 10 * time.Second
+
+// ServiceStopGracePeriod returns the period which the machines of the service
+// are given to exit after having received their stop signal.
+func ServiceStopGracePeriod(service types.ServiceConfig) time.Duration {
+	if service.StopGracePeriod == nil {
+		return DefaultStopGracePeriod
+	}
+
+	return time.Duration(*service.StopGracePeriod)
+}
+
+// ServiceStopSignal returns the signal which is sent to the machines of the
+// service when they are stopped.  The service's `stop_signal` may either be a
+// name, with or without the `SIG` prefix, or a number.  SIGTERM is used if it
+// is not set.
+func ServiceStopSignal(service types.ServiceConfig) (unix.Signal, error) {
+	if service.StopSignal == "" {
+		return unix.SIGTERM, nil
+	}
+
+	if num, err := strconv.Atoi(service.StopSignal); err == nil && num > 0 {
+		return unix.Signal(num), nil
+	}
+
+	name := strings.ToUpper(service.StopSignal)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	signal := unix.SignalNum(name)
+	if signal == 0 {
+		return 0, fmt.Errorf("service %s has an unknown stop signal '%s'", service.Name, service.StopSignal)
+	}
+
+	return signal, nil
+}
+
+// ServicePlatform returns the platform and architecture of the service, whose
+// platform is in the form `<platform>/<arch>`, e.g. `kvm/arm64`.  Aliases of
+// platforms are resolved, e.g. `kvm` to `qemu`.  An error is returned if either
+// is not known or if the combination is not supported by any machine driver of
+// the host.
+func ServicePlatform(service types.ServiceConfig) (string, string, error) {
+	plat, arch, ok := strings.Cut(service.Platform, "/")
+	if !ok || plat == "" || arch == "" || strings.Contains(arch, "/") {
+		return "", "", fmt.Errorf("service %s has an invalid platform '%s': must be in the form <platform>/<arch>", service.Name, service.Platform)
+	}
+
+	platform, ok := mplatform.PlatformsByName()[plat]
+	if !ok {
+		return "", "", fmt.Errorf("service %s has an unknown platform '%s': must be one of %s", service.Name, plat, strings.Join(sortedKeys(mplatform.PlatformsByName()), ", "))
+	}
+
+	if _, ok := mplatform.Strategies()[platform]; !ok {
+		return "", "", fmt.Errorf("service %s requests platform '%s' which is not supported by any machine driver of the host", service.Name, plat)
+	}
+
+	if ukarch.ArchitectureByName(arch) == ukarch.ArchitectureUnknown {
+		return "", "", fmt.Errorf("service %s has an unknown architecture '%s': must be one of %s", service.Name, arch, strings.Join(sortedKeys(ukarch.ArchitecturesByName()), ", "))
+	}
+
+	// Unlike QEMU, which can emulate other architectures, Firecracker relies on
+	// hardware virtualization and can only run machines of the host's
+	// architecture.
+	if platform == mplatform.PlatformFirecracker {
+		hostArch, err := ukarch.HostArchitecture()
+		if err != nil {
+			return "", "", err
+		}
+
+		if arch != hostArch {
+			return "", "", fmt.Errorf("service %s requests platform '%s' which cannot run architecture '%s' on a %s host", service.Name, plat, arch, hostArch)
+		}
+	}
+
+	return platform.String(), arch, nil
+}
+
+// sortedKeys returns the keys of the provided map in ascending order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// ServiceAddress returns the IPv4 address of the provided container of a
+// service on the given network.
+func (project *Project) ServiceAddress(service types.ServiceConfig, container, network string) string {
+	if addresses, ok := project.ReplicaAddresses[container]; ok {
+		return addresses[network]
+	}
+
+	if config, ok := service.Networks[network]; ok && config != nil {
+		return config.Ipv4Address
+	}
+
+	return ""
 }
 
 // Validate performs some early checks on the project to ensure it is valid,
@@ -89,6 +523,46 @@ func (project *Project) Validate(ctx context.Context) error {
 		if service.Image == "" && service.Build == nil {
 			return fmt.Errorf("service %s has neither an image nor a build context", service.Name)
 		}
+
+		// Check that the resource limits can be satisfied
+		if memory := ServiceMemory(service); memory != "" {
+			qty, err := resource.ParseQuantity(memory)
+			if err != nil {
+				return fmt.Errorf("service %s has an invalid memory limit '%s': %w", service.Name, memory, err)
+			}
+
+			if qty.Value() < 1024*1024 {
+				return fmt.Errorf("service %s must request at least 1Mi of memory", service.Name)
+			}
+		}
+
+		if ServiceReplicas(service) < 0 {
+			return fmt.Errorf("service %s must have a non-negative number of replicas", service.Name)
+		}
+
+		if _, err := ServiceCPUs(service); err != nil {
+			return err
+		}
+
+		if _, err := ServiceStopSignal(service); err != nil {
+			return err
+		}
+
+		if ServiceStopGracePeriod(service) < 0 {
+			return fmt.Errorf("service %s must have a non-negative stop grace period", service.Name)
+		}
+
+		if HasHealthcheck(service) {
+			if _, err := parseHealthcheck(service.HealthCheck.Test); err != nil {
+				return fmt.Errorf("service %s: %w", service.Name, err)
+			}
+		}
+	}
+
+	for _, network := range project.Networks {
+		if _, err := NetworkMTU(network); err != nil {
+			return err
+		}
 	}
 
 	// If the project has no name, use the directory name
@@ -117,6 +591,10 @@ func (project *Project) Validate(ctx context.Context) error {
 			service.Platform = fmt.Sprint(hostPlatform, "/", hostArch)
 		}
 
+		if _, _, err := ServicePlatform(service); err != nil {
+			return service, err
+		}
+
 		return service, nil
 	},
 	)
@@ -127,73 +605,228 @@ func (project *Project) Validate(ctx context.Context) error {
 	return nil
 }
 
+const (
+	// DefaultSubnetPool is the pool from which subnets are allocated for
+	// networks without IPAM configuration unless configured otherwise.
+	DefaultSubnetPool = "172.16.0.0/12"
+
+	// DefaultSubnetPrefix is the prefix length of the subnets which are
+	// allocated from the subnet pool unless configured otherwise.
+	DefaultSubnetPrefix = 24
+)
+
+// subnetPool returns the pool from which subnets are allocated, as set via
+// the `compose.subnet_pool` and `compose.subnet_prefix` configuration.
+func subnetPool(ctx context.Context) (network.NetworkPool, error) {
+	pool := config.G[config.KraftKit](ctx).Compose.SubnetPool
+	if pool == "" {
+		pool = DefaultSubnetPool
+	}
+
+	prefix := config.G[config.KraftKit](ctx).Compose.SubnetPrefix
+	if prefix == 0 {
+		prefix = DefaultSubnetPrefix
+	}
+
+	_, subnet, err := net.ParseCIDR(pool)
+	if err != nil || subnet.IP.To4() == nil {
+		return nil, fmt.Errorf("invalid subnet pool '%s': must be an IPv4 subnet in CIDR notation", pool)
+	}
+
+	if ones, _ := subnet.Mask.Size(); prefix < ones || prefix > 30 {
+		return nil, fmt.Errorf("invalid subnet prefix %d: must be between %d and 30", prefix, ones)
+	}
+
+	return network.NetworkPool{{Subnet: subnet.String(), Size: prefix}}, nil
+}
+
+// AllocateSubnets assigns a subnet to each managed network without IPAM
+// configuration such that addresses can be assigned to its services.
+// Networks which already exist keep their subnet, otherwise a subnet is
+// allocated from the configured pool which collides neither with the subnets
+// of the project nor with those of the existing networks.
+func (project *Project) AllocateSubnets(ctx context.Context, existing *networkapi.NetworkList) error {
+	existingByName := make(map[string]networkapi.Network, len(existing.Items))
+	for _, n := range existing.Items {
+		existingByName[n.Name] = n
+	}
+
+	used := network.NetworkSubnets(existing)
+
+	var names []string
+	for name, n := range project.Networks {
+		for _, ipamConfig := range n.Ipam.Config {
+			if _, subnet, err := net.ParseCIDR(ipamConfig.Subnet); err == nil {
+				used = append(used, *subnet)
+			}
+		}
+
+		if n.External || len(n.Ipam.Config) > 0 {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	// Allocate in a stable order such that the same project is always assigned
+	// the same subnets.
+	sort.Strings(names)
+
+	pool, err := subnetPool(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		n := project.Networks[name]
+
+		var ipamConfig *types.IPAMPool
+
+		if e, ok := existingByName[n.Name]; ok {
+			mask := net.IPMask(net.ParseIP(e.Spec.Netmask).To4())
+			gateway := net.ParseIP(e.Spec.Gateway).To4()
+			if mask == nil || gateway == nil {
+				continue
+			}
+
+			ipamConfig = &types.IPAMPool{
+				Subnet:  (&net.IPNet{IP: gateway.Mask(mask), Mask: mask}).String(),
+				Gateway: gateway.String(),
+			}
+		} else {
+			subnet, err := network.FindFreeSubnet(pool, used)
+			if err != nil {
+				return fmt.Errorf("could not allocate subnet for network %s: %w", n.Name, err)
+			}
+
+			used = append(used, *subnet)
+
+			// The first address of the subnet is that of the network's bridge.
+			// Machines on internal networks are not given it as their gateway.
+			ipamConfig = &types.IPAMPool{
+				Subnet:  subnet.String(),
+				Gateway: firstAddress(subnet).String(),
+			}
+		}
+
+		log.G(ctx).
+			WithField("network", n.Name).
+			WithField("subnet", ipamConfig.Subnet).
+			WithField("gateway", ipamConfig.Gateway).
+			Debug("allocated")
+
+		n.Ipam.Config = []*types.IPAMPool{ipamConfig}
+		project.Networks[name] = n
+	}
+
+	return nil
+}
+
+// firstAddress returns the first address of the subnet which can be assigned
+// to a host, i.e. the one following its network address.
+func firstAddress(subnet *net.IPNet) net.IP {
+	ip := subnet.IP.Mask(subnet.Mask)
+	ip[len(ip)-1]++
+
+	return ip
+}
+
 func (project *Project) AssignIPs(ctx context.Context) error {
-	var err error
 	usedAddresses := make(map[string]map[string]struct{})
+
+	// The address pools of each network, in the order in which they are used.
+	pools := make(map[string][]*net.IPNet)
+
 	for i, network := range project.Networks {
 		if network.External || len(network.Ipam.Config) == 0 {
 			continue
 		}
 
-		// Join all the IPAM configs together
-		ipamConfig := network.Ipam.Config[0]
-		for _, config := range network.Ipam.Config[1:] {
-			if config.Subnet != "" {
-				ipamConfig.Subnet = config.Subnet
-			}
-			if config.Gateway != "" {
-				ipamConfig.Gateway = config.Gateway
+		usedAddresses[i] = make(map[string]struct{})
+
+		for j, ipamConfig := range network.Ipam.Config {
+			if ipamConfig.Subnet == "" {
+				return fmt.Errorf("network %s has no subnet specified", network.Name)
 			}
-		}
 
-		if ipamConfig.Subnet == "" {
-			return fmt.Errorf("network %s has no subnet specified", network.Name)
-		}
+			// Check that the subnet is of type addr/subnet
+			if len(strings.Split(ipamConfig.Subnet, "/")) != 2 {
+				return fmt.Errorf("network %s has an invalid subnet specified", network.Name)
+			}
 
-		// Check that the subnet is of type addr/subnet
-		if len(strings.Split(ipamConfig.Subnet, "/")) != 2 {
-			return fmt.Errorf("network %s has an invalid subnet specified", network.Name)
-		}
+			subnetIP, subnetMask, err := net.ParseCIDR(ipamConfig.Subnet)
+			if err != nil {
+				return fmt.Errorf("failed to parse %s network subnet", network.Name)
+			}
 
-		subnetIP, subnetMask, err := net.ParseCIDR(ipamConfig.Subnet)
-		if err != nil {
-			return fmt.Errorf("failed to parse %s network subnet", network.Name)
-		}
+			if subnetMask == nil {
+				return fmt.Errorf("failed to parse network %s subnet mask", network.Name)
+			}
 
-		if subnetMask == nil {
-			return fmt.Errorf("failed to parse network %s subnet mask", network.Name)
-		}
+			// Check that the gateway is of type addr.  Internal networks have no
+			// gateway to the outside, but their bridge still requires an address
+			// which must not be the network address of the subnet.
+			if ipamConfig.Gateway == "" && network.Internal {
+				ipamConfig.Gateway = firstAddress(subnetMask).String()
+			} else if ipamConfig.Gateway == "" {
+				ipamConfig.Gateway = subnetIP.String()
+			} else {
+				// Additionally check the gateway is part of the subnet
+				gatewayIP := net.ParseIP(ipamConfig.Gateway)
+				if gatewayIP == nil {
+					return fmt.Errorf("failed to parse %s network gateway", network.Name)
+				}
 
-		// Check that the gateway is of type addr
-		if ipamConfig.Gateway == "" {
-			ipamConfig.Gateway = subnetIP.String()
-		} else {
-			// Additionally check the gateway is part of the subnet
-			gatewayIP := net.ParseIP(ipamConfig.Gateway)
-			if gatewayIP == nil {
-				return fmt.Errorf("failed to parse %s network gateway", network.Name)
+				if !subnetMask.Contains(gatewayIP) {
+					return fmt.Errorf("network %s gateway %s is not within the subnet %s", network.Name, ipamConfig.Gateway, ipamConfig.Subnet)
+				}
 			}
 
-			if !subnetMask.Contains(gatewayIP) {
-				return fmt.Errorf("network %s gateway is not within the subnet", network.Name)
+			if ipamConfig.Gateway != "" {
+				usedAddresses[i][ipamConfig.Gateway] = struct{}{}
 			}
-		}
+			usedAddresses[i][subnetMask.IP.String()] = struct{}{}
 
-		usedAddresses[i] = make(map[string]struct{})
-		usedAddresses[i][ipamConfig.Gateway] = struct{}{}
-		usedAddresses[i][subnetMask.IP.String()] = struct{}{}
+			pools[i] = append(pools[i], subnetMask)
+			network.Ipam.Config[j] = ipamConfig
+		}
 
-		network.Ipam.Config[0] = ipamConfig
 		project.Networks[i] = network
 	}
 
+	// Services are assigned addresses sequentially and in order of their name
+	// such that the same project is always assigned the same addresses and
+	// errors are reported deterministically.
+	serviceNames := make([]string, 0, len(project.Services))
+	for serviceName := range project.Services {
+		serviceNames = append(serviceNames, serviceName)
+	}
+
+	sort.Strings(serviceNames)
+
+	// The service which requested each static IP address, per network.
+	staticAddresses := make(map[string]map[string]string)
+
 	// Mark used IPs for services with static IPs
-	for _, service := range project.Services {
+	for _, serviceName := range serviceNames {
+		service := project.Services[serviceName]
 		if service.Networks == nil {
 			continue
 		}
 
-		for name, network := range service.Networks {
+		networkNames := make([]string, 0, len(service.Networks))
+		for name := range service.Networks {
+			networkNames = append(networkNames, name)
+		}
+
+		sort.Strings(networkNames)
+
+		for _, name := range networkNames {
+			network := service.Networks[name]
 			if _, ok := project.Networks[name]; !ok {
 				return fmt.Errorf("service %s references non-existent network %s", service.Name, name)
 			}
@@ -203,65 +836,219 @@ func (project *Project) AssignIPs(ctx context.Context) error {
 					return fmt.Errorf("cannot assign IP address to service %s on network %s without IPAM config", service.Name, name)
 				}
 
+				ip := net.ParseIP(network.Ipv4Address)
+				if ip == nil {
+					return fmt.Errorf("service %s has an invalid IP address %s on network %s", service.Name, network.Ipv4Address, name)
+				}
+
+				inPool := false
+				for _, pool := range pools[name] {
+					if pool.Contains(ip) {
+						inPool = true
+						break
+					}
+				}
+
+				if !inPool {
+					return fmt.Errorf("service %s requests IP address %s which is not within any address pool of network %s", service.Name, network.Ipv4Address, name)
+				}
+
+				if staticAddresses[name] == nil {
+					staticAddresses[name] = make(map[string]string)
+				}
+
+				if other, ok := staticAddresses[name][ip.String()]; ok {
+					return fmt.Errorf("services %s and %s both request IP address %s on network %s", other, service.Name, network.Ipv4Address, name)
+				}
+
+				staticAddresses[name][ip.String()] = service.Name
 				usedAddresses[name][network.Ipv4Address] = struct{}{}
 			}
 		}
 	}
 
-	// WithServicesTransform runs in parallel and hence we need to protect the
-	// usedAddresses map
-	var mu sync.Mutex
-	project.Project, err = project.WithServicesTransform(func(name string, service types.ServiceConfig) (types.ServiceConfig, error) {
-		if service.Networks == nil {
-			return service, nil
-		}
-		for name, network := range service.Networks {
-			if network == nil {
-				service.Networks[name] = &types.ServiceNetworkConfig{}
-				network = service.Networks[name]
+	// Addresses which were previously assigned to machines, as recorded in the
+	// project's state, are kept by the same machines such that re-creating the
+	// project does not cause their addresses to drift.  Addresses of machines
+	// which no longer belong to the project remain reserved until they are
+	// released via ReleaseAddresses.
+	reserved := make(map[string]map[string]string)
+
+	containers := make([]string, 0, len(project.Addresses))
+	for container := range project.Addresses {
+		containers = append(containers, container)
+	}
+
+	sort.Strings(containers)
+
+	for _, container := range containers {
+		for name, addr := range project.Addresses[container] {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				continue
 			}
 
-			if network.Ipv4Address != "" || len(project.Networks[name].Ipam.Config) == 0 {
+			// Addresses which have since been requested statically, or which are no
+			// longer within the network's address pools, are not kept.
+			if _, used := usedAddresses[name][ip.String()]; used {
 				continue
 			}
 
-			// Start at the network's subnet IP and increment until we find
-			// a free one
-			_, subnet, err := net.ParseCIDR(project.Networks[name].Ipam.Config[0].Subnet)
-			if err != nil {
-				return service, err
+			inPool := false
+			for _, pool := range pools[name] {
+				if pool.Contains(ip) {
+					inPool = true
+					break
+				}
 			}
 
-			if subnet == nil {
-				// This should not be possible
-				return service, fmt.Errorf("failed to parse network %s subnet", name)
+			if !inPool {
+				continue
 			}
 
+			usedAddresses[name][ip.String()] = struct{}{}
+
+			if reserved[container] == nil {
+				reserved[container] = make(map[string]string)
+			}
+
+			reserved[container][name] = ip.String()
+		}
+	}
+
+	// nextFreeIP walks the network's address pools in order, starting at each
+	// pool's subnet IP and incrementing until it finds a free one, which is then
+	// marked as used.
+	nextFreeIP := func(name string) (string, error) {
+		for _, subnet := range pools[name] {
 			ip := subnet.IP
 
-			mu.Lock()
 			for _, exists := usedAddresses[name][ip.String()]; subnet.Contains(ip) && exists; _, exists = usedAddresses[name][ip.String()] {
 				ip = iputils.IncreaseIP(ip)
 			}
 
 			if !subnet.Contains(ip) {
-				mu.Unlock()
-				return service, fmt.Errorf("not enough free IP addresses in network %s", name)
+				// This pool is exhausted, advance to the next one.
+				continue
 			}
 
-			service.Networks[name].Ipv4Address = ip.String()
 			usedAddresses[name][ip.String()] = struct{}{}
 
-			// We have to unlock after we marked the ip as used in the map
-			mu.Unlock()
+			return ip.String(), nil
 		}
 
-		return service, nil
-	})
-	if err != nil {
-		return err
+		return "", fmt.Errorf("not enough free IP addresses in network %s", name)
+	}
+
+	// nextAddress returns the address which is reserved for the container on
+	// the network, if any, otherwise the next free one.
+	nextAddress := func(container, name string) (string, error) {
+		if addr, ok := reserved[container][name]; ok {
+			delete(reserved[container], name)
+			return addr, nil
+		}
+
+		return nextFreeIP(name)
 	}
 
+	addresses := make(map[string]map[string]string)
+	record := func(container, name, addr string) {
+		if addresses[container] == nil {
+			addresses[container] = make(map[string]string)
+		}
+
+		addresses[container][name] = addr
+	}
+
+	project.ReplicaAddresses = make(map[string]map[string]string)
+
+	var errs []error
+
+	for _, serviceName := range serviceNames {
+		service := project.Services[serviceName]
+		if service.Networks == nil {
+			continue
+		}
+
+		serviceContainers := ServiceContainerNames(service)
+
+		networkNames := make([]string, 0, len(service.Networks))
+		for name := range service.Networks {
+			networkNames = append(networkNames, name)
+		}
+
+		sort.Strings(networkNames)
+
+	networks:
+		for _, name := range networkNames {
+			network := service.Networks[name]
+			if network == nil {
+				network = &types.ServiceNetworkConfig{}
+				service.Networks[name] = network
+			}
+
+			if len(project.Networks[name].Ipam.Config) == 0 {
+				continue
+			}
+
+			if network.Ipv4Address == "" {
+				ip, err := nextAddress(serviceContainers[0], name)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("service %s: %w", serviceName, err))
+					break networks
+				}
+
+				network.Ipv4Address = ip
+			}
+
+			record(serviceContainers[0], name, network.Ipv4Address)
+
+			// The first replica uses the service's address whilst every other
+			// replica is assigned a distinct one.
+			if len(serviceContainers) > 1 {
+				for i, container := range serviceContainers {
+					if _, ok := project.ReplicaAddresses[container]; !ok {
+						project.ReplicaAddresses[container] = make(map[string]string)
+					}
+
+					if i == 0 {
+						project.ReplicaAddresses[container][name] = network.Ipv4Address
+						continue
+					}
+
+					ip, err := nextAddress(container, name)
+					if err != nil {
+						errs = append(errs, fmt.Errorf("service %s: replica %s: %w", serviceName, container, err))
+						break networks
+					}
+
+					project.ReplicaAddresses[container][name] = ip
+					record(container, name, ip)
+				}
+			}
+		}
+
+		project.Services[serviceName] = service
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	// Addresses of machines which no longer belong to the project are kept in
+	// the record until they are released.
+	for container, reservations := range reserved {
+		if _, ok := addresses[container]; ok {
+			continue
+		}
+
+		for name, addr := range reservations {
+			record(container, name, addr)
+		}
+	}
+
+	project.Addresses = addresses
+
 	return nil
 }
 
@@ -0,0 +1,700 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package compose
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+)
+
+func testNetwork(name, gateway, netmask string) networkapi.Network {
+	network := networkapi.Network{
+		Spec: networkapi.NetworkSpec{
+			Gateway: gateway,
+			Netmask: netmask,
+		},
+	}
+	network.Name = name
+
+	return network
+}
+
+func TestAllocateSubnets(t *testing.T) {
+	tests := []struct {
+		name     string
+		networks types.Networks
+		existing []networkapi.Network
+		subnets  map[string]string
+		gateways map[string]string
+	}{
+		{
+			name: "first subnets of the pool",
+			networks: types.Networks{
+				"back":  {Name: "test_back"},
+				"front": {Name: "test_front"},
+			},
+			subnets: map[string]string{
+				"back":  "172.16.0.0/24",
+				"front": "172.16.1.0/24",
+			},
+			gateways: map[string]string{
+				"back":  "172.16.0.1",
+				"front": "172.16.1.1",
+			},
+		},
+		{
+			name: "avoids existing and project subnets",
+			networks: types.Networks{
+				"default": {Name: "test_default"},
+				"static": {
+					Name: "test_static",
+					Ipam: types.IPAMConfig{
+						Config: []*types.IPAMPool{{Subnet: "172.16.1.0/24"}},
+					},
+				},
+			},
+			existing: []networkapi.Network{
+				testNetwork("kraft0", "172.16.0.1", "255.255.255.0"),
+				// Bridges without an address must not prevent allocation.
+				testNetwork("br0", "", ""),
+			},
+			subnets: map[string]string{
+				"default": "172.16.2.0/24",
+				"static":  "172.16.1.0/24",
+			},
+			gateways: map[string]string{
+				"default": "172.16.2.1",
+				"static":  "",
+			},
+		},
+		{
+			name: "keeps the subnet of existing networks",
+			networks: types.Networks{
+				"default": {Name: "test_default"},
+			},
+			existing: []networkapi.Network{
+				testNetwork("test_default", "172.16.7.1", "255.255.255.0"),
+			},
+			subnets: map[string]string{
+				"default": "172.16.7.0/24",
+			},
+			gateways: map[string]string{
+				"default": "172.16.7.1",
+			},
+		},
+		{
+			name: "gives internal networks a bridge address",
+			networks: types.Networks{
+				"private": {Name: "test_private", Internal: true},
+			},
+			subnets: map[string]string{
+				"private": "172.16.0.0/24",
+			},
+			gateways: map[string]string{
+				"private": "172.16.0.1",
+			},
+		},
+		{
+			name: "keeps the bridge address of existing internal networks",
+			networks: types.Networks{
+				"private": {Name: "test_private", Internal: true},
+			},
+			existing: []networkapi.Network{
+				testNetwork("test_private", "172.16.7.1", "255.255.255.0"),
+			},
+			subnets: map[string]string{
+				"private": "172.16.7.0/24",
+			},
+			gateways: map[string]string{
+				"private": "172.16.7.1",
+			},
+		},
+		{
+			name: "skips external networks",
+			networks: types.Networks{
+				"outside": {Name: "outside", External: true},
+			},
+			subnets: map[string]string{
+				"outside": "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := &Project{
+				Project: &types.Project{
+					Name:     "test",
+					Networks: tt.networks,
+				},
+			}
+
+			existing := &networkapi.NetworkList{Items: tt.existing}
+
+			if err := project.AllocateSubnets(context.Background(), existing); err != nil {
+				t.Fatal("AllocateSubnets:", err)
+			}
+
+			for name, expect := range tt.subnets {
+				var subnet, gateway string
+				if config := project.Networks[name].Ipam.Config; len(config) > 0 {
+					subnet = config[0].Subnet
+					gateway = config[0].Gateway
+				}
+
+				if subnet != expect {
+					t.Errorf("network %s: expected subnet '%s', got '%s'", name, expect, subnet)
+				}
+
+				if expect := tt.gateways[name]; gateway != expect {
+					t.Errorf("network %s: expected gateway '%s', got '%s'", name, expect, gateway)
+				}
+			}
+		})
+	}
+}
+
+func TestAllocateSubnetsStable(t *testing.T) {
+	newProject := func() *Project {
+		return &Project{
+			Project: &types.Project{
+				Name: "test",
+				Networks: types.Networks{
+					"a": {Name: "test_a"},
+					"b": {Name: "test_b"},
+					"c": {Name: "test_c"},
+				},
+			},
+		}
+	}
+
+	existing := &networkapi.NetworkList{
+		Items: []networkapi.Network{
+			testNetwork("kraft0", "172.16.1.1", "255.255.255.0"),
+		},
+	}
+
+	first := newProject()
+	if err := first.AllocateSubnets(context.Background(), existing); err != nil {
+		t.Fatal("AllocateSubnets:", err)
+	}
+
+	for range 5 {
+		second := newProject()
+		if err := second.AllocateSubnets(context.Background(), existing); err != nil {
+			t.Fatal("AllocateSubnets:", err)
+		}
+
+		for name := range first.Networks {
+			if a, b := first.Networks[name].Ipam.Config[0].Subnet, second.Networks[name].Ipam.Config[0].Subnet; a != b {
+				t.Errorf("network %s: expected stable subnet %s, got %s", name, a, b)
+			}
+		}
+	}
+
+	if subnet := first.Networks["b"].Ipam.Config[0].Subnet; subnet != "172.16.2.0/24" {
+		t.Errorf("expected network b to be allocated 172.16.2.0/24, got %s", subnet)
+	}
+}
+
+// testProject returns a project with a single network of the provided subnet
+// and gateway which is joined by each of the services.
+func testProject(subnet, gateway string, services ...types.ServiceConfig) *Project {
+	project := &Project{
+		Project: &types.Project{
+			Name: "test",
+			Networks: types.Networks{
+				"default": {
+					Name: "test_default",
+					Ipam: types.IPAMConfig{
+						Config: []*types.IPAMPool{{Subnet: subnet, Gateway: gateway}},
+					},
+				},
+			},
+			Services: types.Services{},
+		},
+	}
+
+	for _, service := range services {
+		if service.ContainerName == "" {
+			service.ContainerName = "test-" + service.Name
+		}
+
+		if service.Networks == nil {
+			service.Networks = map[string]*types.ServiceNetworkConfig{"default": nil}
+		}
+
+		project.Services[service.Name] = service
+	}
+
+	return project
+}
+
+func TestAssignIPs(t *testing.T) {
+	replicas := 2
+
+	project := testProject("10.0.0.0/24", "10.0.0.1",
+		types.ServiceConfig{
+			Name: "static",
+			Networks: map[string]*types.ServiceNetworkConfig{
+				"default": {Ipv4Address: "10.0.0.2"},
+			},
+		},
+		types.ServiceConfig{Name: "db"},
+		types.ServiceConfig{
+			Name:   "web",
+			Deploy: &types.DeployConfig{Replicas: &replicas},
+		},
+	)
+
+	if err := project.AssignIPs(context.Background()); err != nil {
+		t.Fatal("AssignIPs:", err)
+	}
+
+	expect := map[string]string{
+		"test-static": "10.0.0.2",
+		"test-db":     "10.0.0.3",
+		"test-web-1":  "10.0.0.4",
+		"test-web-2":  "10.0.0.5",
+	}
+
+	for container, addr := range expect {
+		if got := project.Addresses[container]["default"]; got != addr {
+			t.Errorf("%s: expected address %s, got '%s'", container, addr, got)
+		}
+	}
+
+	if got := project.Services["db"].Networks["default"].Ipv4Address; got != "10.0.0.3" {
+		t.Errorf("expected service db to be assigned 10.0.0.3, got '%s'", got)
+	}
+
+	for _, container := range []string{"test-web-1", "test-web-2"} {
+		if got := project.ServiceAddress(project.Services["web"], container, "default"); got != expect[container] {
+			t.Errorf("%s: expected replica address %s, got '%s'", container, expect[container], got)
+		}
+	}
+}
+
+func TestAssignIPsInternal(t *testing.T) {
+	project := testProject("10.0.0.0/24", "", types.ServiceConfig{Name: "web"})
+
+	network := project.Networks["default"]
+	network.Internal = true
+	project.Networks["default"] = network
+
+	if err := project.AssignIPs(context.Background()); err != nil {
+		t.Fatal("AssignIPs:", err)
+	}
+
+	// The bridge of the network is given the first address of the subnet, which
+	// is then not assigned to any service.
+	if gateway := project.Networks["default"].Ipam.Config[0].Gateway; gateway != "10.0.0.1" {
+		t.Errorf("expected the bridge address 10.0.0.1, got '%s'", gateway)
+	}
+
+	if got := project.Addresses["test-web"]["default"]; got != "10.0.0.2" {
+		t.Errorf("expected address 10.0.0.2, got '%s'", got)
+	}
+}
+
+func TestAssignIPsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		project *Project
+		context string
+	}{
+		{
+			name: "address outside of subnet",
+			project: testProject("10.0.0.0/24", "10.0.0.1", types.ServiceConfig{
+				Name: "web",
+				Networks: map[string]*types.ServiceNetworkConfig{
+					"default": {Ipv4Address: "10.0.1.2"},
+				},
+			}),
+			context: "service web",
+		},
+		{
+			name:    "gateway outside of subnet",
+			project: testProject("10.0.0.0/24", "10.0.1.1", types.ServiceConfig{Name: "web"}),
+			context: "network test_default",
+		},
+		{
+			name: "duplicate static address",
+			project: testProject("10.0.0.0/24", "10.0.0.1",
+				types.ServiceConfig{
+					Name: "a",
+					Networks: map[string]*types.ServiceNetworkConfig{
+						"default": {Ipv4Address: "10.0.0.2"},
+					},
+				},
+				types.ServiceConfig{
+					Name: "b",
+					Networks: map[string]*types.ServiceNetworkConfig{
+						"default": {Ipv4Address: "10.0.0.2"},
+					},
+				},
+			),
+			context: "services a and b",
+		},
+		{
+			name: "unknown network",
+			project: testProject("10.0.0.0/24", "10.0.0.1", types.ServiceConfig{
+				Name: "web",
+				Networks: map[string]*types.ServiceNetworkConfig{
+					"other": nil,
+				},
+			}),
+			context: "service web",
+		},
+		{
+			name: "gateway outside of second pool",
+			project: func() *Project {
+				project := testProject("10.0.0.0/24", "10.0.0.1", types.ServiceConfig{Name: "web"})
+				network := project.Networks["default"]
+				network.Ipam.Config = append(network.Ipam.Config, &types.IPAMPool{Subnet: "10.0.1.0/24", Gateway: "10.0.0.254"})
+				project.Networks["default"] = network
+				return project
+			}(),
+			context: "network test_default",
+		},
+		{
+			name: "address outside of every pool",
+			project: func() *Project {
+				project := testProject("10.0.0.0/24", "10.0.0.1", types.ServiceConfig{
+					Name: "web",
+					Networks: map[string]*types.ServiceNetworkConfig{
+						"default": {Ipv4Address: "10.0.2.2"},
+					},
+				})
+				network := project.Networks["default"]
+				network.Ipam.Config = append(network.Ipam.Config, &types.IPAMPool{Subnet: "10.0.1.0/24"})
+				project.Networks["default"] = network
+				return project
+			}(),
+			context: "service web",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.project.AssignIPs(context.Background())
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			// Errors identify the service or network which caused them.
+			if !strings.Contains(err.Error(), tt.context) {
+				t.Errorf("expected error to mention '%s', got '%s'", tt.context, err)
+			}
+		})
+	}
+}
+
+func TestAssignIPsMultiplePools(t *testing.T) {
+	// The first pool has two free addresses besides its network address and
+	// gateway, after which allocation continues in the second pool.
+	project := testProject("10.0.0.0/30", "10.0.0.1",
+		types.ServiceConfig{Name: "a"},
+		types.ServiceConfig{Name: "b"},
+		types.ServiceConfig{Name: "c"},
+		types.ServiceConfig{
+			Name: "static",
+			Networks: map[string]*types.ServiceNetworkConfig{
+				"default": {Ipv4Address: "10.0.1.10"},
+			},
+		},
+	)
+
+	network := project.Networks["default"]
+	network.Ipam.Config = append(network.Ipam.Config, &types.IPAMPool{Subnet: "10.0.1.0/24", Gateway: "10.0.1.1"})
+	project.Networks["default"] = network
+
+	if err := project.AssignIPs(context.Background()); err != nil {
+		t.Fatal("AssignIPs:", err)
+	}
+
+	expect := map[string]string{
+		"test-a":      "10.0.0.2",
+		"test-b":      "10.0.0.3",
+		"test-c":      "10.0.1.2",
+		"test-static": "10.0.1.10",
+	}
+
+	for container, addr := range expect {
+		if got := project.Addresses[container]["default"]; got != addr {
+			t.Errorf("%s: expected address %s, got '%s'", container, addr, got)
+		}
+	}
+}
+
+func TestAssignIPsExhaustedIsDeterministic(t *testing.T) {
+	// The subnet has two free addresses besides its network address and
+	// gateway, such that only the last of the services in order of their name
+	// is not assigned one.
+	for range 10 {
+		project := testProject("10.0.0.0/30", "10.0.0.1",
+			types.ServiceConfig{Name: "c"},
+			types.ServiceConfig{Name: "a"},
+			types.ServiceConfig{Name: "b"},
+		)
+
+		err := project.AssignIPs(context.Background())
+		if err == nil {
+			t.Fatal("expected the network to be exhausted")
+		}
+
+		if expect := "service c: not enough free IP addresses in network default"; err.Error() != expect {
+			t.Fatalf("expected error '%s', got '%s'", expect, err)
+		}
+	}
+}
+
+func TestAssignIPsConflictIsDeterministic(t *testing.T) {
+	// Three services request the same static address, such that the conflict
+	// is always reported between the first two in order of their name.
+	for range 10 {
+		services := make([]types.ServiceConfig, 0, 3)
+		for _, name := range []string{"c", "a", "b"} {
+			services = append(services, types.ServiceConfig{
+				Name: name,
+				Networks: map[string]*types.ServiceNetworkConfig{
+					"default": {Ipv4Address: "10.0.0.2"},
+				},
+			})
+		}
+
+		err := testProject("10.0.0.0/24", "10.0.0.1", services...).AssignIPs(context.Background())
+		if err == nil {
+			t.Fatal("expected the static addresses to conflict")
+		}
+
+		if expect := "services a and b both request IP address 10.0.0.2 on network default"; err.Error() != expect {
+			t.Fatalf("expected error '%s', got '%s'", expect, err)
+		}
+	}
+}
+
+func TestValidateReplicas(t *testing.T) {
+	tests := []struct {
+		name     string
+		replicas int
+		wantErr  bool
+	}{
+		{
+			name:     "none",
+			replicas: 0,
+		},
+		{
+			name:     "several",
+			replicas: 3,
+		},
+		{
+			name:     "negative",
+			replicas: -1,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := testProject("10.0.0.0/24", "10.0.0.1",
+				types.ServiceConfig{
+					Name:     "web",
+					Image:    "nginx:latest",
+					Platform: "qemu/x86_64",
+					Deploy:   &types.DeployConfig{Replicas: &tt.replicas},
+				},
+			)
+
+			err := project.Validate(context.Background())
+			if tt.wantErr && err == nil {
+				t.Fatal("expected an error for a negative number of replicas")
+			} else if !tt.wantErr && err != nil {
+				t.Fatal("Validate:", err)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if names := ServiceContainerNames(project.Services["web"]); len(names) != tt.replicas {
+				t.Errorf("expected %d container names, got %v", tt.replicas, names)
+			}
+		})
+	}
+}
+
+func TestServicePorts(t *testing.T) {
+	replicas := 2
+
+	service := types.ServiceConfig{
+		Name:          "web",
+		ContainerName: "test-web",
+		Deploy:        &types.DeployConfig{Replicas: &replicas},
+		Ports: []types.ServicePortConfig{
+			{Published: "8080", Target: 80, Protocol: "tcp"},
+			{HostIP: "127.0.0.1", Published: "8443", Target: 443},
+			{Target: 9000, Protocol: "udp"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		container string
+		expected  string
+	}{
+		{
+			name:      "first replica",
+			container: "test-web-1",
+			expected:  "0.0.0.0:8080->80/tcp, 127.0.0.1:8443->443/tcp",
+		},
+		{
+			name:      "other replica",
+			container: "test-web-2",
+			expected:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ports, err := ServicePorts(service, tt.container)
+			if err != nil {
+				t.Fatal("ServicePorts:", err)
+			}
+
+			if got := ports.String(); got != tt.expected {
+				t.Errorf("expected ports '%s', got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNetworkMTU(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     map[string]string
+		expected int
+		wantErr  bool
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:     "valid",
+			opts:     map[string]string{"com.docker.network.driver.mtu": "1450"},
+			expected: 1450,
+		},
+		{
+			name:     "minimum",
+			opts:     map[string]string{"com.docker.network.driver.mtu": "68"},
+			expected: 68,
+		},
+		{
+			name:    "too small",
+			opts:    map[string]string{"com.docker.network.driver.mtu": "67"},
+			wantErr: true,
+		},
+		{
+			name:    "too large",
+			opts:    map[string]string{"com.docker.network.driver.mtu": "65536"},
+			wantErr: true,
+		},
+		{
+			name:    "non-numeric",
+			opts:    map[string]string{"com.docker.network.driver.mtu": "jumbo"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mtu, err := NetworkMTU(types.NetworkConfig{
+				Name:       "default",
+				DriverOpts: tt.opts,
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got mtu %d", mtu)
+				}
+				return
+			} else if err != nil {
+				t.Fatal("NetworkMTU:", err)
+			}
+
+			if mtu != tt.expected {
+				t.Errorf("expected mtu %d, got %d", tt.expected, mtu)
+			}
+		})
+	}
+}
+
+func TestServicePlatform(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform string
+		plat     string
+		arch     string
+		wantErr  bool
+	}{
+		{
+			name:     "valid",
+			platform: "qemu/x86_64",
+			plat:     "qemu",
+			arch:     "x86_64",
+		},
+		{
+			name:     "alias",
+			platform: "kvm/arm64",
+			plat:     "qemu",
+			arch:     "arm64",
+		},
+		{
+			name:     "unsupported architecture",
+			platform: "qemu/riscv64",
+			wantErr:  true,
+		},
+		{
+			name:     "unknown platform",
+			platform: "vbox/x86_64",
+			wantErr:  true,
+		},
+		{
+			name:     "missing architecture",
+			platform: "qemu",
+			wantErr:  true,
+		},
+		{
+			name:     "too many components",
+			platform: "qemu/arm/v7",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plat, arch, err := ServicePlatform(types.ServiceConfig{
+				Name:     "web",
+				Platform: tt.platform,
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got '%s/%s'", plat, arch)
+				}
+				return
+			} else if err != nil {
+				t.Fatal("ServicePlatform:", err)
+			}
+
+			if plat != tt.plat || arch != tt.arch {
+				t.Errorf("expected '%s/%s', got '%s/%s'", tt.plat, tt.arch, plat, arch)
+			}
+		})
+	}
+}
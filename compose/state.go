@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package compose
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/compose-spec/compose-go/v2/types"
+
+	"kraftkit.sh/log"
+)
+
+// StateFileName is the path, relative to the project's working directory, of
+// the file which holds the resolved state of the project.
+var StateFileName = filepath.Join(".kraftkit", "compose.json")
+
+// projectState is the on-disk representation of a resolved project.
+type projectState struct {
+	// Hash is the checksum of the compose files at the time the project was
+	// resolved and is used to detect drift.
+	Hash string `json:"hash"`
+
+	// Project is the fully resolved project, including assigned IP addresses
+	// and container names.
+	Project *types.Project `json:"project"`
+
+	// ReplicaAddresses holds the IPv4 addresses assigned to service replicas.
+	ReplicaAddresses map[string]map[string]string `json:"replicaAddresses,omitempty"`
+
+	// Addresses holds the IPv4 addresses held by each machine of the project
+	// which have not been released.
+	Addresses map[string]map[string]string `json:"addresses,omitempty"`
+}
+
+// statePath returns the absolute path to the project's state file.
+func (project *Project) statePath() string {
+	return filepath.Join(project.WorkingDir, StateFileName)
+}
+
+// hash computes a checksum across the contents of all the project's compose
+// files.
+func (project *Project) hash() (string, error) {
+	h := sha256.New()
+
+	for _, file := range project.ComposeFiles {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(project.WorkingDir, file)
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return "", fmt.Errorf("could not open compose file: %w", err)
+		}
+
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("could not read compose file: %w", err)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Save serializes the resolved project to a file in the project's working
+// directory such that subsequent invocations can re-use the exact same
+// configuration, even if the compose file has changed since.
+func (project *Project) Save(ctx context.Context) error {
+	hash, err := project.hash()
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(projectState{
+		Hash:             hash,
+		Project:          project.Project,
+		ReplicaAddresses: project.ReplicaAddresses,
+		Addresses:        project.Addresses,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal project state: %w", err)
+	}
+
+	path := project.statePath()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("could not create project state directory: %w", err)
+	}
+
+	log.G(ctx).
+		WithField("path", path).
+		Debug("saving project state")
+
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return fmt.Errorf("could not write project state: %w", err)
+	}
+
+	return nil
+}
+
+// Load replaces the project with the previously saved resolved project, if
+// one exists.  If the compose files have changed since the project was saved,
+// a warning is emitted and the saved configuration is still used.
+func (project *Project) Load(ctx context.Context) error {
+	path := project.statePath()
+
+	state, err := project.readState()
+	if err != nil || state == nil {
+		return err
+	}
+
+	if hash, err := project.hash(); err != nil {
+		log.G(ctx).
+			WithError(err).
+			Debug("could not compute compose file checksum")
+	} else if hash != state.Hash {
+		log.G(ctx).
+			WithField("path", path).
+			Warn("compose file has changed since the project was created, using saved configuration")
+	}
+
+	log.G(ctx).
+		WithField("path", path).
+		Debug("using saved project state")
+
+	project.Project = state.Project
+	project.ReplicaAddresses = state.ReplicaAddresses
+	project.Addresses = state.Addresses
+
+	return nil
+}
+
+// readState returns the previously saved state of the project or nil if none
+// exists.
+func (project *Project) readState() (*projectState, error) {
+	path := project.statePath()
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read project state: %w", err)
+	}
+
+	var state projectState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("could not unmarshal project state: %w", err)
+	}
+
+	if state.Project == nil {
+		return nil, fmt.Errorf("project state at %s is empty", path)
+	}
+
+	return &state, nil
+}
+
+// LoadAddresses restores the addresses held by the machines of the project
+// from its previously saved state, if any, without replacing the project
+// itself.  This allows AssignIPs to keep the addresses of existing machines
+// when the project is re-created from changed compose files.
+func (project *Project) LoadAddresses(ctx context.Context) error {
+	state, err := project.readState()
+	if err != nil || state == nil {
+		return err
+	}
+
+	log.G(ctx).
+		WithField("path", project.statePath()).
+		Debug("using saved addresses")
+
+	project.Addresses = state.Addresses
+
+	return nil
+}
+
+// ReleaseAddresses frees the addresses held by the provided machines such
+// that AssignIPs can assign them to other machines.  The project must be
+// saved for the release to persist.
+func (project *Project) ReleaseAddresses(ctx context.Context, containers ...string) {
+	for _, container := range containers {
+		if _, ok := project.Addresses[container]; !ok {
+			continue
+		}
+
+		log.G(ctx).
+			WithField("machine", container).
+			WithField("addresses", project.Addresses[container]).
+			Debug("releasing")
+
+		delete(project.Addresses, container)
+	}
+}
+
+// RemoveState deletes the saved state of the project, if any.
+func (project *Project) RemoveState(ctx context.Context) error {
+	path := project.statePath()
+
+	log.G(ctx).
+		WithField("path", path).
+		Debug("removing project state")
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not remove project state: %w", err)
+	}
+
+	return nil
+}
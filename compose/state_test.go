@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package compose
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+// testStateProject returns a project in the provided working directory whose
+// services all join the same network.
+func testStateProject(t *testing.T, workdir string, services ...string) *Project {
+	t.Helper()
+
+	composefile := filepath.Join(workdir, "compose.yaml")
+	if err := os.WriteFile(composefile, []byte("services: {}\n"), 0o644); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+
+	configs := make([]types.ServiceConfig, 0, len(services))
+	for _, service := range services {
+		configs = append(configs, types.ServiceConfig{Name: service})
+	}
+
+	project := testProject("10.0.0.0/24", "10.0.0.1", configs...)
+	project.WorkingDir = workdir
+	project.ComposeFiles = []string{composefile}
+
+	return project
+}
+
+// create mimics `kraft compose create`, which restores the addresses of the
+// saved state before assigning addresses and saving the project.
+func create(t *testing.T, project *Project) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	if err := project.LoadAddresses(ctx); err != nil {
+		t.Fatal("LoadAddresses:", err)
+	}
+
+	if err := project.AssignIPs(ctx); err != nil {
+		t.Fatal("AssignIPs:", err)
+	}
+
+	if err := project.Save(ctx); err != nil {
+		t.Fatal("Save:", err)
+	}
+}
+
+func expectAddresses(t *testing.T, project *Project, expect map[string]string) {
+	t.Helper()
+
+	for container, addr := range expect {
+		if got := project.Addresses[container]["default"]; got != addr {
+			t.Errorf("%s: expected address %s, got '%s'", container, addr, got)
+		}
+	}
+}
+
+func TestAddressesAreKeptAcrossCreates(t *testing.T) {
+	workdir := t.TempDir()
+
+	create(t, testStateProject(t, workdir, "b", "c"))
+
+	// A service which sorts first is added, which must not cause the addresses
+	// of the existing machines to drift.
+	project := testStateProject(t, workdir, "a", "b", "c")
+	create(t, project)
+
+	expectAddresses(t, project, map[string]string{
+		"test-a": "10.0.0.4",
+		"test-b": "10.0.0.2",
+		"test-c": "10.0.0.3",
+	})
+}
+
+func TestAddressesOfRemovedMachinesStayReserved(t *testing.T) {
+	workdir := t.TempDir()
+
+	create(t, testStateProject(t, workdir, "a", "b"))
+
+	// Service a is removed from the project but its machine has not been
+	// removed, such that its address must not be handed out.
+	project := testStateProject(t, workdir, "b", "c")
+	create(t, project)
+
+	expectAddresses(t, project, map[string]string{
+		"test-a": "10.0.0.2",
+		"test-b": "10.0.0.3",
+		"test-c": "10.0.0.4",
+	})
+}
+
+func TestReleasedAddressesAreReassigned(t *testing.T) {
+	ctx := context.Background()
+	workdir := t.TempDir()
+
+	project := testStateProject(t, workdir, "a", "b")
+	create(t, project)
+
+	// The machine of service a is removed, e.g. as an orphan or via down.
+	project.ReleaseAddresses(ctx, "test-a")
+	if err := project.Save(ctx); err != nil {
+		t.Fatal("Save:", err)
+	}
+
+	project = testStateProject(t, workdir, "b", "c")
+	create(t, project)
+
+	expectAddresses(t, project, map[string]string{
+		"test-b": "10.0.0.3",
+		"test-c": "10.0.0.2",
+	})
+
+	if _, ok := project.Addresses["test-a"]; ok {
+		t.Error("expected the address of test-a to have been released")
+	}
+}
+
+func TestAddressesAreDeterministicWithoutState(t *testing.T) {
+	ctx := context.Background()
+	workdir := t.TempDir()
+
+	first := testStateProject(t, workdir, "c", "a", "b")
+	create(t, first)
+
+	if err := first.RemoveState(ctx); err != nil {
+		t.Fatal("RemoveState:", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(workdir, StateFileName)); !os.IsNotExist(err) {
+		t.Fatalf("expected the state file to be removed: %v", err)
+	}
+
+	second := testStateProject(t, workdir, "b", "c", "a")
+	create(t, second)
+
+	expectAddresses(t, second, map[string]string{
+		"test-a": "10.0.0.2",
+		"test-b": "10.0.0.3",
+		"test-c": "10.0.0.4",
+	})
+
+	for container, addresses := range first.Addresses {
+		if got := second.Addresses[container]["default"]; got != addresses["default"] {
+			t.Errorf("%s: expected address %s, got '%s'", container, addresses["default"], got)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"slices"
 
 	zip "api.zip"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -84,7 +85,7 @@ func (v1 *v1Compose) refreshRunningServices(ctx context.Context, embeddedProject
 	for _, machine := range embeddedProject.Status.Machines {
 		isService := false
 		for _, service := range project.Services {
-			if service.ContainerName == machine.Name {
+			if slices.Contains(ServiceContainerNames(service), machine.Name) {
 				isService = true
 				break
 			}
@@ -112,7 +113,7 @@ func (v1 *v1Compose) refreshRunningServices(ctx context.Context, embeddedProject
 		}
 		isService := false
 		for _, service := range project.Services {
-			if service.ContainerName == m.Name {
+			if slices.Contains(ServiceContainerNames(service), m.Name) {
 				isService = true
 				break
 			}
@@ -233,7 +234,16 @@ func (v1 *v1Compose) refreshExistingVolumes(ctx context.Context, embeddedProject
 }
 
 func (v1 *v1Compose) refreshStatus(ctx context.Context, embeddedProject *composev1.Compose) error {
-	project, err := NewProjectFromComposeFile(ctx, embeddedProject.Spec.Workdir, embeddedProject.Spec.Composefile)
+	composefiles := embeddedProject.Spec.Composefiles
+	if len(composefiles) == 0 && embeddedProject.Spec.Composefile != "" {
+		composefiles = []string{embeddedProject.Spec.Composefile}
+	}
+
+	// The project is reloaded with its original name, which may have been set
+	// via `--project-name` rather than derived from its working directory.
+	project, err := NewProjectFromComposeFiles(ctx, embeddedProject.Spec.Workdir, composefiles,
+		WithProjectName(embeddedProject.Name),
+	)
 	if err != nil {
 		return ErrInvalidComposefile
 	}
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package compose
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	composev1 "kraftkit.sh/api/compose/v1"
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+	volumeapi "kraftkit.sh/api/volume/v1alpha1"
+	"kraftkit.sh/log"
+)
+
+// fakeNetworkService lists no networks.
+type fakeNetworkService struct {
+	networkapi.NetworkService
+}
+
+func (fake *fakeNetworkService) List(_ context.Context, list *networkapi.NetworkList) (*networkapi.NetworkList, error) {
+	return list, nil
+}
+
+// fakeVolumeService lists no volumes.
+type fakeVolumeService struct {
+	volumeapi.VolumeService
+}
+
+func (fake *fakeVolumeService) List(_ context.Context, list *volumeapi.VolumeList) (*volumeapi.VolumeList, error) {
+	return list, nil
+}
+
+func TestRefreshStatusUsesProjectName(t *testing.T) {
+	workdir := t.TempDir()
+
+	composefile := filepath.Join(workdir, "compose.yaml")
+	if err := os.WriteFile(composefile, []byte(`services:
+  web:
+    image: nginx:latest
+    platform: qemu/x86_64
+`), 0o644); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&out)
+	ctx := log.WithLogger(context.Background(), logger)
+
+	v1 := &v1Compose{
+		machineController: &fakeMachineService{
+			machines: []machineapi.Machine{
+				testMachine("custom-web", "", machineapi.MachineStateRunning),
+				testMachine("custom-old", "", machineapi.MachineStateRunning),
+			},
+		},
+		networkController: &fakeNetworkService{},
+		volumeController:  &fakeVolumeService{},
+	}
+
+	// The project was created with `--project-name custom` such that its name
+	// differs from that of its working directory.
+	embeddedProject := &composev1.Compose{
+		Spec: composev1.ComposeSpec{
+			Workdir:      workdir,
+			Composefile:  composefile,
+			Composefiles: []string{composefile},
+		},
+		Status: composev1.ComposeStatus{
+			Machines: []metav1.ObjectMeta{
+				{Name: "custom-web"},
+				{Name: "custom-old"},
+			},
+		},
+	}
+	embeddedProject.Name = "custom"
+
+	if err := v1.refreshStatus(ctx, embeddedProject); err != nil {
+		t.Fatal("refreshStatus:", err)
+	}
+
+	if len(embeddedProject.Status.Machines) != 2 {
+		t.Errorf("expected 2 machines, got %v", embeddedProject.Status.Machines)
+	}
+
+	// Only the machine which is no longer a service of the project is an
+	// orphan.
+	if !strings.Contains(out.String(), "custom-old") {
+		t.Errorf("expected custom-old to be reported as an orphan, got: %s", out.String())
+	}
+
+	if strings.Contains(out.String(), "custom-web") {
+		t.Errorf("expected custom-web to belong to the project, got: %s", out.String())
+	}
+}
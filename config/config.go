@@ -51,6 +51,17 @@ type KraftKit struct {
 		Manifests []string `yaml:"manifests" env:"KRAFTKIT_UNIKRAFT_MANIFESTS" long:"with-manifest" usage:"Paths to package or component manifests"`
 	} `yaml:"unikraft"`
 
+	Compose struct {
+		FileNames    []string `yaml:"file_names,omitempty" env:"KRAFTKIT_COMPOSE_FILE_NAMES" long:"compose-file-names" usage:"Compose file names to look for before the default names"`
+		SubnetPool   string   `yaml:"subnet_pool,omitempty" env:"KRAFTKIT_COMPOSE_SUBNET_POOL" long:"compose-subnet-pool" usage:"Pool from which subnets are allocated for compose networks without IPAM configuration" default:"172.16.0.0/12"`
+		SubnetPrefix int      `yaml:"subnet_prefix,omitempty" env:"KRAFTKIT_COMPOSE_SUBNET_PREFIX" long:"compose-subnet-prefix" usage:"Prefix length of the subnets allocated for compose networks" default:"24"`
+	} `yaml:"compose,omitempty"`
+
+	OCI struct {
+		SigningKey      string `yaml:"signing_key,omitempty" env:"KRAFTKIT_OCI_SIGNING_KEY" long:"oci-signing-key" usage:"Path to a PEM-encoded private key with which packaged OCI manifests are signed"`
+		VerificationKey string `yaml:"verification_key,omitempty" env:"KRAFTKIT_OCI_VERIFICATION_KEY" long:"oci-verification-key" usage:"Path to a PEM-encoded public key against which the signatures of pulled OCI manifests are verified"`
+	} `yaml:"oci,omitempty"`
+
 	Auth map[string]AuthConfig `yaml:"auth,omitempty" noattribute:"true"`
 
 	Aliases map[string]map[string]string `yaml:"aliases" noattribute:"true"`
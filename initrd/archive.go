@@ -0,0 +1,339 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	sfile "github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+	"github.com/anchore/stereoscope/pkg/filetree/filenode"
+	"github.com/anchore/stereoscope/pkg/image"
+	sdocker "github.com/anchore/stereoscope/pkg/image/docker"
+	soci "github.com/anchore/stereoscope/pkg/image/oci"
+	"github.com/cavaliergopher/cpio"
+	"github.com/opencontainers/go-digest"
+
+	"kraftkit.sh/log"
+)
+
+// imageProvider returns the stereoscope provider of the image at source.
+type imageProvider func(ctx context.Context, tmpDirGen *sfile.TempDirGenerator, source string, opts InitrdOptions) (image.Provider, error)
+
+// imageArchive is an initrd whose contents are the flattened filesystem of an
+// image which is read via stereoscope.
+type imageArchive struct {
+	opts     InitrdOptions
+	typ      Type
+	path     string
+	provider imageProvider
+	args     []string
+	env      []string
+	labels   map[string]string
+	workdir  string
+	digest   digest.Digest
+}
+
+// NewFromOCIArchive accepts an input path which represents an OCI image
+// tarball, e.g. as produced by `skopeo copy ... oci-archive:<path>`, whose
+// flattened filesystem becomes the CPIO archive.
+func NewFromOCIArchive(ctx context.Context, path string, opts ...InitrdOption) (Initrd, error) {
+	if !tarballContains(path, "index.json") {
+		return nil, fmt.Errorf("file is not an OCI image archive")
+	}
+
+	return newFromImageArchive(ctx, TypeOCIArchive, path, ociArchiveProvider, opts...)
+}
+
+// ociArchiveProvider returns a stereoscope provider of the OCI image tarball at
+// source.
+func ociArchiveProvider(_ context.Context, tmpDirGen *sfile.TempDirGenerator, source string, _ InitrdOptions) (image.Provider, error) {
+	return soci.NewArchiveProvider(tmpDirGen, source), nil
+}
+
+// NewFromDockerArchive accepts an input path which represents a Docker image
+// tarball, e.g. as produced by `docker save`, whose flattened filesystem
+// becomes the CPIO archive.
+func NewFromDockerArchive(ctx context.Context, path string, opts ...InitrdOption) (Initrd, error) {
+	if !tarballContains(path, "manifest.json") {
+		return nil, fmt.Errorf("file is not a Docker image archive")
+	}
+
+	return newFromImageArchive(ctx, TypeDockerArchive, path, func(_ context.Context, tmpDirGen *sfile.TempDirGenerator, path string, _ InitrdOptions) (image.Provider, error) {
+		return sdocker.NewArchiveProvider(tmpDirGen, path), nil
+	}, opts...)
+}
+
+// tarballContains returns whether the file at the provided path is a tarball
+// which contains a top-level entry with the provided name.
+func tarballContains(path, name string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+
+	defer f.Close()
+
+	reader := tar.NewReader(f)
+	for {
+		header, err := reader.Next()
+		if err != nil {
+			return false
+		}
+
+		if filepath.Clean(header.Name) == name {
+			return true
+		}
+	}
+}
+
+func newFromImageArchive(_ context.Context, typ Type, path string, provider imageProvider, opts ...InitrdOption) (Initrd, error) {
+	initrd := imageArchive{
+		opts:     InitrdOptions{},
+		typ:      typ,
+		path:     path,
+		provider: provider,
+	}
+
+	for _, opt := range opts {
+		if err := opt(&initrd.opts); err != nil {
+			return nil, err
+		}
+	}
+
+	return &initrd, nil
+}
+
+// Build implements Initrd.
+func (initrd *imageArchive) Build(ctx context.Context) (string, error) {
+	return buildToFile(ctx, &initrd.opts, &initrd.digest, initrd.BuildTo)
+}
+
+// BuildTo implements Initrd.
+func (initrd *imageArchive) BuildTo(ctx context.Context, w io.Writer) error {
+	tempgen := sfile.NewTempDirGenerator("kraftkit")
+	if tempgen == nil {
+		return fmt.Errorf("could not create temp dir generator")
+	}
+
+	defer func() {
+		if err := tempgen.Cleanup(); err != nil {
+			log.G(ctx).
+				WithError(err).
+				Debug("cleaning up temp dir generator")
+		}
+	}()
+
+	provider, err := initrd.provider(ctx, tempgen, initrd.path, initrd.opts)
+	if err != nil {
+		return err
+	}
+
+	img, err := provider.Provide(ctx)
+	if err != nil {
+		return fmt.Errorf("could not provide image: %w", err)
+	}
+
+	defer func() {
+		if err := img.Cleanup(); err != nil {
+			log.G(ctx).
+				WithError(err).
+				Debug("cleaning up image")
+		}
+	}()
+
+	if err := img.Read(); err != nil {
+		return fmt.Errorf("could not read image: %w", err)
+	}
+
+	initrd.args = append(slices.Clone(img.Metadata.Config.Config.Entrypoint),
+		img.Metadata.Config.Config.Cmd...,
+	)
+	initrd.env = img.Metadata.Config.Config.Env
+	initrd.labels = img.Metadata.Config.Config.Labels
+	initrd.workdir = img.Metadata.Config.Config.WorkingDir
+
+	cpioWriter, closeWriter, err := newCPIOWriter(w, initrd.opts)
+	if err != nil {
+		return err
+	}
+
+	if err := writeSquashedTree(ctx, cpioWriter, img); err != nil {
+		return err
+	}
+
+	return closeWriter()
+}
+
+// writeSquashedTree serializes the flattened filesystem of the image into the
+// CPIO archive.  The squashed tree is the result of applying each layer in
+// order, such that files removed via whiteouts in upper layers are not
+// present.  Symbolic links are archived as-is and hence never traversed.
+func writeSquashedTree(ctx context.Context, writer *cpio.Writer, img *image.Image) error {
+	conditions := &filetree.WalkConditions{
+		ShouldContinueBranch: func(_ sfile.Path, node filenode.FileNode) bool {
+			return !node.IsLink()
+		},
+		LinkOptions: []filetree.LinkResolutionOption{},
+	}
+
+	if err := img.SquashedTree().Walk(func(path sfile.Path, node filenode.FileNode) error {
+		if path == "/" || node.Reference == nil {
+			return nil
+		}
+
+		entry, err := img.FileCatalog.Get(*node.Reference)
+		if err != nil {
+			return fmt.Errorf("could not get metadata of %s: %w", path, err)
+		}
+
+		return writeImageEntry(ctx, writer, img, string(path), entry)
+	}, conditions); err != nil {
+		return fmt.Errorf("could not flatten image: %w", err)
+	}
+
+	return nil
+}
+
+// writeImageEntry serializes the file at the provided path of the flattened
+// image into the CPIO archive.
+func writeImageEntry(ctx context.Context, writer *cpio.Writer, img *image.Image, path string, entry filetree.IndexEntry) error {
+	header := &cpio.Header{
+		Name: path,
+		Uid:  entry.UserID,
+		Guid: entry.GroupID,
+	}
+
+	if entry.FileInfo != nil {
+		header.Mode = cpio.FileMode(entry.Mode().Perm())
+		header.ModTime = entry.ModTime()
+	}
+
+	switch entry.Type {
+	case sfile.TypeDirectory:
+		log.G(ctx).
+			WithField("dst", path).
+			Debug("mkdir")
+
+		header.Mode |= cpio.TypeDir
+
+		return writer.WriteHeader(header)
+
+	case sfile.TypeSymLink:
+		log.G(ctx).
+			WithField("src", path).
+			WithField("link", entry.LinkDestination).
+			Debug("symlinking")
+
+		header.Mode |= cpio.TypeSymlink
+		header.Linkname = entry.LinkDestination
+		header.Size = int64(len(entry.LinkDestination))
+
+		if err := writer.WriteHeader(header); err != nil {
+			return fmt.Errorf("could not write CPIO header: %w", err)
+		}
+
+		if _, err := writer.Write([]byte(entry.LinkDestination)); err != nil {
+			return fmt.Errorf("could not write CPIO data for %s: %w", path, err)
+		}
+
+		return nil
+
+	case sfile.TypeRegular, sfile.TypeHardLink:
+		log.G(ctx).
+			WithField("dst", path).
+			Debug("copying")
+
+		// Hard links are resolved against the flattened image and their contents
+		// are copied such that they are self-contained within the archive.
+		var reader io.ReadCloser
+		var err error
+		if entry.Type == sfile.TypeHardLink {
+			reader, err = img.OpenPathFromSquash(sfile.Path(filepath.Join("/", entry.LinkDestination)))
+		} else {
+			reader, err = img.FileCatalog.Open(entry.Reference)
+		}
+		if err != nil {
+			return fmt.Errorf("could not open %s: %w", path, err)
+		}
+
+		defer reader.Close()
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return fmt.Errorf("could not read file: %w", err)
+		}
+
+		header.Mode |= cpio.TypeReg
+		header.Size = int64(len(data))
+
+		if err := writer.WriteHeader(header); err != nil {
+			return fmt.Errorf("could not write CPIO header: %w", err)
+		}
+
+		if _, err := writer.Write(data); err != nil {
+			return fmt.Errorf("could not write CPIO data for %s: %w", path, err)
+		}
+
+		return nil
+
+	default:
+		log.G(ctx).
+			WithField("file", path).
+			WithField("type", entry.Type.String()).
+			Warn("unsupported file type")
+
+		return nil
+	}
+}
+
+// Env implements Initrd.
+func (initrd *imageArchive) Env() []string {
+	return initrd.env
+}
+
+// Args implements Initrd.
+func (initrd *imageArchive) Args() []string {
+	return initrd.args
+}
+
+// Labels implements Initrd.
+func (initrd *imageArchive) Labels() map[string]string {
+	return initrd.labels
+}
+
+// Name implements Initrd.
+func (initrd *imageArchive) Name() string {
+	return string(initrd.typ)
+}
+
+// Digest implements Initrd.
+func (initrd *imageArchive) Digest() (digest.Digest, error) {
+	if initrd.digest == "" {
+		return "", fmt.Errorf("initramfs must be built before its digest is known")
+	}
+
+	return initrd.digest, nil
+}
+
+// WorkingDir implements Initrd.
+func (initrd *imageArchive) WorkingDir() string {
+	return initrd.workdir
+}
+
+// List implements Initrd.
+func (initrd *imageArchive) List(ctx context.Context) ([]InitrdEntry, error) {
+	if initrd.opts.output == "" {
+		return nil, fmt.Errorf("initramfs must be built before it can be listed")
+	}
+
+	return listArchive(ctx, initrd.opts.output)
+}
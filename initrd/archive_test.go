@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/cavaliergopher/cpio"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+
+	"kraftkit.sh/initrd"
+)
+
+func TestNewFromDockerArchive(t *testing.T) {
+	ctx := context.Background()
+
+	var layerTar bytes.Buffer
+	tw := tar.NewWriter(&layerTar)
+	content := []byte("#!/bin/sh\n")
+	for _, hdr := range []*tar.Header{
+		{Name: "sbin/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "sbin/init", Typeflag: tar.TypeReg, Mode: 0o755, Size: int64(len(content))},
+		{Name: "init", Typeflag: tar.TypeSymlink, Linkname: "/sbin/init", Mode: 0o777},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal("WriteHeader:", err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write(content); err != nil {
+				t.Fatal("Write:", err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal("Close:", err)
+	}
+
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(layerTar.Bytes())), nil
+	})
+	if err != nil {
+		t.Fatal("LayerFromOpener:", err)
+	}
+
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		t.Fatal("AppendLayers:", err)
+	}
+
+	ref, err := name.ParseReference("kraftkit.sh/test:latest")
+	if err != nil {
+		t.Fatal("ParseReference:", err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "image.tar")
+	if err := tarball.WriteToFile(archive, ref, img); err != nil {
+		t.Fatal("WriteToFile:", err)
+	}
+
+	ird, err := initrd.New(ctx, archive,
+		initrd.WithOutput(filepath.Join(t.TempDir(), "initramfs.cpio")),
+	)
+	if err != nil {
+		t.Fatal("New:", err)
+	}
+
+	irdPath, err := ird.Build(ctx)
+	if err != nil {
+		t.Fatal("Build:", err)
+	}
+
+	r := cpio.NewReader(openFile(t, irdPath))
+
+	expectModes := map[string]cpio.FileMode{
+		"/sbin":      cpio.TypeDir,
+		"/sbin/init": cpio.TypeReg,
+		"/init":      cpio.TypeSymlink,
+	}
+
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Failed to read next cpio header:", err)
+		}
+
+		expectMode, ok := expectModes[hdr.Name]
+		if !ok {
+			t.Error("Encountered unexpected file in cpio archive:", hdr.Name)
+			continue
+		}
+
+		if gotMode := hdr.Mode & cpio.ModeType; gotMode != expectMode {
+			t.Errorf("file [%s]: got mode %s, expected %s", hdr.Name, gotMode, expectMode)
+		}
+
+		delete(expectModes, hdr.Name)
+	}
+
+	for name := range expectModes {
+		t.Error("Missing file in cpio archive:", name)
+	}
+
+	if err := initrd.ValidateArchive(ctx, irdPath, ird.Args()); err != nil {
+		t.Error("ValidateArchive:", err)
+	}
+}
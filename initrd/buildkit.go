@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/moby/buildkit/client"
+
+	"kraftkit.sh/log"
+)
+
+// buildkitProbeTimeout is the maximum amount of time spent connecting to each
+// candidate BuildKit address during discovery.
+const buildkitProbeTimeout = 3 * time.Second
+
+// buildkitCandidates returns the ordered list of BuildKit addresses to probe.
+// An explicitly configured address always takes precedence, followed by the
+// BUILDKIT_HOST environmental variable and then the well-known locations of a
+// native rootful and rootless BuildKit daemon.
+func buildkitCandidates(configured string) []string {
+	var candidates []string
+
+	for _, addr := range []string{
+		configured,
+		os.Getenv("BUILDKIT_HOST"),
+		"unix:///run/buildkit/buildkitd.sock",
+	} {
+		if addr != "" {
+			candidates = append(candidates, addr)
+		}
+	}
+
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		candidates = append(candidates,
+			"unix://"+filepath.Join(dir, "buildkit", "buildkitd.sock"),
+		)
+	}
+
+	return candidates
+}
+
+// discoverBuildKit returns a client connected to the first reachable BuildKit
+// daemon from the list of candidates along with the address that was used.
+func discoverBuildKit(ctx context.Context, configured string) (*client.Client, string, *client.Info, error) {
+	seen := map[string]struct{}{}
+
+	for _, addr := range buildkitCandidates(configured) {
+		if _, ok := seen[addr]; ok {
+			continue
+		}
+		seen[addr] = struct{}{}
+
+		// Avoid dialing sockets which do not exist.
+		if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+			if _, err := os.Stat(path); err != nil {
+				log.G(ctx).
+					WithField("addr", addr).
+					Trace("skipping buildkit address")
+				continue
+			}
+		}
+
+		c, err := client.New(ctx, addr)
+		if err != nil {
+			log.G(ctx).
+				WithField("addr", addr).
+				WithError(err).
+				Debug("could not create buildkit client")
+			continue
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, buildkitProbeTimeout)
+		info, err := c.Info(probeCtx)
+		cancel()
+		if err != nil {
+			log.G(ctx).
+				WithField("addr", addr).
+				WithError(err).
+				Debug("could not connect to buildkit")
+			_ = c.Close()
+			continue
+		}
+
+		log.G(ctx).
+			WithField("addr", addr).
+			Info("found buildkit")
+
+		return c, addr, info, nil
+	}
+
+	return nil, "", nil, fmt.Errorf("could not find a running buildkit daemon")
+}
@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBuildkitCandidates(t *testing.T) {
+	tests := []struct {
+		name          string
+		configured    string
+		buildkitHost  string
+		xdgRuntimeDir string
+		expected      []string
+	}{
+		{
+			name: "defaults",
+			expected: []string{
+				"unix:///run/buildkit/buildkitd.sock",
+			},
+		},
+		{
+			name:          "rootless",
+			xdgRuntimeDir: "/run/user/1000",
+			expected: []string{
+				"unix:///run/buildkit/buildkitd.sock",
+				"unix:///run/user/1000/buildkit/buildkitd.sock",
+			},
+		},
+		{
+			name:         "environment",
+			buildkitHost: "tcp://localhost:1234",
+			expected: []string{
+				"tcp://localhost:1234",
+				"unix:///run/buildkit/buildkitd.sock",
+			},
+		},
+		{
+			name:          "configured takes precedence",
+			configured:    "docker-container://buildkit",
+			buildkitHost:  "tcp://localhost:1234",
+			xdgRuntimeDir: "/run/user/1000",
+			expected: []string{
+				"docker-container://buildkit",
+				"tcp://localhost:1234",
+				"unix:///run/buildkit/buildkitd.sock",
+				"unix:///run/user/1000/buildkit/buildkitd.sock",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("BUILDKIT_HOST", tt.buildkitHost)
+			t.Setenv("XDG_RUNTIME_DIR", tt.xdgRuntimeDir)
+
+			if got := buildkitCandidates(tt.configured); !slices.Equal(got, tt.expected) {
+				t.Errorf("expected candidates %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -7,20 +7,108 @@ package initrd
 import (
 	"context"
 	"fmt"
+	"strings"
 )
 
-// New attempts to return the builder for a supplied path which
-// will allow the provided ...
+// Type is the kind of input from which an initramfs is built.
+type Type string
+
+const (
+	TypeURL           = Type("url")
+	TypeFile          = Type("file")
+	TypeDirectory     = Type("directory")
+	TypeDockerfile    = Type("dockerfile")
+	TypeOCIArchive    = Type("oci-archive")
+	TypeDockerArchive = Type("docker-archive")
+	TypeOCIImage      = Type("oci-image")
+	TypeImageRef      = Type("image-ref")
+)
+
+// builder constructs an Initrd from an input of a specific Type.
+type builder func(context.Context, string, ...InitrdOption) (Initrd, error)
+
+// builders contains the constructor of each Type, in the order in which they
+// are attempted by New when no Type is set via WithType.  Since image
+// references and OCI images overlap, the image reference builder is only used
+// when explicitly requested.
+var builders = []struct {
+	typ   Type
+	build builder
+}{
+	{TypeFile, NewFromFile},
+	{TypeDirectory, NewFromDirectory},
+	{TypeDockerfile, NewFromDockerfile},
+	{TypeOCIArchive, NewFromOCIArchive},
+	{TypeDockerArchive, NewFromDockerArchive},
+	{TypeOCIImage, NewFromOCIImage},
+	{TypeURL, NewFromURL},
+	{TypeImageRef, NewFromImageRef},
+}
+
+// Types returns all known types of input from which an initramfs can be
+// built.
+func Types() []Type {
+	types := make([]Type, len(builders))
+	for i, b := range builders {
+		types[i] = b.typ
+	}
+
+	return types
+}
+
+// builderOf returns the constructor of the provided Type.
+func builderOf(typ Type) (builder, bool) {
+	for _, b := range builders {
+		if b.typ == typ {
+			return b.build, true
+		}
+	}
+
+	return nil, false
+}
+
+// New returns the builder for the supplied path, which may be a URL, an
+// existing CPIO archive, a directory, a Dockerfile, an OCI or Docker archive
+// or an OCI image.  Each kind of input is attempted in turn and the first
+// which accepts the path is returned.  Ambiguous paths, e.g. a directory named
+// `Dockerfile`, can be resolved by setting the type explicitly via WithType.
 func New(ctx context.Context, path string, opts ...InitrdOption) (Initrd, error) {
-	if builder, err := NewFromFile(ctx, path, opts...); err == nil {
-		return builder, nil
-	} else if builder, err := NewFromDirectory(ctx, path, opts...); err == nil {
-		return builder, nil
-	} else if builder, err := NewFromDockerfile(ctx, path, opts...); err == nil {
-		return builder, nil
-	} else if builder, err := NewFromOCIImage(ctx, path, opts...); err == nil {
-		return builder, nil
+	var options InitrdOptions
+	for _, opt := range opts {
+		if err := opt(&options); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.typ != "" {
+		build, _ := builderOf(options.typ)
+		return build(ctx, path, opts...)
+	}
+
+	if isRemoteURL(path) {
+		return NewFromURL(ctx, path, opts...)
+	}
+
+	for _, b := range builders {
+		if b.typ == TypeURL || b.typ == TypeImageRef {
+			continue
+		}
+
+		if builder, err := b.build(ctx, path, opts...); err == nil {
+			return builder, nil
+		}
 	}
 
 	return nil, fmt.Errorf("could not determine how to build initrd from: %s", path)
 }
+
+// typesString returns a human-readable list of all known types.
+func typesString() string {
+	types := Types()
+	strs := make([]string, len(types))
+	for i, typ := range types {
+		strs[i] = string(typ)
+	}
+
+	return strings.Join(strs, ", ")
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kraftkit.sh/initrd"
+)
+
+func TestNewWithType(t *testing.T) {
+	ctx := context.Background()
+
+	// A directory which is literally named "Dockerfile".
+	dir := filepath.Join(t.TempDir(), "Dockerfile")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hello"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := initrd.New(ctx, dir, initrd.WithType(initrd.TypeDockerfile)); err == nil {
+		t.Error("expected a directory to be rejected as a Dockerfile")
+	}
+
+	ird, err := initrd.New(ctx, dir, initrd.WithType(initrd.TypeDirectory))
+	if err != nil {
+		t.Fatal("New:", err)
+	}
+
+	if got := ird.Name(); got != string(initrd.TypeDirectory) {
+		t.Errorf("expected name %q, got %q", initrd.TypeDirectory, got)
+	}
+
+	irdPath, err := ird.Build(ctx)
+	if err != nil {
+		t.Fatal("Build:", err)
+	}
+	t.Cleanup(func() {
+		_ = os.Remove(irdPath)
+	})
+
+	if _, err := initrd.New(ctx, dir, initrd.WithType("tarball")); err == nil {
+		t.Error("expected an unknown type to be rejected")
+	}
+}
@@ -7,6 +7,7 @@ package initrd
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path"
@@ -14,12 +15,14 @@ import (
 	"strings"
 
 	"github.com/cavaliergopher/cpio"
+	"github.com/opencontainers/go-digest"
 	"kraftkit.sh/log"
 )
 
 type directory struct {
-	opts InitrdOptions
-	path string
+	opts   InitrdOptions
+	path   string
+	digest digest.Digest
 }
 
 // NewFromDirectory returns an instantiated Initrd interface which is is able to
@@ -55,38 +58,16 @@ func NewFromDirectory(_ context.Context, dir string, opts ...InitrdOption) (Init
 
 // Build implements Initrd.
 func (initrd *directory) Build(ctx context.Context) (string, error) {
-	if initrd.opts.output == "" {
-		fi, err := os.CreateTemp("", "")
-		if err != nil {
-			return "", fmt.Errorf("could not make temporary file: %w", err)
-		}
-
-		initrd.opts.output = fi.Name()
-		err = fi.Close()
-		if err != nil {
-			return "", fmt.Errorf("could not close temporary file: %w", err)
-		}
-	}
-
-	if err := os.MkdirAll(filepath.Dir(initrd.opts.output), 0o755); err != nil {
-		return "", fmt.Errorf("could not create output directory: %w", err)
-	}
+	return buildToFile(ctx, &initrd.opts, &initrd.digest, initrd.BuildTo)
+}
 
-	f, err := os.OpenFile(initrd.opts.output, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+// BuildTo implements Initrd.
+func (initrd *directory) BuildTo(ctx context.Context, w io.Writer) error {
+	writer, closeWriter, err := newCPIOWriter(w, initrd.opts)
 	if err != nil {
-		return "", fmt.Errorf("could not open initramfs file: %w", err)
+		return err
 	}
 
-	defer f.Close()
-
-	writer := cpio.NewWriter(f)
-	defer writer.Close()
-	defer func() {
-		if err := f.Sync(); err != nil {
-			log.G(ctx).Errorf("syncing cpio archive failed: %s", err)
-		}
-	}()
-
 	// Recursively walk the output directory on successful build and serialize to
 	// the output
 	if err := filepath.WalkDir(initrd.path, func(path string, d fs.DirEntry, err error) error {
@@ -173,16 +154,10 @@ func (initrd *directory) Build(ctx context.Context) (string, error) {
 
 		return nil
 	}); err != nil {
-		return "", fmt.Errorf("could not walk output path: %w", err)
-	}
-
-	if initrd.opts.compress {
-		if err := compressFiles(initrd.opts.output, writer, f); err != nil {
-			return "", fmt.Errorf("could not compress files: %w", err)
-		}
+		return fmt.Errorf("could not walk output path: %w", err)
 	}
 
-	return initrd.opts.output, nil
+	return closeWriter()
 }
 
 // Env implements Initrd.
@@ -194,3 +169,36 @@ func (initrd *directory) Env() []string {
 func (initrd *directory) Args() []string {
 	return nil
 }
+
+// Labels implements Initrd.
+func (initrd *directory) Labels() map[string]string {
+	return nil
+}
+
+// Name implements Initrd.
+func (initrd *directory) Name() string {
+	return string(TypeDirectory)
+}
+
+// Digest implements Initrd.
+func (initrd *directory) Digest() (digest.Digest, error) {
+	if initrd.digest == "" {
+		return "", fmt.Errorf("initramfs must be built before its digest is known")
+	}
+
+	return initrd.digest, nil
+}
+
+// WorkingDir implements Initrd.
+func (initrd *directory) WorkingDir() string {
+	return ""
+}
+
+// List implements Initrd.
+func (initrd *directory) List(ctx context.Context) ([]InitrdEntry, error) {
+	if initrd.opts.output == "" {
+		return nil, fmt.Errorf("initramfs must be built before it can be listed")
+	}
+
+	return listArchive(ctx, initrd.opts.output)
+}
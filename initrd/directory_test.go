@@ -5,12 +5,16 @@
 package initrd_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/cavaliergopher/cpio"
+	"github.com/opencontainers/go-digest"
 
 	"kraftkit.sh/initrd"
 )
@@ -89,6 +93,46 @@ func TestNewFromDirectory(t *testing.T) {
 	}
 }
 
+func TestNewFromDirectoryBuildTo(t *testing.T) {
+	ctx := context.Background()
+
+	ird, err := initrd.NewFromDirectory(ctx, "testdata/rootfs",
+		initrd.WithCompression(true),
+	)
+	if err != nil {
+		t.Fatal("NewFromDirectory:", err)
+	}
+
+	var buf bytes.Buffer
+	if err := ird.BuildTo(ctx, &buf); err != nil {
+		t.Fatal("BuildTo:", err)
+	}
+
+	gr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal("expected a gzip-compressed archive:", err)
+	}
+
+	r := cpio.NewReader(gr)
+
+	var names []string
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Failed to read next cpio header:", err)
+		}
+
+		names = append(names, hdr.Name)
+	}
+
+	if len(names) != 6 {
+		t.Errorf("expected 6 files in cpio archive, got %d: %v", len(names), names)
+	}
+}
+
 // openFile opens a file for reading, and closes it when the test completes.
 func openFile(t *testing.T, path string) io.Reader {
 	t.Helper()
@@ -105,3 +149,80 @@ func openFile(t *testing.T, path string) io.Reader {
 
 	return f
 }
+
+func TestNewFromDirectoryDigest(t *testing.T) {
+	ctx := context.Background()
+
+	ird, err := initrd.NewFromDirectory(ctx, "testdata/rootfs")
+	if err != nil {
+		t.Fatal("NewFromDirectory:", err)
+	}
+
+	if _, err := ird.Digest(); err == nil {
+		t.Error("Expected an error when requesting the digest before building")
+	}
+
+	irdPath, err := ird.Build(ctx)
+	if err != nil {
+		t.Fatal("Build:", err)
+	}
+	t.Cleanup(func() {
+		if err := os.Remove(irdPath); err != nil {
+			t.Fatal("Failed to remove initrd file:", err)
+		}
+	})
+
+	dgst, err := ird.Digest()
+	if err != nil {
+		t.Fatal("Digest:", err)
+	}
+
+	data, err := os.ReadFile(irdPath)
+	if err != nil {
+		t.Fatal("Failed to read initrd file:", err)
+	}
+
+	if expect := digest.FromBytes(data); dgst != expect {
+		t.Errorf("Expected digest %s, got %s", expect, dgst)
+	}
+}
+
+func TestNewFromDirectoryFailedBuildKeepsOutput(t *testing.T) {
+	ctx := context.Background()
+
+	// The output names an existing directory, which the initramfs cannot
+	// replace, such that the build fails.
+	output := filepath.Join(t.TempDir(), "initramfs")
+	if err := os.Mkdir(output, 0o755); err != nil {
+		t.Fatal("Mkdir:", err)
+	}
+
+	keep := filepath.Join(output, "keep")
+	if err := os.WriteFile(keep, []byte("keep"), 0o644); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+
+	ird, err := initrd.NewFromDirectory(ctx, "testdata/rootfs",
+		initrd.WithOutput(output),
+	)
+	if err != nil {
+		t.Fatal("NewFromDirectory:", err)
+	}
+
+	if _, err := ird.Build(ctx); err == nil {
+		t.Fatal("expected build to fail")
+	}
+
+	if b, err := os.ReadFile(keep); err != nil || string(b) != "keep" {
+		t.Errorf("expected existing output to be left untouched, got %q: %v", b, err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(output))
+	if err != nil {
+		t.Fatal("ReadDir:", err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("expected no leftover temporary files, got %d entries", len(entries))
+	}
+}
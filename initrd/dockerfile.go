@@ -7,26 +7,33 @@ package initrd
 import (
 	"archive/tar"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 
 	"golang.org/x/sync/errgroup"
 	"kraftkit.sh/config"
 	"kraftkit.sh/log"
 
-	sfile "github.com/anchore/stereoscope/pkg/file"
-	soci "github.com/anchore/stereoscope/pkg/image/oci"
 	"github.com/cavaliergopher/cpio"
+	"github.com/docker/docker/api/types/container"
 	"github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/identity"
 	"github.com/moby/buildkit/session/filesync"
+	"github.com/moby/buildkit/util/entitlements"
 	"github.com/moby/buildkit/util/progress/progressui"
+	"github.com/moby/patternmatcher/ignorefile"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
+	"github.com/tonistiigi/fsutil"
 
 	_ "github.com/moby/buildkit/client/connhelper/dockercontainer"
 	_ "github.com/moby/buildkit/client/connhelper/kubepod"
@@ -108,6 +115,9 @@ type dockerfile struct {
 	args       []string
 	dockerfile string
 	env        []string
+	labels     map[string]string
+	workdir    string
+	digest     digest.Digest
 }
 
 func fixedWriteCloser(wc io.WriteCloser) filesync.FileOutputFunc {
@@ -116,6 +126,24 @@ func fixedWriteCloser(wc io.WriteCloser) filesync.FileOutputFunc {
 	}
 }
 
+// logVertexWarning surfaces a warning emitted by BuildKit, e.g. when the
+// Dockerfile uses deprecated syntax, alongside its location.
+func logVertexWarning(ctx context.Context, warning client.VertexWarning) {
+	fields := logrus.Fields{}
+
+	if warning.SourceInfo != nil {
+		fields["file"] = warning.SourceInfo.Filename
+	}
+	if len(warning.Range) > 0 {
+		fields["line"] = warning.Range[0].Start.Line
+	}
+	if warning.URL != "" {
+		fields["url"] = warning.URL
+	}
+
+	log.G(ctx).WithFields(fields).Warn(string(warning.Short))
+}
+
 // NewFromDockerfile accepts an input path which represents a Dockerfile that
 // can be constructed via buildkit to become a CPIO archive.
 func NewFromDockerfile(ctx context.Context, path string, opts ...InitrdOption) (Initrd, error) {
@@ -123,6 +151,10 @@ func NewFromDockerfile(ctx context.Context, path string, opts ...InitrdOption) (
 		return nil, fmt.Errorf("file is not a Dockerfile")
 	}
 
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		return nil, fmt.Errorf("path is a directory and not a Dockerfile")
+	}
+
 	initrd := dockerfile{
 		opts: InitrdOptions{
 			workdir: filepath.Dir(path),
@@ -141,38 +173,83 @@ func NewFromDockerfile(ctx context.Context, path string, opts ...InitrdOption) (
 
 // Build implements Initrd.
 func (initrd *dockerfile) Build(ctx context.Context) (string, error) {
-	if initrd.opts.output == "" {
-		fi, err := os.CreateTemp("", "")
-		if err != nil {
-			return "", err
-		}
+	return buildToFile(ctx, &initrd.opts, &initrd.digest, initrd.BuildTo)
+}
 
-		initrd.opts.output = fi.Name()
-	}
+// BuildTo implements Initrd.
+func (initrd *dockerfile) BuildTo(ctx context.Context, w io.Writer) (err error) {
+	// Resources are released using a context which is not cancelled alongside
+	// ctx such that a cancelled build does not leave any artifacts behind.
+	cleanupCtx := context.WithoutCancel(ctx)
 
 	outputDir, err := os.MkdirTemp("", "")
 	if err != nil {
-		return "", fmt.Errorf("could not make temporary directory: %w", err)
+		return fmt.Errorf("could not make temporary directory: %w", err)
 	}
 	defer os.RemoveAll(outputDir)
 
-	tarOutput, err := os.CreateTemp("", "")
-	if err != nil {
-		return "", fmt.Errorf("could not make temporary file: %w", err)
+	exporter := initrd.opts.exporter
+	if exporter == "" {
+		exporter = BuildKitExporterBoth
 	}
-	defer tarOutput.Close()
-	defer os.RemoveAll(tarOutput.Name())
 
-	ociOutput, err := os.CreateTemp("", "")
-	if err != nil {
-		return "", fmt.Errorf("could not make temporary file: %w", err)
+	var exports []client.ExportEntry
+	var tarOutput, ociOutput *os.File
+
+	if exporter == BuildKitExporterTar || exporter == BuildKitExporterBoth {
+		tarOutput, err = os.CreateTemp("", "")
+		if err != nil {
+			return fmt.Errorf("could not make temporary file: %w", err)
+		}
+		defer tarOutput.Close()
+		defer os.RemoveAll(tarOutput.Name())
+
+		exports = append(exports, client.ExportEntry{
+			Type:   client.ExporterTar,
+			Output: fixedWriteCloser(tarOutput),
+		})
 	}
-	defer ociOutput.Close()
-	defer os.RemoveAll(ociOutput.Name())
 
-	buildkitAddr := config.G[config.KraftKit](ctx).BuildKitHost
-	c, _ := client.New(ctx, buildkitAddr)
-	buildKitInfo, connerr := c.Info(ctx)
+	if exporter == BuildKitExporterOCI || exporter == BuildKitExporterBoth {
+		ociOutput, err = os.CreateTemp("", "")
+		if err != nil {
+			return fmt.Errorf("could not make temporary file: %w", err)
+		}
+		defer ociOutput.Close()
+		defer os.RemoveAll(ociOutput.Name())
+
+		exports = append(exports, client.ExportEntry{
+			Type:   client.ExporterOCI,
+			Output: fixedWriteCloser(ociOutput),
+		})
+	}
+
+	c, buildkitAddr, buildKitInfo, connerr := discoverBuildKit(ctx, config.G[config.KraftKit](ctx).BuildKitHost)
+
+	// The ephemeral BuildKit container, if one is created, is terminated once
+	// the build has completed unless it has failed and should be kept for
+	// debugging.
+	var buildkitd testcontainers.Container
+	defer func() {
+		if buildkitd == nil {
+			return
+		}
+
+		if err != nil && initrd.opts.keepBuilder {
+			log.G(cleanupCtx).
+				WithField("container", buildkitd.GetContainerID()).
+				WithField("addr", buildkitAddr).
+				Warn("keeping buildkit container after failed build, remove it once done")
+			return
+		}
+
+		if err := buildkitd.Terminate(cleanupCtx); err != nil {
+			log.G(cleanupCtx).
+				WithError(err).
+				Debug("terminating buildkit container")
+		}
+	}()
+
 	if connerr != nil {
 		log.G(ctx).Info("creating ephemeral buildkit container")
 
@@ -186,10 +263,11 @@ func (initrd *dockerfile) Build(ctx context.Context) (string, error) {
 				return
 			}
 
-			log.G(ctx).Warnf("could not connect to BuildKit client '%s' is BuildKit running?", buildkitAddr)
+			log.G(ctx).Warn("could not connect to BuildKit client, is BuildKit running?")
 			log.G(ctx).Warn("")
-			log.G(ctx).Warn("By default, KraftKit will look for a native install which")
-			log.G(ctx).Warn("is located at /run/buildkit/buildkit.sock.  Alternatively, you")
+			log.G(ctx).Warn("By default, KraftKit will look for the address set in BUILDKIT_HOST")
+			log.G(ctx).Warn("or a native install which is located at /run/buildkit/buildkitd.sock")
+			log.G(ctx).Warn("or $XDG_RUNTIME_DIR/buildkit/buildkitd.sock.  Alternatively, you")
 			log.G(ctx).Warn("can run BuildKit in a container (recommended for macOS users)")
 			log.G(ctx).Warn("which you can do by running:")
 			log.G(ctx).Warn("")
@@ -203,100 +281,103 @@ func (initrd *dockerfile) Build(ctx context.Context) (string, error) {
 		// Port 0 means "give me any free port"
 		addr, err := net.ResolveTCPAddr("tcp", ":0")
 		if err != nil {
-			return "", err
+			return err
 		}
 		l, err := net.ListenTCP("tcp", addr)
 		if err != nil {
-			return "", err
+			return err
 		}
 
 		port := l.Addr().(*net.TCPAddr).Port
 		_ = l.Close()
 
-		buildkitd, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
-			Started: true,
-			Logger:  printf,
-			ContainerRequest: testcontainers.ContainerRequest{
-				AlwaysPullImage: true,
-				Image:           "moby/buildkit:v0.14.1",
-				WaitingFor:      wait.ForLog(fmt.Sprintf("running server on [::]:%d", port)),
-				Privileged:      true,
-				ExposedPorts:    []string{fmt.Sprintf("%d:%d/tcp", port, port)},
-				Cmd:             []string{"--addr", fmt.Sprintf("tcp://0.0.0.0:%d", port)},
-				Mounts: testcontainers.ContainerMounts{
-					{
-						Source: testcontainers.GenericVolumeMountSource{
-							Name: "kraftkit-buildkit-cache",
-						},
-						Target: "/var/lib/buildkit",
-					},
-				},
-			},
+		if err := initrd.disableReaper(); err != nil {
+			return fmt.Errorf("disabling the buildkit container reaper: %w", err)
+		}
+
+		buildkitd, err = testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+			Started:          true,
+			Logger:           printf,
+			ContainerRequest: initrd.buildkitContainerRequest(port),
 		})
 		if err != nil {
-			return "", fmt.Errorf("creating buildkit container: %w", err)
+			return fmt.Errorf("creating buildkit container: %w", err)
 		}
 
-		defer func() {
-			if err := buildkitd.Terminate(ctx); err != nil && !strings.Contains(err.Error(), "context cancelled") {
-				log.G(ctx).
-					WithError(err).
-					Debug("terminating buildkit container")
-			}
-		}()
-
 		buildkitAddr = fmt.Sprintf("tcp://localhost:%d", port)
 
-		c, _ = client.New(ctx, buildkitAddr)
-		buildKitInfo, connerr = c.Info(ctx)
+		c, err = client.New(ctx, buildkitAddr)
 		if err != nil {
-			return "", fmt.Errorf("connecting to container buildkit client: %w", err)
+			return fmt.Errorf("creating container buildkit client: %w", err)
+		}
+
+		buildKitInfo, connerr = c.Info(ctx)
+		if connerr != nil {
+			return fmt.Errorf("connecting to container buildkit client: %w", connerr)
 		}
 	}
 
+	defer c.Close()
+
 	log.G(ctx).
 		WithField("addr", buildkitAddr).
 		WithField("version", buildKitInfo.BuildkitVersion.Version).
 		Debug("using buildkit")
 
-	var cacheExports []client.CacheOptionsEntry
-	if len(initrd.opts.cacheDir) > 0 {
-		cacheExports = []client.CacheOptionsEntry{
-			{
-				Type: "local",
-				Attrs: map[string]string{
-					"dest":         initrd.opts.cacheDir,
-					"ignore-error": "true",
-				},
-			},
+	// Clear the local cache such that it is repopulated by the build.
+	if len(initrd.opts.cacheDir) > 0 && initrd.opts.noCache {
+		if err := os.RemoveAll(initrd.opts.cacheDir); err != nil {
+			return fmt.Errorf("could not clear cache directory: %w", err)
 		}
 	}
 
+	cacheImports, cacheExports := initrd.cacheOptions()
+
+	excludes, err := initrd.dockerignore()
+	if err != nil {
+		return err
+	}
+
+	contextFS, err := fsutil.NewFS(initrd.opts.workdir)
+	if err != nil {
+		return fmt.Errorf("could not open build context: %w", err)
+	}
+
+	// Filter the context on the client side such that ignored files are never
+	// sent to BuildKit.
+	if len(excludes) > 0 {
+		contextFS, err = fsutil.NewFilterFS(contextFS, &fsutil.FilterOpt{
+			ExcludePatterns: excludes,
+		})
+		if err != nil {
+			return fmt.Errorf("could not filter build context: %w", err)
+		}
+	}
+
+	dockerfileFS, err := fsutil.NewFS(initrd.opts.workdir)
+	if err != nil {
+		return fmt.Errorf("could not open Dockerfile directory: %w", err)
+	}
+
 	solveOpt := &client.SolveOpt{
-		Ref: identity.NewID(),
-		Exports: []client.ExportEntry{
-			{
-				Type:   client.ExporterTar,
-				Output: fixedWriteCloser(tarOutput),
-			},
-			{
-				Type:   client.ExporterOCI,
-				Output: fixedWriteCloser(ociOutput),
-			},
-		},
+		Ref:          identity.NewID(),
+		Exports:      exports,
+		CacheImports: cacheImports,
 		CacheExports: cacheExports,
-		LocalDirs: map[string]string{
-			"context":    initrd.opts.workdir,
-			"dockerfile": initrd.opts.workdir,
-		},
-		Frontend: "dockerfile.v0",
-		FrontendAttrs: map[string]string{
-			"filename": filepath.Base(initrd.dockerfile),
+		LocalMounts: map[string]fsutil.FS{
+			"context":    contextFS,
+			"dockerfile": dockerfileFS,
 		},
+		Frontend:      "dockerfile.v0",
+		FrontendAttrs: initrd.frontendAttrs(),
 	}
 
-	if initrd.opts.arch != "" {
-		solveOpt.FrontendAttrs["platform"] = fmt.Sprintf("linux/%s", initrd.opts.arch)
+	switch initrd.opts.networkMode {
+	case BuildKitNetworkModeNone:
+		solveOpt.FrontendAttrs["force-network-mode"] = string(BuildKitNetworkModeNone)
+	case BuildKitNetworkModeHost:
+		solveOpt.FrontendAttrs["force-network-mode"] = string(BuildKitNetworkModeHost)
+		solveOpt.AllowedEntitlements = append(solveOpt.AllowedEntitlements, entitlements.EntitlementNetworkHost)
 	}
 
 	ch := make(chan *client.SolveStatus)
@@ -316,75 +397,205 @@ func (initrd *dockerfile) Build(ctx context.Context) (string, error) {
 			return fmt.Errorf("could not create progress display: %w", err)
 		}
 
-		_, err = d.UpdateFrom(ctx, ch)
+		warnings, err := d.UpdateFrom(ctx, ch)
 		if err != nil {
 			return fmt.Errorf("could not display output progress: %w", err)
 		}
 
+		for _, warning := range warnings {
+			logVertexWarning(ctx, warning)
+		}
+
 		return nil
 	})
 
 	if err := eg.Wait(); err != nil {
-		return "", fmt.Errorf("could not wait for err group: %w", err)
+		return fmt.Errorf("could not wait for err group: %w", err)
 	}
 
-	// parse the output directory with stereoscope
-	tempgen := sfile.NewTempDirGenerator("kraftkit")
-	if tempgen == nil {
-		return "", fmt.Errorf("could not create temp dir generator")
-	}
+	switch exporter {
+	case BuildKitExporterOCI:
+		// Without the tarball, the filesystem is flattened from the layers of
+		// the OCI archive.
+		archive := imageArchive{
+			opts:     initrd.opts,
+			typ:      TypeOCIArchive,
+			path:     ociOutput.Name(),
+			provider: ociArchiveProvider,
+		}
+
+		if err := archive.BuildTo(ctx, w); err != nil {
+			return err
+		}
+
+		initrd.args = archive.args
+		initrd.env = archive.env
+		initrd.labels = archive.labels
+		initrd.workdir = archive.workdir
+
+		return nil
 
-	provider := soci.NewArchiveProvider(tempgen, ociOutput.Name())
-	if provider == nil {
-		return "", fmt.Errorf("could not create image provider")
+	case BuildKitExporterBoth:
+		// The filesystem is read from the tarball whilst the OCI archive is only
+		// read for the configuration of the image.
+		imageConfig, err := readOCIArchiveConfig(ctx, ociOutput.Name())
+		if err != nil {
+			return err
+		}
+
+		initrd.args = append(slices.Clone(imageConfig.Entrypoint), imageConfig.Cmd...)
+		initrd.env = imageConfig.Env
+		initrd.labels = imageConfig.Labels
+		initrd.workdir = imageConfig.WorkingDir
 	}
 
-	img, err := provider.Provide(ctx)
+	cpioWriter, closeWriter, err := newCPIOWriter(w, initrd.opts)
 	if err != nil {
-		return "", fmt.Errorf("could not provide image: %w", err)
+		return err
 	}
 
-	err = img.Read()
+	tarArchive, err := os.Open(tarOutput.Name())
 	if err != nil {
-		return "", fmt.Errorf("could not read image: %w", err)
+		return fmt.Errorf("could not open output tarball: %w", err)
 	}
 
-	initrd.args = append(img.Metadata.Config.Config.Entrypoint,
-		img.Metadata.Config.Config.Cmd...,
-	)
-	initrd.env = img.Metadata.Config.Config.Env
+	defer tarArchive.Close()
 
-	if err := tempgen.Cleanup(); err != nil {
-		return "", fmt.Errorf("could not cleanup temp dir generator: %w", err)
+	if err := tarToCPIO(ctx, tar.NewReader(tarArchive), cpioWriter); err != nil {
+		return err
 	}
 
-	if err := img.Cleanup(); err != nil {
-		return "", fmt.Errorf("could not cleanup image: %w", err)
+	return closeWriter()
+}
+
+// cacheOptions returns the caches which BuildKit imports from and exports to.
+// When no cache should be used, caches are only exported such that they are
+// repopulated by the build.
+func (initrd *dockerfile) cacheOptions() (imports, exports []client.CacheOptionsEntry) {
+	if len(initrd.opts.cacheDir) > 0 {
+		if !initrd.opts.noCache {
+			imports = append(imports, client.CacheOptionsEntry{
+				Type: "local",
+				Attrs: map[string]string{
+					"src": initrd.opts.cacheDir,
+				},
+			})
+		}
+		exports = append(exports, client.CacheOptionsEntry{
+			Type: "local",
+			Attrs: map[string]string{
+				"dest":         initrd.opts.cacheDir,
+				"ignore-error": "true",
+			},
+		})
+	}
+	if len(initrd.opts.remoteCache) > 0 {
+		if !initrd.opts.noCache {
+			imports = append(imports, client.CacheOptionsEntry{
+				Type: "registry",
+				Attrs: map[string]string{
+					"ref": initrd.opts.remoteCache,
+				},
+			})
+		}
+		exports = append(exports, client.CacheOptionsEntry{
+			Type: "registry",
+			Attrs: map[string]string{
+				"ref":          initrd.opts.remoteCache,
+				"ignore-error": "true",
+			},
+		})
 	}
 
-	if err := os.MkdirAll(filepath.Dir(initrd.opts.output), 0o755); err != nil {
-		return "", fmt.Errorf("could not create output directory: %w", err)
+	return imports, exports
+}
+
+// frontendAttrs returns the attributes which are passed to BuildKit's
+// Dockerfile frontend.
+func (initrd *dockerfile) frontendAttrs() map[string]string {
+	attrs := map[string]string{
+		"filename": filepath.Base(initrd.dockerfile),
 	}
 
-	cpioFile, err := os.OpenFile(initrd.opts.output, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
-	if err != nil {
-		return "", fmt.Errorf("could not open initramfs file: %w", err)
+	if initrd.opts.noCache {
+		attrs["no-cache"] = ""
 	}
 
-	defer cpioFile.Close()
+	if platform := initrd.opts.platform(); platform != "" {
+		attrs["platform"] = platform
+	}
+
+	return attrs
+}
 
-	cpioWriter := cpio.NewWriter(cpioFile)
+// disableReaper disables Ryuk, the reaper of testcontainers, if the BuildKit
+// container should be kept after a failed build, since it would otherwise
+// remove the container once this process exits.  The reaper can only be
+// disabled globally, via the environment, before the first container is
+// created by the process.
+func (initrd *dockerfile) disableReaper() error {
+	if !initrd.opts.keepBuilder {
+		return nil
+	}
 
-	defer cpioWriter.Close()
+	return os.Setenv("TESTCONTAINERS_RYUK_DISABLED", "true")
+}
 
-	tarArchive, err := os.Open(tarOutput.Name())
-	if err != nil {
-		return "", fmt.Errorf("could not open output tarball: %w", err)
+// buildkitContainerRequest returns the request for the ephemeral BuildKit
+// container which listens on the provided port.
+func (initrd *dockerfile) buildkitContainerRequest(port int) testcontainers.ContainerRequest {
+	image := "moby/buildkit:v0.14.1"
+	cacheVolume := "kraftkit-buildkit-cache"
+	cacheTarget := "/var/lib/buildkit"
+	cmd := []string{
+		"--addr", fmt.Sprintf("tcp://0.0.0.0:%d", port),
 	}
 
-	defer tarArchive.Close()
+	// The daemon only permits host networking if the build requests it.
+	if initrd.opts.networkMode == BuildKitNetworkModeHost {
+		cmd = append(cmd, "--allow-insecure-entitlement", string(entitlements.EntitlementNetworkHost))
+	}
+	var securityOpts []string
+
+	if initrd.opts.unprivileged {
+		image += "-rootless"
+		cacheVolume = "kraftkit-buildkit-rootless-cache"
+		cacheTarget = "/home/user/.local/share/buildkit"
+		cmd = append(cmd, "--oci-worker-no-process-sandbox")
+		securityOpts = []string{"seccomp=unconfined", "apparmor=unconfined"}
+	}
+
+	return testcontainers.ContainerRequest{
+		AlwaysPullImage: true,
+		Image:           image,
+		WaitingFor:      wait.ForLog(fmt.Sprintf("running server on [::]:%d", port)),
+		Privileged:      !initrd.opts.unprivileged,
+		ExposedPorts:    []string{fmt.Sprintf("%d:%d/tcp", port, port)},
+		Cmd:             cmd,
+		HostConfigModifier: func(hc *container.HostConfig) {
+			hc.SecurityOpt = append(hc.SecurityOpt, securityOpts...)
+			if initrd.opts.builderNetwork != "" {
+				hc.NetworkMode = container.NetworkMode(initrd.opts.builderNetwork)
+			}
+		},
+		Mounts: testcontainers.ContainerMounts{
+			{
+				Source: testcontainers.GenericVolumeMountSource{
+					Name: cacheVolume,
+				},
+				Target: testcontainers.ContainerMountTarget(cacheTarget),
+			},
+		},
+	}
+}
 
-	tarReader := tar.NewReader(tarArchive)
+// tarToCPIO converts each entry of the tarball into an entry of the CPIO
+// archive.  Devices and FIFOs are not supported by the CPIO archive and are
+// skipped.
+func tarToCPIO(ctx context.Context, tarReader *tar.Reader, cpioWriter *cpio.Writer) error {
+	// Skipped files are summarized once rather than warned about individually,
+	// as images commonly contain many device nodes under /dev.
+	var blocks, chars, fifos int
 
 	for {
 		tarHeader, err := tarReader.Next()
@@ -392,7 +603,7 @@ func (initrd *dockerfile) Build(ctx context.Context) (string, error) {
 			break // End of archive
 		}
 		if err != nil {
-			return "", fmt.Errorf("could not read tar header: %w", err)
+			return fmt.Errorf("could not read tar header: %w", err)
 		}
 
 		internal := filepath.Clean(fmt.Sprintf("/%s", tarHeader.Name))
@@ -407,23 +618,35 @@ func (initrd *dockerfile) Build(ctx context.Context) (string, error) {
 		// Populate platform specific information
 		populateCPIO(tarHeader.FileInfo(), cpioHeader)
 
+		// The newc CPIO format cannot represent extended attributes, such that
+		// e.g. file capabilities set via setcap are lost.
+		if xattrs := tarXattrs(tarHeader); len(xattrs) > 0 {
+			log.G(ctx).
+				WithField("file", internal).
+				WithField("xattrs", strings.Join(xattrs, ",")).
+				Warn("dropping extended attributes which are not supported in the initramfs")
+		}
+
 		switch tarHeader.Typeflag {
 		case tar.TypeBlock:
 			log.G(ctx).
 				WithField("file", tarHeader.Name).
-				Warn("ignoring block devices")
+				Trace("ignoring block device")
+			blocks++
 			continue
 
 		case tar.TypeChar:
 			log.G(ctx).
 				WithField("file", tarHeader.Name).
-				Warn("ignoring char devices")
+				Trace("ignoring char device")
+			chars++
 			continue
 
 		case tar.TypeFifo:
 			log.G(ctx).
 				WithField("file", tarHeader.Name).
-				Warn("ignoring fifo files")
+				Trace("ignoring fifo file")
+			fifos++
 			continue
 
 		case tar.TypeSymlink:
@@ -437,11 +660,11 @@ func (initrd *dockerfile) Build(ctx context.Context) (string, error) {
 			cpioHeader.Size = int64(len(tarHeader.Linkname))
 
 			if err := cpioWriter.WriteHeader(cpioHeader); err != nil {
-				return "", fmt.Errorf("could not write CPIO header: %w", err)
+				return fmt.Errorf("could not write CPIO header: %w", err)
 			}
 
 			if _, err := cpioWriter.Write([]byte(tarHeader.Linkname)); err != nil {
-				return "", fmt.Errorf("could not write CPIO data for %s: %w", internal, err)
+				return fmt.Errorf("could not write CPIO data for %s: %w", internal, err)
 			}
 
 		case tar.TypeLink:
@@ -454,30 +677,36 @@ func (initrd *dockerfile) Build(ctx context.Context) (string, error) {
 			cpioHeader.Linkname = tarHeader.Linkname
 			cpioHeader.Size = 0
 			if err := cpioWriter.WriteHeader(cpioHeader); err != nil {
-				return "", fmt.Errorf("could not write CPIO header: %w", err)
+				return fmt.Errorf("could not write CPIO header: %w", err)
 			}
 
-		case tar.TypeReg:
+		case tar.TypeReg, tar.TypeGNUSparse:
 			log.G(ctx).
 				WithField("src", tarHeader.Name).
 				WithField("dst", internal).
 				Debug("copying")
 
+			// The size of the header is the logical size of the file, which for
+			// sparse files includes its holes which are read back as zeros.
 			cpioHeader.Mode |= cpio.TypeReg
 			cpioHeader.Linkname = tarHeader.Linkname
-			cpioHeader.Size = tarHeader.FileInfo().Size()
+			cpioHeader.Size = tarHeader.Size
 
 			if err := cpioWriter.WriteHeader(cpioHeader); err != nil {
-				return "", fmt.Errorf("could not write CPIO header: %w", err)
+				return fmt.Errorf("could not write CPIO header: %w", err)
 			}
 
-			data, err := io.ReadAll(tarReader)
-			if err != nil {
-				return "", fmt.Errorf("could not read file: %w", err)
+			if tarHeader.Size == 0 {
+				continue
 			}
 
-			if _, err := cpioWriter.Write(data); err != nil {
-				return "", fmt.Errorf("could not write CPIO data for %s: %w", internal, err)
+			// Exactly as many bytes as declared must be written, otherwise the
+			// archive is corrupt.
+			n, err := io.CopyN(cpioWriter, tarReader, tarHeader.Size)
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return fmt.Errorf("could not copy %s: expected %d bytes but only %d were available", internal, tarHeader.Size, n)
+			} else if err != nil {
+				return fmt.Errorf("could not write CPIO data for %s: %w", internal, err)
 			}
 
 		case tar.TypeDir:
@@ -488,7 +717,7 @@ func (initrd *dockerfile) Build(ctx context.Context) (string, error) {
 			cpioHeader.Mode |= cpio.TypeDir
 
 			if err := cpioWriter.WriteHeader(cpioHeader); err != nil {
-				return "", fmt.Errorf("could not write CPIO header: %w", err)
+				return fmt.Errorf("could not write CPIO header: %w", err)
 			}
 
 		default:
@@ -499,13 +728,75 @@ func (initrd *dockerfile) Build(ctx context.Context) (string, error) {
 		}
 	}
 
-	if initrd.opts.compress {
-		if err := compressFiles(initrd.opts.output, cpioWriter, cpioFile); err != nil {
-			return "", fmt.Errorf("could not compress files: %w", err)
+	var skipped []string
+	for _, kind := range []struct {
+		count int
+		name  string
+	}{
+		{blocks, "block device"},
+		{chars, "char device"},
+		{fifos, "fifo"},
+	} {
+		switch {
+		case kind.count == 1:
+			skipped = append(skipped, "1 "+kind.name)
+		case kind.count > 1:
+			skipped = append(skipped, fmt.Sprintf("%d %ss", kind.count, kind.name))
 		}
 	}
 
-	return initrd.opts.output, nil
+	if len(skipped) > 0 {
+		log.G(ctx).Warnf("skipped %s: not supported in the initramfs", strings.Join(skipped, ", "))
+	}
+
+	return nil
+}
+
+// tarXattrPrefix is the prefix of the PAX records which carry the extended
+// attributes of a file.
+const tarXattrPrefix = "SCHILY.xattr."
+
+// tarXattrs returns the sorted names of the extended attributes of the entry.
+func tarXattrs(header *tar.Header) []string {
+	var xattrs []string
+
+	for key := range header.PAXRecords {
+		if name, ok := strings.CutPrefix(key, tarXattrPrefix); ok {
+			xattrs = append(xattrs, name)
+		}
+	}
+
+	sort.Strings(xattrs)
+
+	return xattrs
+}
+
+// dockerignore returns the exclude patterns of the ignore file adjacent to the
+// Dockerfile.  Like BuildKit, a Dockerfile-specific ignore file, e.g.
+// `rootfs.Dockerfile.dockerignore`, takes precedence over `.dockerignore`.
+func (initrd *dockerfile) dockerignore() ([]string, error) {
+	for _, name := range []string{
+		filepath.Base(initrd.dockerfile) + ".dockerignore",
+		".dockerignore",
+	} {
+		f, err := os.Open(filepath.Join(initrd.opts.workdir, name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("could not open %s: %w", name, err)
+		}
+
+		defer f.Close()
+
+		excludes, err := ignorefile.ReadAll(f)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", name, err)
+		}
+
+		return excludes, nil
+	}
+
+	return nil, nil
 }
 
 // Env implements Initrd.
@@ -517,3 +808,36 @@ func (initrd *dockerfile) Env() []string {
 func (initrd *dockerfile) Args() []string {
 	return initrd.args
 }
+
+// Labels implements Initrd.
+func (initrd *dockerfile) Labels() map[string]string {
+	return initrd.labels
+}
+
+// Name implements Initrd.
+func (initrd *dockerfile) Name() string {
+	return string(TypeDockerfile)
+}
+
+// Digest implements Initrd.
+func (initrd *dockerfile) Digest() (digest.Digest, error) {
+	if initrd.digest == "" {
+		return "", fmt.Errorf("initramfs must be built before its digest is known")
+	}
+
+	return initrd.digest, nil
+}
+
+// WorkingDir implements Initrd.
+func (initrd *dockerfile) WorkingDir() string {
+	return initrd.workdir
+}
+
+// List implements Initrd.
+func (initrd *dockerfile) List(ctx context.Context) ([]InitrdEntry, error) {
+	if initrd.opts.output == "" {
+		return nil, fmt.Errorf("initramfs must be built before it can be listed")
+	}
+
+	return listArchive(ctx, initrd.opts.output)
+}
@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestDockerfileDisableReaper(t *testing.T) {
+	tests := []struct {
+		name        string
+		keepBuilder bool
+		expected    string
+	}{
+		{
+			name:        "keep builder",
+			keepBuilder: true,
+			expected:    "true",
+		},
+		{
+			name:        "terminate builder",
+			keepBuilder: false,
+			expected:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Restores the environment once the test has completed.
+			t.Setenv("TESTCONTAINERS_RYUK_DISABLED", "")
+
+			initrd := &dockerfile{
+				opts: InitrdOptions{keepBuilder: tt.keepBuilder},
+			}
+
+			if err := initrd.disableReaper(); err != nil {
+				t.Fatal("disableReaper:", err)
+			}
+
+			if got := os.Getenv("TESTCONTAINERS_RYUK_DISABLED"); got != tt.expected {
+				t.Errorf("expected TESTCONTAINERS_RYUK_DISABLED to be '%s', got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDockerfileBuildkitContainerRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         InitrdOptions
+		image        string
+		privileged   bool
+		entitlement  bool
+		noProcessBox bool
+	}{
+		{
+			name:       "default",
+			image:      "moby/buildkit:v0.14.1",
+			privileged: true,
+		},
+		{
+			name:        "host network",
+			opts:        InitrdOptions{networkMode: BuildKitNetworkModeHost},
+			image:       "moby/buildkit:v0.14.1",
+			privileged:  true,
+			entitlement: true,
+		},
+		{
+			name:         "unprivileged",
+			opts:         InitrdOptions{unprivileged: true},
+			image:        "moby/buildkit:v0.14.1-rootless",
+			noProcessBox: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initrd := &dockerfile{opts: tt.opts}
+
+			req := initrd.buildkitContainerRequest(1234)
+
+			if req.Image != tt.image {
+				t.Errorf("expected image '%s', got '%s'", tt.image, req.Image)
+			}
+
+			if req.Privileged != tt.privileged {
+				t.Errorf("expected privileged to be %t, got %t", tt.privileged, req.Privileged)
+			}
+
+			if got := slices.Contains(req.Cmd, "--allow-insecure-entitlement"); got != tt.entitlement {
+				t.Errorf("expected the network.host entitlement to be allowed: %t, got %v", tt.entitlement, req.Cmd)
+			}
+
+			if got := slices.Contains(req.Cmd, "--oci-worker-no-process-sandbox"); got != tt.noProcessBox {
+				t.Errorf("expected the process sandbox to be disabled: %t, got %v", tt.noProcessBox, req.Cmd)
+			}
+		})
+	}
+}
+
+func TestDockerfileWithNoCache(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []InitrdOption
+		noCache bool
+		imports int
+		exports int
+	}{
+		{
+			name: "no caches",
+		},
+		{
+			name: "no caches without cache",
+			opts: []InitrdOption{
+				WithNoCache(true),
+			},
+			noCache: true,
+		},
+		{
+			name: "caches",
+			opts: []InitrdOption{
+				WithCacheDir("/tmp/cache"),
+				WithRemoteCache("registry.example.com/cache"),
+			},
+			imports: 2,
+			exports: 2,
+		},
+		{
+			name: "caches without cache",
+			opts: []InitrdOption{
+				WithCacheDir("/tmp/cache"),
+				WithRemoteCache("registry.example.com/cache"),
+				WithNoCache(true),
+			},
+			noCache: true,
+			exports: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initrd := &dockerfile{dockerfile: "/app/Dockerfile"}
+
+			for _, opt := range tt.opts {
+				if err := opt(&initrd.opts); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			imports, exports := initrd.cacheOptions()
+
+			if len(imports) != tt.imports {
+				t.Errorf("expected %d cache imports, got %v", tt.imports, imports)
+			}
+
+			if len(exports) != tt.exports {
+				t.Errorf("expected %d cache exports, got %v", tt.exports, exports)
+			}
+
+			attrs := initrd.frontendAttrs()
+
+			if _, ok := attrs["no-cache"]; ok != tt.noCache {
+				t.Errorf("expected the no-cache attribute to be set: %t, got %v", tt.noCache, attrs)
+			}
+
+			if attrs["filename"] != "Dockerfile" {
+				t.Errorf("expected filename 'Dockerfile', got '%s'", attrs["filename"])
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/cavaliergopher/cpio"
+	"github.com/sirupsen/logrus"
+
+	"kraftkit.sh/log"
+)
+
+// tarball returns a tarball containing a regular file for each of the provided
+// files.
+func tarball(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0o644,
+			Size:     int64(len(data)),
+		}); err != nil {
+			t.Fatal("WriteHeader:", err)
+		}
+
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal("Write:", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal("Close:", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestTarToCPIO(t *testing.T) {
+	ctx := context.Background()
+
+	files := map[string][]byte{
+		"empty": {},
+		"large": bytes.Repeat([]byte("kraftkit"), 1024*1024),
+	}
+
+	var out bytes.Buffer
+	cw := cpio.NewWriter(&out)
+
+	if err := tarToCPIO(ctx, tar.NewReader(bytes.NewReader(tarball(t, files))), cw); err != nil {
+		t.Fatal("tarToCPIO:", err)
+	}
+
+	if err := cw.Close(); err != nil {
+		t.Fatal("Close:", err)
+	}
+
+	cr := cpio.NewReader(&out)
+	found := 0
+
+	for {
+		hdr, err := cr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal("Next:", err)
+		}
+
+		expect, ok := files[hdr.Name[1:]]
+		if !ok {
+			t.Errorf("unexpected file: %s", hdr.Name)
+			continue
+		}
+
+		found++
+
+		data, err := io.ReadAll(cr)
+		if err != nil {
+			t.Fatal("ReadAll:", err)
+		}
+
+		if hdr.Size != int64(len(expect)) || !bytes.Equal(data, expect) {
+			t.Errorf("%s: expected %d bytes, got %d bytes of size %d", hdr.Name, len(expect), len(data), hdr.Size)
+		}
+	}
+
+	if found != len(files) {
+		t.Errorf("expected %d files, found %d", len(files), found)
+	}
+}
+
+func TestTarToCPIOTruncated(t *testing.T) {
+	data := tarball(t, map[string][]byte{
+		"truncated": bytes.Repeat([]byte{'a'}, 1024),
+	})
+
+	// Keep the header and only half of the contents of the file.
+	truncated := data[:512+512]
+
+	err := tarToCPIO(context.Background(), tar.NewReader(bytes.NewReader(truncated)), cpio.NewWriter(io.Discard))
+	if err == nil {
+		t.Fatal("expected an error for a truncated file")
+	}
+}
+
+func TestTarToCPIOSummarizesSkippedFiles(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	for i, typ := range []byte{tar.TypeChar, tar.TypeChar, tar.TypeChar, tar.TypeFifo} {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: typ,
+			Name:     "dev/" + string(rune('a'+i)),
+			Mode:     0o644,
+		}); err != nil {
+			t.Fatal("WriteHeader:", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal("Close:", err)
+	}
+
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&out)
+	ctx := log.WithLogger(context.Background(), logger)
+
+	if err := tarToCPIO(ctx, tar.NewReader(&buf), cpio.NewWriter(io.Discard)); err != nil {
+		t.Fatal("tarToCPIO:", err)
+	}
+
+	if count := strings.Count(out.String(), "level=warning"); count != 1 {
+		t.Errorf("expected a single warning, got %d: %s", count, out.String())
+	}
+
+	if !strings.Contains(out.String(), "skipped 3 char devices, 1 fifo") {
+		t.Errorf("expected the skipped files to be summarized, got: %s", out.String())
+	}
+}
+
+func TestTarToCPIOWarnsAboutXattrs(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "bin/ping",
+		Mode:     0o755,
+		Format:   tar.FormatPAX,
+		PAXRecords: map[string]string{
+			"SCHILY.xattr.security.capability": "\x01",
+		},
+	}); err != nil {
+		t.Fatal("WriteHeader:", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal("Close:", err)
+	}
+
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&out)
+	ctx := log.WithLogger(context.Background(), logger)
+
+	if err := tarToCPIO(ctx, tar.NewReader(&buf), cpio.NewWriter(io.Discard)); err != nil {
+		t.Fatal("tarToCPIO:", err)
+	}
+
+	if !strings.Contains(out.String(), "security.capability") || !strings.Contains(out.String(), "/bin/ping") {
+		t.Errorf("expected a warning about the dropped xattr, got: %s", out.String())
+	}
+}
@@ -5,14 +5,20 @@
 package initrd_test
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/cavaliergopher/cpio"
+	"github.com/sirupsen/logrus"
 
 	"kraftkit.sh/initrd"
+	"kraftkit.sh/log"
 )
 
 func TestNewFromDockerfile(t *testing.T) {
@@ -99,3 +105,271 @@ func TestNewFromDockerfile(t *testing.T) {
 		t.Errorf("Expected %d files, got %d: %#v", len(expectHeaders), len(gotFiles), gotFiles)
 	}
 }
+
+func TestDockerfileBuildCancelledCleansUp(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	workdir := t.TempDir()
+	path := filepath.Join(workdir, "Dockerfile")
+	if err := os.WriteFile(path, []byte("FROM scratch\n"), 0o644); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ird, err := initrd.NewFromDockerfile(ctx, path)
+	if err != nil {
+		t.Fatal("NewFromDockerfile:", err)
+	}
+
+	// Cancel before the build has a chance to solve such that it is interrupted
+	// regardless of whether BuildKit is available.
+	cancel()
+
+	if _, err := ird.Build(ctx); err == nil {
+		t.Fatal("expected cancelled build to fail")
+	}
+
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		t.Fatal("ReadDir:", err)
+	}
+
+	for _, entry := range entries {
+		t.Errorf("leftover temporary file: %s", entry.Name())
+	}
+}
+
+func TestDockerfileBuildCancelledDuringBuildKeepsOutput(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	workdir := t.TempDir()
+	path := filepath.Join(workdir, "Dockerfile")
+	if err := os.WriteFile(path, []byte("FROM alpine:3.19\nRUN sleep 60\n"), 0o644); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+
+	// A previously built initramfs must survive the cancelled build.
+	outputDir := t.TempDir()
+	output := filepath.Join(outputDir, "initramfs.cpio")
+	if err := os.WriteFile(output, []byte("previous"), 0o644); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ird, err := initrd.NewFromDockerfile(ctx, path,
+		initrd.WithOutput(output),
+	)
+	if err != nil {
+		t.Fatal("NewFromDockerfile:", err)
+	}
+
+	// Cancel whilst the build is solving the long-running step.
+	timer := time.AfterFunc(5*time.Second, cancel)
+	defer timer.Stop()
+
+	if _, err := ird.Build(ctx); err == nil {
+		t.Fatal("expected cancelled build to fail")
+	}
+
+	if b, err := os.ReadFile(output); err != nil || string(b) != "previous" {
+		t.Errorf("expected existing output to be left untouched, got %q: %v", b, err)
+	}
+
+	for _, dir := range []string{tmp, outputDir} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal("ReadDir:", err)
+		}
+
+		for _, entry := range entries {
+			if filepath.Join(dir, entry.Name()) != output {
+				t.Errorf("leftover temporary file: %s", entry.Name())
+			}
+		}
+	}
+}
+
+// cpioFile returns the content of the file with the provided name within the
+// CPIO archive at path.
+func cpioFile(t *testing.T, path, name string) []byte {
+	t.Helper()
+
+	r := cpio.NewReader(openFile(t, path))
+
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Failed to read next cpio header:", err)
+		}
+
+		if hdr.Name != name {
+			continue
+		}
+
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal("ReadAll:", err)
+		}
+
+		return b
+	}
+
+	t.Fatalf("missing file in cpio archive: %s", name)
+	return nil
+}
+
+func TestNewFromDockerfileCacheDir(t *testing.T) {
+	const cacheDockerfile = "testdata/cache.Dockerfile"
+
+	ctx := context.Background()
+	cacheDir := t.TempDir()
+
+	build := func(noCache bool) []byte {
+		t.Helper()
+
+		output := filepath.Join(t.TempDir(), "initramfs.cpio")
+
+		ird, err := initrd.NewFromDockerfile(ctx, cacheDockerfile,
+			initrd.WithCacheDir(cacheDir),
+			initrd.WithNoCache(noCache),
+			initrd.WithOutput(output),
+		)
+		if err != nil {
+			t.Fatal("NewFromDockerfile:", err)
+		}
+
+		if _, err := ird.Build(ctx); err != nil {
+			t.Fatal("Build:", err)
+		}
+
+		// Every build must populate the cache such that subsequent builds are
+		// able to import it.
+		if _, err := os.Stat(filepath.Join(cacheDir, "index.json")); err != nil {
+			t.Fatal("expected cache to be exported:", err)
+		}
+
+		// The build identifier is unique to each execution of the RUN step.
+		return cpioFile(t, output, "/build-id")
+	}
+
+	first := build(false)
+
+	// The RUN step of the second build must be served from the cache imported
+	// from the first build rather than being executed again.
+	if second := build(false); !bytes.Equal(first, second) {
+		t.Errorf("expected a cache hit, but the step was rebuilt: got build-id %q, expected %q", second, first)
+	}
+
+	// Conversely, bypassing the cache must execute the step again, which ensures
+	// that the build identifier indeed changes with every execution.
+	if uncached := build(true); bytes.Equal(first, uncached) {
+		t.Errorf("expected the step to be rebuilt without the cache, got the same build-id %q", uncached)
+	}
+}
+
+func TestNewFromDockerfileLogsWarnings(t *testing.T) {
+	const maintainerDockerfile = "testdata/maintainer.Dockerfile"
+
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&out)
+	ctx := log.WithLogger(context.Background(), logger)
+
+	ird, err := initrd.NewFromDockerfile(ctx, maintainerDockerfile,
+		initrd.WithOutput(filepath.Join(t.TempDir(), "initramfs.cpio")),
+	)
+	if err != nil {
+		t.Fatal("NewFromDockerfile:", err)
+	}
+
+	if _, err := ird.Build(ctx); err != nil {
+		t.Fatal("Build:", err)
+	}
+
+	if !strings.Contains(strings.ToLower(out.String()), "deprecated") {
+		t.Errorf("expected deprecated MAINTAINER warning to be logged, got: %s", out.String())
+	}
+	if !strings.Contains(out.String(), "maintainer.Dockerfile") {
+		t.Errorf("expected warning to reference the Dockerfile, got: %s", out.String())
+	}
+}
+
+func TestNewFromDockerfileDockerignore(t *testing.T) {
+	ctx := context.Background()
+	workdir := t.TempDir()
+
+	for name, content := range map[string]string{
+		"Dockerfile":    "FROM scratch\nCOPY . /\n",
+		".dockerignore": "secret.txt\n",
+		"secret.txt":    "hunter2\n",
+		"public.txt":    "hello\n",
+	} {
+		if err := os.WriteFile(filepath.Join(workdir, name), []byte(content), 0o644); err != nil {
+			t.Fatal("WriteFile:", err)
+		}
+	}
+
+	ird, err := initrd.NewFromDockerfile(ctx, filepath.Join(workdir, "Dockerfile"),
+		initrd.WithOutput(filepath.Join(t.TempDir(), "initramfs.cpio")),
+	)
+	if err != nil {
+		t.Fatal("NewFromDockerfile:", err)
+	}
+
+	irdPath, err := ird.Build(ctx)
+	if err != nil {
+		t.Fatal("Build:", err)
+	}
+
+	r := cpio.NewReader(openFile(t, irdPath))
+
+	found := map[string]bool{}
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Failed to read next cpio header:", err)
+		}
+
+		found[hdr.Name] = true
+	}
+
+	if found["/secret.txt"] {
+		t.Error("ignored file /secret.txt is present in cpio archive")
+	}
+	if !found["/public.txt"] {
+		t.Error("file /public.txt is missing from cpio archive")
+	}
+}
+
+func TestNewFromDockerfileInvalidExporter(t *testing.T) {
+	if _, err := initrd.NewFromDockerfile(context.Background(), "testdata/rootfs.Dockerfile",
+		initrd.WithBuildKitExporter("docker"),
+	); err == nil {
+		t.Error("expected an unknown exporter to be rejected")
+	}
+}
+
+func TestNewFromDockerfileInvalidNetwork(t *testing.T) {
+	if _, err := initrd.NewFromDockerfile(context.Background(), "testdata/rootfs.Dockerfile",
+		initrd.WithNetworkMode("bridge"),
+	); err == nil {
+		t.Error("expected an unknown network mode to be rejected")
+	}
+
+	if _, err := initrd.NewFromDockerfile(context.Background(), "testdata/rootfs.Dockerfile",
+		initrd.WithBuilderNetwork("none"),
+	); err == nil {
+		t.Error("expected the buildkit container to not be attached to the 'none' network")
+	}
+}
@@ -5,21 +5,30 @@
 package initrd
 
 import (
+	"compress/gzip"
 	"context"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/cavaliergopher/cpio"
+	"github.com/opencontainers/go-digest"
 )
 
 type file struct {
-	opts InitrdOptions
-	path string
+	opts   InitrdOptions
+	path   string
+	digest digest.Digest
 }
 
 // NewFromFile accepts an input file which already represents a CPIO archive and
 // is provided as a mechanism for satisfying the Initrd interface.
 func NewFromFile(_ context.Context, path string, opts ...InitrdOption) (Initrd, error) {
+	return newFromFile(path, opts...)
+}
+
+func newFromFile(path string, opts ...InitrdOption) (*file, error) {
 	fi, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -55,9 +64,42 @@ func NewFromFile(_ context.Context, path string, opts ...InitrdOption) (Initrd,
 	return &initrd, nil
 }
 
-// Build implements Initrd.
-func (initrd *file) Build(_ context.Context) (string, error) {
-	return initrd.path, nil
+// Build implements Initrd.  The archive is used in place unless it should be
+// placed at a different output or compressed, in which case it is written to
+// the output.
+func (initrd *file) Build(ctx context.Context) (string, error) {
+	inPlace := initrd.opts.output == "" || filepath.Clean(initrd.opts.output) == filepath.Clean(initrd.path)
+	if inPlace && !initrd.opts.compress {
+		return initrd.path, nil
+	}
+
+	return buildToFile(ctx, &initrd.opts, &initrd.digest, initrd.BuildTo)
+}
+
+// BuildTo implements Initrd.
+func (initrd *file) BuildTo(_ context.Context, w io.Writer) error {
+	fi, err := os.Open(initrd.path)
+	if err != nil {
+		return err
+	}
+
+	defer fi.Close()
+
+	if !initrd.opts.compress {
+		_, err = io.Copy(w, fi)
+		return err
+	}
+
+	gw, err := gzip.NewWriterLevel(w, initrd.opts.CompressionLevel())
+	if err != nil {
+		return fmt.Errorf("could not create gzip writer: %w", err)
+	}
+
+	if _, err := io.Copy(gw, fi); err != nil {
+		return err
+	}
+
+	return gw.Close()
 }
 
 // Env implements Initrd.
@@ -69,3 +111,47 @@ func (initrd *file) Env() []string {
 func (initrd *file) Args() []string {
 	return nil
 }
+
+// Labels implements Initrd.
+func (initrd *file) Labels() map[string]string {
+	return nil
+}
+
+// Name implements Initrd.
+func (initrd *file) Name() string {
+	return string(TypeFile)
+}
+
+// Digest implements Initrd.  Since the archive already exists, its digest is
+// computed on first use unless it was already known, e.g. from downloading it.
+func (initrd *file) Digest() (digest.Digest, error) {
+	if initrd.digest != "" {
+		return initrd.digest, nil
+	}
+
+	fi, err := os.Open(initrd.path)
+	if err != nil {
+		return "", err
+	}
+
+	defer fi.Close()
+
+	dgst, err := digest.SHA256.FromReader(fi)
+	if err != nil {
+		return "", fmt.Errorf("could not compute digest: %w", err)
+	}
+
+	initrd.digest = dgst
+
+	return initrd.digest, nil
+}
+
+// WorkingDir implements Initrd.
+func (initrd *file) WorkingDir() string {
+	return ""
+}
+
+// List implements Initrd.
+func (initrd *file) List(ctx context.Context) ([]InitrdEntry, error) {
+	return listArchive(ctx, initrd.path)
+}
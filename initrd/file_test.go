@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+
+	"kraftkit.sh/initrd"
+)
+
+// testArchive builds a CPIO archive from the test root file system and returns
+// its path and contents.
+func testArchive(t *testing.T) (string, []byte) {
+	t.Helper()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "source.cpio")
+
+	dir, err := initrd.NewFromDirectory(ctx, "testdata/rootfs",
+		initrd.WithOutput(path),
+	)
+	if err != nil {
+		t.Fatal("NewFromDirectory:", err)
+	}
+
+	if _, err := dir.Build(ctx); err != nil {
+		t.Fatal("Build:", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal("ReadFile:", err)
+	}
+
+	return path, b
+}
+
+func TestNewFromFileBuild(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   bool
+		compress bool
+	}{
+		{
+			name: "in place",
+		},
+		{
+			name:   "output",
+			output: true,
+		},
+		{
+			name:     "compressed output",
+			output:   true,
+			compress: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			source, content := testArchive(t)
+
+			opts := []initrd.InitrdOption{
+				initrd.WithCompression(tt.compress),
+			}
+
+			expected := source
+			if tt.output {
+				expected = filepath.Join(t.TempDir(), "initramfs.cpio")
+				opts = append(opts, initrd.WithOutput(expected))
+			}
+
+			file, err := initrd.NewFromFile(ctx, source, opts...)
+			if err != nil {
+				t.Fatal("NewFromFile:", err)
+			}
+
+			output, err := file.Build(ctx)
+			if err != nil {
+				t.Fatal("Build:", err)
+			}
+
+			if output != expected {
+				t.Fatalf("expected output '%s', got '%s'", expected, output)
+			}
+
+			built, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal("ReadFile:", err)
+			}
+
+			dgst, err := file.Digest()
+			if err != nil {
+				t.Fatal("Digest:", err)
+			}
+
+			if dgst != digest.FromBytes(built) {
+				t.Errorf("expected digest %s, got %s", digest.FromBytes(built), dgst)
+			}
+
+			if tt.compress {
+				gr, err := gzip.NewReader(bytes.NewReader(built))
+				if err != nil {
+					t.Fatal("expected a gzip-compressed initramfs:", err)
+				}
+
+				built, err = io.ReadAll(gr)
+				if err != nil {
+					t.Fatal("ReadAll:", err)
+				}
+			}
+
+			if !bytes.Equal(built, content) {
+				t.Error("expected the initramfs to have the contents of the source archive")
+			}
+
+			// The source archive is never modified.
+			if b, err := os.ReadFile(source); err != nil || !bytes.Equal(b, content) {
+				t.Errorf("expected the source archive to be left untouched: %v", err)
+			}
+		})
+	}
+}
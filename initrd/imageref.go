@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"runtime"
+
+	sfile "github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	soci "github.com/anchore/stereoscope/pkg/image/oci"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/log"
+	"kraftkit.sh/oci/simpleauth"
+	"kraftkit.sh/version"
+)
+
+// NewFromImageRef accepts a reference to an image in a remote registry, either
+// by tag, e.g. `docker.io/library/nginx:alpine`, or by digest, which is pulled
+// and whose flattened filesystem becomes the CPIO archive.  If the reference
+// points to an index, the image is selected for the architecture set via
+// WithArchitecture, defaulting to that of the host, whereas an image which is
+// referenced directly must match this architecture.
+//
+// Unlike NewFromOCIImage, which relies on containers/image and its own
+// registry credentials, registries are authenticated against using KraftKit's
+// configured credentials and the image is squashed in the same way as other
+// image archives, i.e. honoring whiteouts and hardlinks across layers.
+func NewFromImageRef(ctx context.Context, ref string, opts ...InitrdOption) (Initrd, error) {
+	if _, err := name.ParseReference(ref); err != nil {
+		return nil, fmt.Errorf("invalid image reference: %w", err)
+	}
+
+	return newFromImageArchive(ctx, TypeImageRef, ref, registryProvider, opts...)
+}
+
+// registryProvider pulls the image referenced by source for the platform of
+// the initramfs and returns a stereoscope provider of the pulled image.
+func registryProvider(ctx context.Context, tmpDirGen *sfile.TempDirGenerator, source string, opts InitrdOptions) (image.Provider, error) {
+	ref, err := name.ParseReference(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image reference: %w", err)
+	}
+
+	plat := v1.Platform{
+		OS:           "linux",
+		Architecture: runtime.GOARCH,
+	}
+	if opts.arch != "" {
+		plat.Architecture = opts.ociArchitecture()
+	}
+	if opts.variant != "" {
+		plat.Variant = opts.variant
+	}
+
+	authConfig := &authn.AuthConfig{}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	// Annoyingly convert between regtypes and authn.
+	if auth, ok := config.G[config.KraftKit](ctx).Auth[ref.Context().RegistryStr()]; ok {
+		authConfig.Username = auth.User
+		authConfig.Password = auth.Token
+
+		if !auth.VerifySSL {
+			transport.TLSClientConfig = &tls.Config{
+				InsecureSkipVerify: true,
+			}
+		}
+	}
+
+	log.G(ctx).
+		WithField("ref", ref.Name()).
+		WithField("platform", plat.String()).
+		Debug("pulling image")
+
+	desc, err := remote.Get(ref,
+		remote.WithContext(ctx),
+		remote.WithUserAgent(version.UserAgent()),
+		remote.WithAuth(&simpleauth.SimpleAuthenticator{
+			Auth: authConfig,
+		}),
+		remote.WithTransport(transport),
+		remote.WithPlatform(plat),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not pull image: %w", err)
+	}
+
+	// Indexes are resolved to the manifest which matches the platform, whilst
+	// single manifests are returned as-is.
+	img, err := desc.Image()
+	if err != nil {
+		return nil, fmt.Errorf("image '%s' has no manifest for platform %s: %w", source, plat.String(), err)
+	}
+
+	if !desc.MediaType.IsIndex() {
+		cfg, err := img.ConfigFile()
+		if err != nil {
+			return nil, fmt.Errorf("could not read image configuration: %w", err)
+		}
+
+		if actual := cfg.Platform(); actual == nil || !actual.Satisfies(plat) {
+			return nil, fmt.Errorf("image '%s' is not for platform %s", source, plat.String())
+		}
+	}
+
+	layoutDir, err := tmpDirGen.NewDirectory("image-ref-layout")
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := layout.Write(layoutDir, empty.Index)
+	if err != nil {
+		return nil, fmt.Errorf("could not create image layout: %w", err)
+	}
+
+	if err := path.AppendImage(img); err != nil {
+		return nil, fmt.Errorf("could not write image: %w", err)
+	}
+
+	return soci.NewDirectoryProvider(tmpDirGen, layoutDir), nil
+}
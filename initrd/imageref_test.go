@@ -0,0 +1,304 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cavaliergopher/cpio"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/initrd"
+)
+
+// testLayer returns an image layer with the provided entries, where regular
+// files contain their own name.
+func testLayer(t *testing.T, hdrs ...*tar.Header) v1.Layer {
+	t.Helper()
+
+	var layerTar bytes.Buffer
+	tw := tar.NewWriter(&layerTar)
+	for _, hdr := range hdrs {
+		var content []byte
+		if hdr.Typeflag == tar.TypeReg && !strings.HasPrefix(filepath.Base(hdr.Name), ".wh.") {
+			content = []byte(hdr.Name)
+		}
+
+		hdr.Size = int64(len(content))
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal("WriteHeader:", err)
+		}
+
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal("Write:", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal("Close:", err)
+	}
+
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(layerTar.Bytes())), nil
+	})
+	if err != nil {
+		t.Fatal("LayerFromOpener:", err)
+	}
+
+	return layer
+}
+
+func TestNewFromImageRefWhiteout(t *testing.T) {
+	cfg, err := config.NewDefaultKraftKitConfig()
+	if err != nil {
+		t.Fatal("NewDefaultKraftKitConfig:", err)
+	}
+
+	cfgm, err := config.NewConfigManager(cfg)
+	if err != nil {
+		t.Fatal("NewConfigManager:", err)
+	}
+
+	ctx := config.WithConfigManager(context.Background(), cfgm)
+
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+
+	// The upper layer deletes a file of the lower layer via a whiteout.
+	img, err := mutate.AppendLayers(empty.Image,
+		testLayer(t,
+			&tar.Header{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0o755},
+			&tar.Header{Name: "etc/keep", Typeflag: tar.TypeReg, Mode: 0o644},
+			&tar.Header{Name: "etc/remove", Typeflag: tar.TypeReg, Mode: 0o644},
+		),
+		testLayer(t,
+			&tar.Header{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0o755},
+			&tar.Header{Name: "etc/.wh.remove", Typeflag: tar.TypeReg, Mode: 0o644},
+		),
+	)
+	if err != nil {
+		t.Fatal("AppendLayers:", err)
+	}
+
+	index := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add: img,
+		Descriptor: v1.Descriptor{
+			Platform: &v1.Platform{OS: "linux", Architecture: "amd64"},
+		},
+	})
+
+	ref := strings.TrimPrefix(server.URL, "http://") + "/test:latest"
+
+	tag, err := name.NewTag(ref)
+	if err != nil {
+		t.Fatal("NewTag:", err)
+	}
+
+	if err := remote.WriteIndex(tag, index); err != nil {
+		t.Fatal("WriteIndex:", err)
+	}
+
+	ird, err := initrd.NewFromImageRef(ctx, ref,
+		initrd.WithArchitecture("x86_64"),
+		initrd.WithOutput(filepath.Join(t.TempDir(), "initramfs.cpio")),
+	)
+	if err != nil {
+		t.Fatal("NewFromImageRef:", err)
+	}
+
+	irdPath, err := ird.Build(ctx)
+	if err != nil {
+		t.Fatal("Build:", err)
+	}
+
+	r := cpio.NewReader(openFile(t, irdPath))
+
+	expectModes := map[string]cpio.FileMode{
+		"/etc":      cpio.TypeDir,
+		"/etc/keep": cpio.TypeReg,
+	}
+
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Failed to read next cpio header:", err)
+		}
+
+		expectMode, ok := expectModes[hdr.Name]
+		if !ok {
+			t.Error("Encountered unexpected file in cpio archive:", hdr.Name)
+			continue
+		}
+
+		if gotMode := hdr.Mode & cpio.ModeType; gotMode != expectMode {
+			t.Errorf("file [%s]: got mode %s, expected %s", hdr.Name, gotMode, expectMode)
+		}
+
+		delete(expectModes, hdr.Name)
+	}
+
+	for name := range expectModes {
+		t.Error("Missing file in cpio archive:", name)
+	}
+}
+
+func TestNewFromImageRefManifest(t *testing.T) {
+	cfg, err := config.NewDefaultKraftKitConfig()
+	if err != nil {
+		t.Fatal("NewDefaultKraftKitConfig:", err)
+	}
+
+	cfgm, err := config.NewConfigManager(cfg)
+	if err != nil {
+		t.Fatal("NewConfigManager:", err)
+	}
+
+	ctx := config.WithConfigManager(context.Background(), cfgm)
+
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+
+	repo := strings.TrimPrefix(server.URL, "http://") + "/test"
+
+	platformImage := func(arch string) v1.Image {
+		img, err := mutate.AppendLayers(empty.Image,
+			testLayer(t,
+				&tar.Header{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0o755},
+				&tar.Header{Name: "etc/keep", Typeflag: tar.TypeReg, Mode: 0o644},
+			),
+		)
+		if err != nil {
+			t.Fatal("AppendLayers:", err)
+		}
+
+		img, err = mutate.ConfigFile(img, &v1.ConfigFile{
+			OS:           "linux",
+			Architecture: arch,
+		})
+		if err != nil {
+			t.Fatal("ConfigFile:", err)
+		}
+
+		return img
+	}
+
+	amd64 := platformImage("amd64")
+	arm64 := platformImage("arm64")
+
+	index := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add: amd64,
+		Descriptor: v1.Descriptor{
+			Platform: &v1.Platform{OS: "linux", Architecture: "amd64"},
+		},
+	})
+
+	indexDigest, err := index.Digest()
+	if err != nil {
+		t.Fatal("Digest:", err)
+	}
+
+	amd64Digest, err := amd64.Digest()
+	if err != nil {
+		t.Fatal("Digest:", err)
+	}
+
+	for tag, write := range map[string]func(name.Tag) error{
+		"index": func(tag name.Tag) error { return remote.WriteIndex(tag, index) },
+		"amd64": func(tag name.Tag) error { return remote.Write(tag, amd64) },
+		"arm64": func(tag name.Tag) error { return remote.Write(tag, arm64) },
+	} {
+		ref, err := name.NewTag(repo + ":" + tag)
+		if err != nil {
+			t.Fatal("NewTag:", err)
+		}
+
+		if err := write(ref); err != nil {
+			t.Fatal("Write:", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		ref     string
+		wantErr bool
+	}{
+		{
+			name: "index by digest",
+			ref:  repo + "@" + indexDigest.String(),
+		},
+		{
+			name: "manifest by tag",
+			ref:  repo + ":amd64",
+		},
+		{
+			name: "manifest by digest",
+			ref:  repo + "@" + amd64Digest.String(),
+		},
+		{
+			name:    "manifest for another platform",
+			ref:     repo + ":arm64",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ird, err := initrd.NewFromImageRef(ctx, tt.ref,
+				initrd.WithArchitecture("x86_64"),
+				initrd.WithOutput(filepath.Join(t.TempDir(), "initramfs.cpio")),
+			)
+			if err != nil {
+				t.Fatal("NewFromImageRef:", err)
+			}
+
+			irdPath, err := ird.Build(ctx)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an image for another platform to be rejected")
+				}
+				return
+			} else if err != nil {
+				t.Fatal("Build:", err)
+			}
+
+			r := cpio.NewReader(openFile(t, irdPath))
+
+			found := false
+			for {
+				hdr, err := r.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal("Failed to read next cpio header:", err)
+				}
+
+				if hdr.Name == "/etc/keep" {
+					found = true
+				}
+			}
+
+			if !found {
+				t.Error("Missing file in cpio archive: /etc/keep")
+			}
+		})
+	}
+}
@@ -4,7 +4,12 @@
 // You may not use this file except in compliance with the License.
 package initrd
 
-import "context"
+import (
+	"context"
+	"io"
+
+	"github.com/opencontainers/go-digest"
+)
 
 const (
 	// DefaultInitramfsFileName is the default filename used when creating or
@@ -19,12 +24,35 @@ const (
 // Initrd is an interface that is used to allow for different underlying
 // implementations to construct a CPIO archive.
 type Initrd interface {
+	// Name returns the kind of input from which the initramfs is built, e.g.
+	// "dockerfile", such that its source can be identified.
+	Name() string
+
 	// Build the rootfs and return the location of the result or error.
 	Build(context.Context) (string, error)
 
+	// BuildTo builds the rootfs and writes the resulting CPIO archive,
+	// compressed if requested, directly to the provided writer rather than to
+	// a file.
+	BuildTo(context.Context, io.Writer) error
+
+	// Digest returns the SHA256 digest of the built initramfs, e.g. for pinning
+	// it in a manifest or comparing it across builds.
+	Digest() (digest.Digest, error)
+
 	// All environment variables that are set within.
 	Env() []string
 
 	// All arguments that are passed to the initramfs.
 	Args() []string
+
+	// All labels that are set within, e.g. via a Dockerfile's LABEL instruction.
+	Labels() map[string]string
+
+	// The working directory of the entrypoint, if one is set.
+	WorkingDir() string
+
+	// List returns the entries of the built initramfs, e.g. to inspect its
+	// contents without having to boot it.
+	List(context.Context) ([]InitrdEntry, error)
 }
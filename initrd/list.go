@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/cavaliergopher/cpio"
+)
+
+// InitrdEntryType is the type of an entry of a CPIO archive.
+type InitrdEntryType string
+
+const (
+	InitrdEntryTypeRegular   = InitrdEntryType("reg")
+	InitrdEntryTypeDirectory = InitrdEntryType("dir")
+	InitrdEntryTypeSymlink   = InitrdEntryType("symlink")
+	InitrdEntryTypeChar      = InitrdEntryType("char")
+	InitrdEntryTypeBlock     = InitrdEntryType("block")
+	InitrdEntryTypeFifo      = InitrdEntryType("fifo")
+	InitrdEntryTypeSocket    = InitrdEntryType("socket")
+)
+
+// InitrdEntry describes a single entry of a CPIO archive.
+type InitrdEntry struct {
+	// Path is the absolute location of the entry within the archive.
+	Path string
+
+	// Mode contains the permission bits of the entry.
+	Mode fs.FileMode
+
+	// Size is the size of the contents of the entry in bytes.
+	Size int64
+
+	// Type is the type of the entry.
+	Type InitrdEntryType
+
+	// Linkname is the target of the entry if it is a symbolic link.
+	Linkname string
+}
+
+// walkArchive calls fn for each header of the CPIO archive, which may be
+// gzip-compressed, at the provided path.
+func walkArchive(file string, fn func(*cpio.Header) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("could not open initramfs: %w", err)
+	}
+
+	defer f.Close()
+
+	var reader io.Reader = bufio.NewReader(f)
+
+	// Transparently handle compressed archives.
+	magic, err := reader.(*bufio.Reader).Peek(2)
+	if err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gr, err := gzip.NewReader(reader)
+		if err != nil {
+			return fmt.Errorf("could not decompress initramfs: %w", err)
+		}
+
+		defer gr.Close()
+		reader = gr
+	}
+
+	cr := cpio.NewReader(reader)
+
+	for {
+		header, err := cr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("could not read initramfs: %w", err)
+		}
+
+		if err := fn(header); err != nil {
+			return err
+		}
+	}
+}
+
+// listArchive returns the entries of the CPIO archive, which may be
+// gzip-compressed, at the provided path in the order in which they appear.
+func listArchive(_ context.Context, file string) ([]InitrdEntry, error) {
+	var entries []InitrdEntry
+
+	if err := walkArchive(file, func(header *cpio.Header) error {
+		entry := InitrdEntry{
+			Path:     path.Clean("/" + strings.TrimPrefix(header.Name, "./")),
+			Mode:     header.FileInfo().Mode().Perm(),
+			Size:     header.Size,
+			Linkname: header.Linkname,
+		}
+
+		switch header.Mode & cpio.ModeType {
+		case cpio.TypeDir:
+			entry.Type = InitrdEntryTypeDirectory
+		case cpio.TypeSymlink:
+			entry.Type = InitrdEntryTypeSymlink
+		case cpio.TypeChar:
+			entry.Type = InitrdEntryTypeChar
+		case cpio.TypeBlock:
+			entry.Type = InitrdEntryTypeBlock
+		case cpio.TypeFifo:
+			entry.Type = InitrdEntryTypeFifo
+		case cpio.TypeSocket:
+			entry.Type = InitrdEntryTypeSocket
+		default:
+			entry.Type = InitrdEntryTypeRegular
+		}
+
+		entries = append(entries, entry)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"kraftkit.sh/initrd"
+)
+
+func TestNewFromFileList(t *testing.T) {
+	ctx := context.Background()
+	output := filepath.Join(t.TempDir(), "initramfs.cpio")
+
+	dir, err := initrd.NewFromDirectory(ctx, "testdata/rootfs",
+		initrd.WithOutput(output),
+	)
+	if err != nil {
+		t.Fatal("NewFromDirectory:", err)
+	}
+
+	if _, err := dir.Build(ctx); err != nil {
+		t.Fatal("Build:", err)
+	}
+
+	file, err := initrd.NewFromFile(ctx, output)
+	if err != nil {
+		t.Fatal("NewFromFile:", err)
+	}
+
+	entries, err := file.List(ctx)
+	if err != nil {
+		t.Fatal("List:", err)
+	}
+
+	expect := map[string]initrd.InitrdEntry{
+		"/entrypoint.sh":        {Type: initrd.InitrdEntryTypeRegular, Size: 25},
+		"/etc":                  {Type: initrd.InitrdEntryTypeDirectory},
+		"/etc/app.conf":         {Type: initrd.InitrdEntryTypeRegular, Size: 16},
+		"/lib":                  {Type: initrd.InitrdEntryTypeDirectory},
+		"/lib/libtest.so.1":     {Type: initrd.InitrdEntryTypeSymlink, Linkname: "libtest.so.1.0.0"},
+		"/lib/libtest.so.1.0.0": {Type: initrd.InitrdEntryTypeRegular, Size: 9},
+	}
+
+	if len(entries) != len(expect) {
+		t.Errorf("expected %d entries, got %d: %v", len(expect), len(entries), entries)
+	}
+
+	for _, entry := range entries {
+		want, ok := expect[entry.Path]
+		if !ok {
+			t.Errorf("unexpected entry: %s", entry.Path)
+			continue
+		}
+
+		if entry.Type != want.Type || entry.Size != want.Size || entry.Linkname != want.Linkname {
+			t.Errorf("%s: expected %+v, got %+v", entry.Path, want, entry)
+		}
+	}
+}
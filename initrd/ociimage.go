@@ -10,15 +10,12 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"kraftkit.sh/log"
 
 	"github.com/anchore/stereoscope"
-	scfile "github.com/anchore/stereoscope/pkg/file"
-	"github.com/anchore/stereoscope/pkg/filetree"
-	"github.com/anchore/stereoscope/pkg/filetree/filenode"
-	"github.com/cavaliergopher/cpio"
 	"github.com/containers/image/v5/copy"
 	ociarchive "github.com/containers/image/v5/oci/archive"
 	"github.com/containers/image/v5/signature"
@@ -26,6 +23,7 @@ import (
 	"github.com/containers/image/v5/types"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/opencontainers/go-digest"
 )
 
 type ociimage struct {
@@ -34,6 +32,9 @@ type ociimage struct {
 	args      []string
 	ref       types.ImageReference
 	env       []string
+	labels    map[string]string
+	workdir   string
+	digest    digest.Digest
 }
 
 // NewFromOCIImage creates a new initrd from a remote container image.
@@ -79,14 +80,18 @@ func NewFromOCIImage(ctx context.Context, path string, opts ...InitrdOption) (In
 
 // Build implements Initrd.
 func (initrd *ociimage) Build(ctx context.Context) (string, error) {
+	return buildToFile(ctx, &initrd.opts, &initrd.digest, initrd.BuildTo)
+}
+
+// BuildTo implements Initrd.
+func (initrd *ociimage) BuildTo(ctx context.Context, w io.Writer) error {
 	sysCtx := &types.SystemContext{
 		OSChoice: "linux",
 	}
 
-	if initrd.opts.arch == "x86_64" {
-		sysCtx.ArchitectureChoice = "amd64"
-	} else if initrd.opts.arch != "" {
-		sysCtx.ArchitectureChoice = initrd.opts.arch
+	if initrd.opts.arch != "" {
+		sysCtx.ArchitectureChoice = initrd.opts.ociArchitecture()
+		sysCtx.VariantChoice = initrd.opts.variant
 	}
 
 	policy := &signature.Policy{
@@ -97,7 +102,7 @@ func (initrd *ociimage) Build(ctx context.Context) (string, error) {
 
 	policyCtx, err := signature.NewPolicyContext(policy)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate default policy context: %w", err)
+		return fmt.Errorf("failed to generate default policy context: %w", err)
 	}
 
 	defer func() {
@@ -106,7 +111,7 @@ func (initrd *ociimage) Build(ctx context.Context) (string, error) {
 
 	img, err := initrd.ref.NewImage(ctx, sysCtx)
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	defer func() {
@@ -115,27 +120,27 @@ func (initrd *ociimage) Build(ctx context.Context) (string, error) {
 
 	ociImage, err := img.OCIConfig(ctx)
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	if initrd.opts.variant != "" && ociImage.Variant != initrd.opts.variant {
+		log.G(ctx).
+			WithField("expected", initrd.opts.variant).
+			WithField("actual", ociImage.Variant).
+			Warn("image architecture variant does not match")
 	}
 
-	initrd.args = append(ociImage.Config.Entrypoint,
+	initrd.args = append(slices.Clone(ociImage.Config.Entrypoint),
 		ociImage.Config.Cmd...,
 	)
 	initrd.env = ociImage.Config.Env
-
-	if initrd.opts.output == "" {
-		fi, err := os.CreateTemp("", "")
-		if err != nil {
-			return "", err
-		}
-
-		initrd.opts.output = fi.Name()
-	}
+	initrd.labels = ociImage.Config.Labels
+	initrd.workdir = ociImage.Config.WorkingDir
 
 	// Create a temporary directory to output the image to
 	outputDir, err := os.MkdirTemp("", "")
 	if err != nil {
-		return "", fmt.Errorf("could not make temporary directory: %w", err)
+		return fmt.Errorf("could not make temporary directory: %w", err)
 	}
 
 	defer func() {
@@ -146,7 +151,7 @@ func (initrd *ociimage) Build(ctx context.Context) (string, error) {
 
 	dest, err := ociarchive.NewReference(ociTarballFile, "")
 	if err != nil {
-		return "", fmt.Errorf("invalid destination name %s: %v", dest, err)
+		return fmt.Errorf("invalid destination name %s: %v", dest, err)
 	}
 
 	opts := copy.Options{
@@ -160,170 +165,24 @@ func (initrd *ociimage) Build(ctx context.Context) (string, error) {
 		Debug("pulling")
 
 	if _, err = copy.Image(ctx, policyCtx, dest, initrd.ref, &opts); err != nil {
-		return "", fmt.Errorf("failed to copy image: %w", err)
+		return fmt.Errorf("failed to copy image: %w", err)
 	}
 
 	image, err := stereoscope.GetImage(ctx, ociTarballFile)
 	if err != nil {
-		return "", fmt.Errorf("could not load image: %w", err)
+		return fmt.Errorf("could not load image: %w", err)
 	}
 
-	if err := os.MkdirAll(filepath.Dir(initrd.opts.output), 0o755); err != nil {
-		return "", fmt.Errorf("could not create output directory: %w", err)
-	}
-
-	f, err := os.OpenFile(initrd.opts.output, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	cpioWriter, closeWriter, err := newCPIOWriter(w, initrd.opts)
 	if err != nil {
-		return "", fmt.Errorf("could not open initramfs file: %w", err)
-	}
-
-	defer func() {
-		_ = f.Close()
-	}()
-
-	cpioWriter := cpio.NewWriter(f)
-
-	defer func() {
-		_ = cpioWriter.Close()
-	}()
-
-	if err := image.SquashedTree().Walk(func(path scfile.Path, f filenode.FileNode) error {
-		if f.Reference == nil {
-			log.G(ctx).
-				WithField("path", path).
-				Debug("skipping: no reference")
-			return nil
-		}
-
-		info, err := image.FileCatalog.Get(*f.Reference)
-		if err != nil {
-			return err
-		}
-
-		internal := filepath.Clean(fmt.Sprintf("/%s", path))
-
-		cpioHeader := &cpio.Header{
-			Name:    internal,
-			Mode:    cpio.FileMode(info.Mode().Perm()),
-			ModTime: info.ModTime(),
-			Size:    info.Size(),
-		}
-
-		// Populate platform specific information
-		populateCPIO(info, cpioHeader)
-
-		switch f.FileType {
-		case scfile.TypeBlockDevice:
-			log.G(ctx).
-				WithField("file", path).
-				Warn("ignoring block devices")
-			return nil
-
-		case scfile.TypeCharacterDevice:
-			log.G(ctx).
-				WithField("file", path).
-				Warn("ignoring char devices")
-			return nil
-
-		case scfile.TypeFIFO:
-			log.G(ctx).
-				WithField("file", path).
-				Warn("ignoring fifo files")
-			return nil
-
-		case scfile.TypeSymLink:
-			log.G(ctx).
-				WithField("src", path).
-				WithField("link", info.LinkDestination).
-				Debug("symlinking")
-
-			cpioHeader.Mode |= cpio.TypeSymlink
-			cpioHeader.Linkname = info.LinkDestination
-			cpioHeader.Size = int64(len(info.LinkDestination))
-
-			if err := cpioWriter.WriteHeader(cpioHeader); err != nil {
-				return fmt.Errorf("could not write CPIO header: %w", err)
-			}
-
-			if _, err := cpioWriter.Write([]byte(info.LinkDestination)); err != nil {
-				return fmt.Errorf("could not write CPIO data for %s: %w", internal, err)
-			}
-
-		case scfile.TypeHardLink:
-			log.G(ctx).
-				WithField("src", path).
-				WithField("link", info.LinkDestination).
-				Debug("hardlinking")
-
-			cpioHeader.Mode |= cpio.TypeReg
-			cpioHeader.Linkname = info.LinkDestination
-			cpioHeader.Size = 0
-
-			if err := cpioWriter.WriteHeader(cpioHeader); err != nil {
-				return fmt.Errorf("could not write CPIO header: %w", err)
-			}
-
-		case scfile.TypeRegular:
-			log.G(ctx).
-				WithField("src", path).
-				WithField("dst", internal).
-				Debug("copying")
-
-			cpioHeader.Mode |= cpio.TypeReg
-			cpioHeader.Linkname = info.LinkDestination
-			cpioHeader.Size = info.Size()
-
-			if err := cpioWriter.WriteHeader(cpioHeader); err != nil {
-				return fmt.Errorf("could not write CPIO header: %w", err)
-			}
-
-			reader, err := image.OpenPathFromSquash(path)
-			if err != nil {
-				return fmt.Errorf("could not open file: %w", err)
-			}
-
-			data, err := io.ReadAll(reader)
-			if err != nil {
-				return fmt.Errorf("could not read file: %w", err)
-			}
-
-			if _, err := cpioWriter.Write(data); err != nil {
-				return fmt.Errorf("could not write CPIO data for %s: %w", internal, err)
-			}
-
-		case scfile.TypeDirectory:
-			log.G(ctx).
-				WithField("dst", internal).
-				Debug("mkdir")
-
-			cpioHeader.Mode |= cpio.TypeDir
-
-			return cpioWriter.WriteHeader(cpioHeader)
-
-		default:
-			log.G(ctx).
-				WithField("file", path).
-				WithField("type", f.FileType.String()).
-				Warn("unsupported file type")
-		}
-
-		return nil
-	}, &filetree.WalkConditions{
-		LinkOptions: []filetree.LinkResolutionOption{},
-		ShouldContinueBranch: func(path scfile.Path, f filenode.FileNode) bool {
-			return f.LinkPath == ""
-		},
-	}); err != nil {
-		return "", fmt.Errorf("could not walk image: %w", err)
+		return err
 	}
 
-	if initrd.opts.compress {
-		if err := compressFiles(initrd.opts.output, cpioWriter, f); err != nil {
-			return "", fmt.Errorf("could not compress files: %w", err)
-		}
+	if err := writeSquashedTree(ctx, cpioWriter, image); err != nil {
+		return err
 	}
 
-	return initrd.opts.output, nil
+	return closeWriter()
 }
 
 // Env implements Initrd.
@@ -335,3 +194,36 @@ func (initrd *ociimage) Env() []string {
 func (initrd *ociimage) Args() []string {
 	return initrd.args
 }
+
+// Labels implements Initrd.
+func (initrd *ociimage) Labels() map[string]string {
+	return initrd.labels
+}
+
+// Name implements Initrd.
+func (initrd *ociimage) Name() string {
+	return string(TypeOCIImage)
+}
+
+// Digest implements Initrd.
+func (initrd *ociimage) Digest() (digest.Digest, error) {
+	if initrd.digest == "" {
+		return "", fmt.Errorf("initramfs must be built before its digest is known")
+	}
+
+	return initrd.digest, nil
+}
+
+// WorkingDir implements Initrd.
+func (initrd *ociimage) WorkingDir() string {
+	return initrd.workdir
+}
+
+// List implements Initrd.
+func (initrd *ociimage) List(ctx context.Context) ([]InitrdEntry, error) {
+	if initrd.opts.output == "" {
+		return nil, fmt.Errorf("initramfs must be built before it can be listed")
+	}
+
+	return listArchive(ctx, initrd.opts.output)
+}
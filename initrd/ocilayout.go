@@ -0,0 +1,201 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	sfile "github.com/anchore/stereoscope/pkg/file"
+	soci "github.com/anchore/stereoscope/pkg/image/oci"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"kraftkit.sh/log"
+)
+
+// ociArchiveReadAttempts is the number of times the OCI archive exported by
+// BuildKit is read via stereoscope before falling back to reading its image
+// configuration directly.
+const ociArchiveReadAttempts = 3
+
+// mediaTypeDockerManifestList is the media type of a Docker manifest list,
+// the equivalent of an OCI image index.
+const mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+// readOCIArchiveConfig returns the image configuration of the OCI archive at
+// the provided path.  The archive is read via stereoscope, which is retried
+// since it has been observed to fail intermittently on large images.  If it
+// keeps failing, the configuration is read directly from the OCI layout of
+// the archive.
+func readOCIArchiveConfig(ctx context.Context, path string) (*ocispec.ImageConfig, error) {
+	var errs []error
+
+	for attempt := 1; attempt <= ociArchiveReadAttempts; attempt++ {
+		config, err := readOCIArchiveConfigStereoscope(ctx, path)
+		if err == nil {
+			return config, nil
+		}
+
+		log.G(ctx).
+			WithError(err).
+			WithField("attempt", attempt).
+			Debug("could not read image")
+
+		errs = append(errs, err)
+	}
+
+	log.G(ctx).Warn("could not read image via stereoscope, reading its configuration directly")
+
+	config, err := readOCILayoutConfig(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read image: %w", errors.Join(append(errs, err)...))
+	}
+
+	return config, nil
+}
+
+// readOCIArchiveConfigStereoscope returns the image configuration of the OCI
+// archive at the provided path as read by stereoscope.
+func readOCIArchiveConfigStereoscope(ctx context.Context, path string) (*ocispec.ImageConfig, error) {
+	cleanupCtx := context.WithoutCancel(ctx)
+
+	tempgen := sfile.NewTempDirGenerator("kraftkit")
+	if tempgen == nil {
+		return nil, fmt.Errorf("could not create temp dir generator")
+	}
+
+	defer func() {
+		if err := tempgen.Cleanup(); err != nil {
+			log.G(cleanupCtx).
+				WithError(err).
+				Debug("cleaning up temp dir generator")
+		}
+	}()
+
+	provider := soci.NewArchiveProvider(tempgen, path)
+	if provider == nil {
+		return nil, fmt.Errorf("could not create image provider")
+	}
+
+	img, err := provider.Provide(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not provide image: %w", err)
+	}
+
+	defer func() {
+		if err := img.Cleanup(); err != nil {
+			log.G(cleanupCtx).
+				WithError(err).
+				Debug("cleaning up image")
+		}
+	}()
+
+	if err := img.Read(); err != nil {
+		return nil, fmt.Errorf("could not read image: %w", err)
+	}
+
+	config := img.Metadata.Config.Config
+
+	return &ocispec.ImageConfig{
+		Entrypoint: config.Entrypoint,
+		Cmd:        config.Cmd,
+		Env:        config.Env,
+		Labels:     config.Labels,
+		WorkingDir: config.WorkingDir,
+	}, nil
+}
+
+// readOCILayoutConfig returns the image configuration of the OCI archive at
+// the provided path by following its `index.json` to the manifest of the
+// image and then to its configuration.  Nested indexes are followed and
+// attestation manifests, whose platform is unknown, are skipped.
+func readOCILayoutConfig(path string) (*ocispec.ImageConfig, error) {
+	var index ocispec.Index
+	if err := readOCILayoutJSON(path, ocispec.ImageIndexFile, &index); err != nil {
+		return nil, err
+	}
+
+	for {
+		var desc *ocispec.Descriptor
+		for i, manifest := range index.Manifests {
+			if manifest.Platform != nil && manifest.Platform.OS == "unknown" {
+				continue
+			}
+
+			desc = &index.Manifests[i]
+			break
+		}
+
+		if desc == nil {
+			return nil, fmt.Errorf("could not find image manifest in %s", ocispec.ImageIndexFile)
+		}
+
+		switch desc.MediaType {
+		case ocispec.MediaTypeImageIndex, mediaTypeDockerManifestList:
+			index = ocispec.Index{}
+			if err := readOCILayoutJSON(path, ociLayoutBlobPath(desc.Digest), &index); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		var manifest ocispec.Manifest
+		if err := readOCILayoutJSON(path, ociLayoutBlobPath(desc.Digest), &manifest); err != nil {
+			return nil, err
+		}
+
+		var image ocispec.Image
+		if err := readOCILayoutJSON(path, ociLayoutBlobPath(manifest.Config.Digest), &image); err != nil {
+			return nil, err
+		}
+
+		return &image.Config, nil
+	}
+}
+
+// ociLayoutBlobPath returns the location of the blob with the provided digest
+// within an OCI layout.
+func ociLayoutBlobPath(dgst digest.Digest) string {
+	return ocispec.ImageBlobsDir + "/" + dgst.Algorithm().String() + "/" + dgst.Encoded()
+}
+
+// readOCILayoutJSON decodes the file with the provided name of the OCI archive
+// at the provided path into v.
+func readOCILayoutJSON(path, name string, v any) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open OCI archive: %w", err)
+	}
+
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("could not find %s in OCI archive", name)
+		} else if err != nil {
+			return fmt.Errorf("could not read OCI archive: %w", err)
+		}
+
+		if header.Name != name && header.Name != "./"+name {
+			continue
+		}
+
+		if err := json.NewDecoder(tr).Decode(v); err != nil {
+			return fmt.Errorf("could not decode %s: %w", name, err)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestReadOCILayoutConfig(t *testing.T) {
+	files := map[string][]byte{}
+
+	blob := func(mediaType string, v any) ocispec.Descriptor {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		desc := ocispec.Descriptor{
+			MediaType: mediaType,
+			Digest:    digest.FromBytes(data),
+			Size:      int64(len(data)),
+		}
+
+		files[ociLayoutBlobPath(desc.Digest)] = data
+
+		return desc
+	}
+
+	config := blob(ocispec.MediaTypeImageConfig, ocispec.Image{
+		Config: ocispec.ImageConfig{
+			Entrypoint: []string{"/bin/app"},
+			Cmd:        []string{"--serve"},
+			Env:        []string{"PATH=/bin"},
+			WorkingDir: "/srv",
+		},
+	})
+
+	manifest := blob(ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+	})
+	manifest.Platform = &ocispec.Platform{OS: "linux", Architecture: "amd64"}
+
+	attestation := blob(ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+	})
+	attestation.Platform = &ocispec.Platform{OS: "unknown", Architecture: "unknown"}
+
+	nested := blob(ocispec.MediaTypeImageIndex, ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{attestation, manifest},
+	})
+
+	index, err := json.Marshal(ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{nested},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files[ocispec.ImageIndexFile] = index
+
+	path := filepath.Join(t.TempDir(), "oci.tar")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tw := tar.NewWriter(f)
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0o644,
+			Size:     int64(len(data)),
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readOCILayoutConfig(path)
+	if err != nil {
+		t.Fatal("readOCILayoutConfig:", err)
+	}
+
+	if !slices.Equal(got.Entrypoint, []string{"/bin/app"}) ||
+		!slices.Equal(got.Cmd, []string{"--serve"}) ||
+		!slices.Equal(got.Env, []string{"PATH=/bin"}) ||
+		got.WorkingDir != "/srv" {
+		t.Errorf("unexpected image configuration: %+v", got)
+	}
+}
@@ -4,12 +4,30 @@
 // You may not use this file except in compliance with the License.
 package initrd
 
+import (
+	"compress/gzip"
+	"fmt"
+
+	"github.com/opencontainers/go-digest"
+)
+
 type InitrdOptions struct {
-	compress bool
-	output   string
-	cacheDir string
-	arch     string
-	workdir  string
+	compress         bool
+	compressionLevel *int
+	output           string
+	cacheDir         string
+	remoteCache      string
+	noCache          bool
+	keepBuilder      bool
+	exporter         BuildKitExporter
+	networkMode      BuildKitNetworkMode
+	builderNetwork   string
+	unprivileged     bool
+	arch             string
+	variant          string
+	workdir          string
+	expectedDigest   digest.Digest
+	typ              Type
 }
 
 type InitrdOption func(*InitrdOptions) error
@@ -22,6 +40,30 @@ func WithCompression(compress bool) InitrdOption {
 	}
 }
 
+// WithCompressionLevel sets the level used when compressing the resulting CPIO
+// archive file, trading CPU time for size.  The level must be within the range
+// supported by gzip, i.e. between gzip.HuffmanOnly and gzip.BestCompression.
+func WithCompressionLevel(level int) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+			return fmt.Errorf("invalid gzip compression level %d: must be between %d and %d", level, gzip.HuffmanOnly, gzip.BestCompression)
+		}
+
+		opts.compressionLevel = &level
+		return nil
+	}
+}
+
+// CompressionLevel returns the level used when compressing the resulting CPIO
+// archive file.
+func (opts InitrdOptions) CompressionLevel() int {
+	if opts.compressionLevel == nil {
+		return gzip.DefaultCompression
+	}
+
+	return *opts.compressionLevel
+}
+
 // WithOutput sets the location of the output location of the resulting CPIO
 // archive file.
 func WithOutput(output string) InitrdOption {
@@ -41,6 +83,155 @@ func WithCacheDir(dir string) InitrdOption {
 	}
 }
 
+// WithRemoteCache sets the reference of a registry-backed cache which is both
+// imported from and exported to when the initramfs is built, e.g. via
+// BuildKit.  This allows sharing the build cache between hosts.
+func WithRemoteCache(ref string) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		opts.remoteCache = ref
+		return nil
+	}
+}
+
+// WithNoCache forces the initramfs to be rebuilt without re-using any cached
+// intermediate artifacts.  Any cache located in the directory set via
+// WithCacheDir is cleared before being repopulated by the build.
+func WithNoCache(noCache bool) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		opts.noCache = noCache
+		return nil
+	}
+}
+
+// WithKeepBuilder keeps the ephemeral BuildKit container, which is created
+// when no BuildKit daemon can be found, alive if the build fails such that it
+// can be inspected.  Its container ID and address are logged.  The container
+// is always terminated when the build succeeds.
+func WithKeepBuilder(keep bool) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		opts.keepBuilder = keep
+		return nil
+	}
+}
+
+// BuildKitExporter selects the BuildKit exporters which are used when building
+// an initramfs from a Dockerfile.
+type BuildKitExporter string
+
+const (
+	// BuildKitExporterTar only exports the filesystem of the image as a tarball.
+	// This is the fastest but the configuration of the image, e.g. its
+	// entrypoint, environment and labels, is not available.
+	BuildKitExporterTar = BuildKitExporter("tar")
+
+	// BuildKitExporterOCI only exports the image as an OCI archive, whose
+	// layers are flattened into the initramfs and whose configuration is read.
+	BuildKitExporterOCI = BuildKitExporter("oci")
+
+	// BuildKitExporterBoth exports the filesystem of the image as a tarball and
+	// the image as an OCI archive which is only read for its configuration.
+	// This is the default.
+	BuildKitExporterBoth = BuildKitExporter("both")
+)
+
+// WithBuildKitExporter sets the BuildKit exporters which are used when building
+// an initramfs from a Dockerfile.
+func WithBuildKitExporter(exporter BuildKitExporter) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		switch exporter {
+		case BuildKitExporterTar, BuildKitExporterOCI, BuildKitExporterBoth:
+		default:
+			return fmt.Errorf("unknown buildkit exporter '%s': must be one of %s, %s or %s", exporter, BuildKitExporterTar, BuildKitExporterOCI, BuildKitExporterBoth)
+		}
+
+		opts.exporter = exporter
+		return nil
+	}
+}
+
+// BuildKitNetworkMode is the network mode of the RUN instructions of a
+// Dockerfile which is built into an initramfs.
+type BuildKitNetworkMode string
+
+const (
+	// BuildKitNetworkModeDefault runs each RUN instruction in its own network
+	// namespace with access to the network of the BuildKit daemon.  This is the
+	// default.
+	BuildKitNetworkModeDefault = BuildKitNetworkMode("default")
+
+	// BuildKitNetworkModeNone runs each RUN instruction without any network
+	// access, isolating the build.  Instructions which require the network,
+	// e.g. installing packages via `apt-get`, `apk` or `pip`, downloading files
+	// via `curl` or `wget`, or cloning a repository via `git`, fail in this
+	// mode.  `ADD` of a remote URL and pulling base images are performed by the
+	// BuildKit daemon itself and are not affected.
+	BuildKitNetworkModeNone = BuildKitNetworkMode("none")
+
+	// BuildKitNetworkModeHost runs each RUN instruction in the network namespace
+	// of the BuildKit daemon.  This requires the daemon to allow the
+	// `network.host` entitlement, which the ephemeral BuildKit container does.
+	BuildKitNetworkModeHost = BuildKitNetworkMode("host")
+)
+
+// WithNetworkMode sets the network mode of the RUN instructions when building
+// an initramfs from a Dockerfile.
+func WithNetworkMode(mode BuildKitNetworkMode) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		switch mode {
+		case BuildKitNetworkModeDefault, BuildKitNetworkModeNone, BuildKitNetworkModeHost:
+		default:
+			return fmt.Errorf("unknown network mode '%s': must be one of %s, %s or %s", mode, BuildKitNetworkModeDefault, BuildKitNetworkModeNone, BuildKitNetworkModeHost)
+		}
+
+		opts.networkMode = mode
+		return nil
+	}
+}
+
+// WithBuilderNetwork sets the Docker network to which the ephemeral BuildKit
+// container, which is created when no BuildKit daemon can be found, is
+// attached.  The container must remain reachable in order to perform the
+// build, hence the "none" network is rejected: use WithNetworkMode to isolate
+// the build itself instead.
+func WithBuilderNetwork(network string) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		if network == "none" {
+			return fmt.Errorf("the buildkit container cannot be attached to the 'none' network: use the 'none' network mode to isolate the build instead")
+		}
+
+		opts.builderNetwork = network
+		return nil
+	}
+}
+
+// WithBuilderPrivileged sets whether the ephemeral BuildKit container, which
+// is created when no BuildKit daemon can be found, is privileged.  This is the
+// default.  Otherwise, the rootless BuildKit image is used without process
+// sandboxing and with the seccomp and AppArmor profiles disabled, as is
+// required by BuildKit to run unprivileged.  Builds which rely on privileged
+// operations, e.g. mounting filesystems in a RUN instruction, fail in this
+// mode.
+func WithBuilderPrivileged(privileged bool) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		opts.unprivileged = !privileged
+		return nil
+	}
+}
+
+// WithType sets the type of the input from which the initramfs is built when
+// using New, rather than detecting it.  This resolves ambiguous inputs, e.g. a
+// directory named `Dockerfile`.
+func WithType(typ Type) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		if _, ok := builderOf(typ); !ok {
+			return fmt.Errorf("unknown initramfs type '%s': must be one of %s", typ, typesString())
+		}
+
+		opts.typ = typ
+		return nil
+	}
+}
+
 // WithArchitecture sets the architecture of the file contents of binaries in
 // the initramfs.  Files may not always be architecture specific, this option
 // simply indicates the target architecture if any binaries are compiled by the
@@ -52,6 +243,40 @@ func WithArchitecture(arch string) InitrdOption {
 	}
 }
 
+// WithArchVariant sets the variant of the architecture, e.g. "v7" for arm, of
+// the file contents of binaries in the initramfs.  It is only considered when
+// an architecture has been set via WithArchitecture.
+func WithArchVariant(variant string) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		opts.variant = variant
+		return nil
+	}
+}
+
+// ociArchitecture returns the architecture in the form used by OCI platforms.
+func (opts InitrdOptions) ociArchitecture() string {
+	if opts.arch == "x86_64" {
+		return "amd64"
+	}
+
+	return opts.arch
+}
+
+// platform returns the OCI platform string, e.g. "linux/arm/v7", of the
+// initramfs or an empty string if no architecture has been set.
+func (opts InitrdOptions) platform() string {
+	if opts.arch == "" {
+		return ""
+	}
+
+	platform := "linux/" + opts.ociArchitecture()
+	if opts.variant != "" {
+		platform += "/" + opts.variant
+	}
+
+	return platform
+}
+
 // WithWorkdir sets the working directory of the initramfs builder.  This is
 // used as a mechanism for storing temporary files and directories during the
 // serialization of the initramfs.
@@ -61,3 +286,16 @@ func WithWorkdir(dir string) InitrdOption {
 		return nil
 	}
 }
+
+// WithExpectedDigest sets the digest which the contents of a remote initramfs
+// must match once downloaded.  A mismatch results in an error.
+func WithExpectedDigest(dgst digest.Digest) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		if err := dgst.Validate(); err != nil {
+			return fmt.Errorf("invalid expected digest: %w", err)
+		}
+
+		opts.expectedDigest = dgst
+		return nil
+	}
+}
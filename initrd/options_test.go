@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"compress/gzip"
+	"testing"
+)
+
+func TestWithCompressionLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []InitrdOption
+		expected int
+		err      bool
+	}{
+		{
+			name:     "unset",
+			expected: gzip.DefaultCompression,
+		},
+		{
+			name:     "huffman only",
+			opts:     []InitrdOption{WithCompressionLevel(gzip.HuffmanOnly)},
+			expected: gzip.HuffmanOnly,
+		},
+		{
+			name:     "no compression",
+			opts:     []InitrdOption{WithCompressionLevel(gzip.NoCompression)},
+			expected: gzip.NoCompression,
+		},
+		{
+			name:     "best compression",
+			opts:     []InitrdOption{WithCompressionLevel(gzip.BestCompression)},
+			expected: gzip.BestCompression,
+		},
+		{
+			name: "below range",
+			opts: []InitrdOption{WithCompressionLevel(gzip.HuffmanOnly - 1)},
+			err:  true,
+		},
+		{
+			name: "above range",
+			opts: []InitrdOption{WithCompressionLevel(gzip.BestCompression + 1)},
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts InitrdOptions
+
+			var err error
+			for _, opt := range tt.opts {
+				if err = opt(&opts); err != nil {
+					break
+				}
+			}
+
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := opts.CompressionLevel(); got != tt.expected {
+				t.Errorf("expected level %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestInitrdOptionsPlatform(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []InitrdOption
+		expected string
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:     "x86_64",
+			opts:     []InitrdOption{WithArchitecture("x86_64")},
+			expected: "linux/amd64",
+		},
+		{
+			name:     "arm64",
+			opts:     []InitrdOption{WithArchitecture("arm64")},
+			expected: "linux/arm64",
+		},
+		{
+			name: "arm with variant",
+			opts: []InitrdOption{
+				WithArchitecture("arm"),
+				WithArchVariant("v7"),
+			},
+			expected: "linux/arm/v7",
+		},
+		{
+			name: "variant without architecture",
+			opts: []InitrdOption{WithArchVariant("v7")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts InitrdOptions
+
+			for _, opt := range tt.opts {
+				if err := opt(&opts); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			if got := opts.platform(); got != tt.expected {
+				t.Errorf("expected platform '%s', got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+
+	"kraftkit.sh/internal/version"
+	"kraftkit.sh/log"
+)
+
+// isRemoteURL returns whether the provided path uses a scheme which is
+// supported by NewFromURL.
+func isRemoteURL(path string) bool {
+	u, err := url.Parse(path)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "http", "https", "s3":
+		return true
+	}
+
+	return false
+}
+
+// s3ToHTTP converts an `s3://bucket/key` URL into its HTTP equivalent.  If the
+// AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL environmental variables are set, a
+// path-style URL against that endpoint is used, otherwise a virtual-hosted
+// style URL against AWS in the region set by AWS_REGION or AWS_DEFAULT_REGION.
+func s3ToHTTP(u *url.URL) (string, error) {
+	bucket := u.Host
+	key := strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return "", fmt.Errorf("s3 url must be of the form s3://bucket/key: %s", u.String())
+	}
+
+	for _, env := range []string{"AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"} {
+		if endpoint := os.Getenv(env); endpoint != "" {
+			return strings.TrimSuffix(endpoint, "/") + "/" + bucket + "/" + key, nil
+		}
+	}
+
+	host := "s3.amazonaws.com"
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(env); region != "" {
+			host = "s3." + region + ".amazonaws.com"
+			break
+		}
+	}
+
+	return "https://" + bucket + "." + host + "/" + key, nil
+}
+
+// NewFromURL accepts a remote URL which represents a CPIO archive, downloads
+// it and then behaves like NewFromFile.  The `http`, `https` and `s3` schemes
+// are supported.  Requests to S3 are not signed, meaning the object must be
+// publicly readable.
+//
+// The archive is downloaded to the location set via WithOutput, otherwise to a
+// temporary file.  If WithExpectedDigest is set and the output already exists
+// with the expected digest, the download is skipped.
+func NewFromURL(ctx context.Context, path string, opts ...InitrdOption) (Initrd, error) {
+	iopts := InitrdOptions{}
+	for _, opt := range opts {
+		if err := opt(&iopts); err != nil {
+			return nil, err
+		}
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse url: %w", err)
+	}
+
+	resource := path
+	switch u.Scheme {
+	case "http", "https":
+	case "s3":
+		if resource, err = s3ToHTTP(u); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported url scheme: %s", u.Scheme)
+	}
+
+	dst := iopts.output
+	if dst == "" {
+		fi, err := os.CreateTemp("", "kraftkit-initrd-*")
+		if err != nil {
+			return nil, fmt.Errorf("could not create temporary file: %w", err)
+		}
+
+		dst = fi.Name()
+
+		if err := fi.Close(); err != nil {
+			return nil, err
+		}
+	} else if iopts.expectedDigest != "" {
+		if err := verifyDigest(dst, iopts.expectedDigest); err == nil {
+			log.G(ctx).
+				WithField("path", dst).
+				Debug("initramfs already downloaded")
+
+			return NewFromFile(ctx, dst, opts...)
+		}
+	}
+
+	dgst, err := download(ctx, resource, dst)
+	if err != nil {
+		return nil, err
+	}
+
+	if iopts.expectedDigest != "" {
+		if err := verifyDigest(dst, iopts.expectedDigest); err != nil {
+			// Do not leave behind a corrupt cache.
+			os.Remove(dst)
+			return nil, fmt.Errorf("could not verify %s: %w", path, err)
+		}
+	}
+
+	initrd, err := newFromFile(dst, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	initrd.digest = dgst
+
+	return initrd, nil
+}
+
+// download retrieves the resource at the provided HTTP URL and places it at
+// dst and returns the SHA256 digest of its contents.  The content is first
+// written to a partial file which is only moved into place once the download
+// has completed.
+func download(ctx context.Context, resource, dst string) (digest.Digest, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", fmt.Errorf("could not create parent directories: %w", err)
+	}
+
+	get, err := http.NewRequestWithContext(ctx, "GET", resource, nil)
+	if err != nil {
+		return "", err
+	}
+
+	get.Header.Set("User-Agent", version.UserAgent())
+
+	log.G(ctx).WithFields(logrus.Fields{
+		"url":    resource,
+		"method": "GET",
+	}).Trace("http")
+
+	res, err := http.DefaultClient.Do(get)
+	if err != nil {
+		return "", fmt.Errorf("could not download initramfs: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("received HTTP error code %d when downloading initramfs", res.StatusCode)
+	}
+
+	tmp := dst + ".part"
+
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("could not create file: %w", err)
+	}
+
+	digester := digest.SHA256.Digester()
+
+	if _, err := io.Copy(io.MultiWriter(f, digester.Hash()), res.Body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", fmt.Errorf("could not download initramfs: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("could not close file '%s': %w", tmp, err)
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		return "", err
+	}
+
+	return digester.Digest(), nil
+}
+
+// verifyDigest checks that the contents of the file at path match the
+// expected digest.
+func verifyDigest(path string, expected digest.Digest) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	verifier := expected.Verifier()
+	if _, err := io.Copy(verifier, f); err != nil {
+		return fmt.Errorf("could not compute digest: %w", err)
+	}
+
+	if !verifier.Verified() {
+		return fmt.Errorf("digest mismatch: expected %s", expected)
+	}
+
+	return nil
+}
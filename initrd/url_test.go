@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cavaliergopher/cpio"
+	"github.com/opencontainers/go-digest"
+
+	"kraftkit.sh/initrd"
+)
+
+func TestNewFromURL(t *testing.T) {
+	var archive bytes.Buffer
+	w := cpio.NewWriter(&archive)
+	content := []byte("#!/bin/sh\n")
+	if err := w.WriteHeader(&cpio.Header{
+		Name: "init",
+		Mode: cpio.TypeReg | 0o755,
+		Size: int64(len(content)),
+	}); err != nil {
+		t.Fatal("WriteHeader:", err)
+	}
+	if _, err := w.Write(content); err != nil {
+		t.Fatal("Write:", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal("Close:", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = rw.Write(archive.Bytes())
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		digest  digest.Digest
+		wantErr bool
+	}{
+		{
+			name:   "matching digest",
+			digest: digest.FromBytes(archive.Bytes()),
+		},
+		{
+			name:    "mismatching digest",
+			digest:  digest.FromString("not the archive"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "initramfs.cpio")
+
+			ird, err := initrd.NewFromURL(context.Background(), srv.URL+"/initramfs.cpio",
+				initrd.WithOutput(output),
+				initrd.WithExpectedDigest(tt.digest),
+			)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				if _, err := os.Stat(output); !os.IsNotExist(err) {
+					t.Fatal("expected output to be removed")
+				}
+				return
+			} else if err != nil {
+				t.Fatal("NewFromURL:", err)
+			}
+
+			path, err := ird.Build(context.Background())
+			if err != nil {
+				t.Fatal("Build:", err)
+			}
+			if path != output {
+				t.Fatalf("expected %s, got %s", output, path)
+			}
+		})
+	}
+}
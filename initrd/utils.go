@@ -6,52 +6,110 @@ package initrd
 
 import (
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/cavaliergopher/cpio"
+	"github.com/opencontainers/go-digest"
+
+	"kraftkit.sh/log"
 )
 
-func compressFiles(output string, writer *cpio.Writer, reader *os.File) error {
-	err := writer.Close()
-	if err != nil {
-		return fmt.Errorf("could not close CPIO writer: %w", err)
+// newCPIOWriter returns a CPIO writer which writes to w, compressing the
+// archive if requested via WithCompression.  The returned function must be
+// called once all entries have been written in order to write the trailer of
+// the archive and flush any compressed content.
+func newCPIOWriter(w io.Writer, opts InitrdOptions) (*cpio.Writer, func() error, error) {
+	if !opts.compress {
+		writer := cpio.NewWriter(w)
+		return writer, writer.Close, nil
 	}
 
-	_, err = reader.Seek(0, io.SeekStart)
+	gw, err := gzip.NewWriterLevel(w, opts.CompressionLevel())
 	if err != nil {
-		return fmt.Errorf("could not seek to start of file: %w", err)
+		return nil, nil, fmt.Errorf("could not create gzip writer: %w", err)
+	}
+
+	writer := cpio.NewWriter(gw)
+
+	return writer, func() error {
+		if err := writer.Close(); err != nil {
+			return fmt.Errorf("could not close CPIO writer: %w", err)
+		}
+
+		if err := gw.Close(); err != nil {
+			return fmt.Errorf("could not close gzip writer: %w", err)
+		}
+
+		return nil
+	}, nil
+}
+
+// buildToFile serializes the initramfs via buildTo to the output set via
+// WithOutput, or a temporary file if none is set, and returns its location.
+// The SHA256 digest of the initramfs is computed whilst it is written and
+// stored in dgst.  The initramfs is first written to a temporary file next to
+// the output which is only moved into place once the build has succeeded, such
+// that a failed build neither leaves a partial initramfs behind nor removes
+// whatever already exists at the output.
+func buildToFile(ctx context.Context, opts *InitrdOptions, dgst *digest.Digest, buildTo func(context.Context, io.Writer) error) (_ string, err error) {
+	var dir, pattern string
+	if opts.output != "" {
+		dir = filepath.Dir(opts.output)
+		pattern = "." + filepath.Base(opts.output) + ".*"
+
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", fmt.Errorf("could not create output directory: %w", err)
+		}
 	}
 
-	fw, err := os.OpenFile(output+".gz", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	f, err := os.CreateTemp(dir, pattern)
 	if err != nil {
-		return fmt.Errorf("could not open initramfs file: %w", err)
+		return "", fmt.Errorf("could not make temporary file: %w", err)
 	}
 
-	gw := gzip.NewWriter(fw)
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		_ = f.Close()
+
+		if rerr := os.Remove(f.Name()); rerr != nil {
+			log.G(ctx).
+				WithError(rerr).
+				Debug("removing initramfs")
+		}
+	}()
 
-	if _, err := io.Copy(gw, reader); err != nil {
-		return fmt.Errorf("could not compress initramfs file: %w", err)
+	digester := digest.SHA256.Digester()
+
+	if err := buildTo(ctx, io.MultiWriter(f, digester.Hash())); err != nil {
+		return "", err
 	}
 
-	err = gw.Close()
-	if err != nil {
-		return fmt.Errorf("could not close gzip writer: %w", err)
+	if err := f.Sync(); err != nil {
+		return "", fmt.Errorf("could not sync initramfs file: %w", err)
 	}
 
-	err = fw.Close()
-	if err != nil {
-		return fmt.Errorf("could not close compressed initramfs file: %w", err)
+	if err := f.Chmod(0o644); err != nil {
+		return "", fmt.Errorf("could not set initramfs file permissions: %w", err)
 	}
 
-	if err := os.Remove(output); err != nil {
-		return fmt.Errorf("could not remove uncompressed initramfs: %w", err)
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("could not close initramfs file: %w", err)
 	}
 
-	if err := os.Rename(output+".gz", output); err != nil {
-		return fmt.Errorf("could not rename compressed initramfs: %w", err)
+	if opts.output == "" {
+		opts.output = f.Name()
+	} else if err := os.Rename(f.Name(), opts.output); err != nil {
+		return "", fmt.Errorf("could not move initramfs file into place: %w", err)
 	}
 
-	return nil
+	*dgst = digester.Digest()
+
+	return opts.output, nil
 }
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cavaliergopher/cpio"
+
+	"kraftkit.sh/log"
+)
+
+// DefaultInitPaths are the locations, in order of precedence, at which an init
+// program is looked up when no entrypoint has been provided.
+var DefaultInitPaths = []string{
+	"/init",
+	"/sbin/init",
+}
+
+// maxSymlinkDepth is the maximum number of symbolic links which are followed
+// when resolving a path within an archive.
+const maxSymlinkDepth = 40
+
+// ValidateArchive scans the CPIO archive, which may be gzip-compressed, at the
+// provided path for common mistakes without having to boot it.  It checks that
+// the entrypoint, i.e. the first of the provided arguments, with which the
+// archive is started or, if there is neither an entrypoint nor a command, one
+// of the DefaultInitPaths is present and executable.
+func ValidateArchive(ctx context.Context, file string, args []string) error {
+	entries := map[string]*cpio.Header{}
+
+	if err := walkArchive(file, func(header *cpio.Header) error {
+		entries[path.Clean("/"+strings.TrimPrefix(header.Name, "./"))] = header
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	candidates := DefaultInitPaths
+	if len(args) > 0 && path.IsAbs(args[0]) {
+		candidates = []string{args[0]}
+	} else if len(args) > 0 {
+		// Relative entrypoints are resolved at runtime via $PATH, which cannot be
+		// reliably determined here.
+		log.G(ctx).
+			WithField("entrypoint", args[0]).
+			Debug("skipping validation of relative entrypoint")
+		return nil
+	}
+
+	for _, candidate := range candidates {
+		header, resolved, err := resolveArchiveEntry(entries, candidate)
+		if err != nil {
+			return err
+		} else if header == nil {
+			continue
+		}
+
+		if !header.Mode.IsRegular() {
+			return fmt.Errorf("init '%s' is not a regular file", resolved)
+		}
+
+		if header.Mode.Perm()&0o111 == 0 {
+			return fmt.Errorf("init '%s' is not executable (mode %#o)", resolved, uint32(header.Mode.Perm()))
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("no entrypoint or command set and no init found in initramfs: expected one of %s", strings.Join(candidates, ", "))
+}
+
+// resolveArchiveEntry looks up the provided path in the archive's entries,
+// following any symbolic links.  If the path does not exist, a nil header is
+// returned.
+func resolveArchiveEntry(entries map[string]*cpio.Header, name string) (*cpio.Header, string, error) {
+	name = path.Clean(name)
+
+	for i := 0; i < maxSymlinkDepth; i++ {
+		header, ok := entries[name]
+		if !ok {
+			return nil, name, nil
+		}
+
+		if header.Mode&cpio.ModeType != cpio.TypeSymlink {
+			return header, name, nil
+		}
+
+		target := header.Linkname
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(name), target)
+		}
+
+		name = path.Clean(target)
+	}
+
+	return nil, name, fmt.Errorf("too many levels of symbolic links resolving '%s'", name)
+}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kraftkit.sh/initrd"
+)
+
+func TestValidateArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]os.FileMode
+		symlink [2]string
+		args    []string
+		wantErr bool
+	}{
+		{
+			name:  "executable init",
+			files: map[string]os.FileMode{"init": 0o755},
+		},
+		{
+			name:  "executable sbin init",
+			files: map[string]os.FileMode{"sbin/init": 0o755},
+		},
+		{
+			name:    "symlinked sbin init",
+			files:   map[string]os.FileMode{"bin/busybox": 0o755},
+			symlink: [2]string{"../bin/busybox", "sbin/init"},
+		},
+		{
+			name:    "non-executable init",
+			files:   map[string]os.FileMode{"init": 0o644},
+			wantErr: true,
+		},
+		{
+			name:    "missing init",
+			files:   map[string]os.FileMode{"etc/hostname": 0o644},
+			wantErr: true,
+		},
+		{
+			name:  "executable entrypoint without init",
+			files: map[string]os.FileMode{"usr/bin/app": 0o755},
+			args:  []string{"/usr/bin/app", "--flag"},
+		},
+		{
+			name:    "missing entrypoint",
+			files:   map[string]os.FileMode{"init": 0o755},
+			args:    []string{"/usr/bin/app"},
+			wantErr: true,
+		},
+		{
+			name:  "relative entrypoint",
+			files: map[string]os.FileMode{"etc/hostname": 0o644},
+			args:  []string{"app"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			root := t.TempDir()
+
+			for name, mode := range tt.files {
+				file := filepath.Join(root, name)
+				if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+					t.Fatal("MkdirAll:", err)
+				}
+				if err := os.WriteFile(file, []byte("#!/bin/sh\n"), mode); err != nil {
+					t.Fatal("WriteFile:", err)
+				}
+				if err := os.Chmod(file, mode); err != nil {
+					t.Fatal("Chmod:", err)
+				}
+			}
+
+			if tt.symlink[1] != "" {
+				link := filepath.Join(root, tt.symlink[1])
+				if err := os.MkdirAll(filepath.Dir(link), 0o755); err != nil {
+					t.Fatal("MkdirAll:", err)
+				}
+				if err := os.Symlink(tt.symlink[0], link); err != nil {
+					t.Fatal("Symlink:", err)
+				}
+			}
+
+			output := filepath.Join(t.TempDir(), "initramfs.cpio")
+
+			ird, err := initrd.NewFromDirectory(ctx, root, initrd.WithOutput(output))
+			if err != nil {
+				t.Fatal("NewFromDirectory:", err)
+			}
+
+			irdPath, err := ird.Build(ctx)
+			if err != nil {
+				t.Fatal("Build:", err)
+			}
+
+			err = initrd.ValidateArchive(ctx, irdPath, tt.args)
+			if tt.wantErr && err == nil {
+				t.Error("expected an error, got none")
+			} else if !tt.wantErr && err != nil {
+				t.Error("unexpected error:", err)
+			}
+		})
+	}
+}
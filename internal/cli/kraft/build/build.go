@@ -18,6 +18,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/initrd"
 	"kraftkit.sh/internal/cli/kraft/utils"
 	"kraftkit.sh/internal/fancymap"
 	"kraftkit.sh/iostreams"
@@ -39,14 +40,17 @@ type BuildOptions struct {
 	Env          []string        `long:"env" short:"e" usage:"Set environment variables to be built in the unikernel"`
 	ForcePull    bool            `long:"force-pull" usage:"Force pulling packages before building"`
 	Jobs         int             `long:"jobs" short:"j" usage:"Allow N jobs at once"`
+	KeepBuilder  bool            `long:"keep-builder" usage:"Keep the ephemeral BuildKit container if building the root file system fails"`
 	KernelDbg    bool            `long:"dbg" usage:"Build the debuggable (symbolic) kernel image instead of the stripped image"`
-	Kraftfile    string          `long:"kraftfile" short:"K" usage:"Set an alternative path of the Kraftfile"`
+	KraftfileRaw []byte          `noattribute:"true"`
+	Kraftfile    string          `long:"kraftfile" short:"K" usage:"Set an alternative path of the Kraftfile or '-' to read it from standard input"`
 	NoCache      bool            `long:"no-cache" short:"F" usage:"Force a rebuild even if existing intermediate artifacts already exist"`
 	NoConfigure  bool            `long:"no-configure" usage:"Do not run Unikraft's configure step before building"`
 	NoFast       bool            `long:"no-fast" usage:"Do not use maximum parallelization when performing the build"`
 	NoFetch      bool            `long:"no-fetch" usage:"Do not run Unikraft's fetch step before building"`
 	NoRootfs     bool            `long:"no-rootfs" usage:"Do not build the root file system (initramfs)"`
 	NoUpdate     bool            `long:"no-update" usage:"Do not update package index before running the build"`
+	Output       string          `long:"output" short:"o" usage:"Set the output directory of the build"`
 	Platform     string          `long:"plat" short:"p" usage:"Filter the creation of the build by platform of known targets"`
 	PrintStats   bool            `long:"print-stats" usage:"Print build statistics"`
 	Project      app.Application `noattribute:"true"`
@@ -109,10 +113,16 @@ func Build(ctx context.Context, opts *BuildOptions, args ...string) error {
 		return fmt.Errorf("could not complete build: %w", err)
 	}
 
-	if opts.Rootfs, _, _, err = utils.BuildRootfs(ctx, opts.Workdir, opts.Rootfs, false, (*opts.Target).Architecture().String()); err != nil {
+	rootfs, err := utils.BuildRootfs(ctx, opts.Workdir, opts.Rootfs, false, (*opts.Target).Architecture().String(),
+		initrd.WithNoCache(opts.NoCache),
+		initrd.WithKeepBuilder(opts.KeepBuilder),
+	)
+	if err != nil {
 		return err
 	}
 
+	opts.Rootfs = rootfs.Path
+
 	// Set the root file system for the project, since typically a packaging step
 	// may occur after a build, and the root file system is required for packaging
 	// and the packaging step may perform a build of the rootfs again.  Ultimately
@@ -162,6 +172,9 @@ func NewCmd() *cobra.Command {
 
 			# Build path to a Unikraft project
 			$ kraft build path/to/app
+
+			# Build a project whose Kraftfile is read from standard input
+			$ generate-kraftfile | kraft build --kraftfile - path/to/app
 		`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "build",
@@ -182,6 +195,8 @@ func (opts *BuildOptions) Pre(cmd *cobra.Command, args []string) error {
 
 	cmd.SetContext(ctx)
 
+	opts.warnArchitectureMismatch(ctx)
+
 	return nil
 }
 
@@ -7,8 +7,18 @@ package build
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
 
+	"kraftkit.sh/config"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine/qemu"
 	"kraftkit.sh/unikraft/app"
+	"kraftkit.sh/unikraft/arch"
 )
 
 // initProject sets up the project based on the provided context and
@@ -20,7 +30,31 @@ func (opts *BuildOptions) initProject(ctx context.Context) error {
 		app.WithProjectWorkdir(opts.Workdir),
 	}
 
-	if len(opts.Kraftfile) > 0 {
+	if opts.Output != "" {
+		output := opts.Output
+		if !filepath.IsAbs(output) {
+			output = filepath.Join(opts.Workdir, output)
+		}
+
+		if err := checkWritableDir(output); err != nil {
+			return fmt.Errorf("invalid output directory: %w", err)
+		}
+
+		popts = append(popts, app.WithProjectOutDir(opts.Output))
+	}
+
+	// Standard input can only be read once, yet the project may be initialized
+	// by each builder which checks its buildability, so retain its contents.
+	if opts.Kraftfile == "-" && opts.KraftfileRaw == nil {
+		opts.KraftfileRaw, err = io.ReadAll(iostreams.G(ctx).In)
+		if err != nil {
+			return fmt.Errorf("could not read Kraftfile from standard input: %w", err)
+		}
+	}
+
+	if opts.KraftfileRaw != nil {
+		popts = append(popts, app.WithProjectKraftfileFromBytes(opts.KraftfileRaw))
+	} else if len(opts.Kraftfile) > 0 {
 		popts = append(popts, app.WithProjectKraftfile(opts.Kraftfile))
 	} else {
 		popts = append(popts, app.WithProjectDefaultKraftfiles())
@@ -34,3 +68,76 @@ func (opts *BuildOptions) initProject(ctx context.Context) error {
 
 	return nil
 }
+
+// checkWritableDir creates the directory at the provided path if it does not
+// exist and checks that files can be created within it, such that a build
+// fails early rather than after partially producing its artifacts.
+func checkWritableDir(dir string) error {
+	if fi, err := os.Stat(dir); err == nil && !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("could not create %s: %w", dir, err)
+	}
+
+	f, err := os.CreateTemp(dir, ".kraft-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+
+	f.Close()
+
+	return os.Remove(f.Name())
+}
+
+// warnArchitectureMismatch logs a warning when the requested architecture
+// differs from that of the host and no QEMU binary is available to emulate it,
+// since the resulting unikernel could then not be run on this host.
+func (opts *BuildOptions) warnArchitectureMismatch(ctx context.Context) {
+	if opts.Architecture == "" {
+		return
+	}
+
+	host, err := arch.HostArchitecture()
+	if err != nil {
+		log.G(ctx).
+			WithError(err).
+			Debug("could not determine host architecture")
+		return
+	}
+
+	target := opts.Architecture
+	if target == "amd64" {
+		target = "x86_64"
+	}
+
+	if target == host {
+		return
+	}
+
+	var bin string
+	switch target {
+	case "x86_64":
+		bin = qemu.QemuSystemX86
+	case "arm":
+		bin = qemu.QemuSystemArm
+	case "arm64":
+		bin = qemu.QemuSystemAarch64
+	}
+
+	if custom := config.G[config.KraftKit](ctx).Qemu; custom != "" {
+		bin = custom
+	}
+
+	if bin != "" {
+		if _, err := exec.LookPath(bin); err == nil {
+			return
+		}
+	}
+
+	log.G(ctx).
+		WithField("arch", target).
+		WithField("host", host).
+		Warn("building for a different architecture than the host and no emulator was found: the unikernel will not run with 'kraft run' on this host")
+}
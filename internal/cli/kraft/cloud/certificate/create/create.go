@@ -43,7 +43,7 @@ func Create(ctx context.Context, opts *CreateOptions) (*kccertificates.CreateRes
 	}
 
 	if opts.Auth == nil {
-		opts.Auth, err = config.GetKraftCloudAuthConfig(ctx, opts.Token)
+		opts.Auth, err = utils.GetAuthConfig(ctx, opts.Token)
 		if err != nil {
 			return nil, fmt.Errorf("could not retrieve credentials: %w", err)
 		}
@@ -29,6 +29,7 @@ import (
 
 type CloudOptions struct {
 	Metro string `long:"metro" env:"KRAFTCLOUD_METRO" usage:"Set the KraftCloud metro"`
+	Quiet bool   `long:"quiet" short:"q" usage:"Only print the UUIDs of resources, one per line"`
 	Token string `long:"token" env:"KRAFTCLOUD_TOKEN" usage:"Set the KraftCloud token"`
 }
 
@@ -77,6 +78,9 @@ func NewCmd() *cobra.Command {
 
 			# Delete an instance based on its UUID
 			$ kraft cloud instance remove UUID
+
+			# Stop all instances whose name starts with "web-"
+			$ kraft cloud -q instance list --prefix web- | xargs kraft cloud instance stop
 		`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup:  "kraftcloud",
@@ -8,7 +8,6 @@ package list
 import (
 	"context"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/MakeNowJust/heredoc"
@@ -102,7 +101,7 @@ func (opts *ListOptions) Run(ctx context.Context, args []string) error {
 
 	var files string
 	for _, file := range project.ComposeFiles {
-		files += filepath.Join(workdir, file) + ", "
+		files += file + ", "
 	}
 
 	table.AddField(strings.TrimSuffix(files, ", "), nil)
@@ -160,7 +160,7 @@ func (opts *DeployOptions) Pre(cmd *cobra.Command, _ []string) error {
 func (opts *DeployOptions) Run(ctx context.Context, args []string) error {
 	var err error
 
-	opts.Auth, err = config.GetKraftCloudAuthConfig(ctx, opts.Token)
+	opts.Auth, err = utils.GetAuthConfig(ctx, opts.Token)
 	if err != nil {
 		return fmt.Errorf("could not retrieve credentials: %w", err)
 	}
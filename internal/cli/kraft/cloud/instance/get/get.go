@@ -7,20 +7,24 @@ package get
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
 
-	kraftcloud "sdk.kraft.cloud"
+	kcclient "sdk.kraft.cloud/client"
+	kcinstances "sdk.kraft.cloud/instances"
 
 	"kraftkit.sh/cmdfactory"
-	"kraftkit.sh/config"
 	"kraftkit.sh/internal/cli/kraft/cloud/utils"
 )
 
 type GetOptions struct {
-	Output string `long:"output" short:"o" usage:"Set output format. Options: table,yaml,json,list" default:"list"`
+	Output  string        `long:"output" short:"o" usage:"Set output format. Options: table,yaml,json,list" default:"list"`
+	Timeout time.Duration `long:"timeout" usage:"Maximum time to wait for the instance when using --wait" default:"60s"`
+	Wait    bool          `long:"wait" usage:"Wait for the instance to leave a transitional state (starting, stopping, draining)"`
 
 	metro string
 	token string
@@ -47,6 +51,9 @@ func NewCmd() *cobra.Command {
 
 			# Retrieve information about a kraftcloud instance by name
 			$ kraft cloud instance get my-instance-431342
+
+			# Wait for a kraftcloud instance to finish starting or stopping
+			$ kraft cloud instance get --wait my-instance-431342
 		`),
 		Long: heredoc.Doc(`
 			Retrieve the state of an instance.
@@ -76,18 +83,43 @@ func (opts *GetOptions) Pre(cmd *cobra.Command, _ []string) error {
 }
 
 func (opts *GetOptions) Run(ctx context.Context, args []string) error {
-	auth, err := config.GetKraftCloudAuthConfig(ctx, opts.token)
+	client, err := utils.GetClient(ctx, opts.token)
 	if err != nil {
 		return fmt.Errorf("could not retrieve credentials: %w", err)
 	}
 
-	client := kraftcloud.NewInstancesClient(
-		kraftcloud.WithToken(config.GetKraftCloudTokenAuthConfig(*auth)),
-	)
+	var resp *kcclient.ServiceResponse[kcinstances.GetResponseItem]
 
-	resp, err := client.WithMetro(opts.metro).Get(ctx, args[0])
-	if err != nil {
-		return fmt.Errorf("could not get instance %s: %w", args[0], err)
+	get := func() (bool, error) {
+		resp, err = client.Instances().WithMetro(opts.metro).Get(ctx, args[0])
+		if err != nil {
+			return false, fmt.Errorf("could not get instance %s: %w", args[0], err)
+		}
+
+		if !opts.Wait {
+			return true, nil
+		}
+
+		instance, err := resp.FirstOrErr()
+		if err != nil {
+			return false, fmt.Errorf("could not get instance %s: %w", args[0], err)
+		}
+
+		switch instance.State {
+		case kcinstances.InstanceStateStarting,
+			kcinstances.InstanceStateStopping,
+			kcinstances.InstanceStateDraining:
+			return false, nil
+		}
+
+		return true, nil
+	}
+
+	if err := utils.PollUntil(ctx, time.Second, opts.Timeout, get); err != nil {
+		if errors.Is(err, utils.ErrPollTimeout) {
+			return fmt.Errorf("instance %s did not settle within %s: %w", args[0], opts.Timeout, err)
+		}
+		return err
 	}
 
 	return utils.PrintInstances(ctx, opts.Output, *resp)
@@ -11,15 +11,17 @@ import (
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
 
-	kraftcloud "sdk.kraft.cloud"
+	kcclient "sdk.kraft.cloud/client"
+	kcinstances "sdk.kraft.cloud/instances"
 
 	"kraftkit.sh/cmdfactory"
-	"kraftkit.sh/config"
 	"kraftkit.sh/internal/cli/kraft/cloud/utils"
 )
 
 type ListOptions struct {
-	Output string `long:"output" short:"o" usage:"Set output format. Options: table,yaml,json,list" default:"table"`
+	Output string   `long:"output" short:"o" usage:"Set output format. Options: table,yaml,json,list" default:"table"`
+	Prefix string   `long:"prefix" usage:"Only list instances whose name starts with the prefix"`
+	State  []string `long:"state" usage:"Only list instances in the given state(s)"`
 
 	metro string
 	token string
@@ -34,6 +36,9 @@ func NewCmd() *cobra.Command {
 		Example: heredoc.Doc(`
 			# List all instances in your account.
 			$ kraft cloud instance list
+
+			# List all running instances whose name starts with "web-".
+			$ kraft cloud instance list --state running --prefix web-
 		`),
 		Long: heredoc.Doc(`
 			List all instances in your account.
@@ -63,19 +68,35 @@ func (opts *ListOptions) Pre(cmd *cobra.Command, _ []string) error {
 }
 
 func (opts *ListOptions) Run(ctx context.Context, args []string) error {
-	auth, err := config.GetKraftCloudAuthConfig(ctx, opts.token)
+	client, err := utils.GetClient(ctx, opts.token)
 	if err != nil {
 		return fmt.Errorf("could not retrieve credentials: %w", err)
 	}
 
-	client := kraftcloud.NewInstancesClient(
-		kraftcloud.WithToken(config.GetKraftCloudTokenAuthConfig(*auth)),
-	)
+	if opts.Prefix == "" && len(opts.State) == 0 {
+		resp, err := client.Instances().WithMetro(opts.metro).List(ctx)
+		if err != nil {
+			return fmt.Errorf("could not list instances: %w", err)
+		}
+
+		return utils.PrintInstances(ctx, opts.Output, *resp)
+	}
 
-	resp, err := client.WithMetro(opts.metro).List(ctx)
+	filters := utils.InstanceFilters{
+		Metro:      opts.metro,
+		NamePrefix: opts.Prefix,
+	}
+	for _, state := range opts.State {
+		filters.States = append(filters.States, kcinstances.InstanceState(state))
+	}
+
+	instances, err := utils.ListInstances(ctx, client.Instances(), filters)
 	if err != nil {
-		return fmt.Errorf("could not list instances: %w", err)
+		return err
 	}
 
-	return utils.PrintInstances(ctx, opts.Output, *resp)
+	resp := kcclient.ServiceResponse[kcinstances.GetResponseItem]{}
+	resp.Data.Entries = instances
+
+	return utils.PrintInstances(ctx, opts.Output, resp)
 }
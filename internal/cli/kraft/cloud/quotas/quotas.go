@@ -69,7 +69,7 @@ func (opts *QuotasOptions) Pre(cmd *cobra.Command, _ []string) error {
 }
 
 func (opts *QuotasOptions) Run(ctx context.Context, _ []string) error {
-	auth, err := config.GetKraftCloudAuthConfig(ctx, opts.token)
+	auth, err := utils.GetAuthConfig(ctx, opts.token)
 	if err != nil {
 		return fmt.Errorf("could not retrieve credentials: %w", err)
 	}
@@ -7,31 +7,33 @@ package reset
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
 
 	kraftcloud "sdk.kraft.cloud"
-	kcautoscale "sdk.kraft.cloud/services/autoscale"
 
 	"kraftkit.sh/cmdfactory"
 	"kraftkit.sh/config"
 	"kraftkit.sh/internal/cli/kraft/cloud/utils"
+	"kraftkit.sh/log"
 )
 
 type ResetOptions struct {
-	Auth   *config.AuthConfig           `noattribute:"true"`
-	Client kcautoscale.AutoscaleService `noattribute:"true"`
-	Metro  string                       `noattribute:"true"`
-	Token  string                       `noattribute:"true"`
+	All    bool                  `long:"all" usage:"Reset autoscale configuration of all services"`
+	Auth   *config.AuthConfig    `noattribute:"true"`
+	Client kraftcloud.KraftCloud `noattribute:"true"`
+	Metro  string                `noattribute:"true"`
+	Token  string                `noattribute:"true"`
 }
 
 func NewCmd() *cobra.Command {
 	cmd, err := cmdfactory.New(&ResetOptions{}, cobra.Command{
 		Short:   "Reset autoscale configuration of a service",
-		Use:     "reset [FLAGS] UUID|NAME",
-		Args:    cobra.ExactArgs(1),
+		Use:     "reset [FLAGS] [UUID|NAME [UUID|NAME]...]",
+		Args:    cobra.MinimumNArgs(0),
 		Aliases: []string{"rs", "delconfig", "deinit", "rmconfig"},
 		Long:    "Reset autoscale configuration of a service.",
 		Example: heredoc.Doc(`
@@ -40,6 +42,12 @@ func NewCmd() *cobra.Command {
 
 			# Reset an autoscale configuration by name
 			$ kraft cloud scale reset my-service
+
+			# Reset the autoscale configuration of multiple services
+			$ kraft cloud scale reset my-service my-other-service
+
+			# Reset the autoscale configuration of all services
+			$ kraft cloud scale reset --all
 		`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "kraftcloud-scale",
@@ -53,8 +61,12 @@ func NewCmd() *cobra.Command {
 }
 
 func (opts *ResetOptions) Pre(cmd *cobra.Command, args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("specify a service name or UUID")
+	if !opts.All && len(args) == 0 {
+		return fmt.Errorf("either specify a service name or UUID, or use the --all flag")
+	}
+
+	if opts.All && len(args) > 0 {
+		return fmt.Errorf("cannot specify services and use the --all flag")
 	}
 
 	err := utils.PopulateMetroToken(cmd, &opts.Metro, &opts.Token)
@@ -69,25 +81,50 @@ func (opts *ResetOptions) Run(ctx context.Context, args []string) error {
 	var err error
 
 	if opts.Auth == nil {
-		opts.Auth, err = config.GetKraftCloudAuthConfig(ctx, opts.Token)
+		opts.Auth, err = utils.GetAuthConfig(ctx, opts.Token)
 		if err != nil {
 			return fmt.Errorf("could not retrieve credentials: %w", err)
 		}
 	}
 
 	if opts.Client == nil {
-		opts.Client = kraftcloud.NewAutoscaleClient(
+		opts.Client = kraftcloud.NewClient(
 			kraftcloud.WithToken(config.GetKraftCloudTokenAuthConfig(*opts.Auth)),
 		)
 	}
 
-	delResp, err := opts.Client.WithMetro(opts.Metro).DeleteConfigurations(ctx, args[0])
-	if err != nil {
-		return fmt.Errorf("could not reset configuration: %w", err)
+	if opts.All {
+		sgListResp, err := opts.Client.Services().WithMetro(opts.Metro).List(ctx)
+		if err != nil {
+			return fmt.Errorf("listing services: %w", err)
+		}
+
+		if len(sgListResp.Data.Entries) == 0 {
+			log.G(ctx).Info("no services found")
+			return nil
+		}
+
+		for _, sgItem := range sgListResp.Data.Entries {
+			args = append(args, sgItem.Name)
+		}
 	}
-	if _, err := delResp.AllOrErr(); err != nil {
-		return fmt.Errorf("could not reset configuration: %w", err)
+
+	var errGroup []error
+
+	for _, service := range args {
+		log.G(ctx).
+			WithField("service", service).
+			Info("resetting autoscale configuration")
+
+		delResp, err := opts.Client.Autoscale().WithMetro(opts.Metro).DeleteConfigurations(ctx, service)
+		if err != nil {
+			errGroup = append(errGroup, fmt.Errorf("could not reset configuration of '%s': %w", service, err))
+			continue
+		}
+		if _, err := delResp.AllOrErr(); err != nil {
+			errGroup = append(errGroup, fmt.Errorf("could not reset configuration of '%s': %w", service, err))
+		}
 	}
 
-	return nil
+	return errors.Join(errGroup...)
 }
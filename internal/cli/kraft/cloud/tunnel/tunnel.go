@@ -63,7 +63,7 @@ func (opts *TunnelOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
-	auth, err := config.GetKraftCloudAuthConfig(ctx, opts.token)
+	auth, err := utils.GetAuthConfig(ctx, opts.token)
 	if err != nil {
 		return fmt.Errorf("could not retrieve credentials: %w", err)
 	}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+package utils
+
+import (
+	"context"
+	"sync"
+
+	kraftcloud "sdk.kraft.cloud"
+
+	"kraftkit.sh/config"
+)
+
+type clientCacheKey struct{}
+
+// clientCache holds the KraftCloud credentials and client which are resolved
+// at most once per command invocation.
+type clientCache struct {
+	mu     sync.Mutex
+	token  string
+	auth   *config.AuthConfig
+	client kraftcloud.KraftCloud
+}
+
+// withClientCache returns a context which lazily caches the credentials
+// resolved from the provided token along with the client created from them.
+func withClientCache(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, clientCacheKey{}, &clientCache{token: token})
+}
+
+// GetAuthConfig returns the KraftCloud credentials for the provided token.  If
+// the context was populated via PopulateMetroToken and the token matches the
+// one of the invocation, the credentials are only resolved once and re-used
+// by all subsequent callers.
+func GetAuthConfig(ctx context.Context, token string) (*config.AuthConfig, error) {
+	cache, ok := ctx.Value(clientCacheKey{}).(*clientCache)
+	if !ok || cache.token != token {
+		return config.GetKraftCloudAuthConfig(ctx, token)
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.authLocked(ctx)
+}
+
+// GetClient returns a KraftCloud client authenticated with the credentials of
+// the provided token.  If the context was populated via PopulateMetroToken and
+// the token matches the one of the invocation, the client is created once and
+// shared between all callers using the same context.
+func GetClient(ctx context.Context, token string) (kraftcloud.KraftCloud, error) {
+	cache, ok := ctx.Value(clientCacheKey{}).(*clientCache)
+	if !ok || cache.token != token {
+		auth, err := config.GetKraftCloudAuthConfig(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+
+		return newClient(auth), nil
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.client != nil {
+		return cache.client, nil
+	}
+
+	auth, err := cache.authLocked(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.client = newClient(auth)
+
+	return cache.client, nil
+}
+
+// authLocked resolves the credentials of the cache if they have not yet been
+// resolved.  The cache's lock must be held.
+func (cache *clientCache) authLocked(ctx context.Context) (*config.AuthConfig, error) {
+	if cache.auth != nil {
+		return cache.auth, nil
+	}
+
+	auth, err := config.GetKraftCloudAuthConfig(ctx, cache.token)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.auth = auth
+
+	return auth, nil
+}
+
+func newClient(auth *config.AuthConfig) kraftcloud.KraftCloud {
+	return kraftcloud.NewClient(
+		kraftcloud.WithToken(config.GetKraftCloudTokenAuthConfig(*auth)),
+	)
+}
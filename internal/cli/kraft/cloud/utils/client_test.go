@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"kraftkit.sh/config"
+)
+
+func newClientTestContext(t *testing.T) context.Context {
+	t.Helper()
+
+	// Ensure that no credentials are picked up from the environment.
+	t.Setenv("KRAFTCLOUD_TOKEN", "")
+
+	cfg, err := config.NewDefaultKraftKitConfig()
+	if err != nil {
+		t.Fatal("NewDefaultKraftKitConfig:", err)
+	}
+
+	cfg.Auth = nil
+
+	cfgm, err := config.NewConfigManager(cfg)
+	if err != nil {
+		t.Fatal("NewConfigManager:", err)
+	}
+
+	return config.WithConfigManager(context.Background(), cfgm)
+}
+
+func TestGetClientUsesProvidedToken(t *testing.T) {
+	token := base64.StdEncoding.EncodeToString([]byte("user:secret"))
+
+	tests := []struct {
+		name  string
+		cache string
+	}{
+		{
+			name: "without cache",
+		},
+		{
+			name:  "with cache of the same token",
+			cache: token,
+		},
+		{
+			name:  "with cache of another token",
+			cache: base64.StdEncoding.EncodeToString([]byte("other:secret")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newClientTestContext(t)
+			if tt.cache != "" {
+				ctx = withClientCache(ctx, tt.cache)
+			}
+
+			client, err := GetClient(ctx, token)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if client == nil {
+				t.Fatal("expected client")
+			}
+
+			auth, ok := config.G[config.KraftKit](ctx).Auth["index.unikraft.io"]
+			if !ok {
+				t.Fatal("expected credentials to be resolved from the token")
+			}
+
+			if auth.User != "user" || auth.Token != "secret" {
+				t.Errorf("expected credentials of the provided token, got user %q", auth.User)
+			}
+		})
+	}
+}
+
+func TestGetClientWithoutToken(t *testing.T) {
+	if _, err := GetClient(newClientTestContext(t), ""); err == nil {
+		t.Error("expected error without token or stored credentials")
+	}
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	kcclient "sdk.kraft.cloud/client"
+	kcinstances "sdk.kraft.cloud/instances"
+)
+
+// InstanceFilters narrows down the instances returned by ListInstances.  Zero
+// values do not filter.
+type InstanceFilters struct {
+	// Metro to list the instances of, otherwise the client's metro is used.
+	Metro string
+
+	// NamePrefix only matches instances whose name starts with the prefix.
+	NamePrefix string
+
+	// States only matches instances which are in one of the states.
+	States []kcinstances.InstanceState
+}
+
+// instanceLister is the subset of kcinstances.InstancesService which is
+// necessary to list instances.
+type instanceLister interface {
+	List(context.Context) (*kcclient.ServiceResponse[kcinstances.GetResponseItem], error)
+	Get(context.Context, ...string) (*kcclient.ServiceResponse[kcinstances.GetResponseItem], error)
+}
+
+// ListInstances returns the details of all the instances in the account which
+// match the provided filters.
+func ListInstances(ctx context.Context, client kcinstances.InstancesService, filters InstanceFilters) ([]kcinstances.GetResponseItem, error) {
+	if filters.Metro != "" {
+		client = client.WithMetro(filters.Metro)
+	}
+
+	return listInstances(ctx, client, filters)
+}
+
+func listInstances(ctx context.Context, client instanceLister, filters InstanceFilters) ([]kcinstances.GetResponseItem, error) {
+	listResp, err := client.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list instances: %w", err)
+	}
+
+	list, err := listResp.AllOrErr()
+	if err != nil {
+		return nil, fmt.Errorf("could not list instances: %w", err)
+	}
+
+	// Filter by name before retrieving the details of each instance such that
+	// as few instances as possible are requested.
+	uuids := make([]string, 0, len(list))
+	for _, inst := range list {
+		if !strings.HasPrefix(inst.Name, filters.NamePrefix) {
+			continue
+		}
+
+		uuids = append(uuids, inst.UUID)
+	}
+
+	if len(uuids) == 0 {
+		return []kcinstances.GetResponseItem{}, nil
+	}
+
+	getResp, err := client.Get(ctx, uuids...)
+	if err != nil {
+		return nil, fmt.Errorf("getting details of %d instance(s): %w", len(uuids), err)
+	}
+
+	instances, err := getResp.AllOrErr()
+	if err != nil {
+		return nil, fmt.Errorf("getting details of %d instance(s): %w", len(uuids), err)
+	}
+
+	if len(filters.States) == 0 {
+		return instances, nil
+	}
+
+	return slices.DeleteFunc(instances, func(inst kcinstances.GetResponseItem) bool {
+		return !slices.Contains(filters.States, inst.State)
+	}), nil
+}
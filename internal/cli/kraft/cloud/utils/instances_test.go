@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+package utils
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	kcclient "sdk.kraft.cloud/client"
+	kcinstances "sdk.kraft.cloud/instances"
+)
+
+type mockInstances struct {
+	instances []kcinstances.GetResponseItem
+	got       []string
+}
+
+func (m *mockInstances) List(context.Context) (*kcclient.ServiceResponse[kcinstances.GetResponseItem], error) {
+	resp := kcclient.ServiceResponse[kcinstances.GetResponseItem]{}
+	for _, inst := range m.instances {
+		resp.Data.Entries = append(resp.Data.Entries, kcinstances.GetResponseItem{
+			UUID: inst.UUID,
+			Name: inst.Name,
+		})
+	}
+
+	return &resp, nil
+}
+
+func (m *mockInstances) Get(_ context.Context, ids ...string) (*kcclient.ServiceResponse[kcinstances.GetResponseItem], error) {
+	m.got = append(m.got, ids...)
+
+	resp := kcclient.ServiceResponse[kcinstances.GetResponseItem]{}
+	for _, inst := range m.instances {
+		if slices.Contains(ids, inst.UUID) {
+			resp.Data.Entries = append(resp.Data.Entries, inst)
+		}
+	}
+
+	return &resp, nil
+}
+
+func TestListInstances(t *testing.T) {
+	instances := []kcinstances.GetResponseItem{
+		{UUID: "1", Name: "web-1", State: kcinstances.InstanceStateRunning},
+		{UUID: "2", Name: "web-2", State: kcinstances.InstanceStateStopped},
+		{UUID: "3", Name: "db-1", State: kcinstances.InstanceStateRunning},
+	}
+
+	tests := []struct {
+		name    string
+		filters InstanceFilters
+		want    []string
+		wantGet []string
+	}{
+		{
+			name:    "no filters",
+			want:    []string{"1", "2", "3"},
+			wantGet: []string{"1", "2", "3"},
+		},
+		{
+			name:    "name prefix",
+			filters: InstanceFilters{NamePrefix: "web-"},
+			want:    []string{"1", "2"},
+			wantGet: []string{"1", "2"},
+		},
+		{
+			name: "state",
+			filters: InstanceFilters{States: []kcinstances.InstanceState{
+				kcinstances.InstanceStateRunning,
+			}},
+			want:    []string{"1", "3"},
+			wantGet: []string{"1", "2", "3"},
+		},
+		{
+			name: "name prefix and state",
+			filters: InstanceFilters{
+				NamePrefix: "web-",
+				States:     []kcinstances.InstanceState{kcinstances.InstanceStateStopped},
+			},
+			want:    []string{"2"},
+			wantGet: []string{"1", "2"},
+		},
+		{
+			name:    "no match",
+			filters: InstanceFilters{NamePrefix: "cache-"},
+			want:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockInstances{instances: instances}
+
+			got, err := listInstances(context.Background(), client, tt.filters)
+			if err != nil {
+				t.Fatal("listInstances:", err)
+			}
+
+			uuids := []string{}
+			for _, inst := range got {
+				uuids = append(uuids, inst.UUID)
+			}
+
+			if !slices.Equal(uuids, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, uuids)
+			}
+			if !slices.Equal(client.got, tt.wantGet) {
+				t.Errorf("expected details of %v to be requested, got %v", tt.wantGet, client.got)
+			}
+		})
+	}
+}
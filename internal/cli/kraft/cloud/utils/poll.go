@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// maxPollInterval caps the exponentially growing interval between polls.
+const maxPollInterval = 30 * time.Second
+
+// ErrPollTimeout is returned by PollUntil when the condition has not been
+// satisfied before the timeout elapsed.
+var ErrPollTimeout = errors.New("timed out waiting for operation to complete")
+
+// PollUntil repeatedly invokes fn until it reports that it is done, returns an
+// error, the timeout elapses or the context is cancelled.  The interval
+// between invocations starts at the provided interval and doubles after each
+// attempt, up to maxPollInterval, with up to 20% of random jitter added to
+// avoid synchronized clients.  A timeout of zero waits indefinitely.
+func PollUntil(ctx context.Context, interval, timeout time.Duration, fn func() (bool, error)) error {
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		done, err := fn()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		wait := interval + time.Duration(rand.Int63n(int64(interval)/5+1))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return ErrPollTimeout
+		case <-time.After(wait):
+		}
+
+		interval *= 2
+		if interval > maxPollInterval {
+			interval = maxPollInterval
+		}
+	}
+}
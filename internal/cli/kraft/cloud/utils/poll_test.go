@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPollUntilDone(t *testing.T) {
+	attempts := 0
+	err := PollUntil(context.Background(), time.Millisecond, time.Second, func() (bool, error) {
+		attempts++
+		return attempts == 3, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestPollUntilError(t *testing.T) {
+	expected := errors.New("failed")
+	err := PollUntil(context.Background(), time.Millisecond, time.Second, func() (bool, error) {
+		return false, expected
+	})
+	if !errors.Is(err, expected) {
+		t.Errorf("expected %v, got %v", expected, err)
+	}
+}
+
+func TestPollUntilTimeout(t *testing.T) {
+	err := PollUntil(context.Background(), time.Millisecond, 20*time.Millisecond, func() (bool, error) {
+		return false, nil
+	})
+	if !errors.Is(err, ErrPollTimeout) {
+		t.Errorf("expected %v, got %v", ErrPollTimeout, err)
+	}
+}
+
+func TestPollUntilCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := PollUntil(ctx, time.Millisecond, 0, func() (bool, error) {
+		return false, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"kraftkit.sh/log"
 )
@@ -20,5 +21,15 @@ func PopulateMetroToken(cmd *cobra.Command, metro, token *string) error {
 		log.G(cmd.Context()).WithField("token", *token).Debug("using")
 	}
 
+	// Resolve the credentials and client at most once for the invocation.
+	cmd.SetContext(withClientCache(cmd.Context(), *token))
+
+	// In quiet mode only the UUIDs of resources are printed and human-readable
+	// logging is suppressed.
+	if quiet, err := cmd.Flags().GetBool("quiet"); err == nil && quiet {
+		log.G(cmd.Context()).SetLevel(logrus.ErrorLevel)
+		cmd.SetContext(WithQuiet(cmd.Context(), true))
+	}
+
 	return nil
 }
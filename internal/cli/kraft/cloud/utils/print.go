@@ -85,6 +85,12 @@ func parseTime(dateTime, format, uuid string) (string, error) {
 // PrintInstances pretty-prints the provided set of instances or returns
 // an error if unable to send to stdout via the provided context.
 func PrintInstances(ctx context.Context, format string, resp kcclient.ServiceResponse[kcinstances.GetResponseItem]) error {
+	if IsQuiet(ctx) {
+		return printQuiet(ctx, resp, func(item kcinstances.GetResponseItem) string {
+			return item.UUID
+		})
+	}
+
 	if format == "raw" {
 		printRaw(ctx, resp)
 		return nil
@@ -334,6 +340,12 @@ func PrintInstances(ctx context.Context, format string, resp kcclient.ServiceRes
 // PrintVolumes pretty-prints the provided set of volumes or returns
 // an error if unable to send to stdout via the provided context.
 func PrintVolumes(ctx context.Context, format string, resp kcclient.ServiceResponse[kcvolumes.GetResponseItem]) error {
+	if IsQuiet(ctx) {
+		return printQuiet(ctx, resp, func(item kcvolumes.GetResponseItem) string {
+			return item.UUID
+		})
+	}
+
 	if format == "raw" {
 		printRaw(ctx, resp)
 		return nil
@@ -520,6 +532,12 @@ func PrintAutoscaleConfiguration(ctx context.Context, format string, resp kcclie
 // PrintServices pretty-prints the provided set of service or returns
 // an error if unable to send to stdout via the provided context.
 func PrintServices(ctx context.Context, format string, resp kcclient.ServiceResponse[kcservices.GetResponseItem]) error {
+	if IsQuiet(ctx) {
+		return printQuiet(ctx, resp, func(item kcservices.GetResponseItem) string {
+			return item.UUID
+		})
+	}
+
 	if format == "raw" {
 		printRaw(ctx, resp)
 		return nil
@@ -882,6 +900,12 @@ func PrintQuotas(ctx context.Context, auth config.AuthConfig, format string, res
 // PrintCertificates pretty-prints the provided set of certificates or returns
 // an error if unable to send to stdout via the provided context.
 func PrintCertificates(ctx context.Context, format string, resp kcclient.ServiceResponse[kccerts.GetResponseItem]) error {
+	if IsQuiet(ctx) {
+		return printQuiet(ctx, resp, func(item kccerts.GetResponseItem) string {
+			return item.UUID
+		})
+	}
+
 	if format == "raw" {
 		printRaw(ctx, resp)
 		return nil
@@ -996,6 +1020,11 @@ func PrintCertificates(ctx context.Context, format string, resp kcclient.Service
 
 // PrettyPrintInstance outputs a single instance and information about it.
 func PrettyPrintInstance(ctx context.Context, instance kcinstances.GetResponseItem, service *kcservices.GetResponseItem, autoStart bool) {
+	if IsQuiet(ctx) {
+		fmt.Fprintln(iostreams.G(ctx).Out, instance.UUID)
+		return
+	}
+
 	out := iostreams.G(ctx).Out
 
 	var title string
@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	kcclient "sdk.kraft.cloud/client"
+
+	"kraftkit.sh/iostreams"
+)
+
+type quietKey struct{}
+
+// WithQuiet returns a context in which resources are printed only by their
+// UUID, one per line, such that the output can be piped into other programs.
+func WithQuiet(ctx context.Context, quiet bool) context.Context {
+	return context.WithValue(ctx, quietKey{}, quiet)
+}
+
+// IsQuiet returns whether only the UUIDs of resources should be printed.
+func IsQuiet(ctx context.Context) bool {
+	quiet, _ := ctx.Value(quietKey{}).(bool)
+	return quiet
+}
+
+// printQuiet prints the UUID of each of the entries in the response, one per
+// line.
+func printQuiet[T kcclient.APIResponseDataEntry](ctx context.Context, resp kcclient.ServiceResponse[T], uuid func(T) string) error {
+	entries, err := resp.AllOrErr()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		fmt.Fprintln(iostreams.G(ctx).Out, uuid(entry))
+	}
+
+	return nil
+}
@@ -64,7 +64,7 @@ func (opts *DetachOptions) Run(ctx context.Context, args []string) error {
 	var err error
 
 	if opts.Auth == nil {
-		opts.Auth, err = config.GetKraftCloudAuthConfig(ctx, opts.token)
+		opts.Auth, err = utils.GetAuthConfig(ctx, opts.token)
 		if err != nil {
 			return fmt.Errorf("could not retrieve credentials: %w", err)
 		}
@@ -78,7 +78,7 @@ func (opts *ImportOptions) Run(ctx context.Context, _ []string) error {
 	var err error
 
 	if opts.Auth == nil {
-		if opts.Auth, err = config.GetKraftCloudAuthConfig(ctx, opts.Token); err != nil {
+		if opts.Auth, err = utils.GetAuthConfig(ctx, opts.Token); err != nil {
 			return fmt.Errorf("could not retrieve credentials: %w", err)
 		}
 	}
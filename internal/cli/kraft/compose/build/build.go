@@ -9,21 +9,34 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"strings"
+	"runtime"
 
 	"github.com/compose-spec/compose-go/v2/types"
 	"github.com/spf13/cobra"
 
 	"kraftkit.sh/cmdfactory"
 	"kraftkit.sh/compose"
+	"kraftkit.sh/config"
 	"kraftkit.sh/internal/cli/kraft/build"
 	"kraftkit.sh/internal/cli/kraft/pkg"
 	"kraftkit.sh/log"
 	"kraftkit.sh/packmanager"
+	"kraftkit.sh/tui/processtree"
 )
 
+// serviceBuild tracks the completion of the build of a service such that its
+// dependants can wait for it.
+type serviceBuild struct {
+	done chan struct{}
+	err  error
+}
+
 type BuildOptions struct {
-	composefile string
+	NoCache  bool `long:"no-cache" usage:"Do not use cache when building the services"`
+	Parallel int  `long:"parallel" usage:"Maximum number of services to build concurrently (default: number of CPUs)"`
+
+	composefiles []string
+	projectName  string
 }
 
 func NewCmd() *cobra.Command {
@@ -50,10 +63,17 @@ func (opts *BuildOptions) Pre(cmd *cobra.Command, _ []string) error {
 	cmd.SetContext(ctx)
 
 	if cmd.Flag("file").Changed {
-		opts.composefile = cmd.Flag("file").Value.String()
+		opts.composefiles, err = cmd.Flags().GetStringSlice("file")
+		if err != nil {
+			return err
+		}
 	}
 
-	log.G(cmd.Context()).WithField("composefile", opts.composefile).Debug("using")
+	if cmd.Flag("project-name").Changed {
+		opts.projectName = cmd.Flag("project-name").Value.String()
+	}
+
+	log.G(cmd.Context()).WithField("composefiles", opts.composefiles).Debug("using")
 	return nil
 }
 
@@ -63,7 +83,7 @@ func (opts *BuildOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
-	project, err := compose.NewProjectFromComposeFile(ctx, workdir, opts.composefile)
+	project, err := compose.NewProjectFromComposeFiles(ctx, workdir, opts.composefiles, compose.WithProjectName(opts.projectName))
 	if err != nil {
 		return err
 	}
@@ -77,56 +97,127 @@ func (opts *BuildOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
-	for _, service := range services {
+	// Services are built concurrently once all of the services they depend on
+	// have been built.
+	var ordered []types.ServiceConfig
+	states := map[string]*serviceBuild{}
+	for _, service := range project.ServicesOrderedByDependencies(ctx, services, false) {
 		if service.Build == nil {
 			continue
 		}
 
-		if err := buildService(ctx, service); err != nil {
-			return err
-		}
-
-		if service.Image != "" {
-			if err := pkgService(ctx, service); err != nil {
-				return err
-			}
-		}
+		ordered = append(ordered, service)
+		states[service.Name] = &serviceBuild{done: make(chan struct{})}
 	}
 
-	return nil
-}
+	if len(ordered) == 0 {
+		return nil
+	}
 
-func platArchFromService(service types.ServiceConfig) (string, string, error) {
-	// The service platform should be in the form <platform>/<arch>
+	if opts.Parallel <= 0 {
+		opts.Parallel = runtime.NumCPU()
+	}
 
-	parts := strings.SplitN(service.Platform, "/", 2)
+	norender := log.LoggerTypeFromString(config.G[config.KraftKit](ctx).Log.Type) != log.FANCY
+
+	var processes []*processtree.ProcessTreeItem
+	for _, service := range ordered {
+		service := service
+
+		processes = append(processes, processtree.NewProcessTreeItem(
+			service.Name, "",
+			func(ctx context.Context) (err error) {
+				state := states[service.Name]
+				defer func() {
+					state.err = err
+					close(state.done)
+				}()
+
+				for name := range service.DependsOn {
+					dep, ok := states[name]
+					if !ok {
+						continue
+					}
+
+					select {
+					case <-dep.done:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+
+					if dep.err != nil {
+						return fmt.Errorf("dependency %s failed to build", name)
+					}
+				}
+
+				// Nested process trees must not render as their output is
+				// already captured by this process.
+				if !norender {
+					cfg := *config.G[config.KraftKit](ctx)
+					cfg.Log.Type = log.LoggerTypeToString(log.BASIC)
+
+					cfgm, err := config.NewConfigManager(&cfg)
+					if err != nil {
+						return err
+					}
+
+					ctx = config.WithConfigManager(ctx, cfgm)
+				}
+
+				if err := buildService(ctx, service, opts.NoCache); err != nil {
+					return err
+				}
+
+				if service.Image != "" {
+					if err := pkgService(ctx, service); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		))
+	}
 
-	if len(parts) != 2 {
-		return "", "", fmt.Errorf("invalid platform: %s for service %s", service.Platform, service.Name)
+	model, err := processtree.NewProcessTree(
+		ctx,
+		[]processtree.ProcessTreeOption{
+			processtree.IsParallel(true),
+			processtree.WithRenderer(norender),
+			processtree.WithFailFast(true),
+			// Services are started in dependency order, such that the services
+			// which a waiting service depends on are always already running and
+			// limiting their concurrency cannot deadlock.
+			processtree.WithMaxConcurrency(opts.Parallel),
+		},
+		processes...,
+	)
+	if err != nil {
+		return err
 	}
 
-	return parts[0], parts[1], nil
+	return model.Start()
 }
 
-func buildService(ctx context.Context, service types.ServiceConfig) error {
+func buildService(ctx context.Context, service types.ServiceConfig, noCache bool) error {
 	if service.Build == nil {
 		return fmt.Errorf("service %s has no build context", service.Name)
 	}
 
-	plat, arch, err := platArchFromService(service)
+	plat, arch, err := compose.ServicePlatform(service)
 	if err != nil {
 		return err
 	}
 
 	log.G(ctx).Infof("Building service %s...", service.Name)
 
-	buildOptions := build.BuildOptions{Platform: plat, Architecture: arch}
+	buildOptions := build.BuildOptions{Platform: plat, Architecture: arch, NoCache: noCache}
 
 	return buildOptions.Run(ctx, []string{service.Build.Context})
 }
 
 func pkgService(ctx context.Context, service types.ServiceConfig) error {
-	plat, arch, err := platArchFromService(service)
+	plat, arch, err := compose.ServicePlatform(service)
 	if err != nil {
 		return err
 	}
@@ -14,12 +14,16 @@ import (
 
 	"kraftkit.sh/cmdfactory"
 	"kraftkit.sh/internal/cli/kraft/compose/build"
+	"kraftkit.sh/internal/cli/kraft/compose/config"
 	"kraftkit.sh/internal/cli/kraft/compose/create"
 	"kraftkit.sh/internal/cli/kraft/compose/down"
+	"kraftkit.sh/internal/cli/kraft/compose/events"
+	"kraftkit.sh/internal/cli/kraft/compose/exec"
 	"kraftkit.sh/internal/cli/kraft/compose/logs"
 	"kraftkit.sh/internal/cli/kraft/compose/ls"
 	"kraftkit.sh/internal/cli/kraft/compose/pause"
 	"kraftkit.sh/internal/cli/kraft/compose/ps"
+	"kraftkit.sh/internal/cli/kraft/compose/restart"
 	"kraftkit.sh/internal/cli/kraft/compose/start"
 	"kraftkit.sh/internal/cli/kraft/compose/stop"
 	"kraftkit.sh/internal/cli/kraft/compose/unpause"
@@ -27,7 +31,8 @@ import (
 )
 
 type ComposeOptions struct {
-	Composefile string `long:"file" short:"f" usage:"Set the Compose file."`
+	Composefiles []string `long:"file" short:"f" usage:"Set the Compose file(s), later files override earlier ones."`
+	ProjectName  string   `long:"project-name" short:"p" usage:"Set the project name (default is the working directory's name)."`
 }
 
 func NewCmd() *cobra.Command {
@@ -41,6 +46,12 @@ func NewCmd() *cobra.Command {
 		Example: heredoc.Doc(`
 			# Start a compose project
 			$ kraft compose up
+
+			# Start a compose project with an explicit name
+			$ kraft compose --project-name my-project up
+
+			# Start a compose project whose configuration is merged from two files
+			$ kraft compose -f compose.yaml -f compose.override.yaml up
 		`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup:  "compose",
@@ -52,12 +63,16 @@ func NewCmd() *cobra.Command {
 	}
 
 	cmd.AddCommand(build.NewCmd())
+	cmd.AddCommand(config.NewCmd())
 	cmd.AddCommand(create.NewCmd())
 	cmd.AddCommand(down.NewCmd())
+	cmd.AddCommand(events.NewCmd())
+	cmd.AddCommand(exec.NewCmd())
 	cmd.AddCommand(logs.NewCmd())
 	cmd.AddCommand(ls.NewCmd())
 	cmd.AddCommand(pause.NewCmd())
 	cmd.AddCommand(ps.NewCmd())
+	cmd.AddCommand(restart.NewCmd())
 	cmd.AddCommand(start.NewCmd())
 	cmd.AddCommand(stop.NewCmd())
 	cmd.AddCommand(unpause.NewCmd())
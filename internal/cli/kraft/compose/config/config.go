@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/compose"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/packmanager"
+
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+	mnetwork "kraftkit.sh/machine/network"
+)
+
+type ConfigOptions struct {
+	Composefiles []string `noattribute:"true"`
+	Output       string   `long:"output" short:"o" usage:"Set output format. Options: yaml,json" default:"yaml"`
+	ProjectName  string   `noattribute:"true"`
+}
+
+// replicaAddressesExtension is the name of the extension under which the
+// addresses assigned to the replicas of services are output.
+const replicaAddressesExtension = "x-kraftkit-replica-addresses"
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&ConfigOptions{}, cobra.Command{
+		Short:   "Print the resolved compose project",
+		Use:     "config [FLAGS]",
+		Args:    cobra.NoArgs,
+		Aliases: []string{},
+		Long: heredoc.Doc(`
+			Print the compose project after it has been fully resolved, i.e. with
+			container names, platforms and IP addresses assigned to its services.
+		`),
+		Example: heredoc.Doc(`
+			# Print the resolved compose project
+			$ kraft compose config
+
+			# Print the resolved compose project as JSON
+			$ kraft compose config --output json
+		`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "compose",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *ConfigOptions) Pre(cmd *cobra.Command, _ []string) error {
+	ctx, err := packmanager.WithDefaultUmbrellaManagerInContext(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	cmd.SetContext(ctx)
+
+	if opts.Output != "yaml" && opts.Output != "json" {
+		return fmt.Errorf("unsupported output format: %s", opts.Output)
+	}
+
+	if cmd.Flag("file").Changed {
+		opts.Composefiles, err = cmd.Flags().GetStringSlice("file")
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.Flag("project-name").Changed {
+		opts.ProjectName = cmd.Flag("project-name").Value.String()
+	}
+
+	log.G(cmd.Context()).WithField("composefiles", opts.Composefiles).Debug("using")
+	return nil
+}
+
+func (opts *ConfigOptions) Run(ctx context.Context, _ []string) error {
+	workdir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	project, err := compose.NewProjectFromComposeFiles(ctx, workdir, opts.Composefiles, compose.WithProjectName(opts.ProjectName))
+	if err != nil {
+		return err
+	}
+
+	if err := project.Load(ctx); err != nil {
+		return err
+	}
+
+	if err := project.Validate(ctx); err != nil {
+		return err
+	}
+
+	networkController, err := mnetwork.NewNetworkV1alpha1ServiceIterator(ctx)
+	if err != nil {
+		return err
+	}
+
+	networks, err := networkController.List(ctx, &networkapi.NetworkList{})
+	if err != nil {
+		return err
+	}
+
+	if err := project.AllocateSubnets(ctx, networks); err != nil {
+		return err
+	}
+
+	if err := project.AssignIPs(ctx); err != nil {
+		return err
+	}
+
+	// Replica addresses are not part of the Compose specification and are
+	// hence output as an extension of the project.
+	if len(project.ReplicaAddresses) > 0 {
+		if project.Extensions == nil {
+			project.Extensions = types.Extensions{}
+		}
+
+		project.Extensions[replicaAddressesExtension] = project.ReplicaAddresses
+	}
+
+	var out []byte
+	switch opts.Output {
+	case "json":
+		out, err = project.MarshalJSON()
+	default:
+		out, err = project.MarshalYAML()
+	}
+	if err != nil {
+		return fmt.Errorf("could not marshal project: %w", err)
+	}
+
+	_, err = fmt.Fprintln(iostreams.G(ctx).Out, strings.TrimSpace(string(out)))
+	return err
+}
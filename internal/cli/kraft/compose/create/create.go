@@ -8,11 +8,13 @@ package create
 import (
 	"context"
 	"fmt"
+	"net"
 	"os"
-	"strings"
+	"sort"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/spf13/cobra"
 
 	"kraftkit.sh/cmdfactory"
@@ -26,6 +28,7 @@ import (
 	"kraftkit.sh/internal/cli/kraft/run"
 	volcreate "kraftkit.sh/internal/cli/kraft/volume/create"
 	"kraftkit.sh/log"
+	"kraftkit.sh/pack"
 	"kraftkit.sh/packmanager"
 	"kraftkit.sh/unikraft"
 
@@ -41,8 +44,12 @@ import (
 )
 
 type CreateOptions struct {
-	Composefile   string `noattribute:"true"`
-	RemoveOrphans bool   `long:"remove-orphans" usage:"Remove machines for services not defined in the Compose file"`
+	Build         bool     `long:"build" usage:"Build and package services before creating them, even if already packaged"`
+	Composefiles  []string `noattribute:"true"`
+	DryRun        bool     `long:"dry-run" usage:"Print the networks, volumes and services which would be created without creating them"`
+	ProjectName   string   `noattribute:"true"`
+	NoBuild       bool     `long:"no-build" usage:"Do not build services, fail if a service's image is missing"`
+	RemoveOrphans bool     `long:"remove-orphans" usage:"Remove machines for services not defined in the Compose file"`
 }
 
 func NewCmd() *cobra.Command {
@@ -50,10 +57,24 @@ func NewCmd() *cobra.Command {
 		Short:   "Create a compose project",
 		Use:     "create [FLAGS]",
 		Aliases: []string{},
-		Long:    "Create the services and networks for a project.",
+		Long: heredoc.Doc(`
+			Create the services and networks for a project.
+
+			Services which request multiple replicas via 'deploy.replicas' are
+			created as one machine per replica, named after the service's container
+			name suffixed with the replica's index, e.g. 'container-1'.  Each replica
+			is assigned a distinct IP address on every network of the service, and
+			only the first replica publishes the service's ports.
+		`),
 		Example: heredoc.Doc(`
 			# Create the networks and services without running them
 			$ kraft compose create 
+
+			# Rebuild the services before creating them
+			$ kraft compose create --build
+
+			# Print what would be created without creating anything
+			$ kraft compose create --dry-run
 		`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "compose",
@@ -74,11 +95,22 @@ func (opts *CreateOptions) Pre(cmd *cobra.Command, _ []string) error {
 
 	cmd.SetContext(ctx)
 
+	if opts.Build && opts.NoBuild {
+		return fmt.Errorf("cannot use --build and --no-build together")
+	}
+
 	if cmd.Flag("file").Changed {
-		opts.Composefile = cmd.Flag("file").Value.String()
+		opts.Composefiles, err = cmd.Flags().GetStringSlice("file")
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.Flag("project-name").Changed {
+		opts.ProjectName = cmd.Flag("project-name").Value.String()
 	}
 
-	log.G(cmd.Context()).WithField("composefile", opts.Composefile).Debug("using")
+	log.G(cmd.Context()).WithField("composefiles", opts.Composefiles).Debug("using")
 	return nil
 }
 
@@ -88,7 +120,7 @@ func (opts *CreateOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
-	project, err := compose.NewProjectFromComposeFile(ctx, workdir, opts.Composefile)
+	project, err := compose.NewProjectFromComposeFiles(ctx, workdir, opts.Composefiles, compose.WithProjectName(opts.ProjectName))
 	if err != nil {
 		return err
 	}
@@ -97,10 +129,37 @@ func (opts *CreateOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
+	networkController, err := mnetwork.NewNetworkV1alpha1ServiceIterator(ctx)
+	if err != nil {
+		return err
+	}
+
+	networks, err := networkController.List(ctx, &networkapi.NetworkList{})
+	if err != nil {
+		return err
+	}
+
+	if err := project.AllocateSubnets(ctx, networks); err != nil {
+		return err
+	}
+
+	// Keep the addresses of machines which were created previously.
+	if err := project.LoadAddresses(ctx); err != nil {
+		return err
+	}
+
 	if err := project.AssignIPs(ctx); err != nil {
 		return err
 	}
 
+	if opts.DryRun {
+		return plan(ctx, project, args)
+	}
+
+	if err := project.Save(ctx); err != nil {
+		return err
+	}
+
 	if opts.RemoveOrphans {
 		if err := utils.RemoveOrphans(ctx, project); err != nil {
 			return err
@@ -135,8 +194,9 @@ func (opts *CreateOptions) Run(ctx context.Context, args []string) error {
 				Name: project.Name,
 			},
 			Spec: composeapi.ComposeSpec{
-				Composefile: project.ComposeFiles[0],
-				Workdir:     project.WorkingDir,
+				Composefile:  project.ComposeFiles[0],
+				Composefiles: project.ComposeFiles,
+				Workdir:      project.WorkingDir,
 			},
 			Status: composeapi.ComposeStatus{
 				Machines: projectMachines,
@@ -148,34 +208,25 @@ func (opts *CreateOptions) Run(ctx context.Context, args []string) error {
 		}
 	}()
 
-	networkController, err := mnetwork.NewNetworkV1alpha1ServiceIterator(ctx)
-	if err != nil {
-		return err
-	}
-
-	networks, err := networkController.List(ctx, &networkapi.NetworkList{})
-	if err != nil {
-		return err
-	}
+	managedNetworks, externalNetworks := orderedNetworks(project)
 
-	// We need to first create the networks with a provided subnet
-	// and then the ones which we will assign IPs to
-	subnetNetworks := []string{}
-	emptyNetworks := []string{}
-	for name, network := range project.Networks {
-		if network.External {
-			continue
+	// External networks are not managed by the project but must exist such that
+	// services are able to join them.
+	for _, networkName := range externalNetworks {
+		network := project.Networks[networkName]
+		found := false
+		for _, n := range networks.Items {
+			if n.Name == network.Name {
+				found = true
+				break
+			}
 		}
-		if network.Ipam.Config == nil || len(network.Ipam.Config) == 0 {
-			emptyNetworks = append(emptyNetworks, name)
-		} else {
-			subnetNetworks = append(subnetNetworks, name)
+		if !found {
+			return fmt.Errorf("external network %s not found", network.Name)
 		}
 	}
 
-	orderedNetworks := append(subnetNetworks, emptyNetworks...)
-
-	for _, networkName := range orderedNetworks {
+	for _, networkName := range managedNetworks {
 		network := project.Networks[networkName]
 		alreadyRunning := false
 		for _, n := range networks.Items {
@@ -193,13 +244,28 @@ func (opts *CreateOptions) Run(ctx context.Context, args []string) error {
 			driver = network.Driver
 		}
 
+		// The network is created with its gateway as address and the prefix
+		// length of its subnet.
 		subnet := ""
 		if len(network.Ipam.Config) > 0 {
-			subnet = network.Ipam.Config[0].Subnet
+			ipamConfig := network.Ipam.Config[0]
+			subnet = ipamConfig.Subnet
+
+			if _, ipnet, err := net.ParseCIDR(ipamConfig.Subnet); err == nil && ipamConfig.Gateway != "" {
+				ones, _ := ipnet.Mask.Size()
+				subnet = fmt.Sprintf("%s/%d", ipamConfig.Gateway, ones)
+			}
 		}
+		mtu, err := compose.NetworkMTU(network)
+		if err != nil {
+			return err
+		}
+
 		createOptions := netcreate.CreateOptions{
-			Driver:  driver,
-			Network: subnet,
+			Driver:   driver,
+			Network:  subnet,
+			MTU:      mtu,
+			Internal: network.Internal,
 		}
 
 		log.G(ctx).Infof("creating network %s...", network.Name)
@@ -229,6 +295,16 @@ func (opts *CreateOptions) Run(ctx context.Context, args []string) error {
 
 	for _, volume := range project.Volumes {
 		if volume.External {
+			found := false
+			for _, v := range volumes.Items {
+				if v.Name == volume.Name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("external volume %s not found", volume.Name)
+			}
 			continue
 		}
 		alreadyExisting := false
@@ -290,54 +366,81 @@ func (opts *CreateOptions) Run(ctx context.Context, args []string) error {
 	orderedServices := project.ServicesOrderedByDependencies(ctx, services, true)
 	for _, service := range orderedServices {
 		log.G(ctx).Debugf("creating service %s...", service.Name)
-		alreadyCreated := false
-		for _, machine := range machines.Items {
-			if service.ContainerName != machine.Name {
-				continue
-			}
-			if machine.Status.State == machineapi.MachineStateRunning || machine.Status.State == machineapi.MachineStateCreated {
-				alreadyCreated = true
+
+		// Determine which of the service's replicas still need to be created.
+		containers := []string{}
+		for _, container := range compose.ServiceContainerNames(service) {
+			alreadyCreated := false
+			for _, machine := range machines.Items {
+				if container != machine.Name {
+					continue
+				}
+				if machine.Status.State == machineapi.MachineStateRunning || machine.Status.State == machineapi.MachineStateCreated {
+					alreadyCreated = true
+					break
+				}
+				rmOpts := remove.RemoveOptions{
+					Platform: machine.Spec.Platform,
+				}
+
+				if err := rmOpts.Run(ctx, []string{container}); err != nil {
+					return err
+				}
+
+				for i, m := range projectMachines {
+					if m.Name == machine.Name {
+						projectMachines = append(projectMachines[:i], projectMachines[i+1:]...)
+						break
+					}
+				}
 				break
 			}
-			rmOpts := remove.RemoveOptions{
-				Platform: machine.Spec.Platform,
+			if !alreadyCreated {
+				containers = append(containers, container)
 			}
-
-			if err := rmOpts.Run(ctx, []string{service.ContainerName}); err != nil {
+		}
+		if len(containers) == 0 {
+			continue
+		}
+		switch {
+		case opts.Build && service.Build != nil:
+			if err := buildService(ctx, service); err != nil {
 				return err
 			}
 
-			for i, m := range projectMachines {
-				if m.Name == machine.Name {
-					projectMachines = append(projectMachines[:i], projectMachines[i+1:]...)
-					break
+			if service.Image != "" {
+				if err := pkgService(ctx, service); err != nil {
+					return err
 				}
 			}
-			break
-		}
-		if alreadyCreated {
-			continue
-		}
-		if service.Image == "" {
+		case service.Image == "":
+			if opts.NoBuild {
+				return fmt.Errorf("service %s has no image and --no-build was set", service.Name)
+			}
+
 			if err := buildService(ctx, service); err != nil {
 				return err
 			}
-		} else if err := ensureServiceIsPackaged(ctx, service); err != nil {
-			return err
+		default:
+			if err := ensureServiceIsPackaged(ctx, service, !opts.NoBuild); err != nil {
+				return err
+			}
 		}
 
-		if err := createService(ctx, project, service); err != nil {
-			log.G(ctx).WithError(err).Errorf("failed to create service %s", service.Name)
-		}
+		for _, container := range containers {
+			if err := createService(ctx, project, service, container); err != nil {
+				log.G(ctx).WithError(err).Errorf("failed to create service %s", service.Name)
+			}
 
-		if machine, err := machineController.Get(ctx, &machineapi.Machine{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: service.ContainerName,
-			},
-		}); err == nil && machine.Status.State == machineapi.MachineStateCreated {
-			projectMachines = append(projectMachines, machine.ObjectMeta)
-		} else if err != nil {
-			return err
+			if machine, err := machineController.Get(ctx, &machineapi.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: container,
+				},
+			}); err == nil && machine.Status.State == machineapi.MachineStateCreated {
+				projectMachines = append(projectMachines, machine.ObjectMeta)
+			} else if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -346,8 +449,9 @@ func (opts *CreateOptions) Run(ctx context.Context, args []string) error {
 			Name: project.Name,
 		},
 		Spec: composeapi.ComposeSpec{
-			Composefile: project.ComposeFiles[0],
-			Workdir:     project.WorkingDir,
+			Composefile:  project.ComposeFiles[0],
+			Composefiles: project.ComposeFiles,
+			Workdir:      project.WorkingDir,
 		},
 		Status: composeapi.ComposeStatus{
 			Machines: projectMachines,
@@ -361,41 +465,63 @@ func (opts *CreateOptions) Run(ctx context.Context, args []string) error {
 	return nil
 }
 
-func platArchFromService(service types.ServiceConfig) (string, string, error) {
-	// The service platform should be in the form <platform>/<arch>
-
-	parts := strings.SplitN(service.Platform, "/", 2)
+// orderedNetworks returns the names of the networks of the project which are
+// managed by it, in the order in which they are created, and the names of its
+// external networks.  Networks with a provided subnet are created first such
+// that the subnets of the remaining networks do not conflict with them.
+func orderedNetworks(project *compose.Project) ([]string, []string) {
+	subnetNetworks := []string{}
+	emptyNetworks := []string{}
+	externalNetworks := []string{}
 
-	if len(parts) != 2 {
-		return "", "", fmt.Errorf("invalid platform: %s for service %s", service.Platform, service.Name)
+	for name, network := range project.Networks {
+		if network.External {
+			externalNetworks = append(externalNetworks, name)
+		} else if len(network.Ipam.Config) == 0 {
+			emptyNetworks = append(emptyNetworks, name)
+		} else {
+			subnetNetworks = append(subnetNetworks, name)
+		}
 	}
 
-	return parts[0], parts[1], nil
+	sort.Strings(subnetNetworks)
+	sort.Strings(emptyNetworks)
+	sort.Strings(externalNetworks)
+
+	return append(subnetNetworks, emptyNetworks...), externalNetworks
 }
 
-func ensureServiceIsPackaged(ctx context.Context, service types.ServiceConfig) error {
-	plat, arch, err := platArchFromService(service)
+// ensureServiceIsPackaged checks whether the service's image is available
+// locally or remotely, pulling it if necessary.  If the image cannot be found
+// and build is set, the service is built and packaged instead.
+func ensureServiceIsPackaged(ctx context.Context, service types.ServiceConfig, build bool) error {
+	plat, arch, err := compose.ServicePlatform(service)
 	if err != nil {
 		return err
 	}
 
-	parts := strings.SplitN(service.Image, ":", 2)
-	imageName := parts[0]
-	imageVersion := "latest"
-	if len(parts) == 2 {
-		imageVersion = parts[1]
+	ref, err := name.ParseReference(service.Image,
+		name.WithDefaultRegistry(""),
+		name.WithDefaultTag("latest"),
+	)
+	if err != nil {
+		return fmt.Errorf("could not parse image of service %s: %w", service.Name, err)
 	}
 
-	service.Image = imageName + ":" + imageVersion
+	// The version is either the tag or, if the image is pinned, the digest.
+	imageName := ref.Context().Name()
+	imageVersion := ref.Identifier()
+	_, pinned := ref.(name.Digest)
+
+	if pinned {
+		service.Image = imageName + "@" + imageVersion
+	} else {
+		service.Image = imageName + ":" + imageVersion
+	}
 
 	log.G(ctx).Debugf("searching for service %s locally...", service.Name)
 	// Check whether the image is already in the local catalog
-	packages, err := packmanager.G(ctx).Catalog(ctx,
-		packmanager.WithArchitecture(arch),
-		packmanager.WithName(imageName),
-		packmanager.WithPlatform(plat),
-		packmanager.WithTypes(unikraft.ComponentTypeApp),
-		packmanager.WithVersion(imageVersion))
+	packages, err := localServicePackages(ctx, imageName, imageVersion, plat, arch)
 	if err != nil {
 		return err
 	}
@@ -424,7 +550,29 @@ func ensureServiceIsPackaged(ctx context.Context, service types.ServiceConfig) e
 		log.G(ctx).Infof("found service %s remotely, pulling...", service.Name)
 		// We need to pull it locally
 		pullOptions := pull.PullOptions{Platform: plat, Architecture: arch}
-		return pullOptions.Run(ctx, []string{service.Image})
+		if err := pullOptions.Run(ctx, []string{service.Image}); err != nil {
+			return err
+		}
+
+		if !pinned {
+			return nil
+		}
+
+		// Verify that the pulled content matches the pinned digest
+		packages, err = localServicePackages(ctx, imageName, imageVersion, plat, arch)
+		if err != nil {
+			return err
+		}
+
+		if len(packages) == 0 {
+			return fmt.Errorf("pulled image of service %s does not match digest %s", service.Name, imageVersion)
+		}
+
+		return nil
+	}
+
+	if !build {
+		return fmt.Errorf("could not find image %s for service %s and building is disabled", service.Image, service.Name)
 	}
 
 	// Otherwise, we need to build and package it
@@ -435,12 +583,23 @@ func ensureServiceIsPackaged(ctx context.Context, service types.ServiceConfig) e
 	return pkgService(ctx, service)
 }
 
+// localServicePackages returns the packages of the image with the provided
+// name and version which are available in the local catalog.
+func localServicePackages(ctx context.Context, imageName, imageVersion, plat, arch string) ([]pack.Package, error) {
+	return packmanager.G(ctx).Catalog(ctx,
+		packmanager.WithArchitecture(arch),
+		packmanager.WithName(imageName),
+		packmanager.WithPlatform(plat),
+		packmanager.WithTypes(unikraft.ComponentTypeApp),
+		packmanager.WithVersion(imageVersion))
+}
+
 func buildService(ctx context.Context, service types.ServiceConfig) error {
 	if service.Build == nil {
 		return fmt.Errorf("service %s has no build context", service.Name)
 	}
 
-	plat, arch, err := platArchFromService(service)
+	plat, arch, err := compose.ServicePlatform(service)
 	if err != nil {
 		return err
 	}
@@ -453,7 +612,7 @@ func buildService(ctx context.Context, service types.ServiceConfig) error {
 }
 
 func pkgService(ctx context.Context, service types.ServiceConfig) error {
-	plat, arch, err := platArchFromService(service)
+	plat, arch, err := compose.ServicePlatform(service)
 	if err != nil {
 		return err
 	}
@@ -471,14 +630,16 @@ func pkgService(ctx context.Context, service types.ServiceConfig) error {
 	return pkgOptions.Run(ctx, []string{service.Build.Context})
 }
 
-func createService(ctx context.Context, project *compose.Project, service types.ServiceConfig) error {
+// createService creates the machine with the provided container name for one
+// of the replicas of the service.
+func createService(ctx context.Context, project *compose.Project, service types.ServiceConfig, container string) error {
 	// The service should be packaged at this point
-	plat, arch, err := platArchFromService(service)
+	plat, arch, err := compose.ServicePlatform(service)
 	if err != nil {
 		return err
 	}
 
-	log.G(ctx).Infof("creating service %s...", service.Name)
+	log.G(ctx).Infof("creating service %s (%s)...", service.Name, container)
 
 	networks := []string{}
 	if len(service.DNS) > 2 {
@@ -493,9 +654,9 @@ func createService(ctx context.Context, project *compose.Project, service types.
 	if len(service.DNS) > 1 {
 		dns1 = service.DNS[1]
 	}
-	for name, network := range service.Networks {
+	for name := range service.Networks {
 		arg := uknetdev.NetdevIp{
-			CIDR:     network.Ipv4Address,
+			CIDR:     project.ServiceAddress(service, container, name),
 			DNS0:     dns0,
 			DNS1:     dns1,
 			Hostname: service.Hostname,
@@ -523,24 +684,40 @@ func createService(ctx context.Context, project *compose.Project, service types.
 		environ = append(environ, fmt.Sprintf("%s=%s", k, *v))
 	}
 
+	// Only the first replica publishes the service's ports, as the host ports
+	// of every other replica would otherwise conflict.
 	ports := []string{}
-	for _, port := range service.Ports {
-		ports = append(ports, fmt.Sprintf("%s:%s:%d/%s", port.HostIP, port.Published, port.Target, port.Protocol))
+	if names := compose.ServiceContainerNames(service); len(names) > 0 && names[0] != container {
+		log.G(ctx).
+			WithField("service", service.Name).
+			WithField("replica", container).
+			Debug("not publishing ports for replica")
+	} else {
+		for _, port := range service.Ports {
+			ports = append(ports, fmt.Sprintf("%s:%s:%d/%s", port.HostIP, port.Published, port.Target, port.Protocol))
+		}
+	}
+
+	cpus, err := compose.ServiceCPUs(service)
+	if err != nil {
+		return err
 	}
 
-	memory := ""
-	if service.MemLimit > 0 {
-		memory = fmt.Sprintf("%d", service.MemLimit)
-	} else if service.MemReservation > 0 {
-		memory = fmt.Sprintf("%d", service.MemReservation)
+	labels := []string{}
+	for k, v := range project.ServiceLabels(service, container) {
+		labels = append(labels, fmt.Sprintf("%s=%s", k, v))
 	}
 
+	sort.Strings(labels)
+
 	runOptions := run.RunOptions{
 		Architecture: arch,
+		CPUs:         cpus,
 		Detach:       true,
 		Env:          environ,
-		Memory:       memory,
-		Name:         service.ContainerName,
+		Labels:       labels,
+		Memory:       compose.ServiceMemory(service),
+		Name:         container,
 		Networks:     networks,
 		NoStart:      true,
 		Platform:     plat,
@@ -548,9 +725,10 @@ func createService(ctx context.Context, project *compose.Project, service types.
 		Volumes:      volumes,
 	}
 
-	if service.Image != "" {
-		return runOptions.Run(ctx, []string{service.Image})
+	source := service.Image
+	if source == "" {
+		source = service.Build.Context
 	}
 
-	return runOptions.Run(ctx, []string{service.Build.Context})
+	return runOptions.Run(ctx, append([]string{source}, compose.ServiceArgs(service)...))
 }
@@ -0,0 +1,288 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+package create
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"kraftkit.sh/compose"
+	"kraftkit.sh/internal/tableprinter"
+	"kraftkit.sh/iostreams"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+	volumeapi "kraftkit.sh/api/volume/v1alpha1"
+	mnetwork "kraftkit.sh/machine/network"
+	mplatform "kraftkit.sh/machine/platform"
+	mvolume "kraftkit.sh/machine/volume"
+)
+
+// plan prints the networks, volumes and services of the project which would be
+// created, in the order in which they would be created, without creating
+// anything.  Any problem which would prevent the project from being created is
+// reported once the plan has been printed.
+func plan(ctx context.Context, project *compose.Project, args []string) error {
+	networkController, err := mnetwork.NewNetworkV1alpha1ServiceIterator(ctx)
+	if err != nil {
+		return err
+	}
+
+	networks, err := networkController.List(ctx, &networkapi.NetworkList{})
+	if err != nil {
+		return err
+	}
+
+	volumeController, err := mvolume.NewVolumeV1alpha1ServiceIterator(ctx)
+	if err != nil {
+		return err
+	}
+
+	volumes, err := volumeController.List(ctx, &volumeapi.VolumeList{})
+	if err != nil {
+		return err
+	}
+
+	machineController, err := mplatform.NewMachineV1alpha1ServiceIterator(ctx)
+	if err != nil {
+		return err
+	}
+
+	machines, err := machineController.List(ctx, &machineapi.MachineList{})
+	if err != nil {
+		return err
+	}
+
+	services, err := project.GetServices(args...)
+	if err != nil {
+		return err
+	}
+
+	rows, problems := planRows(ctx, project, services, networks, volumes, machines, serviceImageExistsLocally)
+
+	cs := iostreams.G(ctx).ColorScheme()
+
+	table, err := tableprinter.NewTablePrinter(ctx,
+		tableprinter.WithMaxWidth(iostreams.G(ctx).TerminalWidth()),
+	)
+	if err != nil {
+		return err
+	}
+
+	table.AddField("KIND", cs.Bold)
+	table.AddField("NAME", cs.Bold)
+	table.AddField("ACTION", cs.Bold)
+	table.AddField("DETAILS", cs.Bold)
+	table.EndRow()
+
+	for _, row := range rows {
+		table.AddField(row.kind, nil)
+		table.AddField(row.name, nil)
+		table.AddField(row.action, nil)
+		table.AddField(row.details, nil)
+		table.EndRow()
+	}
+
+	if err := table.Render(iostreams.G(ctx).Out); err != nil {
+		return err
+	}
+
+	return errors.Join(problems...)
+}
+
+// planRow is a single resource of the plan.
+type planRow struct {
+	kind    string
+	name    string
+	action  string
+	details string
+}
+
+// planRows returns the rows of the plan of the project given the existing
+// networks, volumes and machines, along with any problem which would prevent
+// the project from being created.  The imageExists function reports whether
+// the image of a service is available locally for the provided platform and
+// architecture.
+func planRows(
+	ctx context.Context,
+	project *compose.Project,
+	services types.Services,
+	networks *networkapi.NetworkList,
+	volumes *volumeapi.VolumeList,
+	machines *machineapi.MachineList,
+	imageExists func(context.Context, types.ServiceConfig, string, string) (bool, error),
+) ([]planRow, []error) {
+	var rows []planRow
+
+	addRow := func(kind, name, action, details string) {
+		rows = append(rows, planRow{
+			kind:    kind,
+			name:    name,
+			action:  action,
+			details: details,
+		})
+	}
+
+	var problems []error
+
+	networkExists := func(name string) bool {
+		for _, n := range networks.Items {
+			if n.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	managedNetworks, externalNetworks := orderedNetworks(project)
+
+	for _, networkName := range externalNetworks {
+		network := project.Networks[networkName]
+		if networkExists(network.Name) {
+			addRow("network", network.Name, "external", "")
+			continue
+		}
+
+		addRow("network", network.Name, "missing", "")
+		problems = append(problems, fmt.Errorf("external network %s not found", network.Name))
+	}
+
+	for _, networkName := range managedNetworks {
+		network := project.Networks[networkName]
+		if networkExists(network.Name) {
+			addRow("network", network.Name, "exists", "")
+			continue
+		}
+
+		driver := mnetwork.DefaultStrategyName()
+		if network.Driver != "" {
+			driver = network.Driver
+		}
+
+		details := []string{"driver=" + driver}
+		if len(network.Ipam.Config) > 0 {
+			details = append(details, "subnet="+network.Ipam.Config[0].Subnet)
+		}
+
+		if _, err := compose.NetworkMTU(network); err != nil {
+			problems = append(problems, err)
+		}
+
+		addRow("network", network.Name, "create", strings.Join(details, " "))
+	}
+
+	volumeExists := func(name string) bool {
+		for _, v := range volumes.Items {
+			if v.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, volume := range project.Volumes {
+		switch {
+		case volume.External && volumeExists(volume.Name):
+			addRow("volume", volume.Name, "external", "")
+		case volume.External:
+			addRow("volume", volume.Name, "missing", "")
+			problems = append(problems, fmt.Errorf("external volume %s not found", volume.Name))
+		case volumeExists(volume.Name):
+			addRow("volume", volume.Name, "exists", "")
+		default:
+			driver := mvolume.DefaultStrategyName()
+			if volume.Driver != "" {
+				driver = volume.Driver
+			}
+
+			addRow("volume", volume.Name, "create", "driver="+driver)
+		}
+	}
+
+	for _, service := range project.ServicesOrderedByDependencies(ctx, services, true) {
+		details := []string{}
+
+		plat, arch, err := compose.ServicePlatform(service)
+		if err != nil {
+			problems = append(problems, err)
+		} else {
+			details = append(details, "platform="+plat+"/"+arch)
+		}
+
+		if service.Image != "" {
+			image := service.Image
+			if err == nil {
+				if found, ferr := imageExists(ctx, service, plat, arch); ferr != nil {
+					problems = append(problems, ferr)
+				} else if !found {
+					image += " (not found locally)"
+				}
+			}
+
+			details = append(details, "image="+image)
+		} else if service.Build != nil {
+			details = append(details, "build="+service.Build.Context)
+		} else {
+			problems = append(problems, fmt.Errorf("service %s has neither an image nor a build context", service.Name))
+		}
+
+		if args := compose.ServiceArgs(service); args != nil {
+			details = append(details, fmt.Sprintf("args=%q", strings.Join(args, " ")))
+		}
+
+		for _, container := range compose.ServiceContainerNames(service) {
+			action := "create"
+			for _, machine := range machines.Items {
+				if container != machine.Name {
+					continue
+				}
+
+				if machine.Status.State == machineapi.MachineStateRunning || machine.Status.State == machineapi.MachineStateCreated {
+					action = "exists"
+				} else {
+					action = "recreate"
+				}
+				break
+			}
+
+			addresses := []string{}
+			for network := range service.Networks {
+				addresses = append(addresses, fmt.Sprintf("%s=%s", project.Networks[network].Name, project.ServiceAddress(service, container, network)))
+			}
+
+			sort.Strings(addresses)
+
+			addRow("service", container, action, strings.Join(append(details, addresses...), " "))
+		}
+	}
+
+	return rows, problems
+}
+
+// serviceImageExistsLocally checks whether the image of the service is
+// available in the local catalog.
+func serviceImageExistsLocally(ctx context.Context, service types.ServiceConfig, plat, arch string) (bool, error) {
+	ref, err := name.ParseReference(service.Image,
+		name.WithDefaultRegistry(""),
+		name.WithDefaultTag("latest"),
+	)
+	if err != nil {
+		return false, fmt.Errorf("could not parse image of service %s: %w", service.Name, err)
+	}
+
+	packages, err := localServicePackages(ctx, ref.Context().Name(), ref.Identifier(), plat, arch)
+	if err != nil {
+		return false, err
+	}
+
+	return len(packages) > 0, nil
+}
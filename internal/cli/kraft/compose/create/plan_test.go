@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+package create
+
+import (
+	"context"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+	volumeapi "kraftkit.sh/api/volume/v1alpha1"
+	"kraftkit.sh/compose"
+	mnetwork "kraftkit.sh/machine/network"
+	mvolume "kraftkit.sh/machine/volume"
+)
+
+func TestPlanRows(t *testing.T) {
+	ctx := context.Background()
+
+	project := &compose.Project{
+		Project: &types.Project{
+			Name: "test",
+			Networks: types.Networks{
+				"default": {
+					Name: "test_default",
+					Ipam: types.IPAMConfig{
+						Config: []*types.IPAMPool{{Subnet: "10.0.0.0/24", Gateway: "10.0.0.1"}},
+					},
+				},
+				"shared": {
+					Name:     "shared",
+					External: true,
+				},
+			},
+			Volumes: types.Volumes{
+				"data": {Name: "test_data"},
+			},
+			Services: types.Services{
+				"db": {
+					Name:          "db",
+					ContainerName: "test-db",
+					Image:         "db:latest",
+					Platform:      "qemu/x86_64",
+					Networks: map[string]*types.ServiceNetworkConfig{
+						"default": {Ipv4Address: "10.0.0.2"},
+					},
+				},
+				"web": {
+					Name:          "web",
+					ContainerName: "test-web",
+					Build:         &types.BuildConfig{Context: "./web"},
+					Platform:      "kvm/x86_64",
+					Command:       types.ShellCommand{"--port", "8080"},
+					DependsOn: types.DependsOnConfig{
+						"db": {Required: true},
+					},
+					Networks: map[string]*types.ServiceNetworkConfig{
+						"default": {Ipv4Address: "10.0.0.3"},
+					},
+				},
+			},
+		},
+	}
+
+	existing := &machineapi.MachineList{}
+	machine := machineapi.Machine{}
+	machine.Name = "test-db"
+	machine.Status.State = machineapi.MachineStateExited
+	existing.Items = append(existing.Items, machine)
+
+	imageExists := func(context.Context, types.ServiceConfig, string, string) (bool, error) {
+		return false, nil
+	}
+
+	rows, problems := planRows(ctx,
+		project,
+		project.Services,
+		&networkapi.NetworkList{},
+		&volumeapi.VolumeList{},
+		existing,
+		imageExists,
+	)
+
+	expected := []planRow{
+		{kind: "network", name: "shared", action: "missing"},
+		{kind: "network", name: "test_default", action: "create", details: "driver=" + mnetwork.DefaultStrategyName() + " subnet=10.0.0.0/24"},
+		{kind: "volume", name: "test_data", action: "create", details: "driver=" + mvolume.DefaultStrategyName()},
+		{kind: "service", name: "test-db", action: "recreate", details: "platform=qemu/x86_64 image=db:latest (not found locally) test_default=10.0.0.2"},
+		{kind: "service", name: "test-web", action: "create", details: `platform=qemu/x86_64 build=./web args="--port 8080" test_default=10.0.0.3`},
+	}
+
+	if len(rows) != len(expected) {
+		t.Fatalf("expected %d rows, got %d: %+v", len(expected), len(rows), rows)
+	}
+
+	for i, row := range rows {
+		if row != expected[i] {
+			t.Errorf("row %d: expected %+v, got %+v", i, expected[i], row)
+		}
+	}
+
+	// The missing external network prevents the project from being created.
+	if len(problems) != 1 {
+		t.Errorf("expected 1 problem, got %v", problems)
+	}
+}
@@ -8,6 +8,7 @@ package down
 import (
 	"context"
 	"os"
+	"slices"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/compose-spec/compose-go/v2/types"
@@ -28,7 +29,8 @@ import (
 )
 
 type DownOptions struct {
-	composefile   string
+	composefiles  []string
+	projectName   string
 	RemoveOrphans bool `long:"remove-orphans" usage:"Remove machines for services not defined in the Compose file."`
 }
 
@@ -62,10 +64,17 @@ func (opts *DownOptions) Pre(cmd *cobra.Command, _ []string) error {
 	cmd.SetContext(ctx)
 
 	if cmd.Flag("file").Changed {
-		opts.composefile = cmd.Flag("file").Value.String()
+		opts.composefiles, err = cmd.Flags().GetStringSlice("file")
+		if err != nil {
+			return err
+		}
 	}
 
-	log.G(cmd.Context()).WithField("composefile", opts.composefile).Debug("using")
+	if cmd.Flag("project-name").Changed {
+		opts.projectName = cmd.Flag("project-name").Value.String()
+	}
+
+	log.G(cmd.Context()).WithField("composefiles", opts.composefiles).Debug("using")
 	return nil
 }
 
@@ -74,11 +83,15 @@ func (opts *DownOptions) Run(ctx context.Context, args []string) error {
 	if err != nil {
 		return err
 	}
-	project, err := compose.NewProjectFromComposeFile(ctx, workdir, opts.composefile)
+	project, err := compose.NewProjectFromComposeFiles(ctx, workdir, opts.composefiles, compose.WithProjectName(opts.projectName))
 	if err != nil {
 		return err
 	}
 
+	if err := project.Load(ctx); err != nil {
+		return err
+	}
+
 	if err := project.Validate(ctx); err != nil {
 		return err
 	}
@@ -102,14 +115,28 @@ func (opts *DownOptions) Run(ctx context.Context, args []string) error {
 	orderedServices := project.ServicesReversedByDependencies(ctx, project.Services, false)
 	for _, service := range orderedServices {
 		for _, machine := range machines.Items {
-			if service.ContainerName == machine.Name {
-				if err := removeService(ctx, service); err != nil {
+			if slices.Contains(compose.ServiceContainerNames(service), machine.Name) {
+				if err := removeService(ctx, service, machine.Name); err != nil {
+					// Persist the addresses released so far such that they can be
+					// re-used.
+					if err := project.Save(ctx); err != nil {
+						log.G(ctx).WithError(err).Debug("could not save project state")
+					}
+
 					return err
 				}
+
+				project.ReleaseAddresses(ctx, machine.Name)
 			}
 		}
 	}
 
+	// Persist the released addresses before removing the networks, which may
+	// fail.
+	if err := project.Save(ctx); err != nil {
+		return err
+	}
+
 	networkController, err := mnetwork.NewNetworkV1alpha1ServiceIterator(ctx)
 	if err != nil {
 		return err
@@ -133,14 +160,23 @@ func (opts *DownOptions) Run(ctx context.Context, args []string) error {
 		}
 	}
 
-	return nil
+	return project.RemoveState(ctx)
 }
 
-func removeService(ctx context.Context, service types.ServiceConfig) error {
-	log.G(ctx).Infof("removing service %s...", service.Name)
-	removeOptions := machineremove.RemoveOptions{Platform: "auto"}
+func removeService(ctx context.Context, service types.ServiceConfig, container string) error {
+	log.G(ctx).Infof("removing service %s (%s)...", service.Name, container)
+	signal, err := compose.ServiceStopSignal(service)
+	if err != nil {
+		return err
+	}
+
+	removeOptions := machineremove.RemoveOptions{
+		Platform: "auto",
+		Signal:   signal,
+		Timeout:  compose.ServiceStopGracePeriod(service),
+	}
 
-	return removeOptions.Run(ctx, []string{service.ContainerName})
+	return removeOptions.Run(ctx, []string{container})
 }
 
 func removeNetwork(ctx context.Context, network types.NetworkConfig) error {
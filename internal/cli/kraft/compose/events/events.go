@@ -0,0 +1,421 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/compose"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/packmanager"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	mplatform "kraftkit.sh/machine/platform"
+)
+
+type EventsOptions struct {
+	Granularity time.Duration `long:"poll-granularity" usage:"How often the state of the machines is polled" default:"1s"`
+	JSON        bool          `long:"json" usage:"Print each event as a JSON object"`
+	Since       string        `long:"since" usage:"Show events since a timestamp (RFC 3339) or relative duration (e.g. 10m)"`
+	Until       string        `long:"until" usage:"Stream events until a timestamp (RFC 3339) or relative duration (e.g. 10m)"`
+
+	composefiles []string
+	projectName  string
+	since        time.Time
+	until        time.Time
+}
+
+// Event is a lifecycle event of a machine of a service.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Service string    `json:"service"`
+	Machine string    `json:"machine"`
+	Action  string    `json:"action"`
+}
+
+const (
+	ActionCreated    = "created"
+	ActionStarted    = "started"
+	ActionPaused     = "paused"
+	ActionUnpaused   = "unpaused"
+	ActionSuspended  = "suspended"
+	ActionRestarting = "restarting"
+	ActionExited     = "exited"
+	ActionFailed     = "failed"
+	ActionRemoved    = "removed"
+)
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&EventsOptions{}, cobra.Command{
+		Short:   "Stream the lifecycle events of a compose project",
+		Use:     "events [FLAGS] [SERVICE...]",
+		Aliases: []string{},
+		Long: heredoc.Doc(`
+			Stream the lifecycle events of the machines of a compose project.
+
+			The state of the machines is polled and an event is printed whenever a
+			machine is created, started, paused, unpaused, exited or removed.
+
+			Events which occurred before the command was started are only known for
+			machines which still exist and are limited to when they were created,
+			started and exited.
+		`),
+		Example: heredoc.Doc(`
+			# Stream the events of a compose project
+			$ kraft compose events
+
+			# Stream the events of a single service as JSON
+			$ kraft compose events --json nginx
+
+			# Show the events of the last 10 minutes and stream new events for the
+			# next 5 minutes
+			$ kraft compose events --since 10m --until=-5m
+		`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "compose",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *EventsOptions) Pre(cmd *cobra.Command, _ []string) error {
+	ctx, err := packmanager.WithDefaultUmbrellaManagerInContext(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	cmd.SetContext(ctx)
+
+	if cmd.Flag("file").Changed {
+		opts.composefiles, err = cmd.Flags().GetStringSlice("file")
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.Flag("project-name").Changed {
+		opts.projectName = cmd.Flag("project-name").Value.String()
+	}
+
+	if opts.Granularity <= 0 {
+		return fmt.Errorf("poll granularity must be positive")
+	}
+
+	now := time.Now()
+
+	if opts.Since != "" {
+		if opts.since, err = parseTime(opts.Since, now); err != nil {
+			return fmt.Errorf("could not parse --since: %w", err)
+		}
+	}
+
+	if opts.Until != "" {
+		if opts.until, err = parseTime(opts.Until, now); err != nil {
+			return fmt.Errorf("could not parse --until: %w", err)
+		}
+	}
+
+	if !opts.since.IsZero() && !opts.until.IsZero() && opts.until.Before(opts.since) {
+		return fmt.Errorf("--until must not be before --since")
+	}
+
+	log.G(cmd.Context()).WithField("composefiles", opts.composefiles).Debug("using")
+	return nil
+}
+
+// parseTime parses either an RFC 3339 timestamp or a duration relative to now.
+// Positive durations refer to the past, e.g. `10m` is ten minutes ago, and
+// negative durations refer to the future, e.g. `-5m` is in five minutes, as is
+// the convention of `docker events`.
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected an RFC 3339 timestamp or duration but got '%s'", value)
+	}
+
+	return now.Add(-d), nil
+}
+
+func (opts *EventsOptions) Run(ctx context.Context, args []string) error {
+	workdir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	project, err := compose.NewProjectFromComposeFiles(ctx, workdir, opts.composefiles, compose.WithProjectName(opts.projectName))
+	if err != nil {
+		return err
+	}
+
+	if err := project.Load(ctx); err != nil {
+		return err
+	}
+
+	services, err := project.GetServices(args...)
+	if err != nil {
+		return err
+	}
+
+	containers := map[string]string{}
+	for _, service := range services {
+		for _, container := range compose.ServiceContainerNames(service) {
+			containers[container] = service.Name
+		}
+	}
+
+	machineController, err := mplatform.NewMachineV1alpha1ServiceIterator(ctx)
+	if err != nil {
+		return err
+	}
+
+	// serviceOf returns the service of the provided machine or false if the
+	// machine does not belong to the requested services.  Machines of services
+	// which have since been removed from the project are attributed via their
+	// labels when no services were requested explicitly.
+	serviceOf := func(machine machineapi.Machine) (string, bool) {
+		if service, ok := containers[machine.Name]; ok {
+			return service, true
+		}
+
+		if len(args) > 0 || machine.ObjectMeta.Labels[compose.LabelProject] != project.Name {
+			return "", false
+		}
+
+		service, ok := machine.ObjectMeta.Labels[compose.LabelService]
+		return service, ok
+	}
+
+	snapshot := func() (map[string]machineSnapshot, error) {
+		machines, err := machineController.List(ctx, &machineapi.MachineList{})
+		if err != nil {
+			return nil, fmt.Errorf("could not list machines: %w", err)
+		}
+
+		snapshot := map[string]machineSnapshot{}
+		for _, machine := range machines.Items {
+			service, ok := serviceOf(machine)
+			if !ok {
+				continue
+			}
+
+			snapshot[machine.Name] = machineSnapshot{
+				service:   service,
+				state:     machine.Status.State,
+				createdAt: machine.ObjectMeta.CreationTimestamp.Time,
+				startedAt: machine.Status.StartedAt,
+				exitedAt:  machine.Status.ExitedAt,
+			}
+		}
+
+		return snapshot, nil
+	}
+
+	out := iostreams.G(ctx).Out
+
+	previous, err := snapshot()
+	if err != nil {
+		return err
+	}
+
+	if !opts.since.IsZero() {
+		for _, event := range pastEvents(previous, opts.since, opts.until) {
+			if err := opts.print(out, event); err != nil {
+				return err
+			}
+		}
+	}
+
+	ticker := time.NewTicker(opts.Granularity)
+	defer ticker.Stop()
+
+	for {
+		if !opts.until.IsZero() && !time.Now().Before(opts.until) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := snapshot()
+		if err != nil {
+			return err
+		}
+
+		for _, event := range diff(previous, current, time.Now()) {
+			if err := opts.print(out, event); err != nil {
+				return err
+			}
+		}
+
+		previous = current
+	}
+}
+
+// print writes the event to w either as a JSON object or a human-readable
+// line.
+func (opts *EventsOptions) print(w io.Writer, event Event) error {
+	if opts.JSON {
+		return json.NewEncoder(w).Encode(event)
+	}
+
+	_, err := fmt.Fprintf(w, "%s %s %s %s\n",
+		event.Time.Format(time.RFC3339Nano),
+		event.Service,
+		event.Machine,
+		event.Action,
+	)
+	return err
+}
+
+// machineSnapshot is the state of a machine at the time it was polled.
+type machineSnapshot struct {
+	service   string
+	state     machineapi.MachineState
+	createdAt time.Time
+	startedAt time.Time
+	exitedAt  time.Time
+}
+
+// pastEvents returns the events which are known to have occurred between since
+// and until, if set, based on the timestamps of the machines, ordered by time.
+func pastEvents(machines map[string]machineSnapshot, since, until time.Time) []Event {
+	var events []Event
+
+	add := func(name string, machine machineSnapshot, at time.Time, action string) {
+		if at.IsZero() || at.Before(since) || (!until.IsZero() && at.After(until)) {
+			return
+		}
+
+		events = append(events, Event{
+			Time:    at,
+			Service: machine.service,
+			Machine: name,
+			Action:  action,
+		})
+	}
+
+	for name, machine := range machines {
+		add(name, machine, machine.createdAt, ActionCreated)
+		add(name, machine, machine.startedAt, ActionStarted)
+
+		if machine.state == machineapi.MachineStateFailed {
+			add(name, machine, machine.exitedAt, ActionFailed)
+		} else {
+			add(name, machine, machine.exitedAt, ActionExited)
+		}
+	}
+
+	sortEvents(events)
+
+	return events
+}
+
+// diff returns the events which explain the change from previous to current.
+func diff(previous, current map[string]machineSnapshot, now time.Time) []Event {
+	var events []Event
+
+	for name, machine := range current {
+		before, existed := previous[name]
+		if !existed {
+			events = append(events, Event{
+				Time:    now,
+				Service: machine.service,
+				Machine: name,
+				Action:  ActionCreated,
+			})
+		}
+
+		if existed && before.state == machine.state {
+			continue
+		}
+
+		action := stateAction(before.state, machine.state)
+		if action == "" {
+			continue
+		}
+
+		events = append(events, Event{
+			Time:    now,
+			Service: machine.service,
+			Machine: name,
+			Action:  action,
+		})
+	}
+
+	for name, machine := range previous {
+		if _, ok := current[name]; ok {
+			continue
+		}
+
+		events = append(events, Event{
+			Time:    now,
+			Service: machine.service,
+			Machine: name,
+			Action:  ActionRemoved,
+		})
+	}
+
+	sortEvents(events)
+
+	return events
+}
+
+// stateAction returns the action which caused a machine to transition from one
+// state to another, or an empty string if the transition is not an event.
+func stateAction(from, to machineapi.MachineState) string {
+	switch to {
+	case machineapi.MachineStateRunning:
+		if from == machineapi.MachineStatePaused {
+			return ActionUnpaused
+		}
+		return ActionStarted
+	case machineapi.MachineStatePaused:
+		return ActionPaused
+	case machineapi.MachineStateSuspended:
+		return ActionSuspended
+	case machineapi.MachineStateRestarting:
+		return ActionRestarting
+	case machineapi.MachineStateExited:
+		return ActionExited
+	case machineapi.MachineStateFailed, machineapi.MachineStateErrored:
+		return ActionFailed
+	default:
+		return ""
+	}
+}
+
+// sortEvents orders events by time and then by machine such that the output
+// is deterministic, keeping the order of events of the same machine.
+func sortEvents(events []Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Time.Equal(events[j].Time) {
+			return events[i].Time.Before(events[j].Time)
+		}
+
+		return events[i].Machine < events[j].Machine
+	})
+}
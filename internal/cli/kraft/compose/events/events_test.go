@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package events
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+)
+
+func TestDiff(t *testing.T) {
+	now := time.Now()
+
+	previous := map[string]machineSnapshot{
+		"app-1": {service: "app", state: machineapi.MachineStateRunning},
+		"app-2": {service: "app", state: machineapi.MachineStatePaused},
+		"db":    {service: "db", state: machineapi.MachineStateRunning},
+	}
+
+	current := map[string]machineSnapshot{
+		"app-1": {service: "app", state: machineapi.MachineStatePaused},
+		"app-2": {service: "app", state: machineapi.MachineStateRunning},
+		"cache": {service: "cache", state: machineapi.MachineStateRunning},
+	}
+
+	expected := []Event{
+		{Time: now, Service: "app", Machine: "app-1", Action: ActionPaused},
+		{Time: now, Service: "app", Machine: "app-2", Action: ActionUnpaused},
+		{Time: now, Service: "cache", Machine: "cache", Action: ActionCreated},
+		{Time: now, Service: "cache", Machine: "cache", Action: ActionStarted},
+		{Time: now, Service: "db", Machine: "db", Action: ActionRemoved},
+	}
+
+	if got := diff(previous, current, now); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for value, expected := range map[string]time.Time{
+		"2024-01-01T11:00:00Z": time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC),
+		"10m":                  now.Add(-10 * time.Minute),
+		"-5m":                  now.Add(5 * time.Minute),
+	} {
+		got, err := parseTime(value, now)
+		if err != nil {
+			t.Errorf("parseTime(%q): %v", value, err)
+			continue
+		}
+
+		if !got.Equal(expected) {
+			t.Errorf("parseTime(%q): expected %s, got %s", value, expected, got)
+		}
+	}
+
+	if _, err := parseTime("yesterday", now); err == nil {
+		t.Error("expected an invalid time to be rejected")
+	}
+}
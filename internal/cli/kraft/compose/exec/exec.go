@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/compose"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/packmanager"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	mplatform "kraftkit.sh/machine/platform"
+)
+
+type ExecOptions struct {
+	Composefiles []string `noattribute:"true"`
+	Index        int      `long:"index" usage:"Index of the service's replica to execute the command in" default:"1"`
+	Interactive  bool     `long:"interactive" short:"i" usage:"Keep standard input open"`
+	ProjectName  string   `noattribute:"true"`
+	Tty          bool     `long:"tty" short:"t" usage:"Allocate a pseudo-terminal"`
+}
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&ExecOptions{}, cobra.Command{
+		Short:   "Execute a command in a running service",
+		Use:     "exec [FLAGS] SERVICE COMMAND [ARGS...]",
+		Args:    cobra.MinimumNArgs(2),
+		Aliases: []string{},
+		Example: heredoc.Doc(`
+			# Execute a command in the nginx service
+			$ kraft compose exec nginx ls /
+
+			# Start an interactive shell in the second replica of the nginx service
+			$ kraft compose exec -it --index 2 nginx /bin/sh
+		`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "compose",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	// Flags which follow the command belong to the command itself.
+	cmd.Flags().SetInterspersed(false)
+
+	return cmd
+}
+
+func (opts *ExecOptions) Pre(cmd *cobra.Command, _ []string) error {
+	ctx, err := packmanager.WithDefaultUmbrellaManagerInContext(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	cmd.SetContext(ctx)
+
+	if cmd.Flag("file").Changed {
+		opts.Composefiles, err = cmd.Flags().GetStringSlice("file")
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.Flag("project-name").Changed {
+		opts.ProjectName = cmd.Flag("project-name").Value.String()
+	}
+
+	if opts.Index < 1 {
+		return fmt.Errorf("index must be greater than zero")
+	}
+
+	log.G(cmd.Context()).WithField("composefiles", opts.Composefiles).Debug("using")
+	return nil
+}
+
+func (opts *ExecOptions) Run(ctx context.Context, args []string) error {
+	workdir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	project, err := compose.NewProjectFromComposeFiles(ctx, workdir, opts.Composefiles, compose.WithProjectName(opts.ProjectName))
+	if err != nil {
+		return err
+	}
+
+	if err := project.Load(ctx); err != nil {
+		return err
+	}
+
+	if err := project.Validate(ctx); err != nil {
+		return err
+	}
+
+	service, err := project.GetService(args[0])
+	if err != nil {
+		return err
+	}
+
+	containers := compose.ServiceContainerNames(service)
+	if opts.Index > len(containers) {
+		return fmt.Errorf("service %s has no replica with index %d", service.Name, opts.Index)
+	}
+
+	controller, err := mplatform.NewMachineV1alpha1ServiceIterator(ctx)
+	if err != nil {
+		return err
+	}
+
+	machine, err := controller.Get(ctx, &machineapi.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: containers[opts.Index-1],
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not find machine for service %s: %w", service.Name, err)
+	}
+
+	if machine.Status.State != machineapi.MachineStateRunning {
+		return fmt.Errorf("service %s is not running", service.Name)
+	}
+
+	platform, ok := mplatform.PlatformsByName()[machine.Spec.Platform]
+	if !ok {
+		return fmt.Errorf("unknown platform driver: %s", machine.Spec.Platform)
+	}
+
+	strategy, ok := mplatform.Strategies()[platform]
+	if !ok {
+		return fmt.Errorf("unsupported platform driver: %s", platform.String())
+	}
+
+	platformController, err := strategy.NewMachineV1alpha1(ctx)
+	if err != nil {
+		return err
+	}
+
+	execController, ok := platformController.(machineapi.MachineExecService)
+	if !ok {
+		return fmt.Errorf("exec is not supported on platform %s", platform.String())
+	}
+
+	execOpts := machineapi.ExecOptions{
+		Command: args[1:],
+		Stdout:  iostreams.G(ctx).Out,
+		Stderr:  iostreams.G(ctx).ErrOut,
+		Tty:     opts.Tty,
+	}
+	if opts.Interactive {
+		execOpts.Stdin = iostreams.G(ctx).In
+	}
+
+	return execController.Exec(ctx, machine, execOpts)
+}
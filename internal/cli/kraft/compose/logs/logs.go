@@ -28,7 +28,8 @@ import (
 type LogsOptions struct {
 	Follow bool `long:"follow" usage:"Follow log output"`
 
-	Composefile string `noattribute:"true"`
+	Composefiles []string `noattribute:"true"`
+	ProjectName  string   `noattribute:"true"`
 }
 
 func NewCmd() *cobra.Command {
@@ -55,10 +56,17 @@ func (opts *LogsOptions) Pre(cmd *cobra.Command, _ []string) error {
 	cmd.SetContext(ctx)
 
 	if cmd.Flag("file").Changed {
-		opts.Composefile = cmd.Flag("file").Value.String()
+		opts.Composefiles, err = cmd.Flags().GetStringSlice("file")
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.Flag("project-name").Changed {
+		opts.ProjectName = cmd.Flag("project-name").Value.String()
 	}
 
-	log.G(cmd.Context()).WithField("composefile", opts.Composefile).Debug("using")
+	log.G(cmd.Context()).WithField("composefiles", opts.Composefiles).Debug("using")
 
 	return nil
 }
@@ -69,11 +77,15 @@ func (opts *LogsOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
-	project, err := compose.NewProjectFromComposeFile(ctx, workdir, opts.Composefile)
+	project, err := compose.NewProjectFromComposeFiles(ctx, workdir, opts.Composefiles, compose.WithProjectName(opts.ProjectName))
 	if err != nil {
 		return err
 	}
 
+	if err := project.Load(ctx); err != nil {
+		return err
+	}
+
 	if err := project.Validate(ctx); err != nil {
 		return err
 	}
@@ -93,13 +105,15 @@ func (opts *LogsOptions) Run(ctx context.Context, args []string) error {
 		if len(args) == 0 && service.Attach != nil && !*service.Attach {
 			continue
 		}
-		machine, _ := controller.Get(ctx, &machineapi.Machine{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: service.ContainerName,
-			},
-		})
-		if machine != nil {
-			machinesToLog = append(machinesToLog, machine.Name)
+		for _, container := range compose.ServiceContainerNames(service) {
+			machine, _ := controller.Get(ctx, &machineapi.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: container,
+				},
+			})
+			if machine != nil {
+				machinesToLog = append(machinesToLog, machine.Name)
+			}
 		}
 	}
 
@@ -8,6 +8,7 @@ package ls
 import (
 	"context"
 	"path/filepath"
+	"strings"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
@@ -139,8 +140,21 @@ func (opts *LsOptions) Run(ctx context.Context, args []string) error {
 		table.AddField(project.Name, nil)
 		table.AddField(status.String(), ps.MachineStateColor[status])
 
-		composefile := filepath.Join(project.Spec.Workdir, project.Spec.Composefile)
-		table.AddField(composefile, nil)
+		composefiles := project.Spec.Composefiles
+		if len(composefiles) == 0 {
+			composefiles = []string{project.Spec.Composefile}
+		}
+
+		paths := make([]string, len(composefiles))
+		for i, composefile := range composefiles {
+			if filepath.IsAbs(composefile) {
+				paths[i] = composefile
+			} else {
+				paths[i] = filepath.Join(project.Spec.Workdir, composefile)
+			}
+		}
+
+		table.AddField(strings.Join(paths, ","), nil)
 		table.EndRow()
 	}
 
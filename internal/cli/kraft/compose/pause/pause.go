@@ -7,6 +7,8 @@ package pause
 import (
 	"context"
 	"os"
+	"slices"
+	"time"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
@@ -22,7 +24,10 @@ import (
 )
 
 type PauseOptions struct {
-	composefile string
+	Timeout time.Duration `long:"timeout" usage:"Time to wait for each service to quiesce before pausing it"`
+
+	composefiles []string
+	projectName  string
 }
 
 func NewCmd() *cobra.Command {
@@ -33,6 +38,9 @@ func NewCmd() *cobra.Command {
 		Example: heredoc.Doc(`
 			# Pause a compose project
 			$ kraft compose pause 
+
+			# Allow each service up to 10 seconds to quiesce before pausing it
+			$ kraft compose pause --timeout 10s
 		`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "compose",
@@ -54,10 +62,17 @@ func (opts *PauseOptions) Pre(cmd *cobra.Command, _ []string) error {
 	cmd.SetContext(ctx)
 
 	if cmd.Flag("file").Changed {
-		opts.composefile = cmd.Flag("file").Value.String()
+		opts.composefiles, err = cmd.Flags().GetStringSlice("file")
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.Flag("project-name").Changed {
+		opts.projectName = cmd.Flag("project-name").Value.String()
 	}
 
-	log.G(cmd.Context()).WithField("composefile", opts.composefile).Debug("using")
+	log.G(cmd.Context()).WithField("composefiles", opts.composefiles).Debug("using")
 	return nil
 }
 
@@ -67,11 +82,15 @@ func (opts *PauseOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
-	project, err := compose.NewProjectFromComposeFile(ctx, workdir, opts.composefile)
+	project, err := compose.NewProjectFromComposeFiles(ctx, workdir, opts.composefiles, compose.WithProjectName(opts.projectName))
 	if err != nil {
 		return err
 	}
 
+	if err := project.Load(ctx); err != nil {
+		return err
+	}
+
 	if err := project.Validate(ctx); err != nil {
 		return err
 	}
@@ -95,7 +114,7 @@ func (opts *PauseOptions) Run(ctx context.Context, args []string) error {
 	machinesToPause := []string{}
 	for _, service := range orderedServices {
 		for _, machine := range machines.Items {
-			if service.ContainerName == machine.Name && machine.Status.State == machineapi.MachineStateRunning {
+			if slices.Contains(compose.ServiceContainerNames(service), machine.Name) && machine.Status.State == machineapi.MachineStateRunning {
 				machinesToPause = append(machinesToPause, machine.Name)
 			}
 		}
@@ -107,6 +126,7 @@ func (opts *PauseOptions) Run(ctx context.Context, args []string) error {
 
 	kernelPauseOptions := kernelpause.PauseOptions{
 		Platform: "auto",
+		Timeout:  opts.Timeout,
 	}
 
 	return kernelPauseOptions.Run(ctx, machinesToPause)
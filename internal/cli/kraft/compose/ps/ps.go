@@ -10,6 +10,7 @@ import (
 	"os"
 
 	"github.com/MakeNowJust/heredoc"
+	"github.com/compose-spec/compose-go/v2/types"
 	"github.com/spf13/cobra"
 	"kraftkit.sh/cmdfactory"
 	"kraftkit.sh/compose"
@@ -28,19 +29,23 @@ type PsOptions struct {
 	Quiet   bool   `long:"quiet" short:"q" usage:"Only display machine IDs"`
 	ShowAll bool   `long:"all" short:"a" usage:"Show all machines (default shows just running)"`
 
-	composefile string
+	composefiles []string
+	projectName  string
 }
 
 func NewCmd() *cobra.Command {
 	cmd, err := cmdfactory.New(&PsOptions{}, cobra.Command{
 		Short:   "List running services of current project",
-		Use:     "ps [FLAGS]",
-		Args:    cobra.NoArgs,
+		Use:     "ps [FLAGS] [SERVICE...]",
+		Args:    cobra.ArbitraryArgs,
 		Aliases: []string{},
 		Long:    "List running services of current project.",
 		Example: heredoc.Doc(`
 			# List running services of current project
 			$ kraft compose ps
+
+			# List specific services of the current project
+			$ kraft compose ps nginx redis
 		`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "compose",
@@ -62,10 +67,17 @@ func (opts *PsOptions) Pre(cmd *cobra.Command, _ []string) error {
 	cmd.SetContext(ctx)
 
 	if cmd.Flag("file").Changed {
-		opts.composefile = cmd.Flag("file").Value.String()
+		opts.composefiles, err = cmd.Flags().GetStringSlice("file")
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.Flag("project-name").Changed {
+		opts.projectName = cmd.Flag("project-name").Value.String()
 	}
 
-	log.G(cmd.Context()).WithField("composefile", opts.composefile).Debug("using")
+	log.G(cmd.Context()).WithField("composefiles", opts.composefiles).Debug("using")
 	return nil
 }
 
@@ -74,11 +86,15 @@ func (opts *PsOptions) Run(ctx context.Context, args []string) error {
 	if err != nil {
 		return err
 	}
-	project, err := compose.NewProjectFromComposeFile(ctx, workdir, opts.composefile)
+	project, err := compose.NewProjectFromComposeFiles(ctx, workdir, opts.composefiles, compose.WithProjectName(opts.projectName))
 	if err != nil {
 		return err
 	}
 
+	if err := project.Load(ctx); err != nil {
+		return err
+	}
+
 	if err := project.Validate(ctx); err != nil {
 		return err
 	}
@@ -109,24 +125,55 @@ func (opts *PsOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
+	services, err := project.GetServices(args...)
+	if err != nil {
+		return err
+	}
+
+	containers := map[string]types.ServiceConfig{}
+	for _, service := range services {
+		for _, container := range compose.ServiceContainerNames(service) {
+			containers[container] = service
+		}
+	}
+
 	filteredPsTable := []pslist.PsEntry{}
 	for _, psEntry := range psTable {
 		for _, machine := range embeddedProject.Status.Machines {
-			orphaned := true
-			for _, service := range project.Services {
-				if service.ContainerName == machine.Name {
-					orphaned = false
-					break
-				}
+			if psEntry.Name != machine.Name {
+				continue
 			}
 
-			if orphaned && !opts.Orphans {
-				continue
+			// Machines which do not map to a service are either orphaned or were
+			// not requested.
+			service, ok := containers[machine.Name]
+			if !ok && (len(args) > 0 || !opts.Orphans) {
+				break
 			}
 
-			if psEntry.Name == machine.Name {
+			// Orphaned machines are still attributed to the service which created
+			// them, if known.
+			if !ok {
+				psEntry.Service = psEntry.Labels[compose.LabelService]
 				filteredPsTable = append(filteredPsTable, psEntry)
+				break
 			}
+
+			psEntry.Service = service.Name
+
+			// Machines only report their ports whilst running, otherwise show the
+			// ports which the service publishes.
+			if psEntry.Ports == "" {
+				ports, err := compose.ServicePorts(service, machine.Name)
+				if err != nil {
+					return err
+				}
+
+				psEntry.Ports = ports.String()
+			}
+
+			filteredPsTable = append(filteredPsTable, psEntry)
+			break
 		}
 	}
 
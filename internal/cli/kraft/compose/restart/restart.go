@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package restart
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/compose-spec/compose-go/v2/cli"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/compose"
+	"kraftkit.sh/log"
+	"kraftkit.sh/packmanager"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	kernelstart "kraftkit.sh/internal/cli/kraft/start"
+	kernelstop "kraftkit.sh/internal/cli/kraft/stop"
+	mplatform "kraftkit.sh/machine/platform"
+)
+
+type RestartOptions struct {
+	Composefiles []string      `noattribute:"true"`
+	ProjectName  string        `noattribute:"true"`
+	EnvFiles     []string      `long:"env-file" usage:"Set an alternative environment file"`
+	Timeout      time.Duration `long:"timeout" usage:"Maximum time to wait for the services to stop (default: the stop grace period of each service)"`
+}
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&RestartOptions{}, cobra.Command{
+		Short:   "Restart a compose project",
+		Use:     "restart [FLAGS] [SERVICE [SERVICE [...]]]",
+		Aliases: []string{},
+		Example: heredoc.Doc(`
+			# Restart a compose project
+			$ kraft compose restart
+
+			# Restart a single service of a compose project
+			$ kraft compose restart nginx
+		`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "compose",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *RestartOptions) Pre(cmd *cobra.Command, _ []string) error {
+	ctx, err := packmanager.WithDefaultUmbrellaManagerInContext(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	cmd.SetContext(ctx)
+
+	if cmd.Flag("file").Changed {
+		opts.Composefiles, err = cmd.Flags().GetStringSlice("file")
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.Flag("project-name").Changed {
+		opts.ProjectName = cmd.Flag("project-name").Value.String()
+	}
+
+	log.G(cmd.Context()).WithField("composefiles", opts.Composefiles).Debug("using")
+	return nil
+}
+
+func (opts *RestartOptions) Run(ctx context.Context, args []string) error {
+	workdir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	projectOpts := []cli.ProjectOptionsFn{
+		compose.WithProjectName(opts.ProjectName),
+	}
+	if len(opts.EnvFiles) > 0 {
+		envFiles := make([]string, len(opts.EnvFiles))
+		for i, envFile := range opts.EnvFiles {
+			if !filepath.IsAbs(envFile) {
+				envFile = filepath.Join(workdir, envFile)
+			}
+			envFiles[i] = envFile
+		}
+
+		projectOpts = append(projectOpts,
+			cli.WithEnvFiles(envFiles...),
+			cli.WithDotEnv,
+		)
+	}
+
+	project, err := compose.NewProjectFromComposeFiles(ctx, workdir, opts.Composefiles, projectOpts...)
+	if err != nil {
+		return err
+	}
+
+	if err := project.Load(ctx); err != nil {
+		return err
+	}
+
+	if err := project.Validate(ctx); err != nil {
+		return err
+	}
+
+	machineController, err := mplatform.NewMachineV1alpha1ServiceIterator(ctx)
+	if err != nil {
+		return err
+	}
+
+	machines, err := machineController.List(ctx, &machineapi.MachineList{})
+	if err != nil {
+		return err
+	}
+
+	services, err := project.GetServices(args...)
+	if err != nil {
+		return err
+	}
+
+	// Stop the services in the reverse order of their dependencies such that no
+	// service loses a dependency whilst it is still running.  Each service is
+	// stopped with its own signal and grace period, unless a timeout is provided,
+	// before its machines are forcefully stopped.
+	for _, service := range project.ServicesReversedByDependencies(ctx, services, false) {
+		signal, err := compose.ServiceStopSignal(service)
+		if err != nil {
+			return err
+		}
+
+		timeout := compose.ServiceStopGracePeriod(service)
+		if opts.Timeout > 0 {
+			timeout = opts.Timeout
+		}
+
+		for _, machine := range machines.Items {
+			if !slices.Contains(compose.ServiceContainerNames(service), machine.Name) ||
+				(machine.Status.State != machineapi.MachineStateRunning &&
+					machine.Status.State != machineapi.MachineStatePaused) {
+				continue
+			}
+
+			if err := kernelstop.StopMachine(ctx, machineController, &machine, signal, timeout); err != nil {
+				return fmt.Errorf("could not stop machine %s: %w", machine.Name, err)
+			}
+		}
+	}
+
+	// Start the services in the order of their dependencies.
+	machinesToStart := []string{}
+	for _, service := range project.ServicesOrderedByDependencies(ctx, services, false) {
+		for _, machine := range machines.Items {
+			if slices.Contains(compose.ServiceContainerNames(service), machine.Name) {
+				machinesToStart = append(machinesToStart, machine.Name)
+			}
+		}
+	}
+
+	if len(machinesToStart) == 0 {
+		return nil
+	}
+
+	kernelStartOptions := kernelstart.StartOptions{
+		Detach:   true,
+		Platform: "auto",
+	}
+
+	return kernelStartOptions.Run(ctx, machinesToStart)
+}
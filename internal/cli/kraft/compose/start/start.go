@@ -6,9 +6,16 @@ package start
 
 import (
 	"context"
+	"fmt"
+	"maps"
 	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/MakeNowJust/heredoc"
+	"github.com/compose-spec/compose-go/v2/types"
 	"github.com/spf13/cobra"
 
 	"kraftkit.sh/cmdfactory"
@@ -22,7 +29,10 @@ import (
 )
 
 type StartOptions struct {
-	Composefile string `noattribute:"true"`
+	Composefiles []string      `noattribute:"true"`
+	ProjectName  string        `noattribute:"true"`
+	Timeout      time.Duration `long:"timeout" usage:"Maximum time to wait for the services to be running and healthy when using --wait" default:"60s"`
+	Wait         bool          `long:"wait" usage:"Wait for the services to be running and healthy before returning"`
 }
 
 func NewCmd() *cobra.Command {
@@ -33,6 +43,9 @@ func NewCmd() *cobra.Command {
 		Example: heredoc.Doc(`
 			# Start a compose project
 			$ kraft compose start 
+
+			# Start a compose project and wait for its services to be running
+			$ kraft compose start --wait --timeout 30s
 		`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "compose",
@@ -54,10 +67,17 @@ func (opts *StartOptions) Pre(cmd *cobra.Command, _ []string) error {
 	cmd.SetContext(ctx)
 
 	if cmd.Flag("file").Changed {
-		opts.Composefile = cmd.Flag("file").Value.String()
+		opts.Composefiles, err = cmd.Flags().GetStringSlice("file")
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.Flag("project-name").Changed {
+		opts.ProjectName = cmd.Flag("project-name").Value.String()
 	}
 
-	log.G(cmd.Context()).WithField("composefile", opts.Composefile).Debug("using")
+	log.G(cmd.Context()).WithField("composefiles", opts.Composefiles).Debug("using")
 	return nil
 }
 
@@ -67,11 +87,15 @@ func (opts *StartOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
-	project, err := compose.NewProjectFromComposeFile(ctx, workdir, opts.Composefile)
+	project, err := compose.NewProjectFromComposeFiles(ctx, workdir, opts.Composefiles, compose.WithProjectName(opts.ProjectName))
 	if err != nil {
 		return err
 	}
 
+	if err := project.Load(ctx); err != nil {
+		return err
+	}
+
 	if err := project.Validate(ctx); err != nil {
 		return err
 	}
@@ -92,25 +116,153 @@ func (opts *StartOptions) Run(ctx context.Context, args []string) error {
 	}
 
 	orderedServices := project.ServicesOrderedByDependencies(ctx, services, true)
-	machinesToStart := []string{}
+	startedServices := []types.ServiceConfig{}
+	machineServices := map[string]string{}
+
+	kernelStartOptions := kernelstart.StartOptions{
+		Detach:   true,
+		Platform: "auto",
+	}
+
 	for _, service := range orderedServices {
+		machinesToStart := []string{}
 		for _, machine := range machines.Items {
-			if service.ContainerName == machine.Name {
+			if slices.Contains(compose.ServiceContainerNames(service), machine.Name) {
 				if machine.Status.State == machineapi.MachineStateCreated || machine.Status.State == machineapi.MachineStateExited {
 					machinesToStart = append(machinesToStart, machine.Name)
+					machineServices[machine.Name] = service.Name
 				}
 			}
 		}
+
+		if len(machinesToStart) == 0 {
+			continue
+		}
+
+		// Dependencies which must be healthy are waited upon before the service
+		// is started.
+		for name, dependency := range service.DependsOn {
+			if dependency.Condition != types.ServiceConditionHealthy {
+				continue
+			}
+
+			depService, err := project.GetService(name)
+			if err != nil {
+				return err
+			}
+
+			log.G(ctx).
+				WithField("service", service.Name).
+				WithField("dependency", name).
+				Info("waiting for dependency to be healthy")
+
+			// A dependency which never becomes healthy, e.g. because its machines
+			// never start, must not block the service indefinitely.
+			depCtx, cancel := context.WithTimeout(ctx, compose.HealthyTimeout(depService))
+			err = project.WaitHealthy(depCtx, machineController, depService)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("dependency of service %s: %w", service.Name, err)
+			}
+		}
+
+		if err := kernelStartOptions.Run(ctx, machinesToStart); err != nil {
+			return err
+		}
+
+		startedServices = append(startedServices, service)
 	}
 
-	kernelStartOptions := kernelstart.StartOptions{
-		Detach:   true,
-		Platform: "auto",
+	if !opts.Wait || len(machineServices) == 0 {
+		return nil
 	}
 
-	if err := kernelStartOptions.Run(ctx, machinesToStart); err != nil {
+	waitCtx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	if err := waitForRunning(waitCtx, machineController, machineServices, opts.Timeout); err != nil {
 		return err
 	}
 
+	for _, service := range startedServices {
+		if !compose.HasHealthcheck(service) {
+			continue
+		}
+
+		if err := project.WaitHealthy(waitCtx, machineController, service); err != nil {
+			if waitCtx.Err() != nil {
+				return fmt.Errorf("service %s did not become healthy within %s", service.Name, opts.Timeout)
+			}
+			return err
+		}
+	}
+
 	return nil
 }
+
+// waitForRunning polls the machine controller until each of the provided
+// machines, which map to the name of their service, is running.  If the
+// timeout is reached, the services which have not come up are reported.
+func waitForRunning(ctx context.Context, controller machineapi.MachineService, machineServices map[string]string, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	pending := maps.Clone(machineServices)
+
+	for {
+		machines, err := controller.List(ctx, &machineapi.MachineList{})
+		if err != nil && ctx.Err() == nil {
+			return err
+		} else if err == nil {
+			for _, machine := range machines.Items {
+				service, ok := pending[machine.Name]
+				if !ok {
+					continue
+				}
+
+				switch machine.Status.State {
+				case machineapi.MachineStateRunning:
+					log.G(ctx).
+						WithField("service", service).
+						WithField("machine", machine.Name).
+						Debug("running")
+					delete(pending, machine.Name)
+
+				case machineapi.MachineStateExited,
+					machineapi.MachineStateFailed,
+					machineapi.MachineStateErrored:
+					return fmt.Errorf("service %s did not come up: machine %s is %s", service, machine.Name, machine.Status.State)
+				}
+			}
+		}
+
+		if len(pending) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			var services []string
+			for _, service := range pending {
+				if !slices.Contains(services, service) {
+					services = append(services, service)
+				}
+			}
+
+			sort.Strings(services)
+
+			return fmt.Errorf("services did not come up within %s: %s", timeout, strings.Join(services, ", "))
+		case <-ticker.C:
+		}
+	}
+}
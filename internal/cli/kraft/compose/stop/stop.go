@@ -7,6 +7,7 @@ package stop
 import (
 	"context"
 	"os"
+	"slices"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
@@ -22,7 +23,8 @@ import (
 )
 
 type StopOptions struct {
-	Composefile string
+	Composefiles []string `noattribute:"true"`
+	ProjectName  string   `noattribute:"true"`
 }
 
 func NewCmd() *cobra.Command {
@@ -54,10 +56,17 @@ func (opts *StopOptions) Pre(cmd *cobra.Command, _ []string) error {
 	cmd.SetContext(ctx)
 
 	if cmd.Flag("file").Changed {
-		opts.Composefile = cmd.Flag("file").Value.String()
+		opts.Composefiles, err = cmd.Flags().GetStringSlice("file")
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.Flag("project-name").Changed {
+		opts.ProjectName = cmd.Flag("project-name").Value.String()
 	}
 
-	log.G(cmd.Context()).WithField("composefile", opts.Composefile).Debug("using")
+	log.G(cmd.Context()).WithField("composefiles", opts.Composefiles).Debug("using")
 	return nil
 }
 
@@ -67,11 +76,15 @@ func (opts *StopOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
-	project, err := compose.NewProjectFromComposeFile(ctx, workdir, opts.Composefile)
+	project, err := compose.NewProjectFromComposeFiles(ctx, workdir, opts.Composefiles, compose.WithProjectName(opts.ProjectName))
 	if err != nil {
 		return err
 	}
 
+	if err := project.Load(ctx); err != nil {
+		return err
+	}
+
 	if err := project.Validate(ctx); err != nil {
 		return err
 	}
@@ -92,24 +105,37 @@ func (opts *StopOptions) Run(ctx context.Context, args []string) error {
 	}
 
 	orderedServices := project.ServicesReversedByDependencies(ctx, services, false)
-	machinesToStop := []string{}
 	for _, service := range orderedServices {
+		machinesToStop := []string{}
 		for _, machine := range machines.Items {
-			if service.ContainerName == machine.Name &&
+			if slices.Contains(compose.ServiceContainerNames(service), machine.Name) &&
 				(machine.Status.State == machineapi.MachineStateRunning ||
 					machine.Status.State == machineapi.MachineStatePaused) {
 				machinesToStop = append(machinesToStop, machine.Name)
 			}
 		}
-	}
 
-	if len(machinesToStop) == 0 {
-		return nil
-	}
+		if len(machinesToStop) == 0 {
+			continue
+		}
+
+		signal, err := compose.ServiceStopSignal(service)
+		if err != nil {
+			return err
+		}
 
-	kernelStopOptions := kernelstop.StopOptions{
-		Platform: "auto",
+		// Each service is stopped with its own signal and grace period before
+		// its machines are forcefully stopped.
+		kernelStopOptions := kernelstop.StopOptions{
+			Platform: "auto",
+			Signal:   signal,
+			Timeout:  compose.ServiceStopGracePeriod(service),
+		}
+
+		if err := kernelStopOptions.Run(ctx, machinesToStop); err != nil {
+			return err
+		}
 	}
 
-	return kernelStopOptions.Run(ctx, machinesToStop)
+	return nil
 }
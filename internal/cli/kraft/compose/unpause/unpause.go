@@ -7,6 +7,7 @@ package unpause
 import (
 	"context"
 	"os"
+	"slices"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
@@ -22,7 +23,8 @@ import (
 )
 
 type UnpauseOptions struct {
-	Composefile string `noattribute:"true"`
+	Composefiles []string `noattribute:"true"`
+	ProjectName  string   `noattribute:"true"`
 }
 
 func NewCmd() *cobra.Command {
@@ -54,10 +56,17 @@ func (opts *UnpauseOptions) Pre(cmd *cobra.Command, _ []string) error {
 	cmd.SetContext(ctx)
 
 	if cmd.Flag("file").Changed {
-		opts.Composefile = cmd.Flag("file").Value.String()
+		opts.Composefiles, err = cmd.Flags().GetStringSlice("file")
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.Flag("project-name").Changed {
+		opts.ProjectName = cmd.Flag("project-name").Value.String()
 	}
 
-	log.G(cmd.Context()).WithField("composefile", opts.Composefile).Debug("using")
+	log.G(cmd.Context()).WithField("composefiles", opts.Composefiles).Debug("using")
 	return nil
 }
 
@@ -67,11 +76,15 @@ func (opts *UnpauseOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
-	project, err := compose.NewProjectFromComposeFile(ctx, workdir, opts.Composefile)
+	project, err := compose.NewProjectFromComposeFiles(ctx, workdir, opts.Composefiles, compose.WithProjectName(opts.ProjectName))
 	if err != nil {
 		return err
 	}
 
+	if err := project.Load(ctx); err != nil {
+		return err
+	}
+
 	if err := project.Validate(ctx); err != nil {
 		return err
 	}
@@ -95,7 +108,7 @@ func (opts *UnpauseOptions) Run(ctx context.Context, args []string) error {
 	machinesToUnpause := []string{}
 	for _, service := range orderedServices {
 		for _, machine := range machines.Items {
-			if service.ContainerName == machine.Name {
+			if slices.Contains(compose.ServiceContainerNames(service), machine.Name) {
 				if machine.Status.State == machineapi.MachineStatePaused {
 					machinesToUnpause = append(machinesToUnpause, machine.Name)
 				}
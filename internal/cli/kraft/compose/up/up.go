@@ -7,6 +7,10 @@ package up
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
@@ -21,21 +25,39 @@ import (
 )
 
 type UpOptions struct {
+	Build         bool `long:"build" usage:"Build and package services before creating them, even if already packaged"`
 	Detach        bool `long:"detach" short:"d" usage:"Run in background"`
+	NoBuild       bool `long:"no-build" usage:"Do not build services, fail if a service's image is missing"`
 	RemoveOrphans bool `long:"remove-orphans" usage:"Remove machines for services not defined in the Compose file."`
 
-	composefile string
+	composefiles []string
+	projectName  string
 }
 
 func NewCmd() *cobra.Command {
 	cmd, err := cmdfactory.New(&UpOptions{}, cobra.Command{
 		Short:   "Run a compose project",
-		Use:     "up [FLAGS]",
-		Args:    cobra.NoArgs,
+		Use:     "up [FLAGS] [SERVICE...]",
 		Aliases: []string{},
+		Long: heredoc.Doc(`
+			Create and start the services of a compose project in the order of their
+			dependencies.
+
+			Unless --detach is set, the logs of the services are followed until
+			interrupted, e.g. via Ctrl-C, after which the services are stopped.
+		`),
 		Example: heredoc.Doc(`
 			# Run a compose project
 			$ kraft compose up
+
+			# Run a compose project in the background
+			$ kraft compose up --detach
+
+			# Run a single service and its dependencies
+			$ kraft compose up nginx
+
+			# Rebuild the services before running the project
+			$ kraft compose up --build
 		`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "compose",
@@ -56,29 +78,44 @@ func (opts *UpOptions) Pre(cmd *cobra.Command, _ []string) error {
 
 	cmd.SetContext(ctx)
 
+	if opts.Build && opts.NoBuild {
+		return fmt.Errorf("cannot use --build and --no-build together")
+	}
+
 	if cmd.Flag("file").Changed {
-		opts.composefile = cmd.Flag("file").Value.String()
+		opts.composefiles, err = cmd.Flags().GetStringSlice("file")
+		if err != nil {
+			return err
+		}
 	}
 
-	log.G(cmd.Context()).WithField("composefile", opts.composefile).Debug("using")
+	if cmd.Flag("project-name").Changed {
+		opts.projectName = cmd.Flag("project-name").Value.String()
+	}
+
+	log.G(cmd.Context()).WithField("composefiles", opts.composefiles).Debug("using")
 	return nil
 }
 
-func (opts *UpOptions) Run(ctx context.Context, _ []string) error {
+func (opts *UpOptions) Run(ctx context.Context, args []string) error {
 	createOptions := create.CreateOptions{
-		Composefile:   opts.composefile,
+		ProjectName:   opts.projectName,
+		Build:         opts.Build,
+		Composefiles:  opts.composefiles,
+		NoBuild:       opts.NoBuild,
 		RemoveOrphans: opts.RemoveOrphans,
 	}
 
-	if err := createOptions.Run(ctx, []string{}); err != nil {
+	if err := createOptions.Run(ctx, args); err != nil {
 		return err
 	}
 
 	startOptions := start.StartOptions{
-		Composefile: opts.composefile,
+		ProjectName:  opts.projectName,
+		Composefiles: opts.composefiles,
 	}
 
-	if err := startOptions.Run(ctx, []string{}); err != nil {
+	if err := startOptions.Run(ctx, args); err != nil {
 		return err
 	}
 
@@ -87,21 +124,30 @@ func (opts *UpOptions) Run(ctx context.Context, _ []string) error {
 	}
 
 	logsOptions := logs.LogsOptions{
-		Composefile: opts.composefile,
-		Follow:      true,
+		ProjectName:  opts.projectName,
+		Composefiles: opts.composefiles,
+		Follow:       true,
 	}
 
-	if err := logsOptions.Run(ctx, []string{}); err != nil {
+	// Follow the logs until interrupted.  Interrupting only stops following the
+	// logs such that the machines can still be stopped gracefully, after which a
+	// second interrupt terminates immediately.
+	logsCtx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	err := logsOptions.Run(logsCtx, args)
+	interrupted := logsCtx.Err() != nil
+	cancel()
+
+	if err != nil && !interrupted {
 		return err
 	}
 
-	// If we get here it means the context was cancelled, stop the machines
 	log.G(ctx).Infof("stopping machines...")
 	stopOptions := stop.StopOptions{
-		Composefile: opts.composefile,
+		ProjectName:  opts.projectName,
+		Composefiles: opts.composefiles,
 	}
 
-	if err := stopOptions.Run(ctx, []string{}); err != nil {
+	if err := stopOptions.Run(ctx, args); err != nil {
 		return err
 	}
 	return nil
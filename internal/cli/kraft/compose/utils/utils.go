@@ -71,5 +71,13 @@ func RemoveOrphans(ctx context.Context, project *compose.Project) error {
 		Platform: "auto",
 	}
 
-	return removeOptions.Run(ctx, orphanMachines)
+	if err := removeOptions.Run(ctx, orphanMachines); err != nil {
+		return err
+	}
+
+	project.ReleaseAddresses(ctx, orphanMachines...)
+
+	// Persist the release immediately such that it is not lost if a subsequent
+	// step fails.
+	return project.Save(ctx)
 }
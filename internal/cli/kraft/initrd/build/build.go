@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package build
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/config"
+	"kraftkit.sh/initrd"
+	"kraftkit.sh/internal/fancymap"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/tui"
+	"kraftkit.sh/tui/processtree"
+)
+
+type BuildOptions struct {
+	Architecture string `long:"arch" short:"m" usage:"Set the architecture of the binaries in the initramfs"`
+	Compress     bool   `long:"compress" short:"c" usage:"Compress the initramfs"`
+	Output       string `long:"output" short:"o" usage:"Set the path of the resulting initramfs"`
+	Type         string `long:"type" short:"t" usage:"Set the type of the source rather than detecting it"`
+}
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&BuildOptions{}, cobra.Command{
+		Short: "Build an initramfs from a Dockerfile, directory, file or image",
+		Use:   "build [FLAGS] SOURCE",
+		Args:  cobra.ExactArgs(1),
+		Long: heredoc.Docf(`
+			Build an initramfs CPIO archive from a Dockerfile, a directory, an
+			existing CPIO archive, an OCI or Docker archive, an OCI image or a URL.
+
+			The kind of source is detected automatically and can be set explicitly
+			with %[1]s--type%[1]s, which must be one of: %[2]s.
+		`, "`", types()),
+		Example: heredoc.Doc(`
+			# Build an initramfs from a Dockerfile
+			$ kraft initrd build -o initramfs.cpio ./Dockerfile
+
+			# Build a compressed initramfs from a directory for arm64
+			$ kraft initrd build --compress --arch arm64 -o rootfs.cpio ./rootfs
+
+			# Compress an existing initramfs
+			$ kraft initrd build --compress -o initramfs.cpio.gz ./initramfs.cpio
+
+			# Build an initramfs from a directory named Dockerfile
+			$ kraft initrd build --type directory ./Dockerfile
+		`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "build",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *BuildOptions) Pre(cmd *cobra.Command, _ []string) error {
+	if opts.Output == "" {
+		if opts.Architecture != "" {
+			opts.Output = fmt.Sprintf(initrd.DefaultInitramfsArchFileName, opts.Architecture)
+		} else {
+			opts.Output = initrd.DefaultInitramfsFileName
+		}
+	}
+
+	return nil
+}
+
+func (opts *BuildOptions) Run(ctx context.Context, args []string) error {
+	source := args[0]
+
+	output, err := filepath.Abs(opts.Output)
+	if err != nil {
+		return fmt.Errorf("could not determine absolute path of output: %w", err)
+	}
+
+	ropts := []initrd.InitrdOption{
+		initrd.WithOutput(output),
+		initrd.WithCompression(opts.Compress),
+		initrd.WithArchitecture(opts.Architecture),
+	}
+
+	if opts.Type != "" {
+		ropts = append(ropts, initrd.WithType(initrd.Type(opts.Type)))
+	}
+
+	ramfs, err := initrd.New(ctx, source, ropts...)
+	if err != nil {
+		return fmt.Errorf("could not initialize initramfs builder: %w", err)
+	}
+
+	model, err := processtree.NewProcessTree(
+		ctx,
+		[]processtree.ProcessTreeOption{
+			processtree.IsParallel(false),
+			processtree.WithRenderer(log.LoggerTypeFromString(config.G[config.KraftKit](ctx).Log.Type) != log.FANCY),
+		},
+		processtree.NewProcessTreeItem(
+			"building initramfs",
+			ramfs.Name(),
+			func(ctx context.Context) error {
+				output, err = ramfs.Build(ctx)
+				if err != nil {
+					return fmt.Errorf("could not build initramfs from %s: %w", ramfs.Name(), err)
+				}
+
+				return nil
+			},
+		),
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := model.Start(); err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(output)
+	if err != nil {
+		return fmt.Errorf("getting initramfs size: %w", err)
+	}
+
+	dgst, err := ramfs.Digest()
+	if err != nil {
+		return fmt.Errorf("getting initramfs digest: %w", err)
+	}
+
+	entries := []fancymap.FancyMapEntry{
+		{
+			Key:   "initramfs",
+			Value: output,
+			Right: fmt.Sprintf("(%s)", humanize.Bytes(uint64(stat.Size()))),
+		},
+		{
+			Key:   "digest",
+			Value: dgst.String(),
+		},
+		{
+			Key:   "source",
+			Value: ramfs.Name(),
+		},
+	}
+
+	if !iostreams.G(ctx).IsStdoutTTY() {
+		log.G(ctx).
+			WithField("initramfs", output).
+			WithField("size", stat.Size()).
+			WithField("digest", dgst.String()).
+			WithField("source", ramfs.Name()).
+			Info("build completed successfully")
+		return nil
+	}
+
+	fancymap.PrintFancyMap(
+		iostreams.G(ctx).Out,
+		tui.TextGreen,
+		"Build completed successfully!",
+		entries...,
+	)
+
+	return nil
+}
+
+// types returns a human-readable list of the known types of source.
+func types() string {
+	var strs []string
+	for _, typ := range initrd.Types() {
+		strs = append(strs, string(typ))
+	}
+
+	return strings.Join(strs, ", ")
+}
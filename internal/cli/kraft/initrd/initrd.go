@@ -0,0 +1,39 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/internal/cli/kraft/initrd/build"
+)
+
+type InitrdOptions struct{}
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&InitrdOptions{}, cobra.Command{
+		Short:   "Manage initial ramdisks",
+		Use:     "initrd SUBCOMMAND",
+		Aliases: []string{"initramfs", "rootfs"},
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "build",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.AddCommand(build.NewCmd())
+
+	return cmd
+}
+
+func (opts *InitrdOptions) Run(_ context.Context, _ []string) error {
+	return pflag.ErrHelp
+}
@@ -32,6 +32,7 @@ import (
 	"kraftkit.sh/internal/cli/kraft/compose"
 	"kraftkit.sh/internal/cli/kraft/events"
 	"kraftkit.sh/internal/cli/kraft/fetch"
+	"kraftkit.sh/internal/cli/kraft/initrd"
 	"kraftkit.sh/internal/cli/kraft/login"
 	"kraftkit.sh/internal/cli/kraft/logs"
 	"kraftkit.sh/internal/cli/kraft/menu"
@@ -80,6 +81,7 @@ func NewCmd() *cobra.Command {
 	cmd.AddCommand(build.NewCmd())
 	cmd.AddCommand(clean.NewCmd())
 	cmd.AddCommand(fetch.NewCmd())
+	cmd.AddCommand(initrd.NewCmd())
 	cmd.AddCommand(menu.NewCmd())
 	cmd.AddCommand(set.NewCmd())
 	cmd.AddCommand(unset.NewCmd())
@@ -21,8 +21,10 @@ import (
 )
 
 type CreateOptions struct {
-	Driver  string `noattribute:"true"`
-	Network string `long:"network" short:"n" usage:"Set the gateway IP address and the subnet of the network in CIDR format."`
+	Driver   string `noattribute:"true"`
+	Network  string `long:"network" short:"n" usage:"Set the gateway IP address and the subnet of the network in CIDR format."`
+	MTU      int    `long:"mtu" usage:"Set the MTU of the network (default is driver specific)."`
+	Internal bool   `long:"internal" usage:"Isolate the network from external access."`
 }
 
 // Create a new local machine network.
@@ -44,6 +46,12 @@ func NewCmd() *cobra.Command {
 		Example: heredoc.Doc(`
 			# Create a new machine network
 			$ kraft network create my-network --network 133.37.0.1/12
+
+			# Create a new machine network with jumbo frames
+			$ kraft network create my-network --mtu 9000
+
+			# Create a new machine network whose machines cannot reach other networks
+			$ kraft network create my-network --internal
 		`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "net",
@@ -94,13 +102,20 @@ func (opts *CreateOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
+	var labels map[string]string
+	if opts.Internal {
+		labels = map[string]string{network.LabelInternal: "true"}
+	}
+
 	if _, err := controller.Create(ctx, &networkapi.Network{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: args[0],
+			Name:   args[0],
+			Labels: labels,
 		},
 		Spec: networkapi.NetworkSpec{
 			Gateway: addr.IP.String(),
 			Netmask: net.IP(addr.Mask).String(),
+			MTU:     opts.MTU,
 		},
 	}); err != nil {
 		return err
@@ -7,6 +7,7 @@ package pause
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
@@ -19,8 +20,9 @@ import (
 )
 
 type PauseOptions struct {
-	All      bool   `long:"all" usage:"Pause all machines"`
-	Platform string `noattribute:"true"`
+	All      bool          `long:"all" usage:"Pause all machines"`
+	Platform string        `noattribute:"true"`
+	Timeout  time.Duration `long:"timeout" usage:"Time to wait for the unikernel to quiesce before pausing it"`
 }
 
 // Pause a local Unikraft virtual machine.
@@ -134,6 +136,10 @@ func (opts *PauseOptions) Run(ctx context.Context, args []string) error {
 		return fmt.Errorf("machine(s) not found")
 	}
 
+	if opts.Timeout > 0 {
+		ctx = machineapi.WithPauseTimeout(ctx, opts.Timeout)
+	}
+
 	for _, machine := range pause {
 		if machine.Status.State != machineapi.MachineStateRunning {
 			continue
@@ -8,6 +8,8 @@ package pkg
 import (
 	"context"
 	"fmt"
+	"maps"
+	"strings"
 
 	"kraftkit.sh/config"
 	"kraftkit.sh/internal/cli/kraft/utils"
@@ -58,18 +60,34 @@ func (p *packagerCliKernel) Pack(ctx context.Context, opts *PkgOptions, args ...
 		target.WithCommand(opts.Args),
 	)
 
-	var cmds []string
-	var envs []string
-	if opts.Rootfs, cmds, envs, err = utils.BuildRootfs(ctx, opts.Workdir, opts.Rootfs, opts.Compress, targ.Architecture().String()); err != nil {
+	rootfs, err := utils.BuildRootfs(ctx, opts.Workdir, opts.Rootfs, opts.Compress, targ.Architecture().String())
+	if err != nil {
 		return nil, fmt.Errorf("could not build rootfs: %w", err)
 	}
 
-	if len(opts.Args) == 0 && cmds != nil {
-		opts.Args = cmds
+	opts.Rootfs = rootfs.Path
+
+	if len(opts.Args) == 0 && rootfs.Cmd != nil {
+		opts.Args = rootfs.Cmd
+	}
+
+	if rootfs.Env != nil {
+		opts.Env = append(opts.Env, rootfs.Env...)
 	}
 
-	if envs != nil {
-		opts.Env = append(opts.Env, envs...)
+	labels := maps.Clone(rootfs.Labels)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+
+	// Labels of the rootfs, e.g. from a Dockerfile, have the lowest precedence.
+	for _, label := range opts.Labels {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid label format: %s", label)
+		}
+
+		labels[kv[0]] = kv[1]
 	}
 
 	var result []pack.Package
@@ -92,6 +110,9 @@ func (p *packagerCliKernel) Pack(ctx context.Context, opts *PkgOptions, args ...
 					packmanager.PackKConfig(!opts.NoKConfig),
 					packmanager.PackName(opts.Name),
 					packmanager.PackOutput(opts.Output),
+					packmanager.PackProgressFunc(processtree.OnProgress(ctx)),
+					packmanager.PackLabels(labels),
+					packmanager.PackWorkingDir(rootfs.WorkingDir),
 				)
 
 				envs := opts.aggregateEnvs()
@@ -8,11 +8,13 @@ package pkg
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
 	"strings"
 
 	"github.com/mattn/go-shellwords"
 	"kraftkit.sh/config"
+	"kraftkit.sh/initrd"
 	"kraftkit.sh/internal/cli/kraft/utils"
 	"kraftkit.sh/log"
 	"kraftkit.sh/pack"
@@ -283,14 +285,15 @@ func (p *packagerKraftfileRuntime) Pack(ctx context.Context, opts *PkgOptions, a
 		return nil, fmt.Errorf("package does not convert to target")
 	}
 
-	var cmds []string
-	var envs []string
-	if opts.Rootfs, cmds, envs, err = utils.BuildRootfs(ctx, opts.Workdir, opts.Rootfs, opts.Compress, targ.Architecture().String()); err != nil {
+	rootfs, err := utils.BuildRootfs(ctx, opts.Workdir, opts.Rootfs, opts.Compress, targ.Architecture().String())
+	if err != nil {
 		return nil, fmt.Errorf("could not build rootfs: %w", err)
 	}
 
-	if envs != nil {
-		opts.Env = append(opts.Env, envs...)
+	opts.Rootfs = rootfs.Path
+
+	if rootfs.Env != nil {
+		opts.Env = append(opts.Env, rootfs.Env...)
 	}
 
 	// If no arguments have been specified, use the ones which are default and
@@ -300,8 +303,8 @@ func (p *packagerKraftfileRuntime) Pack(ctx context.Context, opts *PkgOptions, a
 			opts.Args = opts.Project.Command()
 		} else if len(targ.Command()) > 0 {
 			opts.Args = targ.Command()
-		} else if cmds != nil {
-			opts.Args = cmds
+		} else if rootfs.Cmd != nil {
+			opts.Args = rootfs.Cmd
 		}
 	}
 
@@ -315,7 +318,22 @@ func (p *packagerKraftfileRuntime) Pack(ctx context.Context, opts *PkgOptions, a
 		}
 	}
 
-	labels := opts.Project.Labels()
+	// The rootfs is started by the runtime with the resolved arguments, so check
+	// that it contains what is needed to do so.  This is not fatal, since the
+	// runtime may provide the entrypoint itself.
+	if rootfs.Path != "" {
+		if err := initrd.ValidateArchive(ctx, rootfs.Path, args); err != nil {
+			log.G(ctx).Warnf("rootfs may not boot: %v", err)
+		}
+	}
+
+	labels := maps.Clone(rootfs.Labels)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+
+	// Labels of the rootfs, e.g. from a Dockerfile, have the lowest precedence.
+	maps.Copy(labels, opts.Project.Labels())
 	if len(opts.Labels) > 0 {
 		for _, label := range opts.Labels {
 			kv := strings.SplitN(label, "=", 2)
@@ -347,7 +365,9 @@ func (p *packagerKraftfileRuntime) Pack(ctx context.Context, opts *PkgOptions, a
 					packmanager.PackKConfig(!opts.NoKConfig),
 					packmanager.PackName(opts.Name),
 					packmanager.PackOutput(opts.Output),
+					packmanager.PackProgressFunc(processtree.OnProgress(ctx)),
 					packmanager.PackLabels(labels),
+					packmanager.PackWorkingDir(rootfs.WorkingDir),
 				)
 
 				if ukversion, ok := targ.KConfig().Get(unikraft.UK_FULLVERSION); ok {
@@ -8,6 +8,7 @@ package pkg
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
 	"slices"
 	"strings"
@@ -91,9 +92,7 @@ func (p *packagerKraftfileUnikraft) Pack(ctx context.Context, opts *PkgOptions,
 	var result []pack.Package
 
 	for _, targ := range selected {
-		var cmds []string
-		var envs []string
-		rootfs := opts.Rootfs
+		rootfs := &utils.Rootfs{Path: opts.Rootfs}
 
 		// Reset the rootfs, such that it is not packaged as an initrd if it is
 		// already embedded inside of the kernel.
@@ -102,9 +101,9 @@ func (p *packagerKraftfileUnikraft) Pack(ctx context.Context, opts *PkgOptions,
 			"CONFIG_LIBVFSCORE_AUTOMOUNT_EINITRD",
 			"CONFIG_LIBVFSCORE_AUTOMOUNT_CI_EINITRD",
 		) {
-			rootfs = ""
+			rootfs.Path = ""
 		} else {
-			if rootfs, cmds, envs, err = utils.BuildRootfs(ctx, opts.Workdir, rootfs, opts.Compress, targ.Architecture().String()); err != nil {
+			if rootfs, err = utils.BuildRootfs(ctx, opts.Workdir, rootfs.Path, opts.Compress, targ.Architecture().String()); err != nil {
 				return nil, fmt.Errorf("could not build rootfs: %w", err)
 			}
 		}
@@ -114,8 +113,8 @@ func (p *packagerKraftfileUnikraft) Pack(ctx context.Context, opts *PkgOptions,
 		baseopts := opts.packopts
 		name := "packaging " + targ.Name() + " (" + opts.Format + ")"
 
-		if envs != nil {
-			opts.Env = append(opts.Env, envs...)
+		if rootfs.Env != nil {
+			opts.Env = append(opts.Env, rootfs.Env...)
 		}
 
 		// If no arguments have been specified, use the ones which are default and
@@ -125,8 +124,8 @@ func (p *packagerKraftfileUnikraft) Pack(ctx context.Context, opts *PkgOptions,
 				opts.Args = opts.Project.Command()
 			} else if len(targ.Command()) > 0 {
 				opts.Args = targ.Command()
-			} else if cmds != nil {
-				opts.Args = cmds
+			} else if rootfs.Cmd != nil {
+				opts.Args = rootfs.Cmd
 			}
 		}
 
@@ -135,7 +134,13 @@ func (p *packagerKraftfileUnikraft) Pack(ctx context.Context, opts *PkgOptions,
 			return nil, err
 		}
 
-		labels := opts.Project.Labels()
+		labels := maps.Clone(rootfs.Labels)
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+
+		// Labels of the rootfs, e.g. from a Dockerfile, have the lowest precedence.
+		maps.Copy(labels, opts.Project.Labels())
 		if len(opts.Labels) > 0 {
 			for _, label := range opts.Labels {
 				kv := strings.SplitN(label, "=", 2)
@@ -162,11 +167,13 @@ func (p *packagerKraftfileUnikraft) Pack(ctx context.Context, opts *PkgOptions,
 			func(ctx context.Context) error {
 				popts := append(baseopts,
 					packmanager.PackArgs(cmdShellArgs...),
-					packmanager.PackInitrd(rootfs),
+					packmanager.PackInitrd(rootfs.Path),
 					packmanager.PackKConfig(!opts.NoKConfig),
 					packmanager.PackName(opts.Name),
 					packmanager.PackOutput(opts.Output),
+					packmanager.PackProgressFunc(processtree.OnProgress(ctx)),
 					packmanager.PackLabels(labels),
+					packmanager.PackWorkingDir(rootfs.WorkingDir),
 				)
 
 				if ukversion, ok := targ.KConfig().Get(unikraft.UK_FULLVERSION); ok {
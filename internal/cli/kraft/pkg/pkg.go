@@ -34,27 +34,28 @@ import (
 )
 
 type PkgOptions struct {
-	Architecture string                    `local:"true" long:"arch" short:"m" usage:"Filter the creation of the package by architecture of known targets"`
-	Args         []string                  `local:"true" long:"args" short:"a" usage:"Pass arguments that will be part of the running kernel's command line"`
-	Compress     bool                      `local:"true" long:"compress" short:"c" usage:"Compress the initrd package (experimental)"`
-	Dbg          bool                      `local:"true" long:"dbg" usage:"Package the debuggable (symbolic) kernel image instead of the stripped image"`
-	Env          []string                  `local:"true" long:"env" short:"e" usage:"Set environment variables to be packed into the package"`
-	Force        bool                      `local:"true" long:"force-format" usage:"Force the use of a packaging handler format"`
-	Format       string                    `local:"true" long:"as" short:"M" usage:"Force the packaging despite possible conflicts" default:"oci"`
-	Kernel       string                    `local:"true" long:"kernel" short:"k" usage:"Override the path to the unikernel image"`
-	Kraftfile    string                    `long:"kraftfile" short:"K" usage:"Set an alternative path of the Kraftfile"`
-	Labels       []string                  `local:"true" long:"label" short:"l" usage:"Set labels to be packed into the package (k=v)"`
-	Name         string                    `local:"true" long:"name" short:"n" usage:"Specify the name of the package"`
-	NoKConfig    bool                      `local:"true" long:"no-kconfig" usage:"Do not include target .config as metadata"`
-	NoPull       bool                      `local:"true" long:"no-pull" usage:"Do not pull package dependencies before packaging"`
-	Output       string                    `local:"true" long:"output" short:"o" usage:"Save the package at the following output"`
-	Platform     string                    `local:"true" long:"plat" short:"p" usage:"Filter the creation of the package by platform of known targets"`
-	Project      app.Application           `noattribute:"true"`
-	Push         bool                      `local:"true" long:"push" short:"P" usage:"Push the package on if successfully packaged"`
-	Rootfs       string                    `local:"true" long:"rootfs" usage:"Specify a path to use as root file system (can be volume or initramfs)"`
-	Strategy     packmanager.MergeStrategy `noattribute:"true"`
-	Target       string                    `local:"true" long:"target" short:"t" usage:"Package a particular known target"`
-	Workdir      string                    `local:"true" long:"workdir" short:"w" usage:"Set an alternative working directory (default is cwd)"`
+	Architecture   string                    `local:"true" long:"arch" short:"m" usage:"Filter the creation of the package by architecture of known targets"`
+	Args           []string                  `local:"true" long:"args" short:"a" usage:"Pass arguments that will be part of the running kernel's command line"`
+	Compress       bool                      `local:"true" long:"compress" short:"c" usage:"Compress the initrd package (experimental)"`
+	CompressLayers bool                      `local:"true" long:"compress-layers" usage:"Compress the layers of the package with gzip"`
+	Dbg            bool                      `local:"true" long:"dbg" usage:"Package the debuggable (symbolic) kernel image instead of the stripped image"`
+	Env            []string                  `local:"true" long:"env" short:"e" usage:"Set environment variables to be packed into the package"`
+	Force          bool                      `local:"true" long:"force-format" usage:"Force the use of a packaging handler format"`
+	Format         string                    `local:"true" long:"as" short:"M" usage:"Force the packaging despite possible conflicts" default:"oci"`
+	Kernel         string                    `local:"true" long:"kernel" short:"k" usage:"Override the path to the unikernel image"`
+	Kraftfile      string                    `long:"kraftfile" short:"K" usage:"Set an alternative path of the Kraftfile"`
+	Labels         []string                  `local:"true" long:"label" short:"l" usage:"Set labels to be packed into the package (k=v)"`
+	Name           string                    `local:"true" long:"name" short:"n" usage:"Specify the name of the package"`
+	NoKConfig      bool                      `local:"true" long:"no-kconfig" usage:"Do not include target .config as metadata"`
+	NoPull         bool                      `local:"true" long:"no-pull" usage:"Do not pull package dependencies before packaging"`
+	Output         string                    `local:"true" long:"output" short:"o" usage:"Save the package at the following output"`
+	Platform       string                    `local:"true" long:"plat" short:"p" usage:"Filter the creation of the package by platform of known targets"`
+	Project        app.Application           `noattribute:"true"`
+	Push           bool                      `local:"true" long:"push" short:"P" usage:"Push the package on if successfully packaged"`
+	Rootfs         string                    `local:"true" long:"rootfs" usage:"Specify a path to use as root file system (can be volume or initramfs)"`
+	Strategy       packmanager.MergeStrategy `noattribute:"true"`
+	Target         string                    `local:"true" long:"target" short:"t" usage:"Package a particular known target"`
+	Workdir        string                    `local:"true" long:"workdir" short:"w" usage:"Set an alternative working directory (default is cwd)"`
 
 	packopts []packmanager.PackOption
 	pm       packmanager.PackageManager
@@ -167,6 +168,10 @@ func Pkg(ctx context.Context, opts *PkgOptions, args ...string) ([]pack.Package,
 		)
 	}
 
+	opts.packopts = append(opts.packopts,
+		packmanager.PackCompressLayers(opts.CompressLayers),
+	)
+
 	var pkgr packager
 
 	packagers := packagers()
@@ -23,8 +23,10 @@ import (
 )
 
 type PushOptions struct {
-	Format    string `local:"true" long:"as" short:"M" usage:"Force the packaging despite possible conflicts" default:"auto"`
-	Kraftfile string `long:"kraftfile" short:"K" usage:"Set an alternative path of the Kraftfile"`
+	Format          string `local:"true" long:"as" short:"M" usage:"Force the packaging despite possible conflicts" default:"auto"`
+	Kraftfile       string `long:"kraftfile" short:"K" usage:"Set an alternative path of the Kraftfile"`
+	MountFrom       string `long:"mount-from" usage:"Repository in the same registry to mount shared layers from"`
+	NoChunkedUpload bool   `long:"no-chunked-upload" usage:"Upload layers in their entirety rather than in resumable chunks"`
 }
 
 // Push a Unikraft component.
@@ -53,6 +55,12 @@ func NewCmd() *cobra.Command {
 
 			# Push the image with a given name
 			$ kraft pkg push unikraft.org/helloworld:latest
+
+			# Push the image to a registry which does not support chunked uploads
+			$ kraft pkg push --no-chunked-upload unikraft.org/helloworld:latest
+
+			# Push the image, mounting shared layers from another repository
+			$ kraft pkg push --mount-from unikraft.org/base unikraft.org/helloworld:latest
 		`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "pkg",
@@ -159,7 +167,14 @@ func (opts *PushOptions) Run(ctx context.Context, args []string) error {
 			fmt.Sprintf("pushing %s", p.String()),
 			"",
 			func(ctx context.Context) error {
-				return p.Push(ctx)
+				pushOpts := []pack.PushOption{
+					pack.WithPushChunkedUpload(!opts.NoChunkedUpload),
+				}
+				if opts.MountFrom != "" {
+					pushOpts = append(pushOpts, pack.WithPushMountFrom(opts.MountFrom))
+				}
+
+				return p.Push(ctx, pushOpts...)
 			},
 		))
 	}
@@ -24,8 +24,9 @@ import (
 )
 
 type PsOptions struct {
-	Architecture string `long:"arch" short:"m" usage:"Filter the list by architecture"`
-	Long         bool   `long:"long" short:"l" usage:"Show more information"`
+	Architecture string   `long:"arch" short:"m" usage:"Filter the list by architecture"`
+	Filter       []string `long:"filter" short:"f" usage:"Filter the list by label, in the format label=key[=value]"`
+	Long         bool     `long:"long" short:"l" usage:"Show more information"`
 	platform     string
 	Quiet        bool   `long:"quiet" short:"q" usage:"Only display machine IDs"`
 	ShowAll      bool   `long:"all" short:"a" usage:"Show all machines (default shows just running)"`
@@ -52,6 +53,9 @@ func NewCmd() *cobra.Command {
 
 			# List all unikernels with more information
 			$ kraft ps --long
+
+			# List all unikernels of a compose project
+			$ kraft ps --all --filter label=com.docker.compose.project=myproject
 		`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "run",
@@ -81,12 +85,19 @@ func (opts *PsOptions) Pre(cmd *cobra.Command, _ []string) error {
 		return fmt.Errorf("invalid output format: %s", opts.Output)
 	}
 
+	for _, filter := range opts.Filter {
+		if kind, label, _ := strings.Cut(filter, "="); kind != "label" || label == "" {
+			return fmt.Errorf("invalid filter: %s: must be in the format label=key[=value]", filter)
+		}
+	}
+
 	return nil
 }
 
 type PsEntry struct {
 	ID      string
 	Name    string
+	Service string
 	Kernel  string
 	Args    string
 	Created string
@@ -97,6 +108,7 @@ type PsEntry struct {
 	Arch    string
 	Plat    string
 	IPs     []string
+	Labels  map[string]string
 }
 
 type colorFunc func(string) string
@@ -178,6 +190,9 @@ func (opts *PsOptions) PsTable(ctx context.Context) ([]PsEntry, error) {
 		if !opts.ShowAll && machine.Status.State != machineapi.MachineStateRunning {
 			continue
 		}
+		if !opts.matchesFilters(machine.ObjectMeta.Labels) {
+			continue
+		}
 		entry := PsEntry{
 			ID:      string(machine.UID),
 			Name:    machine.Name,
@@ -190,6 +205,7 @@ func (opts *PsOptions) PsTable(ctx context.Context) ([]PsEntry, error) {
 			Pid:     machine.Status.Pid,
 			Plat:    machine.Spec.Platform,
 			IPs:     []string{},
+			Labels:  machine.ObjectMeta.Labels,
 		}
 
 		if machine.Status.State == machineapi.MachineStateRunning {
@@ -208,6 +224,23 @@ func (opts *PsOptions) PsTable(ctx context.Context) ([]PsEntry, error) {
 	return items, nil
 }
 
+// matchesFilters checks whether the provided labels satisfy every filter, i.e.
+// whether each filtered label is set and, if the filter provides a value,
+// whether it has that value.
+func (opts *PsOptions) matchesFilters(labels map[string]string) bool {
+	for _, filter := range opts.Filter {
+		_, label, _ := strings.Cut(filter, "=")
+		key, value, hasValue := strings.Cut(label, "=")
+
+		found, ok := labels[key]
+		if !ok || (hasValue && found != value) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (opts *PsOptions) PrintPsTable(ctx context.Context, items []PsEntry) error {
 	err := iostreams.G(ctx).StartPager()
 	if err != nil {
@@ -218,6 +251,16 @@ func (opts *PsOptions) PrintPsTable(ctx context.Context, items []PsEntry) error
 
 	cs := iostreams.G(ctx).ColorScheme()
 
+	// Only show the service column if at least one of the entries belongs to
+	// a service, e.g. when listing the machines of a compose project.
+	showService := false
+	for _, item := range items {
+		if len(item.Service) > 0 {
+			showService = true
+			break
+		}
+	}
+
 	table, err := tableprinter.NewTablePrinter(ctx,
 		tableprinter.WithMaxWidth(iostreams.G(ctx).TerminalWidth()),
 		tableprinter.WithOutputFormatFromString(opts.Output),
@@ -230,6 +273,9 @@ func (opts *PsOptions) PrintPsTable(ctx context.Context, items []PsEntry) error
 	if opts.Long {
 		table.AddField("MACHINE ID", cs.Bold)
 	}
+	if showService {
+		table.AddField("SERVICE", cs.Bold)
+	}
 	table.AddField("NAME", cs.Bold)
 	table.AddField("KERNEL", cs.Bold)
 	table.AddField("ARGS", cs.Bold)
@@ -255,6 +301,9 @@ func (opts *PsOptions) PrintPsTable(ctx context.Context, items []PsEntry) error
 		if opts.Long {
 			table.AddField(item.ID, nil)
 		}
+		if showService {
+			table.AddField(item.Service, nil)
+		}
 		table.AddField(item.Name, nil)
 		table.AddField(item.Kernel, nil)
 		table.AddField(item.Args, nil)
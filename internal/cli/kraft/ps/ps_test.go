@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package ps
+
+import (
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+
+	"kraftkit.sh/compose"
+)
+
+func TestMatchesServiceLabels(t *testing.T) {
+	replicas := 2
+
+	project := &compose.Project{
+		Project: &types.Project{
+			Name: "myproject",
+		},
+	}
+
+	service := types.ServiceConfig{
+		Name:          "web",
+		ContainerName: "myproject-web",
+		Labels: types.Labels{
+			"tier": "frontend",
+			// Standard labels cannot be overridden by the service.
+			compose.LabelProject: "other",
+		},
+		Deploy: &types.DeployConfig{
+			Replicas: &replicas,
+		},
+	}
+
+	labels := project.ServiceLabels(service, "myproject-web-2")
+
+	tests := []struct {
+		name     string
+		filter   []string
+		expected bool
+	}{
+		{
+			name:     "no filter",
+			expected: true,
+		},
+		{
+			name:     "project",
+			filter:   []string{"label=" + compose.LabelProject + "=myproject"},
+			expected: true,
+		},
+		{
+			name:     "overridden project",
+			filter:   []string{"label=" + compose.LabelProject + "=other"},
+			expected: false,
+		},
+		{
+			name: "project and service",
+			filter: []string{
+				"label=" + compose.LabelProject + "=myproject",
+				"label=" + compose.LabelService + "=web",
+			},
+			expected: true,
+		},
+		{
+			name: "other service",
+			filter: []string{
+				"label=" + compose.LabelProject + "=myproject",
+				"label=" + compose.LabelService + "=db",
+			},
+			expected: false,
+		},
+		{
+			name:     "container number",
+			filter:   []string{"label=" + compose.LabelContainerNumber + "=2"},
+			expected: true,
+		},
+		{
+			name:     "user-defined label",
+			filter:   []string{"label=tier=frontend"},
+			expected: true,
+		},
+		{
+			name:     "label without value",
+			filter:   []string{"label=tier"},
+			expected: true,
+		},
+		{
+			name:     "missing label",
+			filter:   []string{"label=env"},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &PsOptions{Filter: tt.filter}
+
+			if got := opts.matchesFilters(labels); got != tt.expected {
+				t.Errorf("expected %v for filter %v on labels %v, got %v", tt.expected, tt.filter, labels, got)
+			}
+		})
+	}
+}
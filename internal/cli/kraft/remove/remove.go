@@ -7,14 +7,17 @@ package remove
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
 
 	machineapi "kraftkit.sh/api/machine/v1alpha1"
 	networkapi "kraftkit.sh/api/network/v1alpha1"
 	volumeapi "kraftkit.sh/api/volume/v1alpha1"
 	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/internal/cli/kraft/stop"
 	"kraftkit.sh/iostreams"
 	"kraftkit.sh/log"
 	"kraftkit.sh/machine/network"
@@ -23,8 +26,10 @@ import (
 )
 
 type RemoveOptions struct {
-	All      bool   `long:"all" usage:"Remove all machines"`
-	Platform string `noattribute:"true"`
+	All      bool          `long:"all" usage:"Remove all machines"`
+	Platform string        `noattribute:"true"`
+	Signal   unix.Signal   `noattribute:"true"`
+	Timeout  time.Duration `noattribute:"true"`
 }
 
 // Remove stops and deletes a local Unikraft virtual machine.
@@ -229,7 +234,7 @@ func (opts *RemoveOptions) Run(ctx context.Context, args []string) error {
 		}
 
 		// Stop the machine before deleting it.
-		if _, err := controller.Stop(ctx, &machine); err != nil {
+		if err := stop.StopMachine(ctx, controller, &machine, opts.Signal, opts.Timeout); err != nil {
 			log.G(ctx).Errorf("could not stop machine %s: %v", machine.Name, err)
 		}
 
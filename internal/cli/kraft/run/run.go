@@ -31,6 +31,7 @@ import (
 
 type RunOptions struct {
 	Architecture  string   `long:"arch" short:"m" usage:"Set the architecture"`
+	CPUs          string   `long:"cpus" usage:"Assign the number of vCPUs to the unikernel"`
 	Detach        bool     `long:"detach" short:"d" usage:"Run unikernel in background"`
 	DisableAccel  bool     `long:"disable-acceleration" short:"W" usage:"Disable acceleration of CPU (usually enables TCG)"`
 	Env           []string `long:"env" short:"e" usage:"Set environment variables, int the format key[=value]"`
@@ -38,6 +39,7 @@ type RunOptions struct {
 	IP            string   `long:"ip" usage:"Assign the provided IP address"`
 	KernelArgs    []string `long:"kernel-arg" short:"a" usage:"Set additional kernel arguments"`
 	Kraftfile     string   `long:"kraftfile" short:"K" usage:"Set an alternative path of the Kraftfile"`
+	Labels        []string `long:"label" usage:"Set a label on the instance, in the format key[=value]" split:"false"`
 	MacAddress    string   `long:"mac" usage:"Assign the provided MAC address"`
 	Memory        string   `long:"memory" short:"M" usage:"Assign memory to the unikernel (K/Ki, M/Mi, G/Gi)" default:"64Mi"`
 	Name          string   `long:"name" short:"n" usage:"Name of the instance"`
@@ -192,6 +194,23 @@ func (opts *RunOptions) Pre(cmd *cobra.Command, _ []string) error {
 		}
 	}
 
+	if opts.CPUs != "" {
+		qty, err := resource.ParseQuantity(opts.CPUs)
+		if err != nil {
+			return fmt.Errorf("could not parse cpus quantity: %w", err)
+		}
+
+		// Value rounds up, so fractional values are checked in milli-units since
+		// vCPUs can only be assigned whole.
+		if qty.MilliValue()%1000 != 0 {
+			return fmt.Errorf("cpus must be a whole number")
+		}
+
+		if qty.MilliValue() < 1000 {
+			return fmt.Errorf("cpus must be at least 1")
+		}
+	}
+
 	return nil
 }
 
@@ -350,6 +369,15 @@ func (opts *RunOptions) Run(ctx context.Context, args []string) error {
 		machine.Spec.Resources.Requests[corev1.ResourceMemory] = quantity
 	}
 
+	if len(opts.CPUs) > 0 {
+		quantity, err := resource.ParseQuantity(opts.CPUs)
+		if err != nil {
+			return err
+		}
+
+		machine.Spec.Resources.Requests[corev1.ResourceCPU] = quantity
+	}
+
 	if err := opts.parseNetworks(ctx, machine); err != nil {
 		return err
 	}
@@ -370,6 +398,10 @@ func (opts *RunOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
+	if err := opts.parseLabels(ctx, machine); err != nil {
+		return err
+	}
+
 	// Create the machine
 	machine, err = opts.machineController.Create(ctx, machine)
 	if err != nil {
@@ -312,6 +312,8 @@ func (runner *runnerPackage) Prepare(ctx context.Context, opts *RunOptions, mach
 		if err != nil {
 			return err
 		}
+
+		machine.Spec.WorkingDir = ramfs.WorkingDir()
 	}
 
 	// Use the symbolic debuggable kernel image?
@@ -112,7 +112,7 @@ func (opts *RunOptions) parseNetworks(ctx context.Context, machine *machineapi.M
 		}
 
 		if interfaceSpec.Gateway == "" {
-			interfaceSpec.Gateway = found.Spec.Gateway
+			interfaceSpec.Gateway = defaultGateway(found)
 		}
 
 		// Generate the UID pre-emptively so that we can uniquely reference the
@@ -157,6 +157,18 @@ func (opts *RunOptions) parseNetworks(ctx context.Context, machine *machineapi.M
 	return nil
 }
 
+// defaultGateway returns the gateway with which interfaces on the provided
+// network are configured unless one is requested.  Machines on internal
+// networks are not given a default gateway such that they cannot reach any
+// other network.
+func defaultGateway(found *networkapi.Network) string {
+	if network.IsInternal(found) {
+		return ""
+	}
+
+	return found.Spec.Gateway
+}
+
 // assignName determines the machine instance's name either from a provided
 // argument or randomly generates one.
 func (opts *RunOptions) assignName(ctx context.Context, machine *machineapi.Machine) error {
@@ -412,6 +424,10 @@ func (opts *RunOptions) prepareRootfs(ctx context.Context, machine *machineapi.M
 					machine.Spec.ApplicationArgs = ramfs.Args()
 				}
 
+				if machine.Spec.WorkingDir == "" {
+					machine.Spec.WorkingDir = ramfs.WorkingDir()
+				}
+
 				return nil
 			},
 		),
@@ -462,3 +478,26 @@ func (opts *RunOptions) parseEnvs(_ context.Context, machine *machineapi.Machine
 
 	return nil
 }
+
+// parseLabels sets the labels provided via --label, e.g. --label=key=value, on
+// the machine.  A label without a value is set with an empty value.
+func (opts *RunOptions) parseLabels(_ context.Context, machine *machineapi.Machine) error {
+	if len(opts.Labels) == 0 {
+		return nil
+	}
+
+	if machine.ObjectMeta.Labels == nil {
+		machine.ObjectMeta.Labels = make(map[string]string, len(opts.Labels))
+	}
+
+	for _, label := range opts.Labels {
+		k, v, _ := strings.Cut(label, "=")
+		if k == "" {
+			return fmt.Errorf("invalid label '%s': must be in the format key[=value]", label)
+		}
+
+		machine.ObjectMeta.Labels[k] = v
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package run
+
+import (
+	"testing"
+
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/machine/network"
+)
+
+func TestDefaultGateway(t *testing.T) {
+	tests := []struct {
+		name     string
+		labels   map[string]string
+		expected string
+	}{
+		{
+			name:     "external",
+			expected: "10.0.0.1",
+		},
+		{
+			name:     "explicitly external",
+			labels:   map[string]string{network.LabelInternal: "false"},
+			expected: "10.0.0.1",
+		},
+		{
+			name:   "internal",
+			labels: map[string]string{network.LabelInternal: "true"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := &networkapi.Network{
+				Spec: networkapi.NetworkSpec{
+					Gateway: "10.0.0.1",
+				},
+			}
+			found.Labels = tt.labels
+
+			if got := defaultGateway(found); got != tt.expected {
+				t.Errorf("expected gateway '%s', got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
@@ -7,9 +7,11 @@ package stop
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
 
 	machineapi "kraftkit.sh/api/machine/v1alpha1"
 	"kraftkit.sh/cmdfactory"
@@ -19,8 +21,10 @@ import (
 )
 
 type StopOptions struct {
-	All      bool   `long:"all" usage:"Remove all machines"`
-	Platform string `noattribute:"true"`
+	All      bool          `long:"all" usage:"Remove all machines"`
+	Platform string        `noattribute:"true"`
+	Signal   unix.Signal   `noattribute:"true"`
+	Timeout  time.Duration `noattribute:"true"`
 }
 
 // Stop a local Unikraft virtual machine.
@@ -137,7 +141,7 @@ func (opts *StopOptions) Run(ctx context.Context, args []string) error {
 	for _, machine := range stop {
 		if machine.Status.State == machineapi.MachineStateExited {
 			continue
-		} else if _, err := controller.Stop(ctx, &machine); err != nil {
+		} else if err := StopMachine(ctx, controller, &machine, opts.Signal, opts.Timeout); err != nil {
 			log.G(ctx).Errorf("could not stop machine %s: %v", machine.Name, err)
 		} else {
 			fmt.Fprintln(iostreams.G(ctx).Out, machine.Name)
@@ -146,3 +150,59 @@ func (opts *StopOptions) Run(ctx context.Context, args []string) error {
 
 	return nil
 }
+
+// StopMachine stops the provided machine.  If a signal is provided and the
+// machine is running, the guest is first requested to shut down gracefully and
+// given the timeout to exit before the machine is forcefully stopped via the
+// controller.  Since signals cannot be delivered to the guest, the signal only
+// indicates that a graceful shutdown is desired, except for SIGKILL which
+// stops the machine immediately.  Signals are never sent to the process of the
+// machine's virtual machine monitor, which would terminate it just as
+// forcefully and, if the machine has already exited, might hit an unrelated
+// process which has since reused its PID.
+func StopMachine(ctx context.Context, controller machineapi.MachineService, machine *machineapi.Machine, signal unix.Signal, timeout time.Duration) error {
+	if signal != 0 && signal != unix.SIGKILL && machine.Status.State == machineapi.MachineStateRunning {
+		if shutdowner, ok := controller.(mplatform.MachineShutdownService); !ok {
+			log.G(ctx).
+				WithField("machine", machine.Name).
+				Debug("platform does not support graceful shutdown, stopping forcefully")
+		} else if _, err := shutdowner.Shutdown(ctx, machine); err != nil {
+			log.G(ctx).
+				WithField("machine", machine.Name).
+				Debugf("could not shut down machine gracefully: %v", err)
+		} else if waitForExit(ctx, controller, machine, timeout) {
+			return nil
+		} else {
+			log.G(ctx).
+				WithField("machine", machine.Name).
+				WithField("timeout", timeout).
+				Debug("machine did not exit in time, stopping forcefully")
+		}
+	}
+
+	_, err := controller.Stop(ctx, machine)
+	return err
+}
+
+// waitForExit polls the state of the machine until it has exited or the
+// timeout has elapsed and returns whether it has exited.
+func waitForExit(ctx context.Context, controller machineapi.MachineService, machine *machineapi.Machine, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if updated, err := controller.Get(ctx, machine); err == nil && updated.Status.State == machineapi.MachineStateExited {
+			*machine = *updated
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package stop
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+)
+
+// fakeMachineService records the calls made to stop a machine, which exits
+// upon a graceful shutdown if exitOnShutdown is set.
+type fakeMachineService struct {
+	machineapi.MachineService
+	exitOnShutdown bool
+	shutdownErr    error
+
+	state     machineapi.MachineState
+	shutdowns int
+	stops     int
+}
+
+func (fake *fakeMachineService) Shutdown(_ context.Context, machine *machineapi.Machine) (*machineapi.Machine, error) {
+	fake.shutdowns++
+
+	if fake.shutdownErr != nil {
+		return machine, fake.shutdownErr
+	}
+
+	if fake.exitOnShutdown {
+		fake.state = machineapi.MachineStateExited
+	}
+
+	return machine, nil
+}
+
+func (fake *fakeMachineService) Get(_ context.Context, machine *machineapi.Machine) (*machineapi.Machine, error) {
+	updated := *machine
+	updated.Status.State = fake.state
+
+	return &updated, nil
+}
+
+func (fake *fakeMachineService) Stop(_ context.Context, machine *machineapi.Machine) (*machineapi.Machine, error) {
+	fake.stops++
+	fake.state = machineapi.MachineStateExited
+
+	return machine, nil
+}
+
+// nonShutdownMachineService does not support graceful shutdowns.
+type nonShutdownMachineService struct {
+	machineapi.MachineService
+	stops int
+}
+
+func (fake *nonShutdownMachineService) Stop(_ context.Context, machine *machineapi.Machine) (*machineapi.Machine, error) {
+	fake.stops++
+
+	return machine, nil
+}
+
+func TestStopMachine(t *testing.T) {
+	tests := []struct {
+		name           string
+		state          machineapi.MachineState
+		signal         unix.Signal
+		exitOnShutdown bool
+		shutdownErr    error
+		shutdowns      int
+		stops          int
+	}{
+		{
+			name:           "graceful",
+			state:          machineapi.MachineStateRunning,
+			signal:         unix.SIGTERM,
+			exitOnShutdown: true,
+			shutdowns:      1,
+			stops:          0,
+		},
+		{
+			name:      "timeout",
+			state:     machineapi.MachineStateRunning,
+			signal:    unix.SIGTERM,
+			shutdowns: 1,
+			stops:     1,
+		},
+		{
+			name:        "shutdown failure",
+			state:       machineapi.MachineStateRunning,
+			signal:      unix.SIGTERM,
+			shutdownErr: errors.New("unsupported"),
+			shutdowns:   1,
+			stops:       1,
+		},
+		{
+			name:   "no signal",
+			state:  machineapi.MachineStateRunning,
+			stops:  1,
+			signal: 0,
+		},
+		{
+			name:   "kill",
+			state:  machineapi.MachineStateRunning,
+			signal: unix.SIGKILL,
+			stops:  1,
+		},
+		{
+			name:   "exited",
+			state:  machineapi.MachineStateExited,
+			signal: unix.SIGTERM,
+			stops:  1,
+		},
+		{
+			name:   "created",
+			state:  machineapi.MachineStateCreated,
+			signal: unix.SIGTERM,
+			stops:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := &fakeMachineService{
+				exitOnShutdown: tt.exitOnShutdown,
+				shutdownErr:    tt.shutdownErr,
+				state:          tt.state,
+			}
+
+			machine := &machineapi.Machine{}
+			machine.Name = "machine"
+			machine.Status.State = tt.state
+
+			if err := StopMachine(context.Background(), controller, machine, tt.signal, 200*time.Millisecond); err != nil {
+				t.Fatal("StopMachine:", err)
+			}
+
+			if controller.shutdowns != tt.shutdowns {
+				t.Errorf("expected %d shutdowns, got %d", tt.shutdowns, controller.shutdowns)
+			}
+
+			if controller.stops != tt.stops {
+				t.Errorf("expected %d stops, got %d", tt.stops, controller.stops)
+			}
+		})
+	}
+}
+
+func TestStopMachineWithoutShutdownSupport(t *testing.T) {
+	controller := &nonShutdownMachineService{}
+
+	machine := &machineapi.Machine{}
+	machine.Name = "machine"
+	machine.Status.State = machineapi.MachineStateRunning
+
+	if err := StopMachine(context.Background(), controller, machine, unix.SIGTERM, time.Second); err != nil {
+		t.Fatal("StopMachine:", err)
+	}
+
+	if controller.stops != 1 {
+		t.Errorf("expected the machine to be stopped forcefully, got %d stops", controller.stops)
+	}
+}
+
+func TestStopMachineDoesNotSignalPid(t *testing.T) {
+	// The process stands in for an unrelated process which has reused the PID
+	// of a machine that has already exited.
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skip("could not start process:", err)
+	}
+
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	controller := &fakeMachineService{state: machineapi.MachineStateExited}
+
+	machine := &machineapi.Machine{}
+	machine.Name = "machine"
+	machine.Status.State = machineapi.MachineStateExited
+	machine.Status.Pid = int32(cmd.Process.Pid)
+
+	if err := StopMachine(context.Background(), controller, machine, unix.SIGTERM, time.Second); err != nil {
+		t.Fatal("StopMachine:", err)
+	}
+
+	if err := cmd.Process.Signal(unix.Signal(0)); err != nil {
+		t.Errorf("expected the process to still be running: %v", err)
+	}
+}
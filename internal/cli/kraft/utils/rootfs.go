@@ -17,18 +17,37 @@ import (
 	"kraftkit.sh/unikraft"
 )
 
+// Rootfs describes a built rootfs alongside the configuration which was set by
+// it, e.g. via a Dockerfile.
+type Rootfs struct {
+	// Path to the built rootfs, or empty if no rootfs was requested.
+	Path string
+
+	// Cmd is the command which the rootfs is to be started with.
+	Cmd []string
+
+	// Env are the environment variables of the rootfs.
+	Env []string
+
+	// WorkingDir is the working directory of the rootfs.
+	WorkingDir string
+
+	// Labels are the labels of the rootfs.
+	Labels map[string]string
+}
+
 // BuildRootfs generates a rootfs based on the provided working directory and
-// the rootfs entrypoint for the provided target(s).
-func BuildRootfs(ctx context.Context, workdir, rootfs string, compress bool, arch string) (string, []string, []string, error) {
+// the rootfs entrypoint for the provided target(s).  Additional options are
+// passed to the initramfs builder.
+func BuildRootfs(ctx context.Context, workdir, rootfs string, compress bool, arch string, opts ...initrd.InitrdOption) (*Rootfs, error) {
 	if rootfs == "" {
-		return "", nil, nil, nil
+		return &Rootfs{}, nil
 	}
 
 	var processes []*processtree.ProcessTreeItem
-	var cmds []string
-	var envs []string
+	result := &Rootfs{}
 
-	ramfs, err := initrd.New(ctx, rootfs,
+	ramfs, err := initrd.New(ctx, rootfs, append([]initrd.InitrdOption{
 		initrd.WithWorkdir(workdir),
 		initrd.WithOutput(filepath.Join(
 			workdir,
@@ -42,9 +61,9 @@ func BuildRootfs(ctx context.Context, workdir, rootfs string, compress bool, arc
 		)),
 		initrd.WithArchitecture(arch),
 		initrd.WithCompression(compress),
-	)
+	}, opts...)...)
 	if err != nil {
-		return "", nil, nil, fmt.Errorf("could not initialize initramfs builder: %w", err)
+		return nil, fmt.Errorf("could not initialize initramfs builder: %w", err)
 	}
 
 	processes = append(processes,
@@ -52,15 +71,17 @@ func BuildRootfs(ctx context.Context, workdir, rootfs string, compress bool, arc
 			"building rootfs",
 			arch,
 			func(ctx context.Context) error {
-				rootfs, err = ramfs.Build(ctx)
+				result.Path, err = ramfs.Build(ctx)
 				if err != nil {
-					return err
+					return fmt.Errorf("could not build initramfs from %s: %w", ramfs.Name(), err)
 				}
 
 				// Always overwrite the existing cmds and envs, considering this will
 				// be the same regardless of the target.
-				cmds = ramfs.Args()
-				envs = ramfs.Env()
+				result.Cmd = ramfs.Args()
+				result.Env = ramfs.Env()
+				result.WorkingDir = ramfs.WorkingDir()
+				result.Labels = ramfs.Labels()
 
 				return nil
 			},
@@ -76,12 +97,12 @@ func BuildRootfs(ctx context.Context, workdir, rootfs string, compress bool, arc
 		processes...,
 	)
 	if err != nil {
-		return "", nil, nil, err
+		return nil, err
 	}
 
 	if err := model.Start(); err != nil {
-		return "", nil, nil, err
+		return nil, err
 	}
 
-	return rootfs, cmds, envs, nil
+	return result, nil
 }
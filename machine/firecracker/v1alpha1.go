@@ -587,6 +587,28 @@ func (service *machineV1alpha1Service) Stop(ctx context.Context, machine *machin
 	return machine, nil
 }
 
+// Shutdown implements kraftkit.sh/machine/platform.MachineShutdownService by
+// sending a Ctrl+Alt+Del keyboard event to the guest, which is only supported
+// on x86_64.
+func (service *machineV1alpha1Service) Shutdown(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	fccfg, err := getFirecrackerConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	client := firecracker.NewClient(fccfg.SocketPath, logrus.NewEntry(log.G(ctx)), false)
+	action := models.InstanceActionInfoActionTypeSendCtrlAltDel
+	info := models.InstanceActionInfo{
+		ActionType: &action,
+	}
+
+	if _, err := client.CreateSyncAction(ctx, &info); err != nil {
+		return machine, err
+	}
+
+	return machine, nil
+}
+
 // Delete implements kraftkit.sh/api/machine/v1alpha1.MachineService.Delete
 func (service *machineV1alpha1Service) Delete(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
 	fccfg, err := getFirecrackerConfigFromPlatformConfig(machine.Status.PlatformConfig)
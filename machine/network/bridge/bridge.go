@@ -9,6 +9,11 @@ import (
 const (
 	// DefaultMTU is the default MTU for new bridge interfaces.
 	DefaultMTU = 1500
+
+	// labelInternal is the label of networks which are isolated from external
+	// access.  It mirrors kraftkit.sh/machine/network.LabelInternal which cannot
+	// be imported without an import cycle.
+	labelInternal = "kraftkit.sh/network.internal"
 )
 
 func init() {
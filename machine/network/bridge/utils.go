@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/erikh/ping"
@@ -12,6 +14,13 @@ import (
 	"kraftkit.sh/machine/network/iputils"
 )
 
+// disableForwarding prevents the host from forwarding IPv4 traffic which is
+// received on the provided interface such that machines attached to it cannot
+// reach any other network.
+func disableForwarding(ifname string) error {
+	return os.WriteFile(filepath.Join("/proc/sys/net/ipv4/conf", ifname, "forwarding"), []byte("0"), 0o644)
+}
+
 // BridgeIPs returns all the IPs attached to the provided bridge
 func BridgeIPs(bridge *netlink.Bridge) ([]string, error) {
 	// get the neighbors
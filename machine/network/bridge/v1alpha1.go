@@ -61,6 +61,9 @@ func (service *v1alpha1Network) Create(ctx context.Context, network *networkv1al
 	}
 
 	bridge.LinkAttrs.MTU = DefaultMTU
+	if network.Spec.MTU > 0 {
+		bridge.LinkAttrs.MTU = network.Spec.MTU
+	}
 
 	_, err := net.InterfaceByName(network.Spec.IfName)
 	if err == nil {
@@ -117,6 +120,13 @@ func (service *v1alpha1Network) Create(ctx context.Context, network *networkv1al
 		return nil, fmt.Errorf("bringing bridge %s up failed: %v", network.Name, err)
 	}
 
+	// Internal networks are isolated by not forwarding any of their traffic.
+	if network.ObjectMeta.Labels[labelInternal] == "true" {
+		if err := disableForwarding(la.Name); err != nil {
+			return nil, fmt.Errorf("isolating bridge %s failed: %v", network.Name, err)
+		}
+	}
+
 	network.CreationTimestamp = metav1.Now()
 
 	link, err := netlink.LinkByName(network.Spec.IfName)
@@ -180,6 +190,10 @@ func (service *v1alpha1Network) Create(ctx context.Context, network *networkv1al
 
 		tap.Name = iface.Spec.IfName
 		tap.MasterIndex = bridge.Attrs().Index
+
+		// Match the MTU of the bridge such that the tap device does not fragment
+		// or drop frames which the bridge accepts.
+		tap.MTU = link.Attrs().MTU
 		tap.HardwareAddr, err = net.ParseMAC(iface.Spec.MacAddress)
 		if err != nil {
 			return nil, err
@@ -371,6 +385,7 @@ func (service *v1alpha1Network) Update(ctx context.Context, network *networkv1al
 		}
 		tap.HardwareAddr = mac
 		tap.MasterIndex = bridge.Attrs().Index
+		tap.MTU = bridge.Attrs().MTU
 		tap.Name = iface.Spec.IfName
 
 		if existing, err := netlink.LinkByName(tap.Name); err == nil {
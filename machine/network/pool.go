@@ -32,21 +32,49 @@ var DefaultNetworkPool = []NetworkPoolEntry{
 	{"192.168.0.0/16", 20},
 }
 
-// FindFreeNetwork finds a free network in the pool.
+// FindFreeNetwork finds a free network in the pool.  The IP address of the
+// returned network is its first allocatable address, i.e. its gateway.
 func FindFreeNetwork(pool NetworkPool, existingNetworks *networkapi.NetworkList) (*net.IPNet, error) {
+	candidate, err := FindFreeSubnet(pool, NetworkSubnets(existingNetworks))
+	if err != nil {
+		return nil, err
+	}
+
+	// Increment the candidate by 1 to get the first allocatable IP
+	candidate.IP[3] = candidate.IP[3] + 1
+
+	return candidate, nil
+}
+
+// NetworkSubnets returns the subnets of the provided networks.  Networks
+// without a parsable IPv4 gateway and netmask, e.g. bridges which only operate
+// at layer 2, have no subnet and are skipped.
+func NetworkSubnets(networks *networkapi.NetworkList) []net.IPNet {
 	convertedNetworks := []net.IPNet{}
 
-	for _, network := range existingNetworks.Items {
+	for _, network := range networks.Items {
 		maskBytes := net.ParseIP(network.Spec.Netmask).To4()
+		gateway := net.ParseIP(network.Spec.Gateway).To4()
+		if maskBytes == nil || gateway == nil {
+			continue
+		}
+
 		mask := net.IPv4Mask(maskBytes[0], maskBytes[1], maskBytes[2], maskBytes[3])
 		// Setup IP address for bridge.
 		convertedNetworks = append(convertedNetworks,
 			net.IPNet{
-				IP:   net.ParseIP(network.Spec.Gateway),
+				IP:   gateway,
 				Mask: mask,
 			})
 	}
 
+	return convertedNetworks
+}
+
+// FindFreeSubnet finds a subnet in the pool which does not intersect with any
+// of the used subnets.  The IP address of the returned subnet is its network
+// address.
+func FindFreeSubnet(pool NetworkPool, used []net.IPNet) (*net.IPNet, error) {
 	for _, poolEntry := range pool {
 		startingIP, networkToSplit, err := net.ParseCIDR(poolEntry.Subnet)
 		if err != nil {
@@ -76,9 +104,9 @@ func FindFreeNetwork(pool NetworkPool, existingNetworks *networkapi.NetworkList)
 				Mask: net.CIDRMask(poolEntry.Size, 32),
 			}
 
-			// Check if the candidate intersects with any existing network
+			// Check if the candidate intersects with any used network
 			intersects := false
-			for _, existing := range convertedNetworks {
+			for _, existing := range used {
 				if NetworksIntersect(existing, candidate) {
 					intersects = true
 					break
@@ -86,10 +114,6 @@ func FindFreeNetwork(pool NetworkPool, existingNetworks *networkapi.NetworkList)
 			}
 
 			if !intersects {
-				// Increment the candidate by 1 to get the first allocatable IP
-				candidate.IP = candidate.IP.To4()
-				candidate.IP[3] = candidate.IP[3] + 1
-
 				return &candidate, nil
 			}
 
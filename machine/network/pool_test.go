@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+)
+
+func testNetwork(name, gateway, netmask string) networkapi.Network {
+	network := networkapi.Network{
+		Spec: networkapi.NetworkSpec{
+			Gateway: gateway,
+			Netmask: netmask,
+		},
+	}
+	network.Name = name
+
+	return network
+}
+
+func mustParseCIDR(t *testing.T, cidr string) net.IPNet {
+	t.Helper()
+
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return *subnet
+}
+
+func TestNetworkSubnets(t *testing.T) {
+	subnets := NetworkSubnets(&networkapi.NetworkList{
+		Items: []networkapi.Network{
+			testNetwork("kraft0", "172.16.0.1", "255.255.255.0"),
+			// Bridges which only operate at layer 2 have no address.
+			testNetwork("br0", "", ""),
+			testNetwork("br1", "10.0.0.1", ""),
+		},
+	})
+
+	if len(subnets) != 1 {
+		t.Fatalf("expected 1 subnet, got %d: %v", len(subnets), subnets)
+	}
+
+	if got := subnets[0].String(); got != "172.16.0.1/24" {
+		t.Errorf("expected subnet 172.16.0.1/24, got %s", got)
+	}
+}
+
+func TestFindFreeSubnet(t *testing.T) {
+	pool := NetworkPool{{Subnet: "172.16.0.0/12", Size: 24}}
+
+	tests := []struct {
+		name   string
+		pool   NetworkPool
+		used   []string
+		expect string
+		err    bool
+	}{
+		{
+			name:   "empty",
+			pool:   pool,
+			expect: "172.16.0.0/24",
+		},
+		{
+			name:   "skips used subnets",
+			pool:   pool,
+			used:   []string{"172.16.0.0/24", "172.16.1.0/24"},
+			expect: "172.16.2.0/24",
+		},
+		{
+			name:   "skips gateway addresses",
+			pool:   pool,
+			used:   []string{"172.16.0.1/24"},
+			expect: "172.16.1.0/24",
+		},
+		{
+			name:   "skips larger subnets",
+			pool:   pool,
+			used:   []string{"172.16.0.0/16"},
+			expect: "172.17.0.0/24",
+		},
+		{
+			name:   "fills gaps",
+			pool:   pool,
+			used:   []string{"172.16.0.0/24", "172.16.2.0/24"},
+			expect: "172.16.1.0/24",
+		},
+		{
+			name:   "advances to next pool entry",
+			pool:   NetworkPool{{Subnet: "10.0.0.0/24", Size: 25}, {Subnet: "10.1.0.0/24", Size: 24}},
+			used:   []string{"10.0.0.0/24"},
+			expect: "10.1.0.0/24",
+		},
+		{
+			name: "exhausted",
+			pool: NetworkPool{{Subnet: "10.0.0.0/24", Size: 25}},
+			used: []string{"10.0.0.0/25", "10.0.0.128/25"},
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var used []net.IPNet
+			for _, cidr := range tt.used {
+				ip, subnet, err := net.ParseCIDR(cidr)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				used = append(used, net.IPNet{IP: ip, Mask: subnet.Mask})
+			}
+
+			subnet, err := FindFreeSubnet(tt.pool, used)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", subnet)
+				}
+				return
+			} else if err != nil {
+				t.Fatal("FindFreeSubnet:", err)
+			}
+
+			if got := subnet.String(); got != tt.expect {
+				t.Errorf("expected %s, got %s", tt.expect, got)
+			}
+
+			if expect := mustParseCIDR(t, tt.expect); !subnet.IP.Equal(expect.IP) {
+				t.Errorf("expected the network address %s, got %s", expect.IP, subnet.IP)
+			}
+		})
+	}
+}
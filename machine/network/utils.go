@@ -5,7 +5,21 @@
 
 package network
 
-import "net"
+import (
+	"net"
+
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+)
+
+// LabelInternal is the label of networks which are isolated from external
+// access, i.e. traffic from its machines is not forwarded by the host and its
+// machines are not configured with a default gateway.
+const LabelInternal = "kraftkit.sh/network.internal"
+
+// IsInternal returns whether the network is isolated from external access.
+func IsInternal(network *networkapi.Network) bool {
+	return network.ObjectMeta.Labels[LabelInternal] == "true"
+}
 
 // NetworksIntersect returns whether two networks have any common IP addresses.
 func NetworksIntersect(a, b net.IPNet) bool {
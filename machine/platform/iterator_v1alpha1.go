@@ -107,6 +107,33 @@ func (iterator *machineV1alpha1ServiceIterator) Stop(ctx context.Context, machin
 	return machine, fmt.Errorf("all iterated platforms failed: %w", merr.NewErrors(errs...))
 }
 
+// Shutdown implements MachineShutdownService by requesting the shutdown via
+// the first platform which supports it.
+func (iterator *machineV1alpha1ServiceIterator) Shutdown(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	var errs []error
+
+	for _, strategy := range iterator.strategies {
+		shutdowner, ok := strategy.(MachineShutdownService)
+		if !ok {
+			continue
+		}
+
+		ret, err := shutdowner.Shutdown(ctx, machine)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		return ret, nil
+	}
+
+	if len(errs) == 0 {
+		return machine, fmt.Errorf("no platform supports shutting down machines gracefully")
+	}
+
+	return machine, fmt.Errorf("all iterated platforms failed: %w", merr.NewErrors(errs...))
+}
+
 // Update implements kraftkit.sh/api/machine/v1alpha1.MachineService
 func (iterator *machineV1alpha1ServiceIterator) Update(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
 	var errs []error
@@ -28,6 +28,15 @@ type Strategy struct {
 	NewMachineV1alpha1 NewStrategyConstructor[machinev1alpha1.MachineService]
 }
 
+// MachineShutdownService is implemented by machine services which are able to
+// request the guest of a machine to shut down gracefully, e.g. via an ACPI
+// power button event, as opposed to Stop which immediately terminates the
+// virtual machine monitor.  The machine is not guaranteed to have exited once
+// Shutdown returns.
+type MachineShutdownService interface {
+	Shutdown(context.Context, *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error)
+}
+
 // Strategies returns the list of registered platform implementations.
 func Strategies() map[Platform]*Strategy {
 	base := hostSupportedStrategies()
@@ -735,6 +735,14 @@ func (service *machineV1alpha1Service) Start(ctx context.Context, machine *machi
 		return machine, err
 	}
 
+	// The links of a paused machine may have been disconnected whilst it was
+	// being quiesced.
+	if machine.Status.State == machinev1alpha1.MachineStatePaused {
+		if qcfg, err := getQEMUConfigFromPlatformConfig(machine.Status.PlatformConfig); err == nil {
+			setLinks(ctx, qmpClient, qcfg, true)
+		}
+	}
+
 	qcfg, ok := machine.Status.PlatformConfig.(QemuConfig)
 	if !ok {
 		return machine, fmt.Errorf("cannot cast QEMU platform configuration from machine status")
@@ -761,8 +769,16 @@ func (service *machineV1alpha1Service) Pause(ctx context.Context, machine *machi
 
 	defer qmpClient.Close()
 
-	_, err = qmpClient.Stop(qmpapi.StopRequest{})
-	if err != nil {
+	if timeout := machinev1alpha1.PauseTimeoutFromContext(ctx); timeout > 0 {
+		qcfg, err := getQEMUConfigFromPlatformConfig(machine.Status.PlatformConfig)
+		if err != nil {
+			return machine, err
+		}
+
+		quiesce(ctx, qmpClient, qcfg, timeout)
+	}
+
+	if _, err := qmpClient.Stop(qmpapi.StopRequest{}); err != nil {
 		return machine, err
 	}
 
@@ -771,6 +787,39 @@ func (service *machineV1alpha1Service) Pause(ctx context.Context, machine *machi
 	return machine, nil
 }
 
+// quiesce asks the guest to quiesce before it is frozen by disconnecting the
+// links of its network devices, such that it receives no new requests, and
+// gives it up to the provided timeout to complete any in-flight work.  The
+// links are reconnected when the machine is resumed.
+func quiesce(ctx context.Context, qmpClient *qmpapi.QEMUMachineProtocolClient, qcfg *QemuConfig, timeout time.Duration) {
+	setLinks(ctx, qmpClient, qcfg, false)
+
+	select {
+	case <-time.After(timeout):
+	case <-ctx.Done():
+	}
+}
+
+// setLinks connects or disconnects the links of the network devices of the
+// guest.
+func setLinks(ctx context.Context, qmpClient *qmpapi.QEMUMachineProtocolClient, qcfg *QemuConfig, up bool) {
+	for i := range qcfg.NetDevs {
+		hostnetid := fmt.Sprintf("hostnet%d", i)
+
+		if _, err := qmpClient.SetLink(qmpapi.SetLinkRequest{
+			Arguments: qmpapi.SetLinkRequestArguments{
+				Name: hostnetid,
+				Up:   up,
+			},
+		}); err != nil {
+			log.G(ctx).
+				WithField("netdev", hostnetid).
+				WithField("up", up).
+				Debugf("could not set link: %v", err)
+		}
+	}
+}
+
 // Logs implements kraftkit.sh/api/machine/v1alpha1.MachineService
 func (service *machineV1alpha1Service) Logs(ctx context.Context, machine *machinev1alpha1.Machine) (chan string, chan error, error) {
 	out, errOut, err := logtail.NewLogTail(ctx, machine.Status.LogFile)
@@ -992,6 +1041,23 @@ func (service *machineV1alpha1Service) Stop(ctx context.Context, machine *machin
 	return machine, nil
 }
 
+// Shutdown implements kraftkit.sh/machine/platform.MachineShutdownService by
+// sending an ACPI power button event to the guest.
+func (service *machineV1alpha1Service) Shutdown(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	qmpClient, err := service.QMPClient(ctx, machine)
+	if err != nil {
+		return machine, fmt.Errorf("could not shut down qemu instance: %v", err)
+	}
+
+	defer qmpClient.Close()
+
+	if _, err := qmpClient.SystemPowerdown(qmpapi.SystemPowerdownRequest{}); err != nil {
+		return machine, err
+	}
+
+	return machine, nil
+}
+
 // Delete implements kraftkit.sh/api/machine/v1alpha1.MachineService.Delete
 func (service *machineV1alpha1Service) Delete(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
 	qcfg, ok := machine.Status.PlatformConfig.(QemuConfig)
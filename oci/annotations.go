@@ -12,8 +12,11 @@ const (
 	AnnotationCreated              = "org.unikraft.image.created"
 	AnnotaitonDescription          = "org.unikraft.image.description"
 	AnnotationKernelPath           = "org.unikraft.kernel.image"
+	AnnotationKernelDbgPath        = "org.unikraft.kernel.image.dbg"
 	AnnotationKernelVersion        = "org.unikraft.kernel.version"
 	AnnotationKernelInitrdPath     = "org.unikraft.kernel.initrd"
+	AnnotationKernelInitrdName     = "org.unikraft.kernel.initrd.name"
+	AnnotationDeviceTreePath       = "org.unikraft.kernel.dtb"
 	AnnotationKernelKConfig        = "org.unikraft.kernel.kconfig."
 	AnnotationKernelArch           = "org.unikraft.kernel.arch"
 	AnnotationKernelPlat           = "org.unikraft.kernel.plat"
@@ -21,3 +24,22 @@ const (
 	AnnotationDiskIndexPathPattern = "org.unikraft.disk-%d"
 	AnnotationKraftKitVersion      = "sh.kraftkit.version"
 )
+
+// matchAnnotationSelector checks whether the provided annotations satisfy the
+// selector.  Every key in the selector must be present in the annotations and,
+// if the selector's value is non-empty, the values must be equal.  When the
+// selector is not satisfied, the first offending key is returned.
+func matchAnnotationSelector(annotations, selector map[string]string) (string, bool) {
+	for key, want := range selector {
+		got, ok := annotations[key]
+		if !ok {
+			return key, false
+		}
+
+		if len(want) > 0 && got != want {
+			return key, false
+		}
+	}
+
+	return "", true
+}
@@ -7,6 +7,7 @@ package oci
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/opencontainers/go-digest"
@@ -18,6 +19,10 @@ type Blob struct {
 	src             string
 	tmp             string // intermediate location of the blob
 	removeAfterSave bool
+
+	// stream, when set, provides the content of the blob whose digest is only
+	// known once it has been streamed to the handler.
+	stream func(context.Context) io.ReadCloser
 }
 
 // NewBlob generates an OCI blob based on input byte array for a given media
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	"kraftkit.sh/oci/handler"
+)
+
+var (
+	// ErrNotFound is returned when content does not exist, either in the handler
+	// or in the remote registry.
+	ErrNotFound = errors.New("not found")
+
+	// ErrManifestNotFound is returned when a manifest does not exist.  It also
+	// matches ErrNotFound.
+	ErrManifestNotFound = fmt.Errorf("manifest %w", ErrNotFound)
+
+	// ErrAlreadyExists is returned when content which is saved already exists.
+	ErrAlreadyExists = errors.New("already exists")
+
+	// ErrUnauthorized is returned when the remote registry rejects a request
+	// due to missing or invalid credentials.
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrDigestMismatch is returned when the digest of content does not match
+	// the digest of the descriptor which refers to it.
+	ErrDigestMismatch = handler.ErrDigestMismatch
+
+	// ErrInvalidSignature is returned when a manifest does not carry a signature
+	// which is accepted by its verifier.
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// classifyError wraps the provided error with the sentinel error which
+// corresponds to its kind, if any, such that callers can branch on it via
+// errors.Is whilst the original error remains accessible via errors.As.  The
+// notFound error is used for content which does not exist, e.g.
+// ErrManifestNotFound.
+func classifyError(err, notFound error) error {
+	if err == nil ||
+		errors.Is(err, ErrNotFound) ||
+		errors.Is(err, ErrAlreadyExists) ||
+		errors.Is(err, ErrUnauthorized) ||
+		errors.Is(err, ErrDigestMismatch) {
+		return err
+	}
+
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		switch terr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %w", notFound, err)
+		}
+	}
+
+	switch {
+	case errdefs.IsNotFound(err), errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("%w: %w", notFound, err)
+	case errdefs.IsAlreadyExists(err):
+		return fmt.Errorf("%w: %w", ErrAlreadyExists, err)
+	}
+
+	return err
+}
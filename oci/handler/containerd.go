@@ -38,6 +38,7 @@ const (
 	ContainerdGCManifestPrefix = "containerd.io/gc.ref.content.m"
 	KraftKitLabelPrefix        = "kraftkit.sh/oci."
 	KraftKitLabelMediaType     = KraftKitLabelPrefix + "mediaType"
+	KraftKitLabelSubject       = KraftKitLabelPrefix + "subject"
 )
 
 type ContainerdHandler struct {
@@ -400,11 +401,26 @@ func (handle *ContainerdHandler) ResolveManifest(ctx context.Context, _ string,
 	return ResolveContainerdObjectFromDigest[ocispec.Manifest](ctx, handle, digest)
 }
 
+// ResolveImage implements ImageResolver.
+func (handle *ContainerdHandler) ResolveImage(ctx context.Context, _ string, dgst digest.Digest) (*ocispec.Image, error) {
+	manifest, err := ResolveContainerdObjectFromDigest[ocispec.Manifest](ctx, handle, dgst)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve image via manifest: %w", err)
+	}
+
+	return ResolveContainerdObjectFromDigest[ocispec.Image](ctx, handle, manifest.Config.Digest)
+}
+
 // ListManifests implements DigestResolver.
 func (handle *ContainerdHandler) ListManifests(ctx context.Context) (manifests map[string]*ocispec.Manifest, err error) {
 	return ListContainerdObjectsByType[ocispec.Manifest](ctx, ocispec.MediaTypeImageManifest, handle)
 }
 
+// ListManifestRefs implements ManifestRefLister.
+func (handle *ContainerdHandler) ListManifestRefs(ctx context.Context) ([]ManifestRef, error) {
+	return listManifestRefs(ctx, handle)
+}
+
 func (handle *ContainerdHandler) DeleteManifest(ctx context.Context, fullref string, dgst digest.Digest) error {
 	manifest, err := handle.ResolveManifest(ctx, fullref, dgst)
 	if err != nil {
@@ -732,8 +748,122 @@ outer:
 	}
 }
 
+// StreamDescriptor implements DescriptorStreamer.
+func (handle *ContainerdHandler) StreamDescriptor(ctx context.Context, ref, mediaType string, reader io.Reader, onProgress func(float64)) (desc ocispec.Descriptor, err error) {
+	ctx, done, err := handle.lease(ctx)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	defer func() {
+		err = combineErrors(err, done(ctx))
+	}()
+
+	writer, err := content.OpenWriter(
+		ctx,
+		handle.client.ContentStore(),
+		content.WithRef(fmt.Sprintf("kraftkit-stream-%d", time.Now().UnixNano())),
+	)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	defer writer.Close()
+
+	log.G(ctx).
+		WithField("ref", ref).
+		WithField("mediaType", mediaType).
+		Trace("streaming")
+
+	size, err := io.Copy(writer, reader)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("could not write blob: %w", err)
+	}
+
+	// The digest is computed by the writer and is therefore not provided.
+	if err := writer.Commit(ctx, size, "",
+		// The use of this label is a hack to prevent containerd's garbage collector
+		// from picking up and removing unreferenced content.
+		content.WithLabels(map[string]string{
+			"containerd.io/gc.root": "true",
+		}),
+	); err != nil && !errdefs.IsAlreadyExists(err) {
+		return ocispec.Descriptor{}, fmt.Errorf("could not commit blob: %w", err)
+	}
+
+	if onProgress != nil {
+		onProgress(1)
+	}
+
+	return ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    writer.Digest(),
+		Size:      size,
+	}, nil
+}
+
+// FetchDescriptor implements DescriptorFetcher.
+func (handle *ContainerdHandler) FetchDescriptor(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	ctx, done, err := handle.lease(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	readerAt, err := handle.client.ContentStore().ReaderAt(ctx, desc)
+	if err != nil {
+		return nil, combineErrors(fmt.Errorf("could not open blob: %w", err), done(ctx))
+	}
+
+	return &containerdBlobReader{
+		Reader:   content.NewReader(readerAt),
+		readerAt: readerAt,
+		done: func() error {
+			return done(ctx)
+		},
+	}, nil
+}
+
+// containerdBlobReader releases the content store's reader as well as the
+// lease under which it was opened once closed.
+type containerdBlobReader struct {
+	io.Reader
+	readerAt content.ReaderAt
+	done     func() error
+}
+
+// Close implements io.Closer.
+func (r *containerdBlobReader) Close() error {
+	return combineErrors(r.readerAt.Close(), r.done())
+}
+
 // PushDescriptor implements DescriptorPusher.
-func (handle *ContainerdHandler) PushDescriptor(ctx context.Context, ref string, target *ocispec.Descriptor) error {
+func (handle *ContainerdHandler) PushDescriptor(ctx context.Context, ref string, target *ocispec.Descriptor, opts ...PushDescriptorOption) error {
+	popts := NewPushDescriptorOptions(opts...)
+
+	// containerd's pusher already skips blobs which exist remotely, but does not
+	// support cross-repository mounting.
+	if popts.MountFrom() != "" {
+		log.G(ctx).
+			WithField("from", popts.MountFrom()).
+			Debug("cross-repository blob mounting is not supported by containerd, ignoring")
+	}
+
+	// containerd's pusher uploads each blob in its entirety, such that a dropped
+	// connection restarts the upload.  Upload them in resumable chunks first and
+	// fall back to containerd's pusher for any blob which could not be uploaded,
+	// e.g. because the registry does not support chunked uploads.
+	if popts.ChunkedUpload() {
+		if err := handle.pushBlobsChunked(ctx, ref, *target); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+
+			log.G(ctx).
+				WithField("ref", ref).
+				Warnf("chunked upload failed, falling back to monolithic upload: %s", err.Error())
+		}
+	}
+
 	resolver, err := dockerconfigresolver.New(
 		ctx,
 		strings.Split(ref, "/")[0],
@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/containerd/containerd/content"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"kraftkit.sh/log"
+)
+
+// SaveReferrer implements ReferrerSaver.  The referrer manifest is labelled
+// with the digest of its subject such that it can be found without walking
+// every manifest in the content store.
+func (handle *ContainerdHandler) SaveReferrer(ctx context.Context, subject, artifact ocispec.Descriptor, reader io.Reader) (err error) {
+	referrer, raw, err := newReferrerManifest(subject, artifact)
+	if err != nil {
+		return err
+	}
+
+	ctx, done, err := handle.lease(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err = combineErrors(err, done(ctx))
+	}()
+
+	cs := handle.client.ContentStore()
+
+	for _, blob := range []struct {
+		desc   ocispec.Descriptor
+		reader io.Reader
+		labels map[string]string
+	}{
+		{
+			desc:   artifact,
+			reader: reader,
+		},
+		{
+			desc:   ocispec.DescriptorEmptyJSON,
+			reader: bytes.NewReader(ocispec.DescriptorEmptyJSON.Data),
+		},
+		{
+			desc:   referrer,
+			reader: bytes.NewReader(raw),
+			labels: map[string]string{
+				KraftKitLabelSubject:                             subject.Digest.String(),
+				fmt.Sprintf("%s.%d", ContainerdGCLayerPrefix, 0): artifact.Digest.String(),
+				fmt.Sprintf("%s.%d", ContainerdGCLayerPrefix, 1): ocispec.DescriptorEmptyJSON.Digest.String(),
+			},
+		},
+	} {
+		labels := map[string]string{
+			// See SaveDescriptor for the use of this label.
+			"containerd.io/gc.root": "true",
+			KraftKitLabelMediaType:  blob.desc.MediaType,
+		}
+		for k, v := range blob.labels {
+			labels[k] = v
+		}
+
+		if err := content.WriteBlob(ctx,
+			cs,
+			blob.desc.Digest.String(),
+			blob.reader,
+			blob.desc,
+			content.WithLabels(labels),
+		); err != nil {
+			return fmt.Errorf("could not write %s: %w", blob.desc.Digest, err)
+		}
+	}
+
+	log.G(ctx).
+		WithField("subject", subject.Digest.String()).
+		WithField("referrer", referrer.Digest.String()).
+		WithField("artifactType", referrer.ArtifactType).
+		Trace("saved referrer")
+
+	return nil
+}
+
+// ListReferrers implements ReferrerLister.
+func (handle *ContainerdHandler) ListReferrers(ctx context.Context, subject digest.Digest, artifactType string) (referrers []ocispec.Descriptor, err error) {
+	ctx, done, err := handle.lease(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		err = combineErrors(err, done(ctx))
+	}()
+
+	cs := handle.client.ContentStore()
+
+	if err := cs.Walk(ctx, func(info content.Info) error {
+		readerAt, err := cs.ReaderAt(ctx, ocispec.Descriptor{
+			Digest: info.Digest,
+		})
+		if err != nil {
+			return err
+		}
+
+		defer readerAt.Close()
+
+		blob, err := readBlob(readerAt)
+		if err != nil {
+			return err
+		}
+
+		var manifest ocispec.Manifest
+		if err := json.Unmarshal(blob, &manifest); err != nil {
+			return fmt.Errorf("could not unmarshal referrer %s: %w", info.Digest, err)
+		}
+
+		referrers = append(referrers, ocispec.Descriptor{
+			MediaType:    ocispec.MediaTypeImageManifest,
+			ArtifactType: manifest.ArtifactType,
+			Digest:       info.Digest,
+			Size:         info.Size,
+			Annotations:  manifest.Annotations,
+		})
+
+		return nil
+	}, fmt.Sprintf("labels.%q==%q", KraftKitLabelSubject, subject.String())); err != nil {
+		return nil, fmt.Errorf("could not list referrers: %w", err)
+	}
+
+	return filterReferrers(referrers, artifactType), nil
+}
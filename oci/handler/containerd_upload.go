@@ -0,0 +1,389 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package handler
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"kraftkit.sh/internal/version"
+	"kraftkit.sh/log"
+	"kraftkit.sh/oci/simpleauth"
+)
+
+const (
+	// DefaultUploadChunkSize is the size of each chunk of a blob which is
+	// uploaded to a remote registry via the chunked blob upload protocol.
+	DefaultUploadChunkSize = 8 * 1024 * 1024
+
+	// DefaultUploadChunkRetries is the number of times the upload of a chunk is
+	// resumed after a failure.
+	DefaultUploadChunkRetries = 3
+)
+
+// pushBlobsChunked uploads the blobs which are referenced by the target, i.e.
+// its configs and layers, to the remote registry via the chunked blob upload
+// protocol.  Manifests and indexes are left to containerd's pusher, which
+// skips the blobs which now exist remotely.
+func (handle *ContainerdHandler) pushBlobsChunked(ctx context.Context, ref string, target ocispec.Descriptor) error {
+	nref, err := name.ParseReference(ref)
+	if err != nil {
+		return err
+	}
+
+	ctx = namespaces.WithNamespace(ctx, handle.namespace)
+	cs := handle.client.ContentStore()
+
+	var blobs []ocispec.Descriptor
+
+	if err := images.Walk(ctx, images.HandlerFunc(func(ctx context.Context, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+		if images.IsManifestType(desc.MediaType) || images.IsIndexType(desc.MediaType) {
+			return images.Children(ctx, cs, desc)
+		}
+
+		blobs = append(blobs, desc)
+		return nil, nil
+	}), target); err != nil {
+		return fmt.Errorf("could not walk %s: %w", target.Digest, err)
+	}
+
+	uploader, err := handle.newChunkedUploader(ctx, nref.Context())
+	if err != nil {
+		return err
+	}
+
+	for _, desc := range blobs {
+		if err := func() error {
+			ra, err := cs.ReaderAt(ctx, desc)
+			if err != nil {
+				return err
+			}
+
+			defer ra.Close()
+
+			return uploader.upload(ctx, desc, ra)
+		}(); err != nil {
+			return fmt.Errorf("could not upload blob %s: %w", desc.Digest, err)
+		}
+	}
+
+	return nil
+}
+
+// newChunkedUploader prepares an uploader to the provided repository which
+// authenticates with the credentials of its registry, if any.
+func (handle *ContainerdHandler) newChunkedUploader(ctx context.Context, repo name.Repository) (*chunkedUploader, error) {
+	var auth authn.Authenticator = authn.Anonymous
+	rt := remote.DefaultTransport.(*http.Transport).Clone()
+
+	// Annoyingly convert between regtypes and authn.
+	if cfg, ok := handle.auths[repo.RegistryStr()]; ok {
+		auth = &simpleauth.SimpleAuthenticator{
+			Auth: &authn.AuthConfig{
+				Username: cfg.User,
+				Password: cfg.Token,
+			},
+		}
+
+		if !cfg.VerifySSL {
+			rt.TLSClientConfig = &tls.Config{
+				InsecureSkipVerify: true,
+			}
+		}
+	}
+
+	tr, err := transport.NewWithContext(ctx,
+		repo.Registry,
+		auth,
+		transport.NewUserAgent(rt, version.UserAgent()),
+		[]string{repo.Scope(transport.PushScope)},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not authenticate with %s: %w", repo.RegistryStr(), err)
+	}
+
+	return &chunkedUploader{
+		client: &http.Client{Transport: tr},
+		base: &url.URL{
+			Scheme: repo.Registry.Scheme(),
+			Host:   repo.RegistryStr(),
+		},
+		repo:      repo.RepositoryStr(),
+		chunkSize: DefaultUploadChunkSize,
+		retries:   DefaultUploadChunkRetries,
+	}, nil
+}
+
+// chunkedUploader uploads blobs to a repository of a remote registry in chunks
+// via `PATCH` requests.  The offset which the registry has confirmed is
+// tracked such that a failed upload is resumed rather than restarted.
+type chunkedUploader struct {
+	client    *http.Client
+	base      *url.URL
+	repo      string
+	chunkSize int64
+	retries   int
+}
+
+// upload uploads the content of the blob with the provided descriptor, which
+// is read from r, unless it already exists in the repository.
+func (u *chunkedUploader) upload(ctx context.Context, desc ocispec.Descriptor, r io.ReaderAt) error {
+	exists, err := u.exists(ctx, desc)
+	if err != nil {
+		return err
+	} else if exists {
+		log.G(ctx).
+			WithField("digest", desc.Digest.String()).
+			Trace("blob exists remotely, skipping")
+		return nil
+	}
+
+	location, err := u.start(ctx)
+	if err != nil {
+		return err
+	}
+
+	var offset int64
+	var failures int
+
+	for offset < desc.Size {
+		size := min(u.chunkSize, desc.Size-offset)
+
+		next, nextLocation, err := u.patch(ctx, location, r, offset, size)
+		if err == nil {
+			offset, location = next, nextLocation
+			failures = 0
+			continue
+		}
+
+		if failures >= u.retries || ctx.Err() != nil {
+			return err
+		}
+
+		failures++
+
+		log.G(ctx).
+			WithError(err).
+			WithField("digest", desc.Digest.String()).
+			WithField("offset", offset).
+			WithField("attempt", failures).
+			Debug("resuming chunked upload")
+
+		// Rather than restarting, ask the registry how much of the blob it has
+		// received and resume from there.
+		confirmed, statusLocation, serr := u.status(ctx, location)
+		if serr != nil {
+			return errors.Join(err, serr)
+		}
+
+		offset, location = confirmed, statusLocation
+	}
+
+	return u.commit(ctx, location, desc)
+}
+
+// exists checks whether the blob with the provided descriptor already exists
+// in the repository.
+func (u *chunkedUploader) exists(ctx context.Context, desc ocispec.Descriptor) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx,
+		http.MethodHead,
+		u.base.JoinPath("v2", u.repo, "blobs", desc.Digest.String()).String(),
+		nil,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+
+	return false, transport.CheckError(resp, http.StatusOK, http.StatusNotFound)
+}
+
+// start initiates a new upload session and returns its location.
+func (u *chunkedUploader) start(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx,
+		http.MethodPost,
+		u.base.JoinPath("v2", u.repo, "blobs", "uploads").String()+"/",
+		nil,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusAccepted); err != nil {
+		return "", err
+	}
+
+	return u.location(resp, "")
+}
+
+// patch uploads size bytes of r starting at offset to the upload session at
+// the provided location and returns the offset confirmed by the registry as
+// well as the location of the session for the next request.
+func (u *chunkedUploader) patch(ctx context.Context, location string, r io.ReaderAt, offset, size int64) (int64, string, error) {
+	req, err := http.NewRequestWithContext(ctx,
+		http.MethodPatch,
+		location,
+		io.NewSectionReader(r, offset, size),
+	)
+	if err != nil {
+		return 0, "", err
+	}
+
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", fmt.Sprintf("%d-%d", offset, offset+size-1))
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusAccepted, http.StatusNoContent); err != nil {
+		return 0, "", err
+	}
+
+	next := offset + size
+	if end, ok := parseUploadRange(resp.Header.Get("Range")); ok {
+		next = end
+	}
+
+	location, err = u.location(resp, location)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return next, location, nil
+}
+
+// status retrieves the offset which the registry has received of the upload
+// session at the provided location.
+func (u *chunkedUploader) status(ctx context.Context, location string) (int64, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return 0, "", err
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusNoContent); err != nil {
+		return 0, "", err
+	}
+
+	// An absent range indicates that nothing has been received yet.
+	end, _ := parseUploadRange(resp.Header.Get("Range"))
+
+	location, err = u.location(resp, location)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return end, location, nil
+}
+
+// commit completes the upload session at the provided location.
+func (u *chunkedUploader) commit(ctx context.Context, location string, desc ocispec.Descriptor) error {
+	loc, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+
+	query := loc.Query()
+	query.Set("digest", desc.Digest.String())
+	loc.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, loc.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	return transport.CheckError(resp, http.StatusCreated)
+}
+
+// location returns the absolute location of the upload session of the
+// response, or the fallback if the response does not carry one.
+func (u *chunkedUploader) location(resp *http.Response, fallback string) (string, error) {
+	location := resp.Header.Get("Location")
+	if location == "" {
+		if fallback == "" {
+			return "", fmt.Errorf("registry did not return an upload location")
+		}
+
+		return fallback, nil
+	}
+
+	loc, err := url.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("could not parse upload location: %w", err)
+	}
+
+	return resp.Request.URL.ResolveReference(loc).String(), nil
+}
+
+// parseUploadRange parses the inclusive `Range` header of an upload session,
+// e.g. `0-1023`, and returns the offset following its end.
+func parseUploadRange(header string) (int64, bool) {
+	_, end, ok := strings.Cut(strings.TrimPrefix(header, "bytes="), "-")
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(end, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return n + 1, true
+}
@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// fakeUploadRegistry implements the chunked blob upload protocol of a single
+// upload session.  The first PATCH request of the chunk at failAt only
+// receives half of its content before failing.
+type fakeUploadRegistry struct {
+	mu       sync.Mutex
+	received []byte
+	failAt   int64
+	failed   bool
+	patches  []string
+	blobs    map[string][]byte
+}
+
+func (reg *fakeUploadRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodHead:
+		if _, ok := reg.blobs[r.URL.Path]; ok {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+
+	case r.Method == http.MethodPost:
+		w.Header().Set("Location", "/v2/test/blobs/uploads/session")
+		w.WriteHeader(http.StatusAccepted)
+
+	case r.Method == http.MethodPatch:
+		reg.patches = append(reg.patches, r.Header.Get("Content-Range"))
+
+		start, _, _ := strings.Cut(r.Header.Get("Content-Range"), "-")
+		if offset, _ := strconv.ParseInt(start, 10, 64); offset != int64(len(reg.received)) {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		if int64(len(reg.received)) == reg.failAt && !reg.failed {
+			reg.failed = true
+			reg.received = append(reg.received, body[:len(body)/2]...)
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		reg.received = append(reg.received, body...)
+		w.Header().Set("Range", fmt.Sprintf("0-%d", len(reg.received)-1))
+		w.WriteHeader(http.StatusAccepted)
+
+	case r.Method == http.MethodGet:
+		if len(reg.received) > 0 {
+			w.Header().Set("Range", fmt.Sprintf("0-%d", len(reg.received)-1))
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut:
+		dgst := digest.Digest(r.URL.Query().Get("digest"))
+		if digest.FromBytes(reg.received) != dgst {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		reg.blobs["/v2/test/blobs/"+dgst.String()] = reg.received
+		w.WriteHeader(http.StatusCreated)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestChunkedUploaderResumesAfterFailure(t *testing.T) {
+	ctx := context.Background()
+
+	reg := &fakeUploadRegistry{
+		failAt: 4,
+		blobs:  make(map[string][]byte),
+	}
+
+	server := httptest.NewServer(reg)
+	defer server.Close()
+
+	base, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploader := &chunkedUploader{
+		client:    server.Client(),
+		base:      base,
+		repo:      "test",
+		chunkSize: 4,
+		retries:   DefaultUploadChunkRetries,
+	}
+
+	blob := []byte("0123456789")
+	desc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageLayer,
+		Digest:    digest.FromBytes(blob),
+		Size:      int64(len(blob)),
+	}
+
+	if err := uploader.upload(ctx, desc, bytes.NewReader(blob)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !bytes.Equal(reg.received, blob) {
+		t.Errorf("expected %q to be received, got %q", blob, reg.received)
+	}
+
+	// The failed chunk is resumed from the offset which the registry has
+	// confirmed rather than restarting the upload.
+	expect := []string{"0-3", "4-7", "6-9"}
+	if strings.Join(reg.patches, ",") != strings.Join(expect, ",") {
+		t.Errorf("expected chunks %v, got %v", expect, reg.patches)
+	}
+
+	// A subsequent upload of the same blob is skipped.
+	reg.patches = nil
+	if err := uploader.upload(ctx, desc, bytes.NewReader(blob)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(reg.patches) != 0 {
+		t.Errorf("expected existing blob to be skipped, got chunks %v", reg.patches)
+	}
+}
+
+func TestParseUploadRange(t *testing.T) {
+	for header, expect := range map[string]int64{
+		"0-0":          1,
+		"0-1023":       1024,
+		"bytes=0-1023": 1024,
+	} {
+		if got, ok := parseUploadRange(header); !ok || got != expect {
+			t.Errorf("%q: expected %d, got %d (ok=%t)", header, expect, got, ok)
+		}
+	}
+
+	if _, ok := parseUploadRange(""); ok {
+		t.Error("expected empty range to be rejected")
+	}
+}
@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"kraftkit.sh/log"
+	ociutils "kraftkit.sh/oci/utils"
+)
+
+// ErrDigestMismatch is returned when the digest of content does not match the
+// digest of the descriptor which refers to it.
+var ErrDigestMismatch = errors.New("digest mismatch")
+
+// CopyManifest copies the manifest with the provided digest, along with its
+// config and layers, from the source handler into the destination handler
+// without contacting a remote registry.  Blobs which already exist in the
+// destination are skipped and the digest of every copied blob is verified.
+// The manifest is then added to the destination's index of fullref, such that
+// the image can be resolved by name.
+func CopyManifest(ctx context.Context, dst, src Handler, fullref string, dgst digest.Digest) error {
+	manifestDesc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    dgst,
+	}
+
+	reader, err := src.FetchDescriptor(ctx, manifestDesc)
+	if err != nil {
+		return fmt.Errorf("could not fetch manifest %s: %w", dgst, err)
+	}
+
+	raw, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("could not read manifest %s: %w", dgst, err)
+	}
+
+	if actual := digest.FromBytes(raw); actual != dgst {
+		return fmt.Errorf("manifest %w: expected %s but got %s", ErrDigestMismatch, dgst, actual)
+	}
+
+	manifestDesc.Size = int64(len(raw))
+
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return fmt.Errorf("could not unmarshal manifest %s: %w", dgst, err)
+	}
+
+	if manifest.MediaType != "" {
+		manifestDesc.MediaType = manifest.MediaType
+	}
+
+	// The config and layers must be present before the manifest which refers
+	// to them is saved.
+	for _, desc := range append([]ocispec.Descriptor{manifest.Config}, manifest.Layers...) {
+		if err := copyBlob(ctx, dst, src, fullref, desc); err != nil {
+			return err
+		}
+	}
+
+	if err := dst.SaveDescriptor(ctx, fullref, manifestDesc, bytes.NewReader(raw), nil); err != nil {
+		return fmt.Errorf("could not save manifest %s: %w", dgst, err)
+	}
+
+	// Retain the platform and annotations with which the source's index refers
+	// to the manifest.
+	if srcIndex, err := src.ResolveIndex(ctx, fullref); err == nil {
+		for _, desc := range srcIndex.Manifests {
+			if desc.Digest == dgst {
+				manifestDesc.Platform = desc.Platform
+				manifestDesc.Annotations = desc.Annotations
+				break
+			}
+		}
+	}
+
+	return saveIndexEntry(ctx, dst, fullref, manifestDesc)
+}
+
+// saveIndexEntry adds the manifest descriptor to the handler's index of
+// fullref, creating the index if it does not exist.  Entries for the same
+// manifest or platform are replaced.
+func saveIndexEntry(ctx context.Context, handle Handler, fullref string, manifestDesc ocispec.Descriptor) error {
+	index, err := handle.ResolveIndex(ctx, fullref)
+	if err != nil {
+		index = &ocispec.Index{
+			Versioned: specs.Versioned{
+				SchemaVersion: 2,
+			},
+			MediaType: ocispec.MediaTypeImageIndex,
+		}
+	}
+
+	checksum, err := ociutils.PlatformChecksum(fullref, manifestDesc.Platform)
+	if err != nil {
+		return fmt.Errorf("could not calculate platform checksum for '%s': %w", manifestDesc.Digest, err)
+	}
+
+	manifests := []ocispec.Descriptor{}
+	for _, desc := range index.Manifests {
+		if desc.Digest == manifestDesc.Digest {
+			continue
+		}
+
+		if manifestDesc.Platform != nil {
+			existing, err := ociutils.PlatformChecksum(fullref, desc.Platform)
+			if err != nil {
+				return fmt.Errorf("could not calculate platform checksum for '%s': %w", desc.Digest, err)
+			}
+
+			if existing == checksum {
+				continue
+			}
+		}
+
+		manifests = append(manifests, desc)
+	}
+
+	index.Manifests = append(manifests, manifestDesc)
+
+	raw, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("could not marshal index: %w", err)
+	}
+
+	indexDesc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Digest:    digest.FromBytes(raw),
+		Size:      int64(len(raw)),
+	}
+
+	if err := handle.SaveDescriptor(ctx, fullref, indexDesc, bytes.NewReader(raw), nil); err != nil {
+		return fmt.Errorf("could not save index of '%s': %w", fullref, err)
+	}
+
+	return nil
+}
+
+// copyBlob copies a single blob from the source handler into the destination
+// handler unless it already exists there.
+func copyBlob(ctx context.Context, dst, src Handler, fullref string, desc ocispec.Descriptor) error {
+	if _, err := dst.DigestInfo(ctx, desc.Digest); err == nil {
+		log.G(ctx).
+			WithField("digest", desc.Digest.String()).
+			Trace("blob exists, skipping")
+		return nil
+	}
+
+	reader, err := src.FetchDescriptor(ctx, desc)
+	if err != nil {
+		return fmt.Errorf("could not fetch blob %s: %w", desc.Digest, err)
+	}
+
+	defer reader.Close()
+
+	if err := dst.SaveDescriptor(ctx, fullref, desc, &verifyingReader{
+		reader:   reader,
+		verifier: desc.Digest.Verifier(),
+		desc:     desc,
+	}, nil); err != nil {
+		return fmt.Errorf("could not save blob %s: %w", desc.Digest, err)
+	}
+
+	return nil
+}
+
+// verifyingReader fails the final read of the underlying reader if the content
+// which was read does not match the size and digest of the descriptor, such
+// that the destination handler discards the written blob.
+type verifyingReader struct {
+	reader   io.Reader
+	verifier digest.Verifier
+	desc     ocispec.Descriptor
+	read     int64
+}
+
+// Read implements io.Reader.
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		_, _ = r.verifier.Write(p[:n])
+		r.read += int64(n)
+	}
+
+	if err == io.EOF {
+		if r.read != r.desc.Size {
+			return n, fmt.Errorf("blob %s size mismatch: expected %d but got %d", r.desc.Digest, r.desc.Size, r.read)
+		}
+		if !r.verifier.Verified() {
+			return n, fmt.Errorf("blob %s %w", r.desc.Digest, ErrDigestMismatch)
+		}
+	}
+
+	return n, err
+}
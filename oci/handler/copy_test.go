@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"kraftkit.sh/oci/handler"
+)
+
+func TestCopyManifestDirectoryToDirectory(t *testing.T) {
+	const fullref = "unikraft.org/test:latest"
+
+	ctx := context.Background()
+
+	src, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := saveBlob(t, src, ocispec.MediaTypeImageConfig, []byte(`{"architecture":"x86_64","os":"qemu","config":{}}`))
+	layer := saveBlob(t, src, ocispec.MediaTypeImageLayer, []byte("layer"))
+
+	raw, err := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{layer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := saveBlob(t, src, ocispec.MediaTypeImageManifest, raw)
+	manifest.Platform = &ocispec.Platform{
+		OS:           "qemu",
+		Architecture: "x86_64",
+	}
+
+	raw, err = json.Marshal(ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{manifest},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexDesc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Digest:    digest.FromBytes(raw),
+		Size:      int64(len(raw)),
+	}
+
+	if err := src.SaveDescriptor(ctx, fullref, indexDesc, bytes.NewReader(raw), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Copying twice must not duplicate the entry in the destination's index.
+	for i := 0; i < 2; i++ {
+		if err := handler.CopyManifest(ctx, dst, src, fullref, manifest.Digest); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	index, err := dst.ResolveIndex(ctx, fullref)
+	if err != nil {
+		t.Fatalf("could not resolve index by name: %v", err)
+	}
+
+	if len(index.Manifests) != 1 {
+		t.Fatalf("expected 1 manifest in index, got %d", len(index.Manifests))
+	}
+
+	if got := index.Manifests[0]; got.Digest != manifest.Digest {
+		t.Errorf("expected manifest %s, got %s", manifest.Digest, got.Digest)
+	} else if got.Platform == nil || got.Platform.OS != "qemu" || got.Platform.Architecture != "x86_64" {
+		t.Errorf("expected platform qemu/x86_64, got %v", got.Platform)
+	}
+
+	image, err := dst.ResolveImage(ctx, fullref, manifest.Digest)
+	if err != nil {
+		t.Fatalf("could not resolve image: %v", err)
+	}
+
+	if image.Architecture != "x86_64" {
+		t.Errorf("expected architecture x86_64, got %s", image.Architecture)
+	}
+
+	if _, err := dst.DigestInfo(ctx, layer.Digest); err != nil {
+		t.Errorf("expected layer to be copied: %v", err)
+	}
+}
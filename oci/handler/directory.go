@@ -40,8 +40,9 @@ import (
 )
 
 const (
-	DirectoryHandlerDigestsDir = "digests"
-	DirectoryHandlerIndexesDir = "indexes"
+	DirectoryHandlerDigestsDir   = "digests"
+	DirectoryHandlerIndexesDir   = "indexes"
+	DirectoryHandlerReferrersDir = "referrers"
 )
 
 type DirectoryHandler struct {
@@ -468,21 +469,40 @@ func (handle *DirectoryHandler) PullDigest(ctx context.Context, mediaType, fullr
 			}
 		}
 
+		// Only pull the layers which do not exist locally.
+		var layers []ocispec.Descriptor
+		for _, layer := range manifest.Layers {
+			layerPath := filepath.Join(
+				handle.path,
+				DirectoryHandlerDigestsDir,
+				layer.Digest.Algorithm().String(),
+				layer.Digest.Encoded(),
+			)
+
+			if fi, err := os.Stat(layerPath); err == nil && fi.Size() == layer.Size {
+				continue
+			}
+
+			layers = append(layers, layer)
+		}
+
 		// First calculate the total size of all layers.  This is done so that the
 		// onProgress callback correctly reports
 		var totalSize int64
-		for _, layer := range manifest.Layers {
+		for _, layer := range layers {
 			totalSize += layer.Size
 		}
 
-		for _, layer := range manifest.Layers {
+		for _, layer := range layers {
 			if err := handle.PullDigest(ctx,
 				ocispec.MediaTypeImageLayer,
 				fullref,
 				layer.Digest,
 				plat,
 				func(size float64) {
-					onProgress(size / float64(totalSize))
+					if onProgress != nil && totalSize > 0 {
+						onProgress(size / float64(totalSize))
+					}
 				},
 			); err != nil {
 				return fmt.Errorf("could not pull layer from digest: %w", err)
@@ -587,7 +607,9 @@ func (handle *DirectoryHandler) SaveDescriptor(ctx context.Context, ref string,
 		return fmt.Errorf("could not make parent directory: %w", err)
 	}
 
-	blob, err := os.OpenFile(blobPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o664)
+	// Hold the lock of the blob whilst it is written such that it is not pruned
+	// concurrently.
+	blob, err := lockedfile.OpenFile(blobPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o664)
 	if err != nil {
 		return fmt.Errorf("could not create blob: %w", err)
 	}
@@ -649,13 +671,109 @@ func (handle *DirectoryHandler) SaveDescriptor(ctx context.Context, ref string,
 	return nil
 }
 
+// StreamDescriptor implements DescriptorStreamer.
+func (handle *DirectoryHandler) StreamDescriptor(ctx context.Context, ref, mediaType string, reader io.Reader, onProgress func(float64)) (ocispec.Descriptor, error) {
+	digestsDir := filepath.Join(handle.path, DirectoryHandlerDigestsDir)
+
+	if err := os.MkdirAll(digestsDir, 0o774); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("could not make digests directory: %w", err)
+	}
+
+	// Stage the content within the handler's own directory such that it can be
+	// atomically moved into place once its digest is known, without requiring
+	// an additional copy.
+	staged, err := os.CreateTemp(digestsDir, ".stream-*")
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("could not create staging blob: %w", err)
+	}
+
+	defer os.Remove(staged.Name())
+
+	log.G(ctx).
+		WithField("ref", ref).
+		WithField("mediaType", mediaType).
+		Trace("streaming")
+
+	digester := digest.Canonical.Digester()
+	size, err := io.Copy(io.MultiWriter(staged, digester.Hash()), reader)
+	if err != nil {
+		staged.Close()
+		return ocispec.Descriptor{}, fmt.Errorf("could not write blob: %w", err)
+	}
+
+	if err := staged.Close(); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("could not close blob: %w", err)
+	}
+
+	desc := ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    digester.Digest(),
+		Size:      size,
+	}
+
+	blobPath := filepath.Join(
+		digestsDir,
+		desc.Digest.Algorithm().String(),
+		desc.Digest.Encoded(),
+	)
+
+	if err := os.MkdirAll(filepath.Dir(blobPath), 0o774); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("could not make parent directory: %w", err)
+	}
+
+	if err := os.Chmod(staged.Name(), 0o664); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("could not set blob permissions: %w", err)
+	}
+
+	if err := os.Rename(staged.Name(), blobPath); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("could not move blob into place: %w", err)
+	}
+
+	if onProgress != nil {
+		onProgress(1)
+	}
+
+	return desc, nil
+}
+
+// FetchDescriptor implements DescriptorFetcher.
+func (handle *DirectoryHandler) FetchDescriptor(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	blobPath := filepath.Join(
+		handle.path,
+		DirectoryHandlerDigestsDir,
+		desc.Digest.Algorithm().String(),
+		desc.Digest.Encoded(),
+	)
+
+	blob, err := os.Open(blobPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open blob: %w", err)
+	}
+
+	return blob, nil
+}
+
 // PushDescriptor implements DescriptorPusher.
-func (handle *DirectoryHandler) PushDescriptor(ctx context.Context, fullref string, desc *ocispec.Descriptor) error {
+func (handle *DirectoryHandler) PushDescriptor(ctx context.Context, fullref string, desc *ocispec.Descriptor, opts ...PushDescriptorOption) error {
 	ref, err := name.ParseReference(fullref)
 	if err != nil {
 		return err
 	}
 
+	popts := NewPushDescriptorOptions(opts...)
+
+	// Blobs which already exist in the remote repository are always skipped by
+	// the remote writer after a HEAD request.  Additionally, when a source
+	// repository is hinted, missing blobs are first attempted to be mounted
+	// from it.
+	var mountFrom name.Reference
+	if popts.MountFrom() != "" {
+		mountFrom, err = name.ParseReference(popts.MountFrom())
+		if err != nil {
+			return fmt.Errorf("could not parse mount source: %w", err)
+		}
+	}
+
 	ropts := []remote.Option{
 		remote.WithContext(ctx),
 		remote.WithUserAgent(version.UserAgent()),
@@ -694,25 +812,27 @@ func (handle *DirectoryHandler) PushDescriptor(ctx context.Context, fullref stri
 	case ocispec.MediaTypeImageIndex:
 		return remote.WriteIndex(ref,
 			&DirectoryIndex{
-				ctx:     ctx,
-				desc:    desc,
-				handle:  handle,
-				fullref: fullref,
+				ctx:       ctx,
+				desc:      desc,
+				handle:    handle,
+				fullref:   fullref,
+				mountFrom: mountFrom,
 			},
 			ropts...,
 		)
 
 	case ocispec.MediaTypeImageManifest:
-		image, err := handle.resolveImage(ctx, fullref, desc.Digest)
+		image, err := handle.ResolveImage(ctx, fullref, desc.Digest)
 		if err != nil {
 			return err
 		}
 
 		return remote.Write(ref,
 			DirectoryManifest{
-				image:  image,
-				desc:   desc,
-				handle: handle,
+				image:     image,
+				desc:      desc,
+				handle:    handle,
+				mountFrom: mountFrom,
 			},
 			append(ropts, remote.WithPlatform(v1.Platform{
 				Architecture: image.Architecture,
@@ -745,7 +865,7 @@ func (handle *DirectoryHandler) ResolveManifest(ctx context.Context, fullref str
 
 	// Check whether the manifest exists
 	if _, err := os.Stat(manifestPath); err != nil {
-		return nil, fmt.Errorf("manifest for '%s' does not exist: %s", dgst.String(), manifestPath)
+		return nil, fmt.Errorf("manifest for '%s' does not exist: %w", dgst.String(), err)
 	}
 
 	// Read the manifest
@@ -761,6 +881,11 @@ func (handle *DirectoryHandler) ResolveManifest(ctx context.Context, fullref str
 		return nil, err
 	}
 
+	// Verify the contents of the manifest to guard against on-disk corruption
+	if actual := dgst.Algorithm().FromBytes(manifestRaw); actual != dgst {
+		return nil, fmt.Errorf("manifest '%s' is corrupt: %w: content has digest '%s'", dgst.String(), ErrDigestMismatch, actual.String())
+	}
+
 	// Unmarshal the manifest
 	manifest := ocispec.Manifest{}
 	if err = json.Unmarshal(manifestRaw, &manifest); err != nil {
@@ -819,6 +944,11 @@ func (handle *DirectoryHandler) ListManifests(ctx context.Context) (map[string]*
 	return manifests, nil
 }
 
+// ListManifestRefs implements ManifestRefLister.
+func (handle *DirectoryHandler) ListManifestRefs(ctx context.Context) ([]ManifestRef, error) {
+	return listManifestRefs(ctx, handle)
+}
+
 func (handle *DirectoryHandler) DeleteManifest(ctx context.Context, fullref string, dgst digest.Digest) error {
 	manifestPath := filepath.Join(
 		handle.path,
@@ -1161,11 +1291,17 @@ func (pt *progressWriter) Read(p []byte) (int, error) {
 	return n, err
 }
 
-func (handle *DirectoryHandler) resolveImage(ctx context.Context, fullref string, dgst digest.Digest) (*ocispec.Image, error) {
-	// Find the manifest of this image
-	ref, err := name.ParseReference(fullref)
-	if err != nil {
-		return nil, fmt.Errorf("parsing reference: %w", err)
+// ResolveImage implements ImageResolver.
+func (handle *DirectoryHandler) ResolveImage(ctx context.Context, fullref string, dgst digest.Digest) (*ocispec.Image, error) {
+	// The reference is optional and only used to improve error messages.
+	refName := dgst.String()
+	if fullref != "" {
+		ref, err := name.ParseReference(fullref)
+		if err != nil {
+			return nil, fmt.Errorf("parsing reference: %w", err)
+		}
+
+		refName = ref.Name()
 	}
 
 	manifest, err := handle.ResolveManifest(ctx, fullref, dgst)
@@ -1189,7 +1325,7 @@ func (handle *DirectoryHandler) resolveImage(ctx context.Context, fullref string
 
 	// Check whether the config exists
 	if _, err := os.Stat(configDir); err != nil {
-		return nil, fmt.Errorf("could not access config file for %s: %w", ref.Name(), err)
+		return nil, fmt.Errorf("could not access config file for %s: %w", refName, err)
 	}
 
 	// Read the config
@@ -1217,7 +1353,7 @@ func (handle *DirectoryHandler) resolveImage(ctx context.Context, fullref string
 
 // UnpackImage implements ImageUnpacker.
 func (handle *DirectoryHandler) UnpackImage(ctx context.Context, fullref string, dgst digest.Digest, dest string) (*ocispec.Image, error) {
-	img, err := handle.resolveImage(ctx, fullref, dgst)
+	img, err := handle.ResolveImage(ctx, fullref, dgst)
 	if err != nil {
 		return nil, fmt.Errorf("resolving config: %w", err)
 	}
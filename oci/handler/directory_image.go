@@ -16,7 +16,9 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
@@ -93,10 +95,11 @@ func (dl DirectoryLayer) MediaType() (types.MediaType, error) {
 }
 
 type DirectoryManifest struct {
-	ctx    context.Context
-	handle *DirectoryHandler
-	image  *ocispec.Image
-	desc   *ocispec.Descriptor
+	ctx       context.Context
+	handle    *DirectoryHandler
+	image     *ocispec.Image
+	desc      *ocispec.Descriptor
+	mountFrom name.Reference
 }
 
 // Layers returns the layers of the image
@@ -119,6 +122,16 @@ func (dm DirectoryManifest) Layers() ([]v1.Layer, error) {
 			mediatype: layer.MediaType,
 		}
 
+		// Hint the remote writer to attempt a cross-repository mount of the blob
+		// before falling back to uploading it.
+		if dm.mountFrom != nil {
+			layers = append(layers, &remote.MountableLayer{
+				Layer:     dlayer,
+				Reference: dm.mountFrom,
+			})
+			continue
+		}
+
 		layers = append(layers, dlayer)
 	}
 
@@ -273,10 +286,11 @@ func (dm DirectoryManifest) LayerByDiffID(hash v1.Hash) (v1.Layer, error) {
 }
 
 type DirectoryIndex struct {
-	desc    *ocispec.Descriptor
-	handle  *DirectoryHandler
-	fullref string
-	ctx     context.Context
+	desc      *ocispec.Descriptor
+	handle    *DirectoryHandler
+	fullref   string
+	ctx       context.Context
+	mountFrom name.Reference
 }
 
 // MediaType implements v1.ImageIndex
@@ -320,7 +334,7 @@ func (di *DirectoryIndex) RawManifest() ([]byte, error) {
 func (di *DirectoryIndex) Image(manifestDigest v1.Hash) (v1.Image, error) {
 	dgst := digest.NewDigestFromHex(manifestDigest.Algorithm, manifestDigest.Hex)
 
-	image, err := di.handle.resolveImage(
+	image, err := di.handle.ResolveImage(
 		di.ctx,
 		di.fullref,
 		dgst,
@@ -349,10 +363,11 @@ func (di *DirectoryIndex) Image(manifestDigest v1.Hash) (v1.Image, error) {
 	)
 
 	return &DirectoryManifest{
-		ctx:    di.ctx,
-		handle: di.handle,
-		image:  image,
-		desc:   &desc,
+		ctx:       di.ctx,
+		handle:    di.handle,
+		image:     image,
+		desc:      &desc,
+		mountFrom: di.mountFrom,
 	}, nil
 }
 
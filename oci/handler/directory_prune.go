@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"kraftkit.sh/internal/lockedfile"
+	"kraftkit.sh/log"
+)
+
+// maxPruneDescriptorSize is the largest blob which is inspected as a potential
+// manifest or index whilst pruning.  Larger blobs are assumed to be layers.
+const maxPruneDescriptorSize = 4 * 1024 * 1024
+
+// pruneDescriptor contains the union of the fields of a manifest and an index
+// which reference other blobs.
+type pruneDescriptor struct {
+	MediaType string               `json:"mediaType,omitempty"`
+	Config    *ocispec.Descriptor  `json:"config,omitempty"`
+	Layers    []ocispec.Descriptor `json:"layers,omitempty"`
+	Manifests []ocispec.Descriptor `json:"manifests,omitempty"`
+}
+
+// Prune removes all blobs from the directory store which are not referenced by
+// a stored manifest or index and returns the number of bytes freed.  Manifests
+// and indexes themselves are always retained; use DeleteManifest or
+// DeleteIndex to remove them first.  When used with WithPruneDryRun, the
+// number of bytes which would be freed is returned without removing anything.
+func (handle *DirectoryHandler) Prune(ctx context.Context, opts ...PruneOption) (int64, error) {
+	options := NewPruneOptions(opts...)
+	digestsDir := filepath.Join(handle.path, DirectoryHandlerDigestsDir)
+
+	if _, err := os.Stat(digestsDir); err != nil && os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	// Blobs which are modified after the walk has started may be referenced by
+	// content which is not considered and are hence retained.
+	start := time.Now()
+
+	blobs := map[digest.Digest]int64{}
+	referenced := map[digest.Digest]bool{}
+
+	if err := filepath.WalkDir(digestsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		// Only consider blobs which follow the `<algorithm>/<encoded>` layout,
+		// which excludes in-progress streams which are being staged.
+		rel, err := filepath.Rel(digestsDir, path)
+		if err != nil {
+			return nil
+		}
+
+		dgst := digest.NewDigestFromEncoded(digest.Algorithm(filepath.Dir(rel)), filepath.Base(rel))
+		if dgst.Validate() != nil {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		blobs[dgst] = info.Size()
+
+		if info.Size() > maxPruneDescriptorSize {
+			return nil
+		}
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+
+		var desc pruneDescriptor
+		if err := json.Unmarshal(raw, &desc); err != nil {
+			return nil
+		}
+
+		switch {
+		case desc.MediaType == ocispec.MediaTypeImageIndex || len(desc.Manifests) > 0:
+			referenced[dgst] = true
+			for _, manifest := range desc.Manifests {
+				referenced[manifest.Digest] = true
+			}
+
+		// Image configs also contain a `config` attribute, which is why the
+		// digest is checked to distinguish them from manifests.
+		case desc.MediaType == ocispec.MediaTypeImageManifest || (desc.Config != nil && desc.Config.Digest != ""):
+			referenced[dgst] = true
+			if desc.Config != nil {
+				referenced[desc.Config.Digest] = true
+			}
+			for _, layer := range desc.Layers {
+				referenced[layer.Digest] = true
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("could not walk digests directory: %w", err)
+	}
+
+	var freed int64
+
+	for dgst, size := range blobs {
+		if referenced[dgst] {
+			continue
+		}
+
+		if options.onCandidate != nil {
+			options.onCandidate(dgst, size)
+		}
+
+		if options.dryRun {
+			freed += size
+			continue
+		}
+
+		log.G(ctx).
+			WithField("digest", dgst.String()).
+			Trace("pruning")
+
+		removed, err := removeBlob(filepath.Join(
+			digestsDir,
+			dgst.Algorithm().String(),
+			dgst.Encoded(),
+		), start)
+		if err != nil {
+			return freed, fmt.Errorf("could not remove blob %s: %w", dgst, err)
+		}
+
+		if removed {
+			freed += size
+		}
+	}
+
+	return freed, nil
+}
+
+// removeBlob removes the blob at the provided path whilst holding its lock,
+// such that a blob which is concurrently being saved is not removed.  Blobs
+// which have been modified since the provided time, or which have been
+// replaced before the lock was acquired, are retained and false is returned.
+func removeBlob(path string, since time.Time) (bool, error) {
+	blob, err := lockedfile.OpenFile(path, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	defer blob.Close()
+
+	locked, err := blob.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	if locked.ModTime().After(since) {
+		return false, nil
+	}
+
+	current, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if !os.SameFile(locked, current) {
+		return false, nil
+	}
+
+	if err := os.Remove(path); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
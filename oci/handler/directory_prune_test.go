@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"kraftkit.sh/oci/handler"
+)
+
+func saveBlob(t *testing.T, handle *handler.DirectoryHandler, mediaType string, data []byte) ocispec.Descriptor {
+	t.Helper()
+
+	desc := ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(data),
+		Size:      int64(len(data)),
+	}
+
+	if err := handle.SaveDescriptor(context.Background(), "", desc, bytes.NewReader(data), nil); err != nil {
+		t.Fatalf("could not save blob: %v", err)
+	}
+
+	return desc
+}
+
+func TestDirectoryHandlerPrune(t *testing.T) {
+	ctx := context.Background()
+
+	handle, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := saveBlob(t, handle, ocispec.MediaTypeImageConfig, []byte(`{"architecture":"x86_64","config":{}}`))
+	layer := saveBlob(t, handle, ocispec.MediaTypeImageLayer, []byte("referenced layer"))
+	orphan := saveBlob(t, handle, ocispec.MediaTypeImageLayer, []byte("orphaned layer"))
+
+	raw, err := json.Marshal(ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{layer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := saveBlob(t, handle, ocispec.MediaTypeImageManifest, raw)
+
+	var candidates []digest.Digest
+	freed, err := handle.Prune(ctx,
+		handler.WithPruneDryRun(true),
+		handler.WithPruneOnCandidate(func(dgst digest.Digest, _ int64) {
+			candidates = append(candidates, dgst)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if freed != orphan.Size {
+		t.Errorf("expected %d bytes to be freed, got %d", orphan.Size, freed)
+	}
+
+	if len(candidates) != 1 || candidates[0] != orphan.Digest {
+		t.Errorf("expected only %s to be a candidate, got %v", orphan.Digest, candidates)
+	}
+
+	if _, err := handle.DigestInfo(ctx, orphan.Digest); err != nil {
+		t.Errorf("expected dry run to retain blob: %v", err)
+	}
+
+	freed, err = handle.Prune(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if freed != orphan.Size {
+		t.Errorf("expected %d bytes to be freed, got %d", orphan.Size, freed)
+	}
+
+	if _, err := handle.DigestInfo(ctx, orphan.Digest); err == nil {
+		t.Errorf("expected blob %s to be removed", orphan.Digest)
+	}
+
+	for _, desc := range []ocispec.Descriptor{config, layer, manifest} {
+		if _, err := handle.DigestInfo(ctx, desc.Digest); err != nil {
+			t.Errorf("expected blob %s to be retained: %v", desc.Digest, err)
+		}
+	}
+}
+
+func TestDirectoryHandlerPruneRetainsModifiedBlobs(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	handle, err := handler.NewDirectoryHandler(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orphan := saveBlob(t, handle, ocispec.MediaTypeImageLayer, []byte("concurrently saved layer"))
+
+	// Simulate a blob which is saved whilst the directory is being pruned.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(
+		dir,
+		handler.DirectoryHandlerDigestsDir,
+		orphan.Digest.Algorithm().String(),
+		orphan.Digest.Encoded(),
+	), future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	freed, err := handle.Prune(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if freed != 0 {
+		t.Errorf("expected no bytes to be freed, got %d", freed)
+	}
+
+	if _, err := handle.DigestInfo(ctx, orphan.Digest); err != nil {
+		t.Errorf("expected blob %s to be retained: %v", orphan.Digest, err)
+	}
+}
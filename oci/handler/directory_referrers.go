@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"kraftkit.sh/internal/lockedfile"
+	"kraftkit.sh/log"
+)
+
+// SaveReferrer implements ReferrerSaver.  Since the directory handler has no
+// registry to query, the referrers of each subject are recorded in an index
+// named after the referrers tag schema, i.e. `referrers/<alg>-<ref>`.
+func (handle *DirectoryHandler) SaveReferrer(ctx context.Context, subject, artifact ocispec.Descriptor, reader io.Reader) error {
+	referrer, raw, err := newReferrerManifest(subject, artifact)
+	if err != nil {
+		return err
+	}
+
+	if err := handle.SaveDescriptor(ctx, "", artifact, reader, nil); err != nil {
+		return fmt.Errorf("could not save artifact: %w", err)
+	}
+
+	if err := handle.SaveDescriptor(ctx, "", ocispec.DescriptorEmptyJSON, bytes.NewReader(ocispec.DescriptorEmptyJSON.Data), nil); err != nil {
+		return fmt.Errorf("could not save artifact config: %w", err)
+	}
+
+	if err := handle.SaveDescriptor(ctx, "", referrer, bytes.NewReader(raw), nil); err != nil {
+		return fmt.Errorf("could not save referrer manifest: %w", err)
+	}
+
+	indexPath := filepath.Join(handle.path, DirectoryHandlerReferrersDir, referrersTag(subject.Digest))
+
+	if err := os.MkdirAll(filepath.Dir(indexPath), 0o774); err != nil {
+		return fmt.Errorf("could not make referrers directory: %w", err)
+	}
+
+	// Serialize updates of the subject's index, including those of other
+	// processes, such that concurrently saved referrers are not lost.
+	unlock, err := lockedfile.MutexAt(indexPath + ".lock").Lock()
+	if err != nil {
+		return fmt.Errorf("could not lock referrers index: %w", err)
+	}
+
+	defer unlock()
+
+	index, err := handle.referrersIndex(subject.Digest)
+	if err != nil {
+		return err
+	}
+
+	for _, existing := range index.Manifests {
+		if existing.Digest == referrer.Digest {
+			return nil
+		}
+	}
+
+	index.Manifests = append(index.Manifests, referrer)
+
+	rawIndex, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("could not marshal referrers index: %w", err)
+	}
+
+	// Write the index atomically such that concurrent readers never observe a
+	// partially written index.
+	staged, err := os.CreateTemp(filepath.Dir(indexPath), ".referrers-*")
+	if err != nil {
+		return fmt.Errorf("could not create referrers index: %w", err)
+	}
+
+	defer os.Remove(staged.Name())
+
+	if _, err := staged.Write(rawIndex); err != nil {
+		staged.Close()
+		return fmt.Errorf("could not write referrers index: %w", err)
+	}
+
+	if err := staged.Close(); err != nil {
+		return fmt.Errorf("could not close referrers index: %w", err)
+	}
+
+	if err := os.Rename(staged.Name(), indexPath); err != nil {
+		return fmt.Errorf("could not move referrers index into place: %w", err)
+	}
+
+	log.G(ctx).
+		WithField("subject", subject.Digest.String()).
+		WithField("referrer", referrer.Digest.String()).
+		WithField("artifactType", referrer.ArtifactType).
+		Trace("saved referrer")
+
+	return nil
+}
+
+// ListReferrers implements ReferrerLister.
+func (handle *DirectoryHandler) ListReferrers(ctx context.Context, subject digest.Digest, artifactType string) ([]ocispec.Descriptor, error) {
+	index, err := handle.referrersIndex(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	return filterReferrers(index.Manifests, artifactType), nil
+}
+
+// referrersIndex returns the index of referrers recorded for the subject, or
+// an empty index if the subject has no referrers.
+func (handle *DirectoryHandler) referrersIndex(subject digest.Digest) (*ocispec.Index, error) {
+	index := ocispec.Index{
+		Versioned: specs.Versioned{
+			SchemaVersion: 2,
+		},
+		MediaType: ocispec.MediaTypeImageIndex,
+	}
+
+	raw, err := os.ReadFile(filepath.Join(handle.path, DirectoryHandlerReferrersDir, referrersTag(subject)))
+	if os.IsNotExist(err) {
+		return &index, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read referrers index: %w", err)
+	}
+
+	if err := json.Unmarshal(raw, &index); err != nil {
+		return nil, fmt.Errorf("could not unmarshal referrers index: %w", err)
+	}
+
+	return &index, nil
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"kraftkit.sh/oci/handler"
+)
+
+func TestDirectoryHandlerReferrers(t *testing.T) {
+	ctx := context.Background()
+
+	handle, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject := saveBlob(t, handle, ocispec.MediaTypeImageManifest, []byte(`{"schemaVersion":2}`))
+
+	sbom := []byte(`{"spdxVersion":"SPDX-2.3"}`)
+	artifact := ocispec.Descriptor{
+		MediaType: "application/spdx+json",
+		Digest:    digest.FromBytes(sbom),
+		Size:      int64(len(sbom)),
+	}
+
+	// Attaching the same artifact twice must not duplicate the referrer.
+	for i := 0; i < 2; i++ {
+		if err := handle.SaveReferrer(ctx, subject, artifact, bytes.NewReader(sbom)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	referrers, err := handle.ListReferrers(ctx, subject.Digest, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(referrers) != 1 {
+		t.Fatalf("expected 1 referrer, got %d", len(referrers))
+	}
+
+	if referrers[0].ArtifactType != artifact.MediaType {
+		t.Errorf("expected artifact type %s, got %s", artifact.MediaType, referrers[0].ArtifactType)
+	}
+
+	if _, err := handle.DigestInfo(ctx, referrers[0].Digest); err != nil {
+		t.Errorf("expected referrer manifest to be saved: %v", err)
+	}
+
+	referrers, err = handle.ListReferrers(ctx, subject.Digest, "application/vnd.cyclonedx+json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(referrers) != 0 {
+		t.Errorf("expected no referrers of another artifact type, got %d", len(referrers))
+	}
+}
+
+func TestDirectoryHandlerReferrersConcurrent(t *testing.T) {
+	const count = 16
+
+	ctx := context.Background()
+
+	handle, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject := saveBlob(t, handle, ocispec.MediaTypeImageManifest, []byte(`{"schemaVersion":2}`))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			data := []byte(fmt.Sprintf(`{"artifact":%d}`, i))
+			artifact := ocispec.Descriptor{
+				MediaType: "application/spdx+json",
+				Digest:    digest.FromBytes(data),
+				Size:      int64(len(data)),
+			}
+
+			errs <- handle.SaveReferrer(ctx, subject, artifact, bytes.NewReader(data))
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	referrers, err := handle.ListReferrers(ctx, subject.Digest, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(referrers) != count {
+		t.Errorf("expected %d referrers, got %d", count, len(referrers))
+	}
+}
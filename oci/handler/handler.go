@@ -34,21 +34,49 @@ type DescriptorSaver interface {
 	SaveDescriptor(context.Context, string, ocispec.Descriptor, io.Reader, func(float64)) error
 }
 
+type DescriptorStreamer interface {
+	// StreamDescriptor saves the content read from the provided io.Reader
+	// whose digest and size are not known in advance.  The digest is computed
+	// whilst the content is being written and the resulting descriptor of the
+	// provided media type is returned.
+	StreamDescriptor(context.Context, string, string, io.Reader, func(float64)) (ocispec.Descriptor, error)
+}
+
+type DescriptorFetcher interface {
+	// FetchDescriptor returns a reader of the stored content of the provided
+	// descriptor.  The caller is responsible for closing the reader.
+	FetchDescriptor(context.Context, ocispec.Descriptor) (io.ReadCloser, error)
+}
+
 type DescriptorPusher interface {
 	// PushDescriptor accepts an input descriptor and an optional canonical name
 	// for the descriptor (such as a tag) and uses the handler to push this to a
 	// remote registry.
-	PushDescriptor(context.Context, string, *ocispec.Descriptor) error
+	// Additional options, such as a source repository for cross-repository
+	// blob mounting, can be provided.
+	PushDescriptor(context.Context, string, *ocispec.Descriptor, ...PushDescriptorOption) error
 }
 
 type ManifestLister interface {
 	ListManifests(context.Context) (map[string]*ocispec.Manifest, error)
 }
 
+type ManifestRefLister interface {
+	// ListManifestRefs returns all stored manifests along with the reference
+	// they are tagged with, their digest, platform and size.
+	ListManifestRefs(context.Context) ([]ManifestRef, error)
+}
+
 type ManifestResolver interface {
 	ResolveManifest(context.Context, string, digest.Digest) (*ocispec.Manifest, error)
 }
 
+type ImageResolver interface {
+	// ResolveImage returns the image configuration referenced by the manifest
+	// with the provided digest.
+	ResolveImage(context.Context, string, digest.Digest) (*ocispec.Image, error)
+}
+
 type ManifestDeleter interface {
 	DeleteManifest(context.Context, string, digest.Digest) error
 }
@@ -65,6 +93,20 @@ type IndexDeleter interface {
 	DeleteIndex(context.Context, string, bool) error
 }
 
+type ReferrerSaver interface {
+	// SaveReferrer stores the artifact, e.g. an SBOM or a signature, whose
+	// content is read from the provided io.Reader and attaches it to the
+	// subject via an OCI 1.1 artifact manifest.
+	SaveReferrer(context.Context, ocispec.Descriptor, ocispec.Descriptor, io.Reader) error
+}
+
+type ReferrerLister interface {
+	// ListReferrers returns the descriptors of the artifact manifests which
+	// refer to the subject with the provided digest.  If the artifact type is
+	// not empty, only referrers of this artifact type are returned.
+	ListReferrers(context.Context, digest.Digest, string) ([]ocispec.Descriptor, error)
+}
+
 type ImageUnpacker interface {
 	UnpackImage(context.Context, string, digest.Digest, string) (*ocispec.Image, error)
 }
@@ -73,12 +115,18 @@ type Handler interface {
 	DigestResolver
 	DigestPuller
 	DescriptorSaver
+	DescriptorStreamer
+	DescriptorFetcher
 	DescriptorPusher
 	ManifestLister
+	ManifestRefLister
 	ManifestResolver
+	ImageResolver
 	ManifestDeleter
 	IndexResolver
 	IndexLister
 	IndexDeleter
+	ReferrerSaver
+	ReferrerLister
 	ImageUnpacker
 }
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ManifestRef describes a manifest which is stored by a handler alongside the
+// reference it is tagged with.
+type ManifestRef struct {
+	// Reference is the canonical name of the index which references the
+	// manifest, e.g. `unikraft.org/nginx:latest`.  It is empty for manifests
+	// which are not referenced by any index.
+	Reference string
+
+	// Digest of the manifest.
+	Digest digest.Digest
+
+	// Platform the manifest targets, if known.
+	Platform *ocispec.Platform
+
+	// Size is the total size in bytes of the manifest's config and layers.
+	Size int64
+}
+
+// listManifestRefs enumerates all image manifests known to the handler.
+// Manifests referenced by an index are returned once per reference, whilst
+// manifests which are not referenced by any index are returned without a
+// reference.  Artifacts which refer to another manifest, e.g. signatures, as
+// well as entries of other media types, e.g. nested indexes, are omitted.  The
+// result is sorted by reference and then by digest.
+func listManifestRefs(ctx context.Context, handle interface {
+	IndexLister
+	ManifestLister
+},
+) ([]ManifestRef, error) {
+	manifests, err := handle.ListManifests(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list manifests: %w", err)
+	}
+
+	indexes, err := handle.ListIndexes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list indexes: %w", err)
+	}
+
+	var refs []ManifestRef
+	tagged := map[string]bool{}
+
+	for fullref, index := range indexes {
+		for _, desc := range index.Manifests {
+			if desc.MediaType != ocispec.MediaTypeImageManifest {
+				continue
+			}
+
+			ref := ManifestRef{
+				Reference: fullref,
+				Digest:    desc.Digest,
+				Platform:  desc.Platform,
+			}
+
+			if manifest, ok := manifests[desc.Digest.String()]; ok {
+				if !isImageManifest(manifest) {
+					continue
+				}
+
+				ref.Size = manifestSize(manifest)
+			}
+
+			tagged[desc.Digest.String()] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	for dgst, manifest := range manifests {
+		if tagged[dgst] || !isImageManifest(manifest) {
+			continue
+		}
+
+		refs = append(refs, ManifestRef{
+			Digest: digest.Digest(dgst),
+			Size:   manifestSize(manifest),
+		})
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Reference != refs[j].Reference {
+			return refs[i].Reference < refs[j].Reference
+		}
+		return refs[i].Digest < refs[j].Digest
+	})
+
+	return refs, nil
+}
+
+// manifestSize returns the total size of the manifest's config and layers.
+func manifestSize(manifest *ocispec.Manifest) int64 {
+	size := manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		size += layer.Size
+	}
+
+	return size
+}
+
+// isImageManifest returns whether the manifest describes an image, as opposed
+// to an artifact which refers to another manifest.  The media type is optional
+// in a manifest and is therefore only checked if it is set.
+func isImageManifest(manifest *ocispec.Manifest) bool {
+	if manifest.MediaType != "" && manifest.MediaType != ocispec.MediaTypeImageManifest {
+		return false
+	}
+
+	return manifest.Subject == nil
+}
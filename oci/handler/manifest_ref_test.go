@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"kraftkit.sh/oci/handler"
+)
+
+// testListManifestRefsSkipsArtifacts checks that only image manifests are
+// listed, omitting signatures attached as referrers and nested indexes.
+func testListManifestRefsSkipsArtifacts(ctx context.Context, t *testing.T, handle handler.Handler) {
+	t.Helper()
+
+	const ref = "unikraft.org/test:latest"
+
+	save := func(mediaType string, data []byte) ocispec.Descriptor {
+		desc := ocispec.Descriptor{
+			MediaType: mediaType,
+			Digest:    digest.FromBytes(data),
+			Size:      int64(len(data)),
+		}
+
+		if err := handle.SaveDescriptor(ctx, "", desc, bytes.NewReader(data), nil); err != nil {
+			t.Fatalf("could not save blob: %v", err)
+		}
+
+		return desc
+	}
+
+	config := save(ocispec.MediaTypeImageConfig, []byte(`{"architecture":"x86_64","os":"qemu","config":{}}`))
+	layer := save(ocispec.MediaTypeImageLayer, []byte("layer"))
+
+	raw, err := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{layer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := save(ocispec.MediaTypeImageManifest, raw)
+	manifest.Platform = &ocispec.Platform{OS: "qemu", Architecture: "x86_64"}
+
+	payload := []byte(manifest.Digest.String())
+	signature := ocispec.Descriptor{
+		MediaType:    "application/vnd.kraftkit.signature.payload.v1",
+		ArtifactType: "application/vnd.kraftkit.signature.v1",
+		Digest:       digest.FromBytes(payload),
+		Size:         int64(len(payload)),
+	}
+
+	if err := handle.SaveReferrer(ctx, manifest, signature, bytes.NewReader(payload)); err != nil {
+		t.Fatal("SaveReferrer:", err)
+	}
+
+	referrers, err := handle.ListReferrers(ctx, manifest.Digest, signature.ArtifactType)
+	if err != nil {
+		t.Fatal("ListReferrers:", err)
+	} else if len(referrers) != 1 {
+		t.Fatalf("expected 1 referrer, got %d", len(referrers))
+	}
+
+	nested := save(ocispec.MediaTypeImageIndex, []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`))
+
+	// Tag an index which lists the image manifest alongside its signature and a
+	// nested index.
+	rawIndex, err := json.Marshal(ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{manifest, referrers[0], nested},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Digest:    digest.FromBytes(rawIndex),
+		Size:      int64(len(rawIndex)),
+	}
+
+	if err := handle.SaveDescriptor(ctx, ref, index, bytes.NewReader(rawIndex), nil); err != nil {
+		t.Fatal("SaveDescriptor:", err)
+	}
+
+	refs, err := handle.ListManifestRefs(ctx)
+	if err != nil {
+		t.Fatal("ListManifestRefs:", err)
+	}
+
+	if len(refs) != 1 {
+		t.Fatalf("expected only the image manifest to be listed, got %+v", refs)
+	}
+
+	if refs[0].Reference != ref || refs[0].Digest != manifest.Digest {
+		t.Errorf("expected '%s@%s', got '%s@%s'", ref, manifest.Digest, refs[0].Reference, refs[0].Digest)
+	}
+
+	if expected := config.Size + layer.Size; refs[0].Size != expected {
+		t.Errorf("expected size %d, got %d", expected, refs[0].Size)
+	}
+}
+
+func TestDirectoryHandlerListManifestRefsSkipsArtifacts(t *testing.T) {
+	handle, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testListManifestRefsSkipsArtifacts(context.Background(), t, handle)
+}
+
+func TestContainerdHandlerListManifestRefsSkipsArtifacts(t *testing.T) {
+	addr := os.Getenv("KRAFTKIT_CONTAINERD_ADDR")
+	if addr == "" {
+		t.Skip("KRAFTKIT_CONTAINERD_ADDR is not set")
+	}
+
+	ctx, handle, err := handler.NewContainerdHandler(context.Background(), addr, "kraftkit-test-"+digest.FromString(t.Name()).Encoded()[:8], nil)
+	if err != nil {
+		t.Skipf("could not connect to containerd: %v", err)
+	}
+
+	testListManifestRefsSkipsArtifacts(ctx, t, handle)
+}
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package handler
+
+import "github.com/opencontainers/go-digest"
+
+// PushDescriptorOptions contains the list of options which can be set whilst
+// pushing a descriptor.
+type PushDescriptorOptions struct {
+	mountFrom       string
+	noChunkedUpload bool
+}
+
+// PushDescriptorOption is an option function which is used to modify
+// PushDescriptorOptions.
+type PushDescriptorOption func(*PushDescriptorOptions)
+
+// NewPushDescriptorOptions creates PushDescriptorOptions.
+func NewPushDescriptorOptions(opts ...PushDescriptorOption) *PushDescriptorOptions {
+	options := &PushDescriptorOptions{}
+
+	for _, o := range opts {
+		o(options)
+	}
+
+	return options
+}
+
+// MountFrom returns the repository hinted as the source for cross-repository
+// blob mounting.
+func (opts *PushDescriptorOptions) MountFrom() string {
+	return opts.mountFrom
+}
+
+// ChunkedUpload returns whether blobs are uploaded via the chunked blob upload
+// protocol, which allows interrupted uploads to be resumed.
+func (opts *PushDescriptorOptions) ChunkedUpload() bool {
+	return !opts.noChunkedUpload
+}
+
+// WithMountFrom hints a repository in the same registry from which blobs that
+// are missing from the target repository can be mounted instead of being
+// re-uploaded.
+func WithMountFrom(repo string) PushDescriptorOption {
+	return func(opts *PushDescriptorOptions) {
+		opts.mountFrom = repo
+	}
+}
+
+// WithChunkedUpload sets whether blobs are uploaded via the chunked blob upload
+// protocol, which is the default for handlers which support it.  It can be
+// disabled for registries which do not support chunked uploads.
+func WithChunkedUpload(enabled bool) PushDescriptorOption {
+	return func(opts *PushDescriptorOptions) {
+		opts.noChunkedUpload = !enabled
+	}
+}
+
+// PruneOptions contains the list of options which can be set whilst pruning
+// unreferenced blobs.
+type PruneOptions struct {
+	dryRun      bool
+	onCandidate func(digest.Digest, int64)
+}
+
+// PruneOption is an option function which is used to modify PruneOptions.
+type PruneOption func(*PruneOptions)
+
+// NewPruneOptions creates PruneOptions.
+func NewPruneOptions(opts ...PruneOption) *PruneOptions {
+	options := &PruneOptions{}
+
+	for _, o := range opts {
+		o(options)
+	}
+
+	return options
+}
+
+// WithPruneDryRun only reports the blobs which would be removed without
+// deleting them.
+func WithPruneDryRun(dryRun bool) PruneOption {
+	return func(opts *PruneOptions) {
+		opts.dryRun = dryRun
+	}
+}
+
+// WithPruneOnCandidate sets a callback which is invoked with the digest and
+// size of every unreferenced blob.
+func WithPruneOnCandidate(onCandidate func(digest.Digest, int64)) PruneOption {
+	return func(opts *PruneOptions) {
+		opts.onCandidate = onCandidate
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// newReferrerManifest returns the OCI 1.1 artifact manifest which attaches the
+// provided artifact to the subject, along with its descriptor as it appears in
+// the subject's list of referrers.  The artifact type of the manifest is the
+// artifact's own artifact type, or its media type if unset.
+func newReferrerManifest(subject, artifact ocispec.Descriptor) (ocispec.Descriptor, []byte, error) {
+	artifactType := artifact.ArtifactType
+	if artifactType == "" {
+		artifactType = artifact.MediaType
+	}
+
+	if artifactType == "" {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("artifact has no media type")
+	}
+
+	// The subject descriptor must not carry any data nor platform information.
+	subject = ocispec.Descriptor{
+		MediaType: subject.MediaType,
+		Digest:    subject.Digest,
+		Size:      subject.Size,
+	}
+
+	raw, err := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{
+			SchemaVersion: 2,
+		},
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: artifactType,
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers:       []ocispec.Descriptor{artifact},
+		Subject:      &subject,
+		Annotations:  artifact.Annotations,
+	})
+	if err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("could not marshal referrer manifest: %w", err)
+	}
+
+	return ocispec.Descriptor{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: artifactType,
+		Digest:       digest.FromBytes(raw),
+		Size:         int64(len(raw)),
+		Annotations:  artifact.Annotations,
+	}, raw, nil
+}
+
+// referrersTag returns the name of the fallback tag under which the referrers
+// of the subject are recorded, as per the OCI distribution specification's
+// referrers tag schema, i.e. `<alg>-<ref>`.
+func referrersTag(subject digest.Digest) string {
+	return fmt.Sprintf("%s-%s", subject.Algorithm().String(), subject.Encoded())
+}
+
+// filterReferrers returns the referrers which are of the provided artifact
+// type, or all referrers if the artifact type is empty.
+func filterReferrers(referrers []ocispec.Descriptor, artifactType string) []ocispec.Descriptor {
+	if artifactType == "" {
+		return referrers
+	}
+
+	var filtered []ocispec.Descriptor
+	for _, referrer := range referrers {
+		if referrer.ArtifactType == artifactType {
+			filtered = append(filtered, referrer)
+		}
+	}
+
+	return filtered
+}
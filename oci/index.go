@@ -193,7 +193,7 @@ func (index *Index) Save(ctx context.Context, fullref string, onProgress func(fl
 	for i, manifest := range index.manifests {
 		desc := manifest.desc
 		if !manifest.saved {
-			desc, err = manifest.Save(ctx, ref.Name(), nil)
+			desc, err = manifest.Save(ctx, ref.Name(), onProgress)
 			if err != nil {
 				return ocispec.Descriptor{}, fmt.Errorf("could not save manifest: %w", err)
 			}
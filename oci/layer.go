@@ -5,9 +5,14 @@
 package oci
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"context"
+	"fmt"
+	"io"
 	"os"
 
+	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"kraftkit.sh/archive"
 )
@@ -16,6 +21,20 @@ type Layer struct {
 	dst  string
 	tmp  string
 	blob *Blob
+
+	// src is the original file the layer was created from, if any.
+	src string
+
+	// stripDebug indicates that the debug sections of src are removed before
+	// it is placed in the layer.
+	stripDebug bool
+
+	// diffID is the digest of the uncompressed content of the layer.  It is
+	// only set when it differs from the digest of the blob.
+	diffID digest.Digest
+
+	// digester computes the DiffID of a compressed, streamed layer.
+	digester digest.Digester
 }
 
 // NewLayerFromFile creates a new layer from a given blob
@@ -24,7 +43,23 @@ func NewLayerFromFile(ctx context.Context, mediaType, src, dst string, opts ...L
 		mediaType = ocispec.MediaTypeImageLayer
 	}
 
-	layer := Layer{dst: dst}
+	layer := Layer{
+		dst: dst,
+		src: src,
+		blob: &Blob{
+			desc: ocispec.Descriptor{
+				MediaType: mediaType,
+			},
+		},
+	}
+
+	// Options are applied before the content of the layer is staged since some
+	// of them, e.g. WithStripDebug, alter the content itself.
+	for _, opt := range opts {
+		if err := opt(&layer); err != nil {
+			return nil, err
+		}
+	}
 
 	removeAfterSave := false
 
@@ -33,6 +68,19 @@ func NewLayerFromFile(ctx context.Context, mediaType, src, dst string, opts ...L
 		MediaTypeImageKernelGzip,
 		MediaTypeImageKernel:
 
+		if layer.stripDebug {
+			stripped, err := stripDebugFile(src)
+			if err != nil {
+				return nil, err
+			}
+
+			if stripped != src {
+				defer os.Remove(stripped)
+			}
+
+			src = stripped
+		}
+
 		tmp, err := os.CreateTemp("", "kraftkit-ociblob*")
 		if err != nil {
 			return nil, err
@@ -67,8 +115,79 @@ func NewLayerFromFile(ctx context.Context, mediaType, src, dst string, opts ...L
 		return nil, err
 	}
 
+	blob.desc.Annotations = layer.blob.desc.Annotations
 	layer.blob = blob
 
+	return &layer, nil
+}
+
+// NewLayerFromReader creates a new layer whose content of the provided size is
+// read from r and placed at dst.  Unlike NewLayerFromFile, the content is not
+// staged to a temporary file but is instead streamed directly to the handler
+// when the manifest is saved, at which point its digest is computed.  As a
+// result, the reader is consumed only once and the layer must not be shared
+// between manifests.
+func NewLayerFromReader(ctx context.Context, mediaType string, r io.Reader, size int64, dst string, opts ...LayerOption) (*Layer, error) {
+	if mediaType == "" {
+		mediaType = ocispec.MediaTypeImageLayer
+	}
+
+	if r == nil {
+		return nil, fmt.Errorf("cannot create layer without reader")
+	} else if size < 0 {
+		return nil, fmt.Errorf("cannot create layer with negative size")
+	}
+
+	layer := Layer{
+		dst: dst,
+		blob: &Blob{
+			desc: ocispec.Descriptor{
+				MediaType: mediaType,
+			},
+		},
+	}
+
+	switch mediaType {
+	case ocispec.MediaTypeImageLayer,
+		MediaTypeImageKernelGzip,
+		MediaTypeImageKernel:
+
+		layer.blob.stream = func(ctx context.Context) io.ReadCloser {
+			pr, pw := io.Pipe()
+
+			go func() {
+				var w io.Writer = pw
+				var gzw *gzip.Writer
+
+				if mediaType == MediaTypeImageKernelGzip {
+					gzw = gzip.NewWriter(pw)
+					w = gzw
+				}
+
+				tw := tar.NewWriter(w)
+
+				err := archive.TarReaderWriter(ctx, r, size, dst, tw,
+					archive.WithStripTimes(true),
+				)
+				if err == nil {
+					err = tw.Close()
+				}
+				if err == nil && gzw != nil {
+					err = gzw.Close()
+				}
+
+				pw.CloseWithError(err)
+			}()
+
+			return pr
+		}
+
+	default:
+		layer.blob.stream = func(context.Context) io.ReadCloser {
+			return io.NopCloser(io.LimitReader(r, size))
+		}
+	}
+
 	for _, opt := range opts {
 		if err := opt(&layer); err != nil {
 			return nil, err
@@ -77,3 +196,103 @@ func NewLayerFromFile(ctx context.Context, mediaType, src, dst string, opts ...L
 
 	return &layer, nil
 }
+
+// DiffID returns the digest of the uncompressed content of the layer.
+func (layer *Layer) DiffID() digest.Digest {
+	if layer.diffID != "" {
+		return layer.diffID
+	}
+
+	return layer.blob.desc.Digest
+}
+
+// compress gzips the content of the layer if it is an uncompressed tarball.
+// The descriptor of the layer carries the compressed digest, whereas the
+// digest of the uncompressed content is retained as its DiffID.
+func (layer *Layer) compress(ctx context.Context) error {
+	if layer.blob == nil || layer.blob.desc.MediaType != ocispec.MediaTypeImageLayer {
+		return nil
+	}
+
+	// Streamed layers are compressed on the fly and their DiffID is computed
+	// whilst streaming.
+	if layer.blob.stream != nil {
+		stream := layer.blob.stream
+		layer.blob.desc.MediaType = ocispec.MediaTypeImageLayerGzip
+		layer.blob.stream = func(ctx context.Context) io.ReadCloser {
+			// The DiffID is computed anew each time the content is streamed.
+			digester := digest.Canonical.Digester()
+			layer.digester = digester
+
+			rc := stream(ctx)
+			pr, pw := io.Pipe()
+
+			go func() {
+				gzw := gzip.NewWriter(pw)
+				_, err := io.Copy(gzw, io.TeeReader(rc, digester.Hash()))
+				if err == nil {
+					err = gzw.Close()
+				}
+
+				rc.Close()
+				pw.CloseWithError(err)
+			}()
+
+			return pr
+		}
+
+		return nil
+	}
+
+	src, err := os.Open(layer.blob.tmp)
+	if err != nil {
+		return fmt.Errorf("could not open layer: %w", err)
+	}
+
+	defer src.Close()
+
+	tmp, err := os.CreateTemp("", "kraftkit-ociblob*")
+	if err != nil {
+		return err
+	}
+
+	gzw := gzip.NewWriter(tmp)
+	if _, err := io.Copy(gzw, src); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not compress layer: %w", err)
+	}
+
+	if err := gzw.Close(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not compress layer: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	blob, err := NewBlobFromFile(ctx, ocispec.MediaTypeImageLayerGzip, tmp.Name(),
+		WithBlobRemoveAfterSave(true),
+	)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	blob.desc.Annotations = layer.blob.desc.Annotations
+	blob.desc.Platform = layer.blob.desc.Platform
+
+	// The uncompressed tarball is no longer necessary if it was staged.
+	if layer.blob.removeAfterSave {
+		os.Remove(layer.blob.tmp)
+	}
+
+	layer.diffID = layer.blob.desc.Digest
+	layer.blob = blob
+	layer.tmp = tmp.Name()
+
+	return nil
+}
@@ -4,7 +4,11 @@
 // You may not use this file except in compliance with the License.
 package oci
 
-import "fmt"
+import (
+	"fmt"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
 
 type LayerOption func(*Layer) error
 
@@ -24,3 +28,27 @@ func WithLayerAnnotation(key, val string) LayerOption {
 		return nil
 	}
 }
+
+// WithStripDebug removes the debug sections and symbol tables from the ELF
+// binary which the layer is created from, e.g. a kernel, before it is placed
+// in the layer, which significantly reduces its size.  The stripped content is reproducible such that the digest
+// of the layer is stable.  Binaries which are not ELF are left untouched.
+func WithStripDebug() LayerOption {
+	return func(layer *Layer) error {
+		if layer.src == "" || layer.blob == nil {
+			return fmt.Errorf("cannot strip debug information of a layer which is not created from a file")
+		}
+
+		switch layer.blob.desc.MediaType {
+		case ocispec.MediaTypeImageLayer,
+			MediaTypeImageKernelGzip,
+			MediaTypeImageKernel:
+		default:
+			return fmt.Errorf("cannot strip debug information of a layer of type %s", layer.blob.desc.MediaType)
+		}
+
+		layer.stripDebug = true
+
+		return nil
+	}
+}
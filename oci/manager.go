@@ -13,6 +13,7 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gobwas/glob"
 	"github.com/google/go-containerregistry/pkg/authn"
@@ -21,6 +22,7 @@ import (
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 
 	"kraftkit.sh/config"
@@ -39,16 +41,27 @@ import (
 )
 
 type ociManager struct {
-	registries []string
-	auths      map[string]config.AuthConfig
-	handle     func(ctx context.Context) (context.Context, handler.Handler, error)
+	registries       []string
+	auths            map[string]config.AuthConfig
+	handle           func(ctx context.Context) (context.Context, handler.Handler, error)
+	defaultRegistry  string
+	defaultNamespace string
+	defaultTag       string
+	namespace        string
+	pingTimeout      time.Duration
+	noChunkedUpload  bool
 }
 
 const OCIFormat pack.PackageFormat = "oci"
 
 // NewOCIManager instantiates a new package manager based on OCI archives.
 func NewOCIManager(ctx context.Context, opts ...any) (packmanager.PackageManager, error) {
-	manager := ociManager{}
+	manager := ociManager{
+		defaultRegistry:  DefaultRegistry,
+		defaultNamespace: DefaultNamespace,
+		defaultTag:       DefaultTag,
+		pingTimeout:      DefaultRegistryPingTimeout,
+	}
 
 	for _, mopt := range opts {
 		opt, ok := mopt.(OCIManagerOption)
@@ -157,7 +170,7 @@ func (manager *ociManager) update(ctx context.Context, auths map[string]config.A
 
 				ref, err := name.ParseReference(fullref,
 					name.WithDefaultRegistry(domain),
-					name.WithDefaultTag(DefaultTag),
+					name.WithDefaultTag(manager.defaultTag),
 				)
 				if err != nil {
 					log.G(ctx).
@@ -188,7 +201,7 @@ func (manager *ociManager) update(ctx context.Context, auths map[string]config.A
 					return
 				}
 
-				v1ManifestPackages := processV1IndexManifests(ctx,
+				v1ManifestPackages := manager.processV1IndexManifests(ctx,
 					handle,
 					fullref,
 					query,
@@ -227,11 +240,13 @@ func (manager *ociManager) Pack(ctx context.Context, entity component.Component,
 		return nil, fmt.Errorf("entity is not Unikraft target")
 	}
 
-	pkg, err := NewPackageFromTarget(ctx, targ, opts...)
+	pkg, err := newPackageFromTarget(ctx, targ, manager.defaultRegistry, manager.defaultTag, opts...)
 	if err != nil {
 		return nil, err
 	}
 
+	pkg.noChunkedUpload = manager.noChunkedUpload
+
 	return []pack.Package{pkg}, nil
 }
 
@@ -245,7 +260,7 @@ func (manager *ociManager) Unpack(ctx context.Context, entity pack.Package, opts
 // Manifest from an Index.  Based on the provided criterium from the query,
 // identify the Descriptor that is compatible and instantiate a pack.Package
 // structure from it.
-func processV1IndexManifests(ctx context.Context, handle handler.Handler, fullref string, query *packmanager.Query, manifests []ocispec.Descriptor) map[string]pack.Package {
+func (manager *ociManager) processV1IndexManifests(ctx context.Context, handle handler.Handler, fullref string, query *packmanager.Query, manifests []ocispec.Descriptor) map[string]pack.Package {
 	packs := make(map[string]pack.Package)
 	var wg sync.WaitGroup
 	wg.Add(len(manifests))
@@ -311,6 +326,20 @@ func processV1IndexManifests(ctx context.Context, handle handler.Handler, fullre
 				}
 			}
 
+			// The descriptor of each manifest in the index carries the manifest's
+			// annotations, such that it is not necessary to instantiate the package
+			// to match them.
+			if query != nil && len(query.Annotations()) > 0 {
+				if key, ok := matchAnnotationSelector(descriptor.Annotations, query.Annotations()); !ok {
+					log.G(ctx).
+						WithField("ref", fullref).
+						WithField("digest", descriptor.Digest.String()).
+						WithField("annotation", key).
+						Trace("skipping manifest: annotation does not match query")
+					return
+				}
+			}
+
 			var auths map[string]config.AuthConfig
 			if query != nil {
 				auths = query.Auths()
@@ -324,11 +353,13 @@ func processV1IndexManifests(ctx context.Context, handle handler.Handler, fullre
 			// If we have made it this far, the query has been successfully
 			// satisfied by this particular manifest and we can generate a package
 			// from it.
-			pack, err := NewPackageFromOCIManifestDigest(ctx,
+			pack, err := newPackageFromOCIManifestDigest(ctx,
 				handle,
 				fullref,
 				auths,
 				descriptor.Digest,
+				manager.defaultRegistry,
+				manager.defaultTag,
 			)
 			if err != nil {
 				log.G(ctx).
@@ -338,6 +369,8 @@ func processV1IndexManifests(ctx context.Context, handle handler.Handler, fullre
 				return
 			}
 
+			pack.noChunkedUpload = manager.noChunkedUpload
+
 			checksum, err := ociutils.PlatformChecksum(pack.String(), descriptor.Platform)
 			if err != nil {
 				log.G(ctx).
@@ -379,7 +412,7 @@ func (manager *ociManager) Catalog(ctx context.Context, qopts ...packmanager.Que
 			return nil, fmt.Errorf("query name is not globable: %w", err)
 		}
 	} else if !strings.ContainsRune(qname, ':') && len(query.Version()) > 0 {
-		qname = fmt.Sprintf("%s:%s", qname, query.Version())
+		qname = joinRef(qname, query.Version())
 	}
 
 	qversion := query.Version()
@@ -387,25 +420,32 @@ func (manager *ociManager) Catalog(ctx context.Context, qopts ...packmanager.Que
 	// format.
 	ref, refErr := name.ParseReference(qname,
 		name.WithDefaultRegistry(""),
-		name.WithDefaultTag(DefaultTag),
+		name.WithDefaultTag(manager.defaultTag),
 	)
 	if refErr == nil {
 		qname = ref.Context().Name()
-		if ref.Identifier() != "latest" && qversion != "" && ref.Identifier() != qversion {
+		if ref.Identifier() != manager.defaultTag && qversion != "" && ref.Identifier() != qversion {
 			return nil, fmt.Errorf("cannot determine which version as name contains version and version query paremeter set")
 		} else if qversion == "" {
 			qversion = ref.Identifier()
 		}
 	}
 
+	// When the version is a digest, indexes are matched by their repository and
+	// the resulting packages are then filtered by their digest.
+	qdigest := ""
+	if _, err := digest.Parse(qversion); err == nil {
+		qdigest = qversion
+	}
+
 	unsetRegistry := false
 
 	// No default registry found, re-parse with
 	if ref != nil && ref.Context().RegistryStr() == "" {
 		unsetRegistry = true
-		ref, refErr = name.ParseReference(fmt.Sprintf("%s:%s", qname, qversion),
-			name.WithDefaultRegistry(DefaultRegistry),
-			name.WithDefaultTag(DefaultTag),
+		ref, refErr = name.ParseReference(joinRef(qname, qversion),
+			name.WithDefaultRegistry(manager.defaultRegistry),
+			name.WithDefaultTag(manager.defaultTag),
 		)
 	}
 
@@ -477,7 +517,7 @@ func (manager *ociManager) Catalog(ctx context.Context, qopts ...packmanager.Que
 			goto resolveLocalIndex
 		}
 
-		for checksum, pack := range processV1IndexManifests(ctx,
+		for checksum, pack := range manager.processV1IndexManifests(ctx,
 			handle,
 			ref.String(),
 			query,
@@ -510,6 +550,13 @@ func (manager *ociManager) Catalog(ctx context.Context, qopts ...packmanager.Que
 				}
 
 				fullref := fmt.Sprintf("%s:%s", ref.Context().RepositoryStr(), ref.Identifier())
+				if qdigest != "" {
+					if !packageHasDigest(pack, qdigest) {
+						continue
+					}
+
+					fullref = joinRef(ref.Context().RepositoryStr(), qdigest)
+				}
 
 				// If the query did specify a registry include this in check otherwise
 				// search for indexes without this as prefix.
@@ -525,9 +572,9 @@ func (manager *ociManager) Catalog(ctx context.Context, qopts ...packmanager.Que
 					continue
 				} else if qglob == nil {
 					if len(qversion) > 0 && len(qname) > 0 {
-						if fullref != fmt.Sprintf("%s:%s", qname, qversion) {
+						if fullref != joinRef(qname, qversion) {
 							log.G(ctx).
-								WithField("want", fmt.Sprintf("%s:%s", qname, qversion)).
+								WithField("want", joinRef(qname, qversion)).
 								WithField("got", fullref).
 								Trace("skipping manifest: name does not match")
 							continue
@@ -550,7 +597,7 @@ resolveLocalIndex:
 	// If the query is local and the reference is a fully qualified OCI reference,
 	// attempt to resolve the exact index and generate packages from it.
 	if query.Local() && len(qversion) > 0 && len(qname) > 0 {
-		oref := fmt.Sprintf("%s:%s", qname, qversion)
+		oref := joinRef(qname, qversion)
 		index, err := handle.ResolveIndex(ctx, oref)
 		if err != nil {
 			log.G(ctx).
@@ -559,7 +606,7 @@ resolveLocalIndex:
 			goto searchLocalIndexes
 		}
 
-		for checksum, pack := range processV1IndexManifests(ctx,
+		for checksum, pack := range manager.processV1IndexManifests(ctx,
 			handle,
 			oref,
 			query,
@@ -587,7 +634,7 @@ searchLocalIndexes:
 		for oref, index := range indexes {
 			ref, err := name.ParseReference(oref,
 				name.WithDefaultRegistry(""),
-				name.WithDefaultTag(DefaultTag),
+				name.WithDefaultTag(manager.defaultTag),
 			)
 			if err != nil {
 				log.G(ctx).
@@ -598,6 +645,9 @@ searchLocalIndexes:
 			}
 
 			fullref := fmt.Sprintf("%s:%s", ref.Context().RepositoryStr(), ref.Identifier())
+			if qdigest != "" {
+				fullref = joinRef(ref.Context().RepositoryStr(), qdigest)
+			}
 
 			// If the query did specify a registry include this in check otherwise
 			// search for indexes without this as prefix.
@@ -622,9 +672,9 @@ searchLocalIndexes:
 				continue
 			} else if qglob == nil {
 				if len(qversion) > 0 && len(qname) > 0 {
-					if fullref != fmt.Sprintf("%s:%s", qname, qversion) {
+					if fullref != joinRef(qname, qversion) {
 						log.G(ctx).
-							WithField("want", fmt.Sprintf("%s:%s", qname, qversion)).
+							WithField("want", joinRef(qname, qversion)).
 							WithField("got", fullref).
 							Trace("skipping index: name does not match")
 						total += len(index.Manifests)
@@ -640,12 +690,16 @@ searchLocalIndexes:
 				}
 			}
 
-			for checksum, pack := range processV1IndexManifests(ctx,
+			for checksum, pack := range manager.processV1IndexManifests(ctx,
 				handle,
 				oref,
 				query,
 				index.Manifests,
 			) {
+				if qdigest != "" && !packageHasDigest(pack, qdigest) {
+					continue
+				}
+
 				packs[checksum] = pack
 				total++
 			}
@@ -676,6 +730,35 @@ returnPacks:
 	return ret, nil
 }
 
+// joinRef joins the name and version of a reference, using the digest
+// separator if the version is a digest.
+func joinRef(name, version string) string {
+	if _, err := digest.Parse(version); err == nil {
+		return fmt.Sprintf("%s@%s", name, version)
+	}
+
+	return fmt.Sprintf("%s:%s", name, version)
+}
+
+// packageHasDigest checks whether either the index or the manifest of the
+// provided package matches the digest.
+func packageHasDigest(p pack.Package, dgst string) bool {
+	ocipack, ok := p.(*ociPackage)
+	if !ok {
+		return false
+	}
+
+	if ocipack.index != nil && ocipack.index.desc != nil && ocipack.index.desc.Digest.String() == dgst {
+		return true
+	}
+
+	if ocipack.manifest != nil && ocipack.manifest.desc != nil && ocipack.manifest.desc.Digest.String() == dgst {
+		return true
+	}
+
+	return false
+}
+
 // SetSources implements packmanager.PackageManager
 func (manager *ociManager) SetSources(_ context.Context, sources ...string) error {
 	manager.registries = sources
@@ -754,7 +837,7 @@ func (manager *ociManager) IsCompatible(ctx context.Context, source string, qopt
 		for _, registry := range manager.registries {
 			ref, err := name.ParseReference(source,
 				name.WithDefaultRegistry(registry),
-				name.WithDefaultTag(DefaultTag),
+				name.WithDefaultTag(manager.defaultTag),
 			)
 			if err != nil {
 				continue
@@ -793,8 +876,8 @@ func (manager *ociManager) IsCompatible(ctx context.Context, source string, qopt
 			Tracef("checking if source is remote image")
 
 		ref, err := name.ParseReference(source,
-			name.WithDefaultRegistry(DefaultRegistry),
-			name.WithDefaultTag(DefaultTag),
+			name.WithDefaultRegistry(manager.defaultRegistry),
+			name.WithDefaultTag(manager.defaultTag),
 		)
 		if err != nil {
 			return false
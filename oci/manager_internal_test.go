@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package oci
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/containerd/containerd/content"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/oci/handler"
+	"kraftkit.sh/packmanager"
+)
+
+// countingHandler counts the lookups of digests which are performed when a
+// package is instantiated.
+type countingHandler struct {
+	handler.Handler
+
+	lookups atomic.Int32
+}
+
+func (handle *countingHandler) DigestInfo(ctx context.Context, dgst digest.Digest) (*content.Info, error) {
+	handle.lookups.Add(1)
+	return handle.Handler.DigestInfo(ctx, dgst)
+}
+
+func TestProcessV1IndexManifestsAnnotationSelector(t *testing.T) {
+	const ref = "unikraft.org/test:latest"
+	const flavor = "org.example.flavor"
+
+	tests := []struct {
+		name     string
+		selector map[string]string
+		expect   int
+	}{
+		{
+			name:     "matching value",
+			selector: map[string]string{flavor: "debug"},
+			expect:   1,
+		},
+		{
+			name:     "present key",
+			selector: map[string]string{flavor: ""},
+			expect:   1,
+		},
+		{
+			name:     "different value",
+			selector: map[string]string{flavor: "release"},
+		},
+		{
+			name:     "missing key",
+			selector: map[string]string{"org.example.missing": ""},
+		},
+	}
+
+	cfg, err := config.NewDefaultKraftKitConfig()
+	if err != nil {
+		t.Fatal("NewDefaultKraftKitConfig:", err)
+	}
+
+	cfgm, err := config.NewConfigManager(cfg)
+	if err != nil {
+		t.Fatal("NewConfigManager:", err)
+	}
+
+	ctx := config.WithConfigManager(context.Background(), cfgm)
+
+	directory, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("NewDirectoryHandler:", err)
+	}
+
+	manifest, err := NewManifest(ctx, directory)
+	if err != nil {
+		t.Fatal("NewManifest:", err)
+	}
+
+	manifest.SetOS(ctx, "qemu")
+	manifest.SetArchitecture(ctx, "x86_64")
+	manifest.SetAnnotation(ctx, flavor, "debug")
+
+	index, err := NewIndex(ctx, directory)
+	if err != nil {
+		t.Fatal("NewIndex:", err)
+	}
+
+	if err := index.AddManifest(ctx, manifest); err != nil {
+		t.Fatal("AddManifest:", err)
+	}
+
+	if _, err := index.Save(ctx, ref, nil); err != nil {
+		t.Fatal("Save:", err)
+	}
+
+	manifests := []ocispec.Descriptor{*manifest.desc}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handle := &countingHandler{Handler: directory}
+			manager := &ociManager{
+				defaultRegistry: DefaultRegistry,
+				defaultTag:      DefaultTag,
+			}
+
+			packs := manager.processV1IndexManifests(ctx,
+				handle,
+				ref,
+				packmanager.NewQuery(packmanager.WithAnnotationSelector(tt.selector)),
+				manifests,
+			)
+
+			if len(packs) != tt.expect {
+				t.Errorf("expected %d packages, got %d", tt.expect, len(packs))
+			}
+
+			// Manifests which do not match are skipped before a package is
+			// instantiated from them.
+			if tt.expect == 0 && handle.lookups.Load() > 0 {
+				t.Errorf("expected no package to be instantiated, got %d lookups", handle.lookups.Load())
+			}
+		})
+	}
+}
@@ -10,6 +10,9 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"kraftkit.sh/config"
 	"kraftkit.sh/log"
@@ -32,7 +35,7 @@ type OCIManagerOption func(context.Context, *ociManager) error
 func WithDetectHandler() OCIManagerOption {
 	return func(ctx context.Context, manager *ociManager) error {
 		if contAddr := config.G[config.KraftKit](ctx).ContainerdAddr; len(contAddr) > 0 {
-			namespace := DefaultNamespace
+			namespace := manager.defaultNamespace
 			if n := os.Getenv("CONTAINERD_NAMESPACE"); n != "" {
 				namespace = n
 			}
@@ -43,7 +46,7 @@ func WithDetectHandler() OCIManagerOption {
 				Trace("using containerd handler")
 
 			manager.handle = func(ctx context.Context) (context.Context, handler.Handler, error) {
-				return handler.NewContainerdHandler(ctx, contAddr, namespace, manager.auths)
+				return handler.NewContainerdHandler(ctx, contAddr, manager.namespaceOr(namespace), manager.auths)
 			}
 
 			return nil
@@ -57,7 +60,7 @@ func WithDetectHandler() OCIManagerOption {
 			Trace("using directory handler")
 
 		manager.handle = func(ctx context.Context) (context.Context, handler.Handler, error) {
-			handle, err := handler.NewDirectoryHandler(ociDir, manager.auths)
+			handle, err := handler.NewDirectoryHandler(manager.namespacedPath(ociDir), manager.auths)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -77,7 +80,7 @@ func WithContainerd(ctx context.Context, addr, namespace string) OCIManagerOptio
 		if n := os.Getenv("CONTAINERD_NAMESPACE"); n != "" {
 			namespace = n
 		} else if namespace == "" {
-			namespace = DefaultNamespace
+			namespace = manager.defaultNamespace
 		}
 
 		log.G(ctx).
@@ -86,7 +89,7 @@ func WithContainerd(ctx context.Context, addr, namespace string) OCIManagerOptio
 			Trace("using containerd handler")
 
 		manager.handle = func(ctx context.Context) (context.Context, handler.Handler, error) {
-			return handler.NewContainerdHandler(ctx, addr, namespace, manager.auths)
+			return handler.NewContainerdHandler(ctx, addr, manager.namespaceOr(namespace), manager.auths)
 		}
 
 		return nil
@@ -102,7 +105,7 @@ func WithDirectory(ctx context.Context, path string) OCIManagerOption {
 			Trace("using directory handler")
 
 		manager.handle = func(ctx context.Context) (context.Context, handler.Handler, error) {
-			handle, err := handler.NewDirectoryHandler(path, manager.auths)
+			handle, err := handler.NewDirectoryHandler(manager.namespacedPath(path), manager.auths)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -114,11 +117,18 @@ func WithDirectory(ctx context.Context, path string) OCIManagerOption {
 	}
 }
 
+// DefaultRegistryPingTimeout is the duration after which a registry which
+// does not respond to a ping is skipped by WithDefaultRegistries.
+const DefaultRegistryPingTimeout = 3 * time.Second
+
 // WithDefaultRegistries sets the list of KraftKit-set registries which is
-// defined through its configuration.
+// defined through its configuration, preceded by the default registry of the
+// manager.  Only registries which respond to a ping within the ping timeout,
+// see WithRegistryPingTimeout, are included.  The registries are pinged in
+// parallel such that a single unreachable registry does not delay others.
 func WithDefaultRegistries() OCIManagerOption {
 	return func(ctx context.Context, manager *ociManager) error {
-		manager.registries = []string{DefaultRegistry}
+		var candidates []string
 
 		for _, manifest := range config.G[config.KraftKit](ctx).Unikraft.Manifests {
 			// Use internal KraftKit knowledge of the fact that the config often lists
@@ -131,12 +141,44 @@ func WithDefaultRegistries() OCIManagerOption {
 				continue
 			}
 
-			regName, err := name.NewRegistry(manifest)
-			if err != nil {
-				continue
-			}
+			candidates = append(candidates, manifest)
+		}
+
+		// Retain the order of the configured registries.
+		reachable := make([]bool, len(candidates))
+
+		var wg sync.WaitGroup
+		wg.Add(len(candidates))
+
+		for i, manifest := range candidates {
+			go func(i int, manifest string) {
+				defer wg.Done()
 
-			if _, err := transport.Ping(ctx, regName, http.DefaultTransport.(*http.Transport).Clone()); err == nil {
+				regName, err := name.NewRegistry(manifest)
+				if err != nil {
+					return
+				}
+
+				pingCtx, cancel := context.WithTimeout(ctx, manager.pingTimeout)
+				defer cancel()
+
+				if _, err := transport.Ping(pingCtx, regName, http.DefaultTransport.(*http.Transport).Clone()); err != nil {
+					log.G(ctx).
+						WithField("registry", manifest).
+						WithError(err).
+						Debug("skipping unreachable registry")
+					return
+				}
+
+				reachable[i] = true
+			}(i, manifest)
+		}
+
+		wg.Wait()
+
+		manager.registries = []string{manager.defaultRegistry}
+		for i, manifest := range candidates {
+			if reachable[i] {
 				manager.registries = append(manager.registries, manifest)
 			}
 		}
@@ -145,6 +187,20 @@ func WithDefaultRegistries() OCIManagerOption {
 	}
 }
 
+// WithRegistryPingTimeout sets the duration after which a registry which does
+// not respond to a ping is skipped by WithDefaultRegistries.  Defaults to
+// DefaultRegistryPingTimeout.  It must precede WithDefaultRegistries.
+func WithRegistryPingTimeout(timeout time.Duration) OCIManagerOption {
+	return func(ctx context.Context, manager *ociManager) error {
+		if timeout <= 0 {
+			return fmt.Errorf("registry ping timeout must be positive")
+		}
+
+		manager.pingTimeout = timeout
+		return nil
+	}
+}
+
 // fileExists returns true if the given path exists and is not a directory.
 func fileExists(path string) bool {
 	fi, err := os.Stat(path)
@@ -276,3 +332,93 @@ func WithDockerConfig(auth regtypes.AuthConfig) OCIManagerOption {
 		return nil
 	}
 }
+
+// WithDefaultRegistry sets the registry which is used for references which do
+// not specify one, e.g. `helloworld:latest`.  Defaults to DefaultRegistry.
+// It must precede WithDefaultRegistries such that it is included in the list
+// of registries.
+func WithDefaultRegistry(registry string) OCIManagerOption {
+	return func(ctx context.Context, manager *ociManager) error {
+		if registry == "" {
+			return fmt.Errorf("cannot use empty default registry")
+		}
+
+		manager.defaultRegistry = registry
+		return nil
+	}
+}
+
+// WithDefaultNamespace sets the containerd namespace which is used when none
+// is otherwise provided.  Defaults to DefaultNamespace.  It must precede the
+// option which selects the handler, e.g. WithDetectHandler or WithContainerd.
+func WithDefaultNamespace(namespace string) OCIManagerOption {
+	return func(ctx context.Context, manager *ociManager) error {
+		if namespace == "" {
+			return fmt.Errorf("cannot use empty default namespace")
+		}
+
+		manager.defaultNamespace = namespace
+		return nil
+	}
+}
+
+// WithDefaultTag sets the tag which is used for references which do not
+// specify one.  Defaults to DefaultTag.
+func WithDefaultTag(tag string) OCIManagerOption {
+	return func(ctx context.Context, manager *ociManager) error {
+		if tag == "" {
+			return fmt.Errorf("cannot use empty default tag")
+		}
+
+		manager.defaultTag = tag
+		return nil
+	}
+}
+
+// WithChunkedUpload sets whether the blobs of packages are uploaded in
+// resumable chunks when they are pushed, which is the default for handlers
+// which support it.  It can be disabled for registries which do not support
+// chunked uploads.
+func WithChunkedUpload(enabled bool) OCIManagerOption {
+	return func(ctx context.Context, manager *ociManager) error {
+		manager.noChunkedUpload = !enabled
+		return nil
+	}
+}
+
+// WithNamespace sets the namespace which is used by the selected handler,
+// regardless of the order of the options and taking precedence over the
+// namespace provided to WithContainerd, the `CONTAINERD_NAMESPACE` environment
+// variable and WithDefaultNamespace.  The containerd handler operates within
+// the namespace and the directory handler within a subdirectory of its root
+// with the name of the namespace.
+func WithNamespace(namespace string) OCIManagerOption {
+	return func(ctx context.Context, manager *ociManager) error {
+		if namespace == "" || namespace == "." || namespace == ".." || strings.ContainsAny(namespace, `/\`) {
+			return fmt.Errorf("invalid namespace: '%s'", namespace)
+		}
+
+		manager.namespace = namespace
+		return nil
+	}
+}
+
+// namespaceOr returns the namespace set via WithNamespace, if any, or otherwise
+// the provided namespace.
+func (manager *ociManager) namespaceOr(namespace string) string {
+	if manager.namespace != "" {
+		return manager.namespace
+	}
+
+	return namespace
+}
+
+// namespacedPath returns the root of the directory handler at the provided
+// path within the namespace set via WithNamespace, if any.
+func (manager *ociManager) namespacedPath(path string) string {
+	if manager.namespace != "" {
+		return filepath.Join(path, manager.namespace)
+	}
+
+	return path
+}
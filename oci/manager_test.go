@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package oci_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/oci"
+	"kraftkit.sh/oci/handler"
+	"kraftkit.sh/packmanager"
+	"kraftkit.sh/unikraft/arch"
+	"kraftkit.sh/unikraft/plat"
+	"kraftkit.sh/unikraft/target"
+)
+
+func TestManagerPackUsesDefaults(t *testing.T) {
+	cfg, err := config.NewDefaultKraftKitConfig()
+	if err != nil {
+		t.Fatal("NewDefaultKraftKitConfig:", err)
+	}
+
+	cfg.RuntimeDir = t.TempDir()
+	cfg.ContainerdAddr = ""
+
+	cfgm, err := config.NewConfigManager(cfg)
+	if err != nil {
+		t.Fatal("NewConfigManager:", err)
+	}
+
+	ctx := config.WithConfigManager(context.Background(), cfgm)
+
+	manager, err := oci.NewOCIManager(ctx,
+		oci.WithDirectory(ctx, filepath.Join(cfg.RuntimeDir, "oci")),
+		oci.WithDefaultRegistry("registry.example.com"),
+		oci.WithDefaultTag("stable"),
+	)
+	if err != nil {
+		t.Fatal("NewOCIManager:", err)
+	}
+
+	targ := target.NewTargetFromOptions(
+		target.WithName("test"),
+		target.WithArchitecture(arch.NewArchitectureFromOptions(arch.WithName("x86_64"))),
+		target.WithPlatform(plat.NewPlatformFromOptions(plat.WithName("qemu"))),
+	)
+
+	packs, err := manager.Pack(ctx, targ, packmanager.PackName("helloworld"))
+	if err != nil {
+		t.Fatal("Pack:", err)
+	}
+
+	if len(packs) != 1 {
+		t.Fatalf("expected 1 package, got %d", len(packs))
+	}
+
+	if name := packs[0].Name(); name != "registry.example.com/helloworld" {
+		t.Errorf("expected name 'registry.example.com/helloworld', got '%s'", name)
+	}
+
+	if version := packs[0].Version(); version != "stable" {
+		t.Errorf("expected version 'stable', got '%s'", version)
+	}
+
+	handle, err := handler.NewDirectoryHandler(filepath.Join(cfg.RuntimeDir, "oci"), nil)
+	if err != nil {
+		t.Fatal("NewDirectoryHandler:", err)
+	}
+
+	if _, err := handle.ResolveIndex(ctx, "registry.example.com/helloworld:stable"); err != nil {
+		t.Errorf("expected the index to be saved with the default tag: %v", err)
+	}
+
+	if _, err := handle.ResolveIndex(ctx, "unikraft.org/helloworld:latest"); err == nil {
+		t.Error("expected the index not to be saved with the global defaults")
+	}
+}
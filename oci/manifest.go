@@ -10,10 +10,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"slices"
-	"sort"
-	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -27,12 +28,18 @@ import (
 	"golang.org/x/sync/errgroup"
 	"oras.land/oras-go/v2/content"
 
+	"kraftkit.sh/initrd"
 	"kraftkit.sh/internal/version"
 	"kraftkit.sh/log"
 	"kraftkit.sh/oci/handler"
 )
 
 type Manifest struct {
+	// mu guards the mutable state of the manifest, i.e. its layers,
+	// configuration, annotations and descriptor, such that layers can be added
+	// from multiple goroutines and whilst the manifest is being saved.
+	mu sync.RWMutex
+
 	saved bool
 
 	handle handler.Handler
@@ -44,11 +51,19 @@ type Manifest struct {
 	layers      []*Layer
 	pushed      sync.Map // wraps map[digest.Digest]bool
 	annotations map[string]string
+
+	pushRetries      int
+	pushRetryBackoff time.Duration
+	signer           Signer
+	verifier         Verifier
+	created          time.Time
+	compressLayers   bool
+	defaultTag       string
 }
 
 // NewManifest instantiates a new image based in a handler and any provided
 // options.
-func NewManifest(ctx context.Context, handle handler.Handler) (*Manifest, error) {
+func NewManifest(ctx context.Context, handle handler.Handler, opts ...ManifestOption) (*Manifest, error) {
 	if handle == nil {
 		return nil, fmt.Errorf("cannot use `NewImage` without handler")
 	}
@@ -60,6 +75,15 @@ func NewManifest(ctx context.Context, handle handler.Handler) (*Manifest, error)
 		config: &ocispec.Image{
 			Config: ocispec.ImageConfig{},
 		},
+		pushRetries:      DefaultPushRetries,
+		pushRetryBackoff: DefaultPushRetryBackoff,
+		defaultTag:       DefaultTag,
+	}
+
+	for _, opt := range opts {
+		if err := opt(&manifest); err != nil {
+			return nil, err
+		}
 	}
 
 	return &manifest, nil
@@ -76,59 +100,172 @@ func NewManifestFromSpec(ctx context.Context, handle handler.Handler, spec ocisp
 
 // NewManifestFromDigest instantiates a new Manifest structure from a given
 // digest.
-func NewManifestFromDigest(ctx context.Context, handle handler.Handler, digest digest.Digest) (*Manifest, error) {
-	manifest, err := NewManifest(ctx, handle)
+func NewManifestFromDigest(ctx context.Context, handle handler.Handler, digest digest.Digest, opts ...ManifestOption) (*Manifest, error) {
+	manifest, err := NewManifest(ctx, handle, opts...)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := manifest.load(ctx, digest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// load populates the manifest from the manifest with the provided digest which
+// is present in the handler.
+func (manifest *Manifest) load(ctx context.Context, digest digest.Digest) error {
+	handle := manifest.handle
+
 	spec, err := handle.ResolveManifest(ctx, "", digest)
 	if err != nil {
-		return nil, fmt.Errorf("could not resolve manifest from digest: %w", err)
+		return fmt.Errorf("could not resolve manifest from digest: %w", classifyError(err, ErrManifestNotFound))
 	}
 
-	manifest.saved = true
-	manifest.desc = &ocispec.Descriptor{
+	// The size of the descriptor must reflect the size of the manifest's
+	// content and not the length of its digest.
+	info, err := handle.DigestInfo(ctx, digest)
+	if err != nil {
+		return fmt.Errorf("could not get manifest info from digest: %w", classifyError(err, ErrManifestNotFound))
+	} else if info == nil {
+		return fmt.Errorf("%w: %s", ErrManifestNotFound, digest.String())
+	}
+
+	manifestDesc := ocispec.Descriptor{
 		MediaType:   ocispec.MediaTypeImageManifest,
 		Digest:      digest,
-		Size:        int64(digest.Algorithm().Size()),
+		Size:        info.Size,
 		Annotations: spec.Annotations,
 		Platform:    spec.Config.Platform,
 	}
 
+	if manifest.verifier != nil {
+		if err := manifest.verify(ctx, manifestDesc); err != nil {
+			return fmt.Errorf("could not verify manifest: %w", err)
+		}
+	}
+
+	manifest.saved = true
+	manifest.desc = &manifestDesc
 	manifest.manifest = spec
 
-	if spec.Config.Platform != nil {
-		manifest.config.Architecture = spec.Config.Platform.Architecture
-		manifest.config.OS = spec.Config.Platform.OS
-		manifest.config.OSVersion = spec.Config.Platform.OSVersion
-		manifest.config.OSFeatures = spec.Config.Platform.OSFeatures
+	// Re-hydrate the complete image configuration such that subsequent saves
+	// faithfully preserve it.  If it cannot be resolved, fall back to what is
+	// known about the platform from the manifest itself.
+	if image, err := handle.ResolveImage(ctx, "", digest); err == nil {
+		manifest.config = image
+	} else {
+		log.G(ctx).
+			WithField("digest", digest.String()).
+			WithError(err).
+			Debug("could not resolve image config")
+
+		if spec.Config.Platform != nil {
+			manifest.config.Architecture = spec.Config.Platform.Architecture
+			manifest.config.OS = spec.Config.Platform.OS
+			manifest.config.OSVersion = spec.Config.Platform.OSVersion
+			manifest.config.OSFeatures = spec.Config.Platform.OSFeatures
+		}
 	}
 	manifest.annotations = spec.Annotations
+	manifest.layers = nil
 
-	for _, desc := range spec.Layers {
-		manifest.layers = append(manifest.layers, &Layer{
+	for i, desc := range spec.Layers {
+		layer := &Layer{
 			blob: &Blob{
 				desc: desc,
 			},
-		})
+		}
+
+		// Retain the DiffID of compressed layers.
+		if len(manifest.config.RootFS.DiffIDs) == len(spec.Layers) &&
+			manifest.config.RootFS.DiffIDs[i] != desc.Digest {
+			layer.diffID = manifest.config.RootFS.DiffIDs[i]
+		}
+
+		manifest.layers = append(manifest.layers, layer)
 	}
 
-	return manifest, nil
+	return nil
 }
 
-// Layers returns the layers of this OCI image.
+// Layers returns a copy of the list of layers of this OCI image.
 func (manifest *Manifest) Layers() []*Layer {
-	return manifest.layers
+	manifest.mu.RLock()
+	defer manifest.mu.RUnlock()
+
+	return slices.Clone(manifest.layers)
+}
+
+// Annotations returns a copy of the annotations of the image.
+func (manifest *Manifest) Annotations() map[string]string {
+	manifest.mu.RLock()
+	defer manifest.mu.RUnlock()
+
+	return maps.Clone(manifest.annotations)
+}
+
+// Labels returns a copy of the labels of the image.
+func (manifest *Manifest) Labels() map[string]string {
+	manifest.mu.RLock()
+	defer manifest.mu.RUnlock()
+
+	return maps.Clone(manifest.config.Config.Labels)
+}
+
+// Architecture returns the architecture of the image.
+func (manifest *Manifest) Architecture() string {
+	manifest.mu.RLock()
+	defer manifest.mu.RUnlock()
+
+	return manifest.config.Architecture
+}
+
+// OS returns the OS of the image.
+func (manifest *Manifest) OS() string {
+	manifest.mu.RLock()
+	defer manifest.mu.RUnlock()
+
+	return manifest.config.OS
+}
+
+// Cmd returns a copy of the command of the image.
+func (manifest *Manifest) Cmd() []string {
+	manifest.mu.RLock()
+	defer manifest.mu.RUnlock()
+
+	return slices.Clone(manifest.config.Config.Cmd)
+}
+
+// Env returns a copy of the environment variables of the image.
+func (manifest *Manifest) Env() []string {
+	manifest.mu.RLock()
+	defer manifest.mu.RUnlock()
+
+	return slices.Clone(manifest.config.Config.Env)
 }
 
 // AddLayer adds a layer directly to the image and returns the resulting
 // descriptor.
 func (manifest *Manifest) AddLayer(ctx context.Context, layer *Layer) (ocispec.Descriptor, error) {
+	return manifest.addLayer(ctx, layer, nil)
+}
+
+// addLayer adds the layer to the image after removing all existing layers for
+// which the optional replace function returns true.  Both happen atomically
+// such that concurrent callers cannot observe the image without either layer.
+func (manifest *Manifest) addLayer(ctx context.Context, layer *Layer, replace func(*Layer) bool) (ocispec.Descriptor, error) {
 	if layer == nil {
 		return ocispec.Descriptor{}, fmt.Errorf("cannot add empty layer")
 	}
 
+	if manifest.compressLayers {
+		if err := layer.compress(ctx); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+
 	log.G(ctx).
 		WithField("src", layer.blob.src).
 		WithField("dest", layer.dst).
@@ -136,16 +273,35 @@ func (manifest *Manifest) AddLayer(ctx context.Context, layer *Layer) (ocispec.D
 		WithField("mediaType", layer.blob.desc.MediaType).
 		Trace("layering")
 
-	manifest.pushed.Store(layer.blob.desc.Digest, false)
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
+	// The digest of streamed layers is only known once they are saved.
+	if layer.blob.stream == nil {
+		manifest.pushed.Store(layer.blob.desc.Digest, false)
+	}
+
+	layers := manifest.layers
+	if replace != nil {
+		// Build a new slice since the existing one may be shared with another
+		// manifest.
+		layers = make([]*Layer, 0, len(manifest.layers)+1)
+		for _, existing := range manifest.layers {
+			if !replace(existing) {
+				layers = append(layers, existing)
+			}
+		}
+	}
 
 	manifest.saved = false
-	manifest.layers = append(manifest.layers, layer)
+	manifest.desc = nil
+	manifest.layers = append(layers, layer)
 
 	return layer.blob.desc, nil
 }
 
 // AddBlob adds a blog to the manifest and returns the resulting descriptor.
-func (manifest *Manifest) AddBlob(ctx context.Context, blob *Blob) (ocispec.Descriptor, error) {
+func (manifest *Manifest) AddBlob(ctx context.Context, blob *Blob) (desc ocispec.Descriptor, err error) {
 	if info, err := manifest.handle.DigestInfo(ctx, blob.desc.Digest); err == nil && info != nil {
 		log.G(ctx).
 			WithField("mediaType", blob.desc.MediaType).
@@ -160,19 +316,21 @@ func (manifest *Manifest) AddBlob(ctx context.Context, blob *Blob) (ocispec.Desc
 		return ocispec.Descriptor{}, err
 	}
 
+	// The error of closing the blob is returned via the named return value as
+	// a failure to close may indicate that its content was not fully read.
 	defer func() {
-		closeErr := fp.Close()
-		if err == nil {
-			err = closeErr
+		if closeErr := fp.Close(); closeErr != nil && err == nil {
+			desc = ocispec.Descriptor{}
+			err = fmt.Errorf("could not close blob: %w", closeErr)
 		}
 	}()
 
-	if err := manifest.handle.SaveDescriptor(ctx, "", blob.desc, fp, nil); err != nil {
-		return ocispec.Descriptor{}, err
+	if err = manifest.handle.SaveDescriptor(ctx, "", blob.desc, fp, nil); err != nil {
+		return ocispec.Descriptor{}, classifyError(err, ErrNotFound)
 	}
 
 	if blob.removeAfterSave {
-		if err := os.Remove(blob.tmp); err != nil {
+		if err = os.Remove(blob.tmp); err != nil {
 			return ocispec.Descriptor{}, err
 		}
 	}
@@ -180,66 +338,402 @@ func (manifest *Manifest) AddBlob(ctx context.Context, blob *Blob) (ocispec.Desc
 	return blob.desc, nil
 }
 
+// SetKernel sets the kernel of the image, replacing any existing one.
+// Additional layer options can be provided, e.g. WithStripDebug to only ship
+// a stripped kernel whilst its debug symbols are kept via SetKernelDbg.
+func (manifest *Manifest) SetKernel(ctx context.Context, path string, opts ...LayerOption) error {
+	log.G(ctx).
+		WithField("src", path).
+		WithField("dest", WellKnownKernelPath).
+		Debug("including kernel")
+
+	layer, err := NewLayerFromFile(ctx,
+		ocispec.MediaTypeImageLayer,
+		path,
+		WellKnownKernelPath,
+		append([]LayerOption{
+			WithLayerAnnotation(AnnotationKernelPath, WellKnownKernelPath),
+		}, opts...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("could not build layer from file: %w", err)
+	}
+
+	return manifest.replaceAnnotatedLayer(ctx, AnnotationKernelPath, layer)
+}
+
+// SetKernelDbg sets the unstripped kernel of the image, which contains its
+// debug symbols, replacing any existing one.
+func (manifest *Manifest) SetKernelDbg(ctx context.Context, path string) error {
+	log.G(ctx).
+		WithField("src", path).
+		WithField("dest", WellKnownKernelDbgPath).
+		Debug("including kernel.dbg")
+
+	layer, err := NewLayerFromFile(ctx,
+		ocispec.MediaTypeImageLayer,
+		path,
+		WellKnownKernelDbgPath,
+		WithLayerAnnotation(AnnotationKernelDbgPath, WellKnownKernelDbgPath),
+	)
+	if err != nil {
+		return fmt.Errorf("could not build layer from file: %w", err)
+	}
+
+	return manifest.replaceAnnotatedLayer(ctx, AnnotationKernelDbgPath, layer)
+}
+
+// SetKernelReader sets the kernel of the image whose content of the provided
+// size is read from r, replacing any existing one.  The content is streamed
+// to the handler when the manifest is saved, see NewLayerFromReader.
+func (manifest *Manifest) SetKernelReader(ctx context.Context, r io.Reader, size int64) error {
+	log.G(ctx).
+		WithField("dest", WellKnownKernelPath).
+		Debug("including kernel")
+
+	layer, err := NewLayerFromReader(ctx,
+		ocispec.MediaTypeImageLayer,
+		r,
+		size,
+		WellKnownKernelPath,
+		WithLayerAnnotation(AnnotationKernelPath, WellKnownKernelPath),
+	)
+	if err != nil {
+		return fmt.Errorf("could not build layer from reader: %w", err)
+	}
+
+	return manifest.replaceAnnotatedLayer(ctx, AnnotationKernelPath, layer)
+}
+
+// SetInitrd sets the default initial ramdisk of the image, replacing any
+// existing default one.  Named initrds added via AddNamedInitrd are kept.
+func (manifest *Manifest) SetInitrd(ctx context.Context, path string) error {
+	log.G(ctx).
+		WithField("src", path).
+		WithField("dest", WellKnownInitrdPath).
+		Debug("including initrd")
+
+	layer, err := NewLayerFromFile(ctx,
+		ocispec.MediaTypeImageLayer,
+		path,
+		WellKnownInitrdPath,
+		WithLayerAnnotation(AnnotationKernelInitrdPath, WellKnownInitrdPath),
+	)
+	if err != nil {
+		return fmt.Errorf("could not build layer from file: %w", err)
+	}
+
+	return manifest.replaceAnnotatedLayer(ctx, AnnotationKernelInitrdPath, layer)
+}
+
+// SetInitrdReader sets the default initial ramdisk of the image whose content
+// of the provided size is read from r, replacing any existing default one.
+// This avoids staging an initrd which was built in memory to a temporary file.
+func (manifest *Manifest) SetInitrdReader(ctx context.Context, r io.Reader, size int64) error {
+	log.G(ctx).
+		WithField("dest", WellKnownInitrdPath).
+		Debug("including initrd")
+
+	layer, err := NewLayerFromReader(ctx,
+		ocispec.MediaTypeImageLayer,
+		r,
+		size,
+		WellKnownInitrdPath,
+		WithLayerAnnotation(AnnotationKernelInitrdPath, WellKnownInitrdPath),
+	)
+	if err != nil {
+		return fmt.Errorf("could not build layer from reader: %w", err)
+	}
+
+	return manifest.replaceAnnotatedLayer(ctx, AnnotationKernelInitrdPath, layer)
+}
+
+// replaceAnnotatedLayer removes all layers carrying the provided annotation
+// before adding the new layer.
+func (manifest *Manifest) replaceAnnotatedLayer(ctx context.Context, annotation string, layer *Layer) error {
+	if _, err := manifest.addLayer(ctx, layer, func(existing *Layer) bool {
+		if existing.blob == nil {
+			return false
+		}
+
+		_, ok := existing.blob.desc.Annotations[annotation]
+		return ok
+	}); err != nil {
+		return fmt.Errorf("could not add layer to manifest: %w", err)
+	}
+
+	return nil
+}
+
+// ApplyInitrd builds the provided initramfs, sets the result as the default
+// initial ramdisk of the image and carries over its runtime configuration,
+// i.e. its arguments, environment variables, working directory and labels.
+// Attributes which are not set by the initramfs are left untouched.
+func (manifest *Manifest) ApplyInitrd(ctx context.Context, rootfs initrd.Initrd) error {
+	path, err := rootfs.Build(ctx)
+	if err != nil {
+		return fmt.Errorf("could not build initrd: %w", err)
+	}
+
+	if err := manifest.SetInitrd(ctx, path); err != nil {
+		return err
+	}
+
+	if args := rootfs.Args(); len(args) > 0 {
+		manifest.SetCmd(ctx, args)
+	}
+
+	if env := rootfs.Env(); len(env) > 0 {
+		manifest.SetEnv(ctx, env)
+	}
+
+	if workdir := rootfs.WorkingDir(); workdir != "" {
+		manifest.SetWorkingDir(ctx, workdir)
+	}
+
+	for k, v := range rootfs.Labels() {
+		manifest.SetLabel(ctx, k, v)
+	}
+
+	return nil
+}
+
+// AddNamedInitrd adds an additional initial ramdisk to the image which is
+// stored at `WellKnownInitrdDir/<name>` and annotated with its name.  An
+// existing initrd with the same name is replaced.
+func (manifest *Manifest) AddNamedInitrd(ctx context.Context, name, path string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid initrd name: '%s'", name)
+	}
+
+	dest := WellKnownInitrdDir + "/" + name
+
+	log.G(ctx).
+		WithField("src", path).
+		WithField("dest", dest).
+		WithField("name", name).
+		Debug("including named initrd")
+
+	layer, err := NewLayerFromFile(ctx,
+		ocispec.MediaTypeImageLayer,
+		path,
+		dest,
+		WithLayerAnnotation(AnnotationKernelInitrdName, name),
+	)
+	if err != nil {
+		return fmt.Errorf("could not build layer from file: %w", err)
+	}
+
+	// Only replace the initrd with the same name.
+	if _, err := manifest.addLayer(ctx, layer, func(existing *Layer) bool {
+		return existing.blob != nil && existing.blob.desc.Annotations[AnnotationKernelInitrdName] == name
+	}); err != nil {
+		return fmt.Errorf("could not add layer to manifest: %w", err)
+	}
+
+	return nil
+}
+
+// NamedInitrds returns the descriptors of all the named initrds of the image
+// keyed by their name.
+func (manifest *Manifest) NamedInitrds() map[string]ocispec.Descriptor {
+	manifest.mu.RLock()
+	defer manifest.mu.RUnlock()
+
+	initrds := make(map[string]ocispec.Descriptor)
+
+	for _, layer := range manifest.layers {
+		if layer.blob == nil {
+			continue
+		}
+
+		if name, ok := layer.blob.desc.Annotations[AnnotationKernelInitrdName]; ok {
+			initrds[name] = layer.blob.desc
+		}
+	}
+
+	return initrds
+}
+
+// SetDeviceTree sets the device tree blob (DTB) of the image, replacing any
+// existing one.  The resulting layer is annotated with AnnotationDeviceTreePath
+// such that it can be located by consumers of the manifest.
+func (manifest *Manifest) SetDeviceTree(ctx context.Context, path string) error {
+	log.G(ctx).
+		WithField("src", path).
+		WithField("dest", WellKnownDeviceTreePath).
+		Debug("including device tree")
+
+	layer, err := NewLayerFromFile(ctx,
+		ocispec.MediaTypeImageLayer,
+		path,
+		WellKnownDeviceTreePath,
+		WithLayerAnnotation(AnnotationDeviceTreePath, WellKnownDeviceTreePath),
+	)
+	if err != nil {
+		return fmt.Errorf("could not build layer from file: %w", err)
+	}
+
+	return manifest.replaceAnnotatedLayer(ctx, AnnotationDeviceTreePath, layer)
+}
+
 // SetLabel sets a label of the image with the provided key.
 func (manifest *Manifest) SetLabel(_ context.Context, key, val string) {
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
 	if manifest.config.Config.Labels == nil {
 		manifest.config.Config.Labels = make(map[string]string)
 	}
 
 	manifest.saved = false
+	manifest.desc = nil
 	manifest.config.Config.Labels[key] = val
 }
 
 // SetAnnotation sets an anootation of the image with the provided key.
 func (manifest *Manifest) SetAnnotation(_ context.Context, key, val string) {
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
 	if manifest.annotations == nil {
 		manifest.annotations = make(map[string]string)
 	}
 
 	manifest.saved = false
+	manifest.desc = nil
 	manifest.annotations[key] = val
 }
 
 // SetArchitecture sets the architecture of the image.
 func (manifest *Manifest) SetArchitecture(_ context.Context, architecture string) {
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
 	manifest.saved = false
+	manifest.desc = nil
 	manifest.config.Architecture = architecture
 }
 
 // SetOS sets the OS of the image.
 func (manifest *Manifest) SetOS(_ context.Context, os string) {
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
 	manifest.saved = false
+	manifest.desc = nil
 	manifest.config.OS = os
 }
 
 // SetOSVersion sets the version of the OS of the image.
 func (manifest *Manifest) SetOSVersion(_ context.Context, osversion string) {
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
 	manifest.saved = false
+	manifest.desc = nil
 	manifest.config.OSVersion = osversion
 }
 
 // SetOSFeature sets any OS features of the image.
 func (manifest *Manifest) SetOSFeature(_ context.Context, feature ...string) {
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
 	if manifest.config.OSFeatures == nil {
 		manifest.config.OSFeatures = make([]string, 0)
 	}
 
 	manifest.saved = false
+	manifest.desc = nil
 	manifest.config.OSFeatures = append(manifest.config.OSFeatures, feature...)
 }
 
 // Set the command of the image.
 func (manifest *Manifest) SetCmd(_ context.Context, cmd []string) {
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
+	manifest.saved = false
+	manifest.desc = nil
 	manifest.config.Config.Cmd = cmd
 }
 
 // Set the environment variables of the image.
 func (manifest *Manifest) SetEnv(_ context.Context, env []string) {
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
+	manifest.saved = false
+	manifest.desc = nil
 	manifest.config.Config.Env = env
 }
 
-// Save the image.
-func (manifest *Manifest) Save(ctx context.Context, fullref string, onProgress func(float64)) (*ocispec.Descriptor, error) {
+// SetUser sets the user (and optionally group) which the image's process runs
+// as.
+func (manifest *Manifest) SetUser(_ context.Context, user string) {
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
+	manifest.saved = false
+	manifest.desc = nil
+	manifest.config.Config.User = user
+}
+
+// SetWorkingDir sets the current working directory of the image's process.
+func (manifest *Manifest) SetWorkingDir(_ context.Context, workdir string) {
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
+	manifest.saved = false
+	manifest.desc = nil
+	manifest.config.Config.WorkingDir = workdir
+}
+
+// SetEntrypoint sets the entrypoint of the image.
+func (manifest *Manifest) SetEntrypoint(_ context.Context, entrypoint []string) {
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
+	manifest.saved = false
+	manifest.desc = nil
+	manifest.config.Config.Entrypoint = entrypoint
+}
+
+// SetExposedPorts sets the set of ports exposed by the image, each in the
+// format `port/protocol`, e.g. `8080/tcp`.  If the protocol is omitted, `tcp`
+// is assumed.
+func (manifest *Manifest) SetExposedPorts(_ context.Context, ports []string) {
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
+	manifest.saved = false
+	manifest.desc = nil
+	manifest.config.Config.ExposedPorts = make(map[string]struct{}, len(ports))
+
+	for _, port := range ports {
+		if !strings.Contains(port, "/") {
+			port += "/tcp"
+		}
+
+		manifest.config.Config.ExposedPorts[port] = struct{}{}
+	}
+}
+
+// Save the image.  A dry-run can be requested via WithDryRun in which case the
+// descriptor which would be saved is returned without writing to the handler.
+func (manifest *Manifest) Save(ctx context.Context, fullref string, onProgress func(float64), opts ...SaveOption) (*ocispec.Descriptor, error) {
+	sopts := saveOptions{}
+	for _, opt := range opts {
+		opt(&sopts)
+	}
+
+	// Saving finalizes the layers and updates the configuration, annotations and
+	// descriptor of the manifest, hence any concurrent modification is held off
+	// until it has completed.
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
 	if manifest.saved && manifest.desc != nil {
 		return manifest.desc, nil
 	}
@@ -250,21 +744,65 @@ func (manifest *Manifest) Save(ctx context.Context, fullref string, onProgress f
 		}
 	}
 
+	if !sopts.skipVerification {
+		if err := manifest.verifyLayers(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	ref, err := name.ParseReference(fullref,
 		name.WithDefaultRegistry(""),
-		name.WithDefaultTag(DefaultTag),
+		name.WithDefaultTag(manifest.defaultTag),
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	// Stream any layers whose content is provided by a reader directly to the
+	// handler, which determines their digest.
+	for _, layer := range manifest.layers {
+		if layer.blob.stream == nil {
+			continue
+		}
+
+		if sopts.dryRun {
+			return nil, fmt.Errorf("cannot perform a dry-run with streamed layers as their digest is not known")
+		}
+
+		reader := layer.blob.stream(ctx)
+		desc, err := manifest.handle.StreamDescriptor(ctx, ref.Name(), layer.blob.desc.MediaType, reader, nil)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not stream layer to %s: %w", layer.dst, classifyError(err, ErrNotFound))
+		}
+
+		desc.Annotations = layer.blob.desc.Annotations
+		desc.Platform = layer.blob.desc.Platform
+
+		layer.blob.desc = desc
+		layer.blob.stream = nil
+
+		if layer.digester != nil {
+			layer.diffID = layer.digester.Digest()
+			layer.digester = nil
+		}
+		manifest.pushed.Store(desc.Digest, true)
+	}
+
+	// Use the creation time of a previous dry-run, if any, such that the
+	// resulting descriptor is the same.
+	created := manifest.created
+	if created.IsZero() {
+		created = time.Now().UTC()
+	}
+
 	// Copy the current set of layers, this will make up the manifest.
 	var layers []ocispec.Descriptor
 	var diffIds []digest.Digest
 
 	for _, layer := range manifest.layers {
 		layers = append(layers, layer.blob.desc)
-		diffIds = append(diffIds, layer.blob.desc.Digest)
+		diffIds = append(diffIds, layer.DiffID())
 	}
 
 	if len(diffIds) > 0 {
@@ -274,20 +812,9 @@ func (manifest *Manifest) Save(ctx context.Context, fullref string, onProgress f
 		}
 	}
 
-	// Sort the features alphabetically.  This ensures that comparisons between
-	// versions are symmetric.
-	sort.Slice(manifest.config.OSFeatures, func(i, j int) bool {
-		// Check if we have numbers, sort them accordingly
-		if z, err := strconv.Atoi(manifest.config.OSFeatures[i]); err == nil {
-			if y, err := strconv.Atoi(manifest.config.OSFeatures[j]); err == nil {
-				return y < z
-			}
-			// If we get only one number, alway say its greater than letter
-			return true
-		}
-		// Compare letters normally
-		return manifest.config.OSFeatures[j] > manifest.config.OSFeatures[i]
-	})
+	// Sort the features in their canonical order.  This ensures that
+	// comparisons between versions are symmetric.
+	sortOSFeatures(manifest.config.OSFeatures)
 
 	configJson, err := json.Marshal(manifest.config)
 	if err != nil {
@@ -321,7 +848,7 @@ func (manifest *Manifest) Save(ctx context.Context, fullref string, onProgress f
 	// General annotations
 	manifest.annotations[ocispec.AnnotationRefName] = ref.Context().String()
 	// manifest.annotations[ocispec.AnnotationRevision] = ref.Identifier()
-	manifest.annotations[ocispec.AnnotationCreated] = time.Now().UTC().Format(time.RFC3339)
+	manifest.annotations[ocispec.AnnotationCreated] = created.Format(time.RFC3339)
 	manifest.annotations[AnnotationKraftKitVersion] = version.Version()
 
 	// containerd compatibility annotations
@@ -333,28 +860,45 @@ func (manifest *Manifest) Save(ctx context.Context, fullref string, onProgress f
 			Versioned: specs.Versioned{
 				SchemaVersion: 2,
 			},
-			Config:      configBlob.desc,
-			MediaType:   ocispec.MediaTypeImageManifest,
-			Layers:      layers,
-			Annotations: manifest.annotations,
+			MediaType: ocispec.MediaTypeImageManifest,
 		}
 	}
 
+	// Always reflect the current configuration, layers and annotations, since
+	// an existing manifest (e.g. one loaded from a digest) may since have been
+	// modified.
+	manifest.manifest.Config = configBlob.desc
+	manifest.manifest.Layers = layers
+	manifest.manifest.Annotations = manifest.annotations
+
 	manifestJson, err := json.Marshal(manifest.manifest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
 	}
 
-	if manifest.desc == nil {
-		manifestDesc := content.NewDescriptorFromBytes(
-			ocispec.MediaTypeImageManifest,
-			manifestJson,
-		)
-		// manifestDesc.ArtifactType = manifest.manifest.Config.MediaType
-		manifestDesc.Annotations = manifest.manifest.Annotations
-		manifestDesc.Platform = platform
+	// The descriptor is always derived from the final manifest such that it
+	// matches the content which is saved.
+	manifestDesc := content.NewDescriptorFromBytes(
+		ocispec.MediaTypeImageManifest,
+		manifestJson,
+	)
+	// manifestDesc.ArtifactType = manifest.manifest.Config.MediaType
+	manifestDesc.Annotations = manifest.manifest.Annotations
+	manifestDesc.Platform = platform
+
+	manifest.desc = &manifestDesc
+
+	if sopts.dryRun {
+		log.G(ctx).
+			WithField("ref", ref.Name()).
+			WithField("digest", manifest.desc.Digest.String()).
+			Debug("dry-run: not saving manifest")
 
-		manifest.desc = &manifestDesc
+		// Retain the creation time such that a subsequent save produces the same
+		// descriptor.
+		manifest.created = created
+
+		return manifest.desc, nil
 	}
 
 	log.G(ctx).
@@ -370,7 +914,7 @@ func (manifest *Manifest) Save(ctx context.Context, fullref string, onProgress f
 		bytes.NewReader(manifestJson),
 		onProgress,
 	); err != nil && !errors.Is(err, errdefs.ErrAlreadyExists) {
-		return nil, fmt.Errorf("failed to save manifest: %w", err)
+		return nil, fmt.Errorf("failed to save manifest: %w", classifyError(err, ErrNotFound))
 	}
 
 	// We check if the config blob already exists now after saving the manifest.
@@ -384,16 +928,39 @@ func (manifest *Manifest) Save(ctx context.Context, fullref string, onProgress f
 		}
 	}
 
+	if manifest.signer != nil {
+		if err := manifest.sign(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	manifest.saved = true
+	manifest.created = time.Time{}
 
 	// Push any outstanding layers last.
 	eg, egCtx := errgroup.WithContext(ctx)
 
+	// Layers are pushed concurrently, hence progress updates, which report the
+	// fraction of layers which have been pushed, are serialized.
+	var progressMu sync.Mutex
+	pushedLayers := 0
+
 	// The same applies to layers with containerd's garbage collector, save these
 	// now after the manifest has been saved.
 	for i := range manifest.layers {
 		eg.Go(func(i int) func() error {
-			return func() error {
+			return func() (err error) {
+				defer func() {
+					if err != nil || onProgress == nil {
+						return
+					}
+
+					progressMu.Lock()
+					pushedLayers++
+					onProgress(float64(pushedLayers) / float64(len(manifest.layers)))
+					progressMu.Unlock()
+				}()
+
 				if manifest.layers[i].blob.tmp == "" {
 					return nil
 				}
@@ -407,8 +974,30 @@ func (manifest *Manifest) Save(ctx context.Context, fullref string, onProgress f
 					return nil
 				}
 
-				if _, err := manifest.AddBlob(egCtx, manifest.layers[i].blob); err != nil {
-					return fmt.Errorf("failed to push layer: %d: %v", i, err)
+				// Transient failures, e.g. over a flaky link, are retried rather than
+				// failing the whole save.  Any progress is reset to that of the layers
+				// which have been pushed before a restart.
+				if err := retryWithBackoff(egCtx,
+					manifest.pushRetries,
+					manifest.pushRetryBackoff,
+					func(attempt int, err error) {
+						log.G(egCtx).
+							WithField("digest", manifest.layers[i].blob.desc.Digest.String()).
+							WithField("attempt", attempt).
+							Warn("retrying layer upload")
+
+						if onProgress != nil {
+							progressMu.Lock()
+							onProgress(float64(pushedLayers) / float64(len(manifest.layers)))
+							progressMu.Unlock()
+						}
+					},
+					func() error {
+						_, err := manifest.AddBlob(egCtx, manifest.layers[i].blob)
+						return err
+					},
+				); err != nil {
+					return fmt.Errorf("failed to push layer: %d: %w", i, classifyError(err, ErrNotFound))
 				}
 
 				return nil
@@ -421,3 +1010,124 @@ func (manifest *Manifest) Save(ctx context.Context, fullref string, onProgress f
 
 	return manifest.desc, nil
 }
+
+// Pull retrieves the manifest, its configuration and all of its layers into
+// the handler from the remote registry at the provided canonical reference and
+// populates the manifest accordingly.  The digest of the manifest is taken from
+// the manifest's descriptor if it is known or otherwise from the reference,
+// e.g. `unikraft.org/helloworld@sha256:...`.  Blobs which are already present
+// in the handler are not retrieved again and the aggregate progress of all
+// remaining blobs is delivered via the optional onProgress callback.
+func (manifest *Manifest) Pull(ctx context.Context, fullref string, onProgress func(float64)) error {
+	ref, err := name.ParseReference(fullref,
+		name.WithDefaultRegistry(""),
+		name.WithDefaultTag(manifest.defaultTag),
+	)
+	if err != nil {
+		return err
+	}
+
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
+	var dgst digest.Digest
+	if manifest.desc != nil {
+		dgst = manifest.desc.Digest
+	} else if refDigest, ok := ref.(name.Digest); ok {
+		dgst = digest.Digest(refDigest.DigestStr())
+	} else {
+		return fmt.Errorf("cannot pull manifest without a digest: %s", fullref)
+	}
+
+	plat := &ocispec.Platform{
+		Architecture: manifest.config.Architecture,
+		OS:           manifest.config.OS,
+		OSVersion:    manifest.config.OSVersion,
+		OSFeatures:   manifest.config.OSFeatures,
+	}
+	if manifest.desc != nil && manifest.desc.Platform != nil {
+		plat = manifest.desc.Platform
+	}
+
+	if info, _ := manifest.handle.DigestInfo(ctx, dgst); info == nil {
+		log.G(ctx).
+			WithField("ref", ref.Name()).
+			WithField("digest", dgst.String()).
+			Debug("pulling manifest")
+
+		// Retrieving the manifest also retrieves its configuration and any of
+		// its layers which are not yet present in the handler.
+		if err := manifest.handle.PullDigest(ctx,
+			ocispec.MediaTypeImageManifest,
+			fullref,
+			dgst,
+			plat,
+			onProgress,
+		); err != nil {
+			return fmt.Errorf("could not pull manifest: %w", classifyError(err, ErrManifestNotFound))
+		}
+
+		if err := manifest.load(ctx, dgst); err != nil {
+			return err
+		}
+
+		if onProgress != nil {
+			onProgress(1)
+		}
+
+		return nil
+	}
+
+	// The manifest itself is already present, which may however be the case
+	// when a previous pull was interrupted before all of its layers have been
+	// retrieved.
+	if err := manifest.load(ctx, dgst); err != nil {
+		return err
+	}
+
+	var missing []ocispec.Descriptor
+	var totalSize int64
+
+	for _, layer := range manifest.layers {
+		if info, _ := manifest.handle.DigestInfo(ctx, layer.blob.desc.Digest); info != nil {
+			continue
+		}
+
+		missing = append(missing, layer.blob.desc)
+		totalSize += layer.blob.desc.Size
+	}
+
+	var pulledSize int64
+
+	for _, desc := range missing {
+		log.G(ctx).
+			WithField("ref", ref.Name()).
+			WithField("digest", desc.Digest.String()).
+			Debug("pulling layer")
+
+		if err := manifest.handle.PullDigest(ctx,
+			ocispec.MediaTypeImageLayer,
+			fullref,
+			desc.Digest,
+			plat,
+			func(progress float64) {
+				if onProgress == nil || totalSize == 0 {
+					return
+				}
+
+				progress = min(max(progress, 0), 1)
+				onProgress((float64(pulledSize) + progress*float64(desc.Size)) / float64(totalSize))
+			},
+		); err != nil {
+			return fmt.Errorf("could not pull layer %s: %w", desc.Digest.String(), classifyError(err, ErrNotFound))
+		}
+
+		pulledSize += desc.Size
+	}
+
+	if onProgress != nil {
+		onProgress(1)
+	}
+
+	return nil
+}
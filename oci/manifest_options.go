@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package oci
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultPushRetries is the number of times a layer upload is retried after
+	// a transient failure.
+	DefaultPushRetries = 3
+
+	// DefaultPushRetryBackoff is the initial delay before retrying a layer
+	// upload, which doubles with each subsequent attempt.
+	DefaultPushRetryBackoff = time.Second
+)
+
+type ManifestOption func(*Manifest) error
+
+// WithPushRetries sets the number of times an upload of a layer is retried
+// after a transient failure (e.g. a timeout or a 5xx response) and the initial
+// delay between attempts, which increases exponentially.  Setting retries to
+// zero disables retrying.
+func WithPushRetries(retries int, base time.Duration) ManifestOption {
+	return func(manifest *Manifest) error {
+		if retries < 0 {
+			return fmt.Errorf("number of retries cannot be negative")
+		}
+		if base < 0 {
+			return fmt.Errorf("retry backoff cannot be negative")
+		}
+
+		manifest.pushRetries = retries
+		manifest.pushRetryBackoff = base
+
+		return nil
+	}
+}
+
+// WithSigner sets a signer which is used to sign the manifest when it is saved.
+// The resulting signature is attached to the manifest as a referring artifact.
+func WithSigner(signer Signer) ManifestOption {
+	return func(manifest *Manifest) error {
+		manifest.signer = signer
+		return nil
+	}
+}
+
+// WithVerifier sets a verifier which is used to check the signature of a
+// manifest when it is instantiated from a digest.  Manifests without a valid
+// signature are rejected.
+func WithVerifier(verifier Verifier) ManifestOption {
+	return func(manifest *Manifest) error {
+		manifest.verifier = verifier
+		return nil
+	}
+}
+
+// saveOptions contains the list of options which can be set whilst saving a
+// manifest.
+type saveOptions struct {
+	dryRun           bool
+	skipVerification bool
+}
+
+type SaveOption func(*saveOptions)
+
+// WithDryRun computes the config, annotations, manifest and its descriptor
+// without saving anything to the handler.  The returned descriptor is the same
+// as the one returned by a subsequent save of the unmodified manifest.
+func WithDryRun() SaveOption {
+	return func(opts *saveOptions) {
+		opts.dryRun = true
+	}
+}
+
+// WithSkipVerification saves the manifest without checking it for
+// inconsistencies via Verify, e.g. for exotic kernels whose machine type does
+// not correspond to the architecture of the image.
+func WithSkipVerification() SaveOption {
+	return func(opts *saveOptions) {
+		opts.skipVerification = true
+	}
+}
+
+// WithCompressedLayers gzips the content of uncompressed tarball layers as they
+// are added to the manifest.
+func WithCompressedLayers() ManifestOption {
+	return func(manifest *Manifest) error {
+		manifest.compressLayers = true
+		return nil
+	}
+}
+
+// WithManifestDefaultTag sets the tag which is used when the manifest is saved
+// or pulled with a reference which does not specify one.  Defaults to
+// DefaultTag.
+func WithManifestDefaultTag(tag string) ManifestOption {
+	return func(manifest *Manifest) error {
+		if tag == "" {
+			return fmt.Errorf("cannot use empty default tag")
+		}
+
+		manifest.defaultTag = tag
+		return nil
+	}
+}
@@ -0,0 +1,1048 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package oci_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/images"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"kraftkit.sh/initrd"
+	"kraftkit.sh/oci"
+	"kraftkit.sh/oci/handler"
+)
+
+func TestNewManifestFromDigestPreservesConfig(t *testing.T) {
+	const ref = "unikraft.org/test:latest"
+
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	handle, err := handler.NewDirectoryHandler(dir, nil)
+	if err != nil {
+		t.Fatal("NewDirectoryHandler:", err)
+	}
+
+	manifest, err := oci.NewManifest(ctx, handle)
+	if err != nil {
+		t.Fatal("NewManifest:", err)
+	}
+
+	manifest.SetOS(ctx, "kraftkit")
+	manifest.SetArchitecture(ctx, "x86_64")
+	manifest.SetCmd(ctx, []string{"/bin/app", "--flag"})
+	manifest.SetEnv(ctx, []string{"FOO=bar"})
+	manifest.SetLabel(ctx, "org.example.label", "value")
+
+	desc, err := manifest.Save(ctx, ref, nil)
+	if err != nil {
+		t.Fatal("Save:", err)
+	}
+
+	loaded, err := oci.NewManifestFromDigest(ctx, handle, desc.Digest)
+	if err != nil {
+		t.Fatal("NewManifestFromDigest:", err)
+	}
+
+	// Force the manifest to be re-generated without touching its config.
+	loaded.SetAnnotation(ctx, "org.example.annotation", "value")
+
+	resaved, err := loaded.Save(ctx, ref, nil)
+	if err != nil {
+		t.Fatal("Save:", err)
+	}
+
+	if resaved.Digest == desc.Digest {
+		t.Fatal("expected the re-saved manifest to have a different digest")
+	}
+
+	before := readConfigBlob(t, handle, dir, desc.Digest)
+	after := readConfigBlob(t, handle, dir, resaved.Digest)
+
+	if string(before) != string(after) {
+		t.Errorf("config blob changed after load and save:\nbefore: %s\nafter:  %s", before, after)
+	}
+
+	image, err := handle.ResolveImage(ctx, "", resaved.Digest)
+	if err != nil {
+		t.Fatal("ResolveImage:", err)
+	}
+
+	if expect := []string{"/bin/app", "--flag"}; !slices.Equal(image.Config.Cmd, expect) {
+		t.Errorf("Cmd: expected %v, got %v", expect, image.Config.Cmd)
+	}
+	if expect := []string{"FOO=bar"}; !slices.Equal(image.Config.Env, expect) {
+		t.Errorf("Env: expected %v, got %v", expect, image.Config.Env)
+	}
+	if got := image.Config.Labels["org.example.label"]; got != "value" {
+		t.Errorf("Labels: expected %q, got %q", "value", got)
+	}
+}
+
+func TestManifestSaveDryRun(t *testing.T) {
+	const ref = "unikraft.org/test:latest"
+
+	ctx := context.Background()
+
+	handle, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("NewDirectoryHandler:", err)
+	}
+
+	manifest, err := oci.NewManifest(ctx, handle)
+	if err != nil {
+		t.Fatal("NewManifest:", err)
+	}
+
+	manifest.SetOS(ctx, "kraftkit")
+	manifest.SetArchitecture(ctx, "x86_64")
+	manifest.SetCmd(ctx, []string{"/bin/app"})
+
+	dryRun, err := manifest.Save(ctx, ref, nil, oci.WithDryRun())
+	if err != nil {
+		t.Fatal("Save (dry-run):", err)
+	}
+
+	if info, _ := handle.DigestInfo(ctx, dryRun.Digest); info != nil {
+		t.Fatal("expected dry-run to not save the manifest")
+	}
+
+	desc, err := manifest.Save(ctx, ref, nil)
+	if err != nil {
+		t.Fatal("Save:", err)
+	}
+
+	if desc.Digest != dryRun.Digest {
+		t.Errorf("expected digest %s, got %s", dryRun.Digest, desc.Digest)
+	}
+
+	if info, _ := handle.DigestInfo(ctx, desc.Digest); info == nil {
+		t.Error("expected the manifest to be saved")
+	}
+}
+
+func TestManifestImageConfigRoundTrip(t *testing.T) {
+	const ref = "unikraft.org/test:latest"
+
+	ctx := context.Background()
+
+	handle, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("NewDirectoryHandler:", err)
+	}
+
+	manifest, err := oci.NewManifest(ctx, handle)
+	if err != nil {
+		t.Fatal("NewManifest:", err)
+	}
+
+	manifest.SetOS(ctx, "kraftkit")
+	manifest.SetArchitecture(ctx, "x86_64")
+	manifest.SetUser(ctx, "1000:1000")
+	manifest.SetWorkingDir(ctx, "/app")
+	manifest.SetEntrypoint(ctx, []string{"/bin/app"})
+	manifest.SetExposedPorts(ctx, []string{"8080", "53/udp"})
+
+	desc, err := manifest.Save(ctx, ref, nil)
+	if err != nil {
+		t.Fatal("Save:", err)
+	}
+
+	loaded, err := oci.NewManifestFromDigest(ctx, handle, desc.Digest)
+	if err != nil {
+		t.Fatal("NewManifestFromDigest:", err)
+	}
+
+	// Changing an unrelated field must retain the remaining configuration.
+	loaded.SetCmd(ctx, []string{"--flag"})
+
+	resaved, err := loaded.Save(ctx, ref, nil)
+	if err != nil {
+		t.Fatal("Save:", err)
+	}
+
+	image, err := handle.ResolveImage(ctx, "", resaved.Digest)
+	if err != nil {
+		t.Fatal("ResolveImage:", err)
+	}
+
+	if image.Config.User != "1000:1000" {
+		t.Errorf("User: expected %q, got %q", "1000:1000", image.Config.User)
+	}
+	if image.Config.WorkingDir != "/app" {
+		t.Errorf("WorkingDir: expected %q, got %q", "/app", image.Config.WorkingDir)
+	}
+	if expect := []string{"/bin/app"}; !slices.Equal(image.Config.Entrypoint, expect) {
+		t.Errorf("Entrypoint: expected %v, got %v", expect, image.Config.Entrypoint)
+	}
+	if expect := []string{"--flag"}; !slices.Equal(image.Config.Cmd, expect) {
+		t.Errorf("Cmd: expected %v, got %v", expect, image.Config.Cmd)
+	}
+
+	if len(image.Config.ExposedPorts) != 2 {
+		t.Errorf("ExposedPorts: expected 2 ports, got %v", image.Config.ExposedPorts)
+	}
+	for _, port := range []string{"8080/tcp", "53/udp"} {
+		if _, ok := image.Config.ExposedPorts[port]; !ok {
+			t.Errorf("ExposedPorts: expected %s, got %v", port, image.Config.ExposedPorts)
+		}
+	}
+}
+
+// fakeInitrd is an initrd.Initrd whose build result and runtime configuration
+// are fixed.
+type fakeInitrd struct {
+	path    string
+	args    []string
+	env     []string
+	labels  map[string]string
+	workdir string
+}
+
+func (f *fakeInitrd) Name() string                          { return "fake" }
+func (f *fakeInitrd) Build(context.Context) (string, error) { return f.path, nil }
+func (f *fakeInitrd) Env() []string                         { return f.env }
+func (f *fakeInitrd) Args() []string                        { return f.args }
+func (f *fakeInitrd) Labels() map[string]string             { return f.labels }
+func (f *fakeInitrd) WorkingDir() string                    { return f.workdir }
+
+func (f *fakeInitrd) Digest() (digest.Digest, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", err
+	}
+
+	return digest.FromBytes(data), nil
+}
+
+func (f *fakeInitrd) List(context.Context) ([]initrd.InitrdEntry, error) {
+	return nil, nil
+}
+
+func (f *fakeInitrd) BuildTo(_ context.Context, w io.Writer) error {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+func TestManifestApplyInitrd(t *testing.T) {
+	ctx := context.Background()
+
+	handle, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("NewDirectoryHandler:", err)
+	}
+
+	manifest, err := oci.NewManifest(ctx, handle)
+	if err != nil {
+		t.Fatal("NewManifest:", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "initramfs.cpio")
+	if err := os.WriteFile(path, []byte("initramfs"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := manifest.ApplyInitrd(ctx, &fakeInitrd{
+		path:    path,
+		args:    []string{"/bin/app", "--flag"},
+		env:     []string{"FOO=bar"},
+		labels:  map[string]string{"org.example.label": "value"},
+		workdir: "/app",
+	}); err != nil {
+		t.Fatal("ApplyInitrd:", err)
+	}
+
+	if cmd := manifest.Cmd(); !slices.Equal(cmd, []string{"/bin/app", "--flag"}) {
+		t.Errorf("unexpected cmd: %v", cmd)
+	}
+
+	if env := manifest.Env(); !slices.Contains(env, "FOO=bar") {
+		t.Errorf("expected env to contain FOO=bar, got %v", env)
+	}
+
+	if label := manifest.Labels()["org.example.label"]; label != "value" {
+		t.Errorf("expected label to be set, got %q", label)
+	}
+
+	if layers := manifest.Layers(); len(layers) != 1 {
+		t.Errorf("expected the initrd layer to be added, got %d layers", len(layers))
+	}
+}
+
+func TestManifestSetInitrdReaderReplacesInitrd(t *testing.T) {
+	ctx := context.Background()
+
+	handle, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("NewDirectoryHandler:", err)
+	}
+
+	manifest, err := oci.NewManifest(ctx, handle)
+	if err != nil {
+		t.Fatal("NewManifest:", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "initramfs.cpio")
+	if err := os.WriteFile(path, []byte("initramfs"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := manifest.SetInitrd(ctx, path); err != nil {
+		t.Fatal("SetInitrd:", err)
+	}
+
+	data := []byte("in-memory initramfs")
+	if err := manifest.SetInitrdReader(ctx, bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal("SetInitrdReader:", err)
+	}
+
+	if layers := manifest.Layers(); len(layers) != 1 {
+		t.Fatalf("expected the initrd layer to be replaced, got %d layers", len(layers))
+	}
+
+	if _, err := manifest.Save(ctx, "unikraft.org/test:latest", nil); err != nil {
+		t.Fatal("Save:", err)
+	}
+}
+
+func TestManifestNamedInitrdsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	handle, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("NewDirectoryHandler:", err)
+	}
+
+	manifest, err := oci.NewManifest(ctx, handle)
+	if err != nil {
+		t.Fatal("NewManifest:", err)
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"default", "base", "app", "app-v2"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal("WriteFile:", err)
+		}
+	}
+
+	if err := manifest.SetInitrd(ctx, filepath.Join(dir, "default")); err != nil {
+		t.Fatal("SetInitrd:", err)
+	}
+
+	if err := manifest.AddNamedInitrd(ctx, "base", filepath.Join(dir, "base")); err != nil {
+		t.Fatal("AddNamedInitrd:", err)
+	}
+
+	if err := manifest.AddNamedInitrd(ctx, "app", filepath.Join(dir, "app")); err != nil {
+		t.Fatal("AddNamedInitrd:", err)
+	}
+
+	for _, name := range []string{"", ".", "..", "a/b"} {
+		if err := manifest.AddNamedInitrd(ctx, name, filepath.Join(dir, "app")); err == nil {
+			t.Errorf("expected the initrd name '%s' to be rejected", name)
+		}
+	}
+
+	expected := manifest.NamedInitrds()
+	if len(expected) != 2 {
+		t.Fatalf("expected 2 named initrds, got %v", expected)
+	}
+
+	desc, err := manifest.Save(ctx, "unikraft.org/test:latest", nil)
+	if err != nil {
+		t.Fatal("Save:", err)
+	}
+
+	loaded, err := oci.NewManifestFromDigest(ctx, handle, desc.Digest)
+	if err != nil {
+		t.Fatal("NewManifestFromDigest:", err)
+	}
+
+	initrds := loaded.NamedInitrds()
+	if len(initrds) != len(expected) {
+		t.Fatalf("expected %d named initrds to be read back, got %v", len(expected), initrds)
+	}
+
+	for name, want := range expected {
+		if got, ok := initrds[name]; !ok || got.Digest != want.Digest {
+			t.Errorf("expected named initrd '%s' with digest %s, got %s", name, want.Digest, got.Digest)
+		}
+	}
+
+	// Replacing a named initrd of a loaded manifest keeps the other initrds.
+	if err := loaded.AddNamedInitrd(ctx, "app", filepath.Join(dir, "app-v2")); err != nil {
+		t.Fatal("AddNamedInitrd:", err)
+	}
+
+	if layers := loaded.Layers(); len(layers) != 3 {
+		t.Errorf("expected the default and 2 named initrds, got %d layers", len(layers))
+	}
+
+	if got := loaded.NamedInitrds()["app"]; got.Digest == expected["app"].Digest {
+		t.Error("expected the named initrd 'app' to be replaced")
+	}
+
+	if got := loaded.NamedInitrds()["base"]; got.Digest != expected["base"].Digest {
+		t.Error("expected the named initrd 'base' to be kept")
+	}
+}
+
+func TestManifestSaveVerifiesKernelArchitecture(t *testing.T) {
+	ctx := context.Background()
+
+	handle, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("NewDirectoryHandler:", err)
+	}
+
+	// A truncated ELF header of a little-endian x86_64 executable.
+	header := make([]byte, 64)
+	copy(header, []byte{0x7f, 'E', 'L', 'F', 2, 1, 1})
+	binary.LittleEndian.PutUint16(header[16:], uint16(elf.ET_EXEC))
+	binary.LittleEndian.PutUint16(header[18:], uint16(elf.EM_X86_64))
+
+	kernel := filepath.Join(t.TempDir(), "kernel")
+	if err := os.WriteFile(kernel, header, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := oci.NewManifest(ctx, handle)
+	if err != nil {
+		t.Fatal("NewManifest:", err)
+	}
+
+	manifest.SetOS(ctx, "kraftkit")
+	manifest.SetArchitecture(ctx, "arm64")
+
+	if err := manifest.SetKernel(ctx, kernel); err != nil {
+		t.Fatal("SetKernel:", err)
+	}
+
+	if _, err := manifest.Save(ctx, "unikraft.org/test:latest", nil, oci.WithDryRun()); err == nil {
+		t.Error("expected mismatched kernel architecture to be rejected")
+	}
+
+	if _, err := manifest.Save(ctx, "unikraft.org/test:latest", nil, oci.WithDryRun(), oci.WithSkipVerification()); err != nil {
+		t.Errorf("expected verification to be skipped: %v", err)
+	}
+
+	manifest.SetArchitecture(ctx, "x86_64")
+
+	if _, err := manifest.Save(ctx, "unikraft.org/test:latest", nil, oci.WithDryRun()); err != nil {
+		t.Errorf("expected matching kernel architecture to be accepted: %v", err)
+	}
+}
+
+// readConfigBlob returns the raw config blob referenced by the manifest with
+// the provided digest.
+func readConfigBlob(t *testing.T, handle *handler.DirectoryHandler, dir string, dgst digest.Digest) []byte {
+	t.Helper()
+
+	spec, err := handle.ResolveManifest(context.Background(), "", dgst)
+	if err != nil {
+		t.Fatal("ResolveManifest:", err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(
+		dir,
+		handler.DirectoryHandlerDigestsDir,
+		spec.Config.Digest.Algorithm().String(),
+		spec.Config.Digest.Encoded(),
+	))
+	if err != nil {
+		t.Fatal("reading config blob:", err)
+	}
+
+	return b
+}
+
+func TestManifestPullSkipsPresentBlobs(t *testing.T) {
+	ctx := context.Background()
+
+	handle, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("NewDirectoryHandler:", err)
+	}
+
+	manifest, err := oci.NewManifest(ctx, handle)
+	if err != nil {
+		t.Fatal("NewManifest:", err)
+	}
+
+	manifest.SetOS(ctx, "kraftkit")
+	manifest.SetArchitecture(ctx, "x86_64")
+
+	if err := manifest.SetInitrdReader(ctx, bytes.NewReader([]byte("initrd")), 6); err != nil {
+		t.Fatal("SetInitrdReader:", err)
+	}
+
+	desc, err := manifest.Save(ctx, "unikraft.org/test:latest", nil)
+	if err != nil {
+		t.Fatal("Save:", err)
+	}
+
+	pulled, err := oci.NewManifest(ctx, handle)
+	if err != nil {
+		t.Fatal("NewManifest:", err)
+	}
+
+	// Since all blobs are present, no request is made to the (non-existent)
+	// registry.
+	var progress float64
+	if err := pulled.Pull(ctx, "unikraft.org/test@"+desc.Digest.String(), func(p float64) {
+		progress = p
+	}); err != nil {
+		t.Fatal("Pull:", err)
+	}
+
+	if progress != 1 {
+		t.Errorf("expected progress to be complete, got %f", progress)
+	}
+
+	if len(pulled.Layers()) != 1 {
+		t.Errorf("expected 1 layer, got %d", len(pulled.Layers()))
+	}
+
+	if pulled.Architecture() != "x86_64" {
+		t.Errorf("expected architecture x86_64, got %s", pulled.Architecture())
+	}
+}
+
+// closingHandler is a handler which closes the file it is provided whilst
+// saving a descriptor, such that the subsequent close by the caller fails.
+type closingHandler struct {
+	handler.Handler
+}
+
+func (closingHandler) DigestInfo(context.Context, digest.Digest) (*content.Info, error) {
+	return nil, nil
+}
+
+func (closingHandler) SaveDescriptor(_ context.Context, _ string, _ ocispec.Descriptor, reader io.Reader, _ func(float64)) error {
+	if f, ok := reader.(*os.File); ok {
+		return f.Close()
+	}
+
+	return nil
+}
+
+func TestManifestAddBlobReturnsCloseError(t *testing.T) {
+	ctx := context.Background()
+
+	manifest, err := oci.NewManifest(ctx, closingHandler{})
+	if err != nil {
+		t.Fatal("NewManifest:", err)
+	}
+
+	blob, err := oci.NewBlob(ctx, ocispec.MediaTypeImageConfig, []byte("{}"))
+	if err != nil {
+		t.Fatal("NewBlob:", err)
+	}
+
+	if _, err := manifest.AddBlob(ctx, blob); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected error %v, got %v", os.ErrClosed, err)
+	}
+}
+
+func TestManifestConcurrentAddLayerAndSave(t *testing.T) {
+	const ref = "unikraft.org/test:latest"
+	const numLayers = 8
+
+	ctx := context.Background()
+
+	handle, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("NewDirectoryHandler:", err)
+	}
+
+	manifest, err := oci.NewManifest(ctx, handle)
+	if err != nil {
+		t.Fatal("NewManifest:", err)
+	}
+
+	manifest.SetOS(ctx, "kraftkit")
+	manifest.SetArchitecture(ctx, "x86_64")
+
+	dir := t.TempDir()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*numLayers)
+
+	for i := 0; i < numLayers; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+
+			path := filepath.Join(dir, "file"+strconv.Itoa(i))
+			if err := os.WriteFile(path, []byte("layer "+strconv.Itoa(i)), 0o644); err != nil {
+				errs <- err
+				return
+			}
+
+			layer, err := oci.NewLayerFromFile(ctx, ocispec.MediaTypeImageLayer, path, "/file"+strconv.Itoa(i))
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			if _, err := manifest.AddLayer(ctx, layer); err != nil {
+				errs <- err
+			}
+		}(i)
+
+		go func() {
+			defer wg.Done()
+
+			manifest.SetLabel(ctx, "org.example.label", "value")
+
+			if _, err := manifest.Save(ctx, ref, nil); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if layers := manifest.Layers(); len(layers) != numLayers {
+		t.Fatalf("expected %d layers, got %d", numLayers, len(layers))
+	}
+
+	desc, err := manifest.Save(ctx, ref, nil)
+	if err != nil {
+		t.Fatal("Save:", err)
+	}
+
+	loaded, err := oci.NewManifestFromDigest(ctx, handle, desc.Digest)
+	if err != nil {
+		t.Fatal("NewManifestFromDigest:", err)
+	}
+
+	if layers := loaded.Layers(); len(layers) != numLayers {
+		t.Errorf("expected %d saved layers, got %d", numLayers, len(layers))
+	}
+}
+
+func TestManifestSaveUsesDefaultTag(t *testing.T) {
+	ctx := context.Background()
+
+	handle, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("NewDirectoryHandler:", err)
+	}
+
+	manifest, err := oci.NewManifest(ctx, handle, oci.WithManifestDefaultTag("stable"))
+	if err != nil {
+		t.Fatal("NewManifest:", err)
+	}
+
+	manifest.SetOS(ctx, "kraftkit")
+	manifest.SetArchitecture(ctx, "x86_64")
+
+	desc, err := manifest.Save(ctx, "unikraft.org/test", nil)
+	if err != nil {
+		t.Fatal("Save:", err)
+	}
+
+	if expect, got := "unikraft.org/test:stable", desc.Annotations[images.AnnotationImageName]; got != expect {
+		t.Errorf("expected image name %q, got %q", expect, got)
+	}
+}
+
+func TestNewManifestFromDigestNotFound(t *testing.T) {
+	ctx := context.Background()
+
+	handle, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal("NewDirectoryHandler:", err)
+	}
+
+	_, err = oci.NewManifestFromDigest(ctx, handle, digest.FromString("missing"))
+	if !errors.Is(err, oci.ErrManifestNotFound) {
+		t.Errorf("expected error %v, got %v", oci.ErrManifestNotFound, err)
+	}
+
+	if !errors.Is(err, oci.ErrNotFound) {
+		t.Errorf("expected error %v, got %v", oci.ErrNotFound, err)
+	}
+}
+
+func TestNewManifestFromDigestVerifiesContent(t *testing.T) {
+	const ref = "unikraft.org/test:latest"
+
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	handle, err := handler.NewDirectoryHandler(dir, nil)
+	if err != nil {
+		t.Fatal("NewDirectoryHandler:", err)
+	}
+
+	manifest, err := oci.NewManifest(ctx, handle)
+	if err != nil {
+		t.Fatal("NewManifest:", err)
+	}
+
+	manifest.SetOS(ctx, "kraftkit")
+	manifest.SetArchitecture(ctx, "x86_64")
+
+	desc, err := manifest.Save(ctx, ref, nil)
+	if err != nil {
+		t.Fatal("Save:", err)
+	}
+
+	manifestPath := filepath.Join(
+		dir,
+		handler.DirectoryHandlerDigestsDir,
+		desc.Digest.Algorithm().String(),
+		desc.Digest.Encoded(),
+	)
+
+	raw, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal("reading manifest blob:", err)
+	}
+
+	loaded, err := oci.NewManifestFromDigest(ctx, handle, desc.Digest)
+	if err != nil {
+		t.Fatal("NewManifestFromDigest:", err)
+	}
+
+	// Saving an unmodified manifest returns its descriptor as loaded.
+	loadedDesc, err := loaded.Save(ctx, ref, nil)
+	if err != nil {
+		t.Fatal("Save:", err)
+	}
+
+	if loadedDesc.Size != int64(len(raw)) {
+		t.Errorf("expected size %d, got %d", len(raw), loadedDesc.Size)
+	}
+
+	// Corrupt the manifest whilst retaining valid JSON.
+	if err := os.WriteFile(manifestPath, append(raw, ' '), 0o644); err != nil {
+		t.Fatal("corrupting manifest blob:", err)
+	}
+
+	if _, err := oci.NewManifestFromDigest(ctx, handle, desc.Digest); err == nil {
+		t.Error("expected error when resolving a corrupt manifest")
+	}
+}
+
+func TestManifestCompressedLayersDiffID(t *testing.T) {
+	content := []byte("compressible initramfs content ")
+	content = bytes.Repeat(content, 1024)
+
+	tests := []struct {
+		name   string
+		setter func(ctx context.Context, manifest *oci.Manifest) error
+	}{
+		{
+			name: "file",
+			setter: func(ctx context.Context, manifest *oci.Manifest) error {
+				path := filepath.Join(t.TempDir(), "initramfs.cpio")
+				if err := os.WriteFile(path, content, 0o644); err != nil {
+					return err
+				}
+
+				return manifest.SetInitrd(ctx, path)
+			},
+		},
+		{
+			name: "reader",
+			setter: func(ctx context.Context, manifest *oci.Manifest) error {
+				return manifest.SetInitrdReader(ctx, bytes.NewReader(content), int64(len(content)))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			handle, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+			if err != nil {
+				t.Fatal("NewDirectoryHandler:", err)
+			}
+
+			manifest, err := oci.NewManifest(ctx, handle, oci.WithCompressedLayers())
+			if err != nil {
+				t.Fatal("NewManifest:", err)
+			}
+
+			if err := tt.setter(ctx, manifest); err != nil {
+				t.Fatal("SetInitrd:", err)
+			}
+
+			desc, err := manifest.Save(ctx, "unikraft.org/test:latest", nil)
+			if err != nil {
+				t.Fatal("Save:", err)
+			}
+
+			spec, err := handle.ResolveManifest(ctx, "", desc.Digest)
+			if err != nil {
+				t.Fatal("ResolveManifest:", err)
+			}
+
+			if len(spec.Layers) != 1 {
+				t.Fatalf("expected 1 layer, got %d", len(spec.Layers))
+			}
+
+			layerDesc := spec.Layers[0]
+			if layerDesc.MediaType != ocispec.MediaTypeImageLayerGzip {
+				t.Errorf("expected media type %s, got %s", ocispec.MediaTypeImageLayerGzip, layerDesc.MediaType)
+			}
+
+			reader, err := handle.FetchDescriptor(ctx, layerDesc)
+			if err != nil {
+				t.Fatal("FetchDescriptor:", err)
+			}
+
+			compressed, err := io.ReadAll(reader)
+			reader.Close()
+			if err != nil {
+				t.Fatal("ReadAll:", err)
+			}
+
+			if dgst := digest.FromBytes(compressed); dgst != layerDesc.Digest {
+				t.Errorf("expected the blob to have digest %s, got %s", layerDesc.Digest, dgst)
+			}
+
+			// The stored blob must be gzip-compressed and not merely labelled as
+			// such.
+			gzr, err := gzip.NewReader(bytes.NewReader(compressed))
+			if err != nil {
+				t.Fatal("expected a gzip-compressed layer:", err)
+			}
+
+			uncompressed, err := io.ReadAll(gzr)
+			if err != nil {
+				t.Fatal("could not decompress layer:", err)
+			}
+
+			if len(compressed) >= len(uncompressed) {
+				t.Errorf("expected the layer to be compressed, got %d bytes from %d", len(compressed), len(uncompressed))
+			}
+
+			tr := tar.NewReader(bytes.NewReader(uncompressed))
+			for {
+				hdr, err := tr.Next()
+				if err != nil {
+					t.Fatal("expected the layer to be a tarball containing the initrd:", err)
+				}
+
+				if hdr.Typeflag != tar.TypeReg {
+					continue
+				}
+
+				if b, err := io.ReadAll(tr); err != nil || !bytes.Equal(b, content) {
+					t.Errorf("expected the layer to contain the initrd: %v", err)
+				}
+
+				break
+			}
+
+			image, err := handle.ResolveImage(ctx, "", desc.Digest)
+			if err != nil {
+				t.Fatal("ResolveImage:", err)
+			}
+
+			diffID := digest.FromBytes(uncompressed)
+
+			if len(image.RootFS.DiffIDs) != 1 || image.RootFS.DiffIDs[0] != diffID {
+				t.Errorf("expected the DiffID %s, got %v", diffID, image.RootFS.DiffIDs)
+			}
+
+			if diffID == layerDesc.Digest {
+				t.Error("expected the DiffID to differ from the digest of the compressed layer")
+			}
+
+			loaded, err := oci.NewManifestFromDigest(ctx, handle, desc.Digest)
+			if err != nil {
+				t.Fatal("NewManifestFromDigest:", err)
+			}
+
+			if got := loaded.Layers()[0].DiffID(); got != diffID {
+				t.Errorf("expected the loaded layer to retain the DiffID %s, got %s", diffID, got)
+			}
+		})
+	}
+}
+
+// flakyHandler simulates a flaky transport by failing the first attempts at
+// saving each layer with the provided error.
+type flakyHandler struct {
+	handler.Handler
+
+	failures int
+	err      error
+
+	mu       sync.Mutex
+	attempts map[digest.Digest]int
+}
+
+func (handle *flakyHandler) SaveDescriptor(ctx context.Context, ref string, desc ocispec.Descriptor, reader io.Reader, onProgress func(float64)) error {
+	if desc.MediaType == ocispec.MediaTypeImageLayer {
+		handle.mu.Lock()
+		handle.attempts[desc.Digest]++
+		attempt := handle.attempts[desc.Digest]
+		handle.mu.Unlock()
+
+		if attempt <= handle.failures {
+			return handle.err
+		}
+	}
+
+	return handle.Handler.SaveDescriptor(ctx, ref, desc, reader, onProgress)
+}
+
+func TestManifestSaveRetriesLayers(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		err      error
+		attempts int
+		wantErr  bool
+	}{
+		{
+			name:     "transient",
+			failures: 2,
+			err:      fmt.Errorf("could not write blob: %w", syscall.ECONNRESET),
+			attempts: 3,
+		},
+		{
+			name:     "retries exhausted",
+			failures: 5,
+			err:      fmt.Errorf("could not write blob: %w", syscall.ECONNRESET),
+			attempts: 4,
+			wantErr:  true,
+		},
+		{
+			name:     "permanent",
+			failures: 1,
+			err:      errors.New("denied"),
+			attempts: 1,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			dir := t.TempDir()
+			directory, err := handler.NewDirectoryHandler(dir, nil)
+			if err != nil {
+				t.Fatal("NewDirectoryHandler:", err)
+			}
+
+			handle := &flakyHandler{
+				Handler:  directory,
+				failures: tt.failures,
+				err:      tt.err,
+				attempts: map[digest.Digest]int{},
+			}
+
+			manifest, err := oci.NewManifest(ctx, handle,
+				oci.WithPushRetries(3, time.Millisecond),
+			)
+			if err != nil {
+				t.Fatal("NewManifest:", err)
+			}
+
+			for i := 0; i < 4; i++ {
+				path := filepath.Join(t.TempDir(), "initramfs.cpio")
+				if err := os.WriteFile(path, []byte(strconv.Itoa(i)), 0o644); err != nil {
+					t.Fatal("WriteFile:", err)
+				}
+
+				if err := manifest.AddNamedInitrd(ctx, strconv.Itoa(i), path); err != nil {
+					t.Fatal("AddNamedInitrd:", err)
+				}
+			}
+
+			// Progress is reported as each layer is pushed and reset on each retry,
+			// which must never happen concurrently even though layers are pushed in
+			// parallel.
+			var inProgress atomic.Bool
+			var progress float64
+			onProgress := func(p float64) {
+				if !inProgress.CompareAndSwap(false, true) {
+					t.Error("onProgress called concurrently")
+					return
+				}
+
+				progress = p
+				time.Sleep(time.Millisecond)
+				inProgress.Store(false)
+			}
+
+			_, err = manifest.Save(ctx, "unikraft.org/test:latest", onProgress)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected Save to fail")
+			} else if !tt.wantErr && err != nil {
+				t.Fatal("Save:", err)
+			}
+
+			handle.mu.Lock()
+			defer handle.mu.Unlock()
+
+			if len(handle.attempts) != 4 && !tt.wantErr {
+				t.Errorf("expected 4 layers to be pushed, got %d", len(handle.attempts))
+			}
+
+			for dgst, attempts := range handle.attempts {
+				if attempts > tt.attempts {
+					t.Errorf("layer %s: expected at most %d attempts, got %d", dgst, tt.attempts, attempts)
+				} else if !tt.wantErr && attempts != tt.attempts {
+					t.Errorf("layer %s: expected %d attempts, got %d", dgst, tt.attempts, attempts)
+				}
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if progress != 1 {
+				t.Errorf("expected the save to complete with progress 1, got %f", progress)
+			}
+
+			for dgst := range handle.attempts {
+				if info, _ := directory.DigestInfo(ctx, dgst); info == nil {
+					t.Errorf("expected layer %s to be saved", dgst)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package oci
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// compareOSFeatures defines the canonical order of OS features, which ensures
+// that comparisons between versions are symmetric:
+//
+//   - numeric features come before non-numeric features;
+//   - numeric features are ordered by their value in descending order, with
+//     ties (e.g. "1" and "01") broken lexically;
+//   - non-numeric features are ordered lexically in ascending order.
+//
+// This is a strict weak ordering, so it is safe to use with the sort package.
+func compareOSFeatures(a, b string) int {
+	x, errA := strconv.ParseInt(a, 10, 64)
+	y, errB := strconv.ParseInt(b, 10, 64)
+
+	switch {
+	case errA == nil && errB == nil:
+		if x != y {
+			if x > y {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+
+	case errA == nil:
+		return -1
+
+	case errB == nil:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+// sortOSFeatures sorts the provided OS features in place according to
+// compareOSFeatures.
+func sortOSFeatures(features []string) {
+	slices.SortStableFunc(features, compareOSFeatures)
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package oci
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSortOSFeatures(t *testing.T) {
+	tests := []struct {
+		name     string
+		features []string
+		expect   []string
+	}{
+		{
+			name:     "empty",
+			features: []string{},
+			expect:   []string{},
+		},
+		{
+			name:     "strings only",
+			features: []string{"vfs", "net", "9pfs"},
+			expect:   []string{"9pfs", "net", "vfs"},
+		},
+		{
+			name:     "numbers only",
+			features: []string{"1", "10", "2"},
+			expect:   []string{"10", "2", "1"},
+		},
+		{
+			name:     "numbers before strings",
+			features: []string{"net", "2", "vfs", "10", "a1"},
+			expect:   []string{"10", "2", "a1", "net", "vfs"},
+		},
+		{
+			name:     "equal numeric values",
+			features: []string{"01", "net", "1", "001"},
+			expect:   []string{"001", "01", "1", "net"},
+		},
+		{
+			name:     "negative numbers",
+			features: []string{"-1", "net", "0", "3"},
+			expect:   []string{"3", "0", "-1", "net"},
+		},
+		{
+			name:     "duplicates",
+			features: []string{"net", "1", "net", "1"},
+			expect:   []string{"1", "1", "net", "net"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			features := slices.Clone(tt.features)
+			sortOSFeatures(features)
+
+			if !slices.Equal(features, tt.expect) {
+				t.Errorf("expected %v, got %v", tt.expect, features)
+			}
+
+			// Sorting must not depend on the input order.
+			reversed := slices.Clone(tt.features)
+			slices.Reverse(reversed)
+			sortOSFeatures(reversed)
+
+			if !slices.Equal(reversed, tt.expect) {
+				t.Errorf("expected %v from reversed input, got %v", tt.expect, reversed)
+			}
+		})
+	}
+}
@@ -74,6 +74,15 @@ type ociPackage struct {
 	command   []string
 	labels    map[string]string
 
+	// defaultRegistry and defaultTag are used to complete references which do
+	// not specify them and are inherited from the manager.
+	defaultRegistry string
+	defaultTag      string
+
+	// noChunkedUpload disables uploading blobs in resumable chunks when the
+	// package is pushed and is inherited from the manager.
+	noChunkedUpload bool
+
 	original *ociPackage
 }
 
@@ -85,6 +94,17 @@ var (
 // NewPackageFromTarget generates an OCI implementation of the pack.Package
 // construct based on an input Application and options.
 func NewPackageFromTarget(ctx context.Context, targ target.Target, opts ...packmanager.PackOption) (pack.Package, error) {
+	pkg, err := newPackageFromTarget(ctx, targ, DefaultRegistry, DefaultTag, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pkg, nil
+}
+
+// newPackageFromTarget is the implementation of NewPackageFromTarget which
+// completes the package's name with the provided default registry and tag.
+func newPackageFromTarget(ctx context.Context, targ target.Target, defaultRegistry, defaultTag string, opts ...packmanager.PackOption) (*ociPackage, error) {
 	var err error
 
 	popts := packmanager.NewPackOptions()
@@ -102,6 +122,9 @@ func NewPackageFromTarget(ctx context.Context, targ target.Target, opts ...packm
 		kernelDbg: targ.KernelDbg(),
 		command:   popts.Args(),
 		labels:    popts.Labels(),
+
+		defaultRegistry: defaultRegistry,
+		defaultTag:      defaultTag,
 	}
 
 	// It is possible that `NewPackageFromTarget` is called with an existing
@@ -119,8 +142,8 @@ func NewPackageFromTarget(ctx context.Context, targ target.Target, opts ...packm
 	}
 	ocipack.ref, err = name.ParseReference(
 		popts.Name(),
-		name.WithDefaultRegistry(DefaultRegistry),
-		name.WithDefaultTag(DefaultTag),
+		name.WithDefaultRegistry(ocipack.defaultRegistry),
+		name.WithDefaultTag(ocipack.defaultTag),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("could not parse image reference: %w", err)
@@ -160,9 +183,24 @@ func NewPackageFromTarget(ctx context.Context, targ target.Target, opts ...packm
 		return nil, err
 	}
 
+	// Sign the manifest when it is saved if a signing key has been configured.
+	signer, err := signerFromConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not load signing key: %w", err)
+	}
+
 	// Prepare a new manifest which contains the individual components of the
 	// target, including the kernel image.
-	ocipack.manifest, err = NewManifest(ctx, ocipack.handle)
+	manifestOpts := []ManifestOption{
+		WithManifestDefaultTag(ocipack.defaultTag),
+		WithSigner(signer),
+	}
+
+	if popts.CompressLayers() {
+		manifestOpts = append(manifestOpts, WithCompressedLayers())
+	}
+
+	ocipack.manifest, err = NewManifest(ctx, ocipack.handle, manifestOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("could not instantiate new manifest structure: %w", err)
 	}
@@ -173,11 +211,21 @@ func NewPackageFromTarget(ctx context.Context, targ target.Target, opts ...packm
 			WithField("dest", WellKnownKernelPath).
 			Debug("including kernel")
 
+		layerOpts := []LayerOption{
+			WithLayerAnnotation(AnnotationKernelPath, WellKnownKernelPath),
+		}
+
+		// The debug symbols are shipped separately, such that only a stripped
+		// kernel needs to be pulled in order to run the package.
+		if popts.KernelDbg() && len(ocipack.KernelDbg()) > 0 {
+			layerOpts = append(layerOpts, WithStripDebug())
+		}
+
 		layer, err := NewLayerFromFile(ctx,
 			ocispec.MediaTypeImageLayer,
 			ocipack.Kernel(),
 			WellKnownKernelPath,
-			WithLayerAnnotation(AnnotationKernelPath, WellKnownKernelPath),
+			layerOpts...,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("could not create new layer structure from file: %w", err)
@@ -256,8 +304,13 @@ func NewPackageFromTarget(ctx context.Context, targ target.Target, opts ...packm
 	// 	log.G(ctx).Debug("including application source files")
 	// }
 
+	// Adopt a deep copy of the original's image configuration, such that
+	// subsequent changes to the new manifest do not leak into the original.
 	if ocipack.original != nil {
-		ocipack.manifest.config = ocipack.original.manifest.config
+		ocipack.manifest.config, err = cloneImage(ocipack.original.manifest.config)
+		if err != nil {
+			return nil, fmt.Errorf("could not copy original image configuration: %w", err)
+		}
 	}
 
 	ocipack.manifest.SetAnnotation(ctx, AnnotationName, ocipack.Name())
@@ -399,11 +452,15 @@ func NewPackageFromTarget(ctx context.Context, targ target.Target, opts ...packm
 			Trace("label")
 	}
 
+	if workdir := popts.WorkingDir(); workdir != "" {
+		ocipack.manifest.SetWorkingDir(ctx, workdir)
+	}
+
 	if err := ocipack.index.AddManifest(ctx, ocipack.manifest); err != nil {
 		return nil, fmt.Errorf("could not add manifest to index: %w", err)
 	}
 
-	if _, err = ocipack.index.Save(ctx, ocipack.ref.String(), nil); err != nil {
+	if _, err = ocipack.index.Save(ctx, ocipack.ref.String(), popts.OnProgress); err != nil {
 		return nil, fmt.Errorf("could not save index: %w", err)
 	}
 
@@ -412,11 +469,13 @@ func NewPackageFromTarget(ctx context.Context, targ target.Target, opts ...packm
 
 // newPackageFromOCIManifestDigest is an internal method which retrieves the OCI
 // manifest from a remote reference and digest and returns, if found, an
-// instantiated Index and Manifest structure based on its contents.
-func newIndexAndManifestFromRemoteDigest(ctx context.Context, handle handler.Handler, fullref string, auths map[string]config.AuthConfig, dgst digest.Digest) (*Index, *Manifest, error) {
+// instantiated Index and Manifest structure based on its contents.  References
+// which do not specify a registry or tag are completed with the provided
+// defaults.
+func newIndexAndManifestFromRemoteDigest(ctx context.Context, handle handler.Handler, fullref string, auths map[string]config.AuthConfig, dgst digest.Digest, defaultRegistry, defaultTag string) (*Index, *Manifest, error) {
 	ref, err := name.ParseReference(fullref,
 		name.WithDefaultRegistry(""),
-		name.WithDefaultTag(DefaultTag),
+		name.WithDefaultTag(defaultTag),
 	)
 	if err != nil {
 		return nil, nil, err
@@ -424,8 +483,8 @@ func newIndexAndManifestFromRemoteDigest(ctx context.Context, handle handler.Han
 
 	if ref.Context().RegistryStr() == "" {
 		ref, err = name.ParseReference(fullref,
-			name.WithDefaultRegistry(DefaultRegistry),
-			name.WithDefaultTag(DefaultTag),
+			name.WithDefaultRegistry(defaultRegistry),
+			name.WithDefaultTag(defaultTag),
 		)
 		if err != nil {
 			return nil, nil, err
@@ -499,7 +558,9 @@ func newIndexAndManifestFromRemoteDigest(ctx context.Context, handle handler.Han
 			return func() error {
 				descriptor := ociIndex.Manifests[i]
 
-				manifest, err := NewManifest(egCtx, handle)
+				manifest, err := NewManifest(egCtx, handle,
+					WithManifestDefaultTag(defaultTag),
+				)
 				if err != nil {
 					return fmt.Errorf("could not instantiate new manifest: %w", err)
 				}
@@ -514,8 +575,12 @@ func newIndexAndManifestFromRemoteDigest(ctx context.Context, handle handler.Han
 				spec, err := handle.ResolveManifest(egCtx, "", descriptor.Digest)
 				if err == nil {
 					manifest.manifest = spec
-					manifest.config.Architecture = descriptor.Platform.Architecture
-					manifest.config.Platform = *descriptor.Platform
+					if image, err := handle.ResolveImage(egCtx, "", descriptor.Digest); err == nil {
+						manifest.config = image
+					} else {
+						manifest.config.Architecture = descriptor.Platform.Architecture
+						manifest.config.Platform = *descriptor.Platform
+					}
 				} else {
 					manifest.v1Image, err = cache.RemoteImage(
 						ref,
@@ -564,6 +629,15 @@ func newIndexAndManifestFromRemoteDigest(ctx context.Context, handle handler.Han
 					if err := json.Unmarshal(b, manifest.config); err != nil {
 						return fmt.Errorf("unmarshalling config: %w", err)
 					}
+
+					// Retain the DiffID of compressed layers.
+					if len(manifest.config.RootFS.DiffIDs) == len(manifest.layers) {
+						for i, layer := range manifest.layers {
+							if diffID := manifest.config.RootFS.DiffIDs[i]; diffID != layer.blob.desc.Digest {
+								layer.diffID = diffID
+							}
+						}
+					}
 				}
 
 				manifest.desc = &descriptor
@@ -590,16 +664,30 @@ func newIndexAndManifestFromRemoteDigest(ctx context.Context, handle handler.Han
 // instantiates a package based on the OCI format based on a provided OCI
 // Image manifest digest.
 func NewPackageFromOCIManifestDigest(ctx context.Context, handle handler.Handler, ref string, auths map[string]config.AuthConfig, dgst digest.Digest) (pack.Package, error) {
+	pkg, err := newPackageFromOCIManifestDigest(ctx, handle, ref, auths, dgst, DefaultRegistry, DefaultTag)
+	if err != nil {
+		return nil, err
+	}
+
+	return pkg, nil
+}
+
+// newPackageFromOCIManifestDigest is the implementation of
+// NewPackageFromOCIManifestDigest which completes the reference with the
+// provided default registry and tag.
+func newPackageFromOCIManifestDigest(ctx context.Context, handle handler.Handler, ref string, auths map[string]config.AuthConfig, dgst digest.Digest, defaultRegistry, defaultTag string) (*ociPackage, error) {
 	var err error
 
 	ocipack := ociPackage{
-		handle: handle,
-		auths:  auths,
+		handle:          handle,
+		auths:           auths,
+		defaultRegistry: defaultRegistry,
+		defaultTag:      defaultTag,
 	}
 
 	ocipack.ref, err = name.ParseReference(ref,
 		name.WithDefaultRegistry(""),
-		name.WithDefaultTag(DefaultTag),
+		name.WithDefaultTag(ocipack.defaultTag),
 	)
 	if err != nil {
 		return nil, err
@@ -615,12 +703,14 @@ func NewPackageFromOCIManifestDigest(ctx context.Context, handle handler.Handler
 				Debugf("could not instantiate index from local reference: %s", err.Error())
 
 			// Re-attempt by fetching remotely.
-			ocipack.index, ocipack.manifest, err = newIndexAndManifestFromRemoteDigest(ctx, handle, ref, auths, dgst)
+			ocipack.index, ocipack.manifest, err = newIndexAndManifestFromRemoteDigest(ctx, handle, ref, auths, dgst, ocipack.defaultRegistry, ocipack.defaultTag)
 			if err != nil {
 				return nil, fmt.Errorf("could not instantiate index and manifest from remote digest: %w", err)
 			}
 		} else {
-			manifest, err := NewManifestFromDigest(ctx, handle, dgst)
+			manifest, err := NewManifestFromDigest(ctx, handle, dgst,
+				WithManifestDefaultTag(ocipack.defaultTag),
+			)
 			if err != nil {
 				return nil, fmt.Errorf("could not instantiate manifest from digest: %w", err)
 			}
@@ -628,7 +718,7 @@ func NewPackageFromOCIManifestDigest(ctx context.Context, handle handler.Handler
 			ocipack.manifest = manifest
 		}
 	} else {
-		ocipack.index, ocipack.manifest, err = newIndexAndManifestFromRemoteDigest(ctx, handle, ref, auths, dgst)
+		ocipack.index, ocipack.manifest, err = newIndexAndManifestFromRemoteDigest(ctx, handle, ref, auths, dgst, ocipack.defaultRegistry, ocipack.defaultTag)
 		if err != nil {
 			return nil, err
 		}
@@ -727,6 +817,11 @@ func (ocipack *ociPackage) Columns() []tableprinter.Column {
 
 // Push implements pack.Package
 func (ocipack *ociPackage) Push(ctx context.Context, opts ...pack.PushOption) error {
+	popts, err := pack.NewPushOptions(opts...)
+	if err != nil {
+		return err
+	}
+
 	// In the circumstance where the original package is available, we use
 	// google/go-containerregistry to re-tag (which is achieved via `pusher.Push`
 	// which ultimately checks if the manifest, its layers, config and ultimately
@@ -787,10 +882,41 @@ func (ocipack *ociPackage) Push(ctx context.Context, opts ...pack.PushOption) er
 		return err
 	}
 
-	if err := ocipack.handle.PushDescriptor(ctx, ocipack.imageRef(), desc); err != nil {
+	pushOpts := []handler.PushDescriptorOption{
+		handler.WithChunkedUpload(!ocipack.noChunkedUpload && popts.ChunkedUpload()),
+	}
+	if mountFrom := popts.MountFrom(); mountFrom != "" {
+		pushOpts = append(pushOpts, handler.WithMountFrom(mountFrom))
+	}
+
+	if err := ocipack.handle.PushDescriptor(ctx, ocipack.imageRef(), desc, pushOpts...); err != nil {
 		return err
 	}
 
+	// Push the signatures of the manifest alongside it.  Each signature is
+	// pushed by digest such that registries discover it as an OCI 1.1 referrer
+	// via its subject, and signatures of multiple signers never overwrite each
+	// other.
+	if ocipack.manifest.desc != nil {
+		signatures, err := ocipack.manifest.signatures(ctx, ocipack.manifest.desc.Digest)
+		if err != nil {
+			return err
+		}
+
+		for i := range signatures {
+			sigRef := fmt.Sprintf("%s@%s", ocipack.ref.Context().Name(), signatures[i].Digest.String())
+
+			log.G(ctx).
+				WithField("ref", sigRef).
+				WithField("digest", signatures[i].Digest.String()).
+				Debug("pushing signature")
+
+			if err := ocipack.handle.PushDescriptor(ctx, sigRef, &signatures[i], pushOpts...); err != nil {
+				return fmt.Errorf("could not push signature: %w", err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -836,6 +962,12 @@ func (ocipack *ociPackage) Pull(ctx context.Context, opts ...pack.PullOption) er
 		return err
 	}
 
+	// Verify the pulled manifest if a verification key has been configured.
+	verifier, err := verifierFromConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("could not load verification key: %w", err)
+	}
+
 	// Pull the index but set the platform such that the relevant manifests can
 	// be retrieved as well.
 	if err := ocipack.handle.PullDigest(
@@ -862,6 +994,11 @@ func (ocipack *ociPackage) Pull(ctx context.Context, opts ...pack.PullOption) er
 	}
 
 	for dgstStr, manifest := range manifests {
+		// Skip artifacts which refer to other manifests, e.g. signatures.
+		if manifest.Subject != nil {
+			continue
+		}
+
 		newChecksum, err := ociutils.PlatformChecksum(ocipack.imageRef(), manifest.Config.Platform)
 		if err != nil {
 			return fmt.Errorf("calculating checksum for '%s': %w", ocipack.imageRef(), err)
@@ -872,7 +1009,18 @@ func (ocipack *ociPackage) Pull(ctx context.Context, opts ...pack.PullOption) er
 		}
 
 		dgst, _ := digest.Parse(dgstStr)
-		ocipack.manifest, err = NewManifestFromDigest(ctx, ocipack.handle, dgst)
+
+		// Signatures are only retrieved when they are to be verified.
+		if verifier != nil {
+			if err := ocipack.pullSignatures(ctx, dgst); err != nil {
+				return err
+			}
+		}
+
+		ocipack.manifest, err = NewManifestFromDigest(ctx, ocipack.handle, dgst,
+			WithManifestDefaultTag(ocipack.defaultTag),
+			WithVerifier(verifier),
+		)
 		if err != nil {
 			return fmt.Errorf("could not rehydrate manifest: %w", err)
 		}
@@ -1045,3 +1193,18 @@ func (ocipack *ociPackage) MarshalYAML() (interface{}, error) {
 		"platform":     ocipack.plat.Name(),
 	}, nil
 }
+
+// cloneImage returns a deep copy of the provided image configuration.
+func cloneImage(image *ocispec.Image) (*ocispec.Image, error) {
+	raw, err := json.Marshal(image)
+	if err != nil {
+		return nil, err
+	}
+
+	var clone ocispec.Image
+	if err := json.Unmarshal(raw, &clone); err != nil {
+		return nil, err
+	}
+
+	return &clone, nil
+}
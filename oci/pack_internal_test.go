@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/packmanager"
+	"kraftkit.sh/unikraft/arch"
+	"kraftkit.sh/unikraft/plat"
+	"kraftkit.sh/unikraft/target"
+)
+
+func TestNewPackageFromOriginalDoesNotModifyOriginal(t *testing.T) {
+	cfg, err := config.NewDefaultKraftKitConfig()
+	if err != nil {
+		t.Fatal("NewDefaultKraftKitConfig:", err)
+	}
+
+	cfg.RuntimeDir = t.TempDir()
+	cfg.ContainerdAddr = ""
+
+	cfgm, err := config.NewConfigManager(cfg)
+	if err != nil {
+		t.Fatal("NewConfigManager:", err)
+	}
+
+	ctx := config.WithConfigManager(context.Background(), cfgm)
+
+	targ := target.NewTargetFromOptions(
+		target.WithName("test"),
+		target.WithArchitecture(arch.NewArchitectureFromOptions(arch.WithName("x86_64"))),
+		target.WithPlatform(plat.NewPlatformFromOptions(plat.WithName("qemu"))),
+	)
+
+	packed, err := newPackageFromTarget(ctx, targ, DefaultRegistry, DefaultTag,
+		packmanager.PackName("unikraft.org/original:latest"),
+		packmanager.PackLabels(map[string]string{"original": "true"}),
+		packmanager.PackWithEnvs([]string{"ORIGINAL=1"}),
+	)
+	if err != nil {
+		t.Fatal("newPackageFromTarget:", err)
+	}
+
+	// Load the original package from the store, as is done when a package is
+	// re-packaged by reference.
+	original, err := newPackageFromOCIManifestDigest(ctx, packed.handle, packed.ref.String(), nil, packed.manifest.desc.Digest, DefaultRegistry, DefaultTag)
+	if err != nil {
+		t.Fatal("newPackageFromOCIManifestDigest:", err)
+	}
+
+	before, err := json.Marshal(original.manifest.config)
+	if err != nil {
+		t.Fatal("Marshal:", err)
+	}
+
+	derived, err := newPackageFromTarget(ctx, original, DefaultRegistry, DefaultTag,
+		packmanager.PackName("unikraft.org/derived:latest"),
+		packmanager.PackLabels(map[string]string{"derived": "true"}),
+		packmanager.PackWithEnvs([]string{"DERIVED=1"}),
+	)
+	if err != nil {
+		t.Fatal("newPackageFromTarget:", err)
+	}
+
+	after, err := json.Marshal(original.manifest.config)
+	if err != nil {
+		t.Fatal("Marshal:", err)
+	}
+
+	if !bytes.Equal(before, after) {
+		t.Errorf("expected the original configuration to be unchanged:\nbefore: %s\nafter:  %s", before, after)
+	}
+
+	// The saved configuration of the derived package inherits the labels of the
+	// original alongside its own.
+	image, err := derived.handle.ResolveImage(ctx, "", derived.manifest.desc.Digest)
+	if err != nil {
+		t.Fatal("ResolveImage:", err)
+	}
+
+	for _, label := range []string{"original", "derived"} {
+		if image.Config.Labels[label] != "true" {
+			t.Errorf("expected the derived configuration to have label '%s', got %v", label, image.Config.Labels)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package oci
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	"kraftkit.sh/log"
+)
+
+// isTransientError determines whether the provided error is likely to be
+// resolved by simply retrying the operation, e.g. a timeout, a dropped
+// connection or a server-side error.  Authentication and authorization
+// failures are never considered transient.
+func isTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		switch terr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return false
+		case http.StatusTooManyRequests, http.StatusRequestTimeout:
+			return true
+		}
+
+		return terr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errdefs.IsUnavailable(err)
+}
+
+// retryWithBackoff invokes fn until it succeeds, returns a non-transient error
+// or the number of retries has been exhausted.  The delay between attempts
+// starts at base and doubles after each attempt.  The onRetry callback, if
+// provided, is invoked before each new attempt.
+func retryWithBackoff(ctx context.Context, retries int, base time.Duration, onRetry func(attempt int, err error), fn func() error) error {
+	delay := base
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retries || !isTransientError(err) {
+			return err
+		}
+
+		log.G(ctx).
+			WithError(err).
+			WithField("attempt", attempt+1).
+			WithField("delay", delay.String()).
+			Debug("retrying after transient error")
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+		}
+
+		if onRetry != nil {
+			onRetry(attempt+1, err)
+		}
+
+		delay *= 2
+	}
+}
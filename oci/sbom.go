@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package oci
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// MediaTypeSBOMSPDX is the media type of an SPDX SBOM in JSON format.
+	MediaTypeSBOMSPDX = "application/spdx+json"
+
+	// MediaTypeSBOMCycloneDX is the media type of a CycloneDX SBOM in JSON
+	// format.
+	MediaTypeSBOMCycloneDX = "application/vnd.cyclonedx+json"
+)
+
+// AttachSBOM attaches the provided SBOM of the given media type, e.g.
+// MediaTypeSBOMSPDX, to the manifest as an OCI 1.1 referrer.  The manifest
+// must have been saved beforehand.
+func (manifest *Manifest) AttachSBOM(ctx context.Context, mediaType string, sbom []byte) error {
+	manifest.mu.RLock()
+	desc := manifest.desc
+	manifest.mu.RUnlock()
+
+	if desc == nil {
+		return fmt.Errorf("manifest must be saved before attaching an SBOM")
+	}
+
+	artifact := ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(sbom),
+		Size:      int64(len(sbom)),
+	}
+
+	if err := manifest.handle.SaveReferrer(ctx, *desc, artifact, bytes.NewReader(sbom)); err != nil {
+		return fmt.Errorf("could not attach SBOM: %w", err)
+	}
+
+	return nil
+}
+
+// SBOMs returns the descriptors of the referrer manifests of the given SBOM
+// media type which are attached to the manifest.
+func (manifest *Manifest) SBOMs(ctx context.Context, mediaType string) ([]ocispec.Descriptor, error) {
+	manifest.mu.RLock()
+	desc := manifest.desc
+	manifest.mu.RUnlock()
+
+	if desc == nil {
+		return nil, fmt.Errorf("manifest has not been saved")
+	}
+
+	return manifest.handle.ListReferrers(ctx, desc.Digest, mediaType)
+}
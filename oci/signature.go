@@ -0,0 +1,370 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/log"
+	"kraftkit.sh/oci/simpleauth"
+)
+
+const (
+	// ArtifactTypeSignature is the artifact type of the manifest which refers to
+	// a signed image manifest.
+	ArtifactTypeSignature = "application/vnd.kraftkit.signature.v1"
+
+	// MediaTypeSignaturePayload is the media type of the layer which contains
+	// the signed payload, i.e. the digest of the signed manifest.
+	MediaTypeSignaturePayload = "application/vnd.kraftkit.signature.payload.v1"
+
+	// AnnotationSignature holds the base64-encoded signature of the payload.
+	AnnotationSignature = "sh.kraftkit.signature"
+)
+
+// Signer computes the signature of the manifest described by the provided
+// descriptor.  The signature must be computed over the payload returned by
+// SignaturePayload.
+type Signer func(ctx context.Context, desc ocispec.Descriptor) (signature []byte, err error)
+
+// Verifier checks that the signature is valid for the manifest described by
+// the provided descriptor.
+type Verifier func(ctx context.Context, desc ocispec.Descriptor, signature []byte) error
+
+// SignaturePayload returns the payload which is signed for the manifest with
+// the provided descriptor.
+func SignaturePayload(desc ocispec.Descriptor) []byte {
+	return []byte(desc.Digest.String())
+}
+
+// NewSignerFromKey returns a Signer which uses the provided private key, e.g.
+// an *ecdsa.PrivateKey, ed25519.PrivateKey or *rsa.PrivateKey.
+func NewSignerFromKey(key crypto.Signer) Signer {
+	return func(_ context.Context, desc ocispec.Descriptor) ([]byte, error) {
+		payload := SignaturePayload(desc)
+
+		if _, ok := key.(ed25519.PrivateKey); ok {
+			return key.Sign(rand.Reader, payload, crypto.Hash(0))
+		}
+
+		sum := sha256.Sum256(payload)
+		return key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	}
+}
+
+// NewVerifierFromPublicKey returns a Verifier which checks signatures against
+// the provided public key.  ECDSA, Ed25519 and RSA (PKCS #1 v1.5) keys are
+// supported.
+func NewVerifierFromPublicKey(pub crypto.PublicKey) (Verifier, error) {
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		return func(_ context.Context, desc ocispec.Descriptor, signature []byte) error {
+			sum := sha256.Sum256(SignaturePayload(desc))
+			if !ecdsa.VerifyASN1(key, sum[:], signature) {
+				return fmt.Errorf("invalid signature")
+			}
+			return nil
+		}, nil
+
+	case ed25519.PublicKey:
+		return func(_ context.Context, desc ocispec.Descriptor, signature []byte) error {
+			if !ed25519.Verify(key, SignaturePayload(desc), signature) {
+				return fmt.Errorf("invalid signature")
+			}
+			return nil
+		}, nil
+
+	case *rsa.PublicKey:
+		return func(_ context.Context, desc ocispec.Descriptor, signature []byte) error {
+			sum := sha256.Sum256(SignaturePayload(desc))
+			return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], signature)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported public key type: %T", pub)
+}
+
+// NewSignerFromFile returns a Signer which uses the PEM-encoded PKCS #8,
+// PKCS #1 or SEC 1 private key at the provided path.
+func NewSignerFromFile(path string) (Signer, error) {
+	block, err := readPEMFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key '%s': %w", path, err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type: %T", key)
+	}
+
+	return NewSignerFromKey(signer), nil
+}
+
+// NewVerifierFromFile returns a Verifier which checks signatures against the
+// PEM-encoded PKIX or PKCS #1 public key at the provided path.
+func NewVerifierFromFile(path string) (Verifier, error) {
+	block, err := readPEMFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var pub any
+	if block.Type == "RSA PUBLIC KEY" {
+		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	} else {
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse public key '%s': %w", path, err)
+	}
+
+	return NewVerifierFromPublicKey(pub)
+}
+
+// readPEMFile returns the first PEM block of the file at the provided path.
+func readPEMFile(path string) (*pem.Block, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read key: %w", err)
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("could not decode key '%s': no PEM data found", path)
+	}
+
+	return block, nil
+}
+
+// signerFromConfig returns the Signer which uses the signing key set in
+// KraftKit's configuration, or nil if none is set.
+func signerFromConfig(ctx context.Context) (Signer, error) {
+	path := config.G[config.KraftKit](ctx).OCI.SigningKey
+	if path == "" {
+		return nil, nil
+	}
+
+	return NewSignerFromFile(path)
+}
+
+// verifierFromConfig returns the Verifier which uses the verification key set
+// in KraftKit's configuration, or nil if none is set.
+func verifierFromConfig(ctx context.Context) (Verifier, error) {
+	path := config.G[config.KraftKit](ctx).OCI.VerificationKey
+	if path == "" {
+		return nil, nil
+	}
+
+	return NewVerifierFromFile(path)
+}
+
+// sign computes the signature of the manifest and attaches it as an OCI 1.1
+// referrer, i.e. an artifact manifest whose subject is the signed manifest.
+func (manifest *Manifest) sign(ctx context.Context) error {
+	signature, err := manifest.signer(ctx, *manifest.desc)
+	if err != nil {
+		return fmt.Errorf("could not sign manifest: %w", err)
+	}
+
+	payload := SignaturePayload(*manifest.desc)
+	payloadDesc := content.NewDescriptorFromBytes(MediaTypeSignaturePayload, payload)
+	payloadDesc.ArtifactType = ArtifactTypeSignature
+	payloadDesc.Annotations = map[string]string{
+		AnnotationSignature: base64.StdEncoding.EncodeToString(signature),
+	}
+
+	log.G(ctx).
+		WithField("subject", manifest.desc.Digest.String()).
+		WithField("payload", payloadDesc.Digest.String()).
+		Debug("attaching signature")
+
+	if err := manifest.handle.SaveReferrer(ctx, *manifest.desc, payloadDesc, bytes.NewReader(payload)); err != nil {
+		return fmt.Errorf("could not save signature: %w", err)
+	}
+
+	return nil
+}
+
+// signatures returns the descriptors of the signature manifests which refer to
+// the manifest with the provided digest.
+func (manifest *Manifest) signatures(ctx context.Context, dgst digest.Digest) ([]ocispec.Descriptor, error) {
+	signatures, err := manifest.handle.ListReferrers(ctx, dgst, ArtifactTypeSignature)
+	if err != nil {
+		return nil, fmt.Errorf("could not list signatures: %w", err)
+	}
+
+	return signatures, nil
+}
+
+// verify checks that at least one signature attached to the manifest with the
+// provided descriptor is accepted by the manifest's verifier.
+func (manifest *Manifest) verify(ctx context.Context, desc ocispec.Descriptor) error {
+	signatures, err := manifest.signatures(ctx, desc.Digest)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	// The signature is always checked against the payload of the manifest being
+	// verified, such that a signature lifted from another manifest is rejected.
+	for _, referrer := range signatures {
+		signature, err := base64.StdEncoding.DecodeString(referrer.Annotations[AnnotationSignature])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not decode signature: %w", err))
+			continue
+		}
+
+		if err := manifest.verifier(ctx, desc, signature); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		return nil
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: manifest '%s' has no valid signature: %w", ErrInvalidSignature, desc.Digest.String(), errors.Join(errs...))
+	}
+
+	return fmt.Errorf("%w: manifest '%s' is not signed", ErrInvalidSignature, desc.Digest.String())
+}
+
+// pullSignatures retrieves the signatures which refer to the manifest with the
+// provided digest from the remote registry and attaches them locally, such
+// that the manifest can subsequently be verified.
+func (ocipack *ociPackage) pullSignatures(ctx context.Context, dgst digest.Digest) error {
+	authConfig := &authn.AuthConfig{}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	// Annoyingly convert between regtypes and authn.
+	if auth, ok := ocipack.auths[ocipack.ref.Context().RegistryStr()]; ok {
+		authConfig.Username = auth.User
+		authConfig.Password = auth.Token
+
+		if !auth.VerifySSL {
+			transport.TLSClientConfig = &tls.Config{
+				InsecureSkipVerify: true,
+			}
+		}
+	}
+
+	ropts := []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithAuth(&simpleauth.SimpleAuthenticator{
+			Auth: authConfig,
+		}),
+		remote.WithTransport(transport),
+	}
+
+	info, err := ocipack.handle.DigestInfo(ctx, dgst)
+	if err != nil {
+		return fmt.Errorf("could not get manifest info from digest: %w", classifyError(err, ErrManifestNotFound))
+	} else if info == nil {
+		return fmt.Errorf("%w: %s", ErrManifestNotFound, dgst.String())
+	}
+
+	subject := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    dgst,
+		Size:      info.Size,
+	}
+
+	referrers, err := remote.Referrers(ocipack.ref.Context().Digest(dgst.String()), ropts...)
+	if err != nil {
+		return fmt.Errorf("could not list remote signatures: %w", err)
+	}
+
+	referrersManifest, err := referrers.IndexManifest()
+	if err != nil {
+		return fmt.Errorf("could not access remote signatures: %w", err)
+	}
+
+	for _, referrer := range referrersManifest.Manifests {
+		if referrer.ArtifactType != ArtifactTypeSignature {
+			continue
+		}
+
+		image, err := remote.Image(ocipack.ref.Context().Digest(referrer.Digest.String()), ropts...)
+		if err != nil {
+			return fmt.Errorf("could not retrieve signature '%s': %w", referrer.Digest.String(), err)
+		}
+
+		spec, err := image.Manifest()
+		if err != nil {
+			return fmt.Errorf("could not access signature '%s': %w", referrer.Digest.String(), err)
+		}
+
+		if len(spec.Layers) != 1 {
+			return fmt.Errorf("signature '%s' has %d payloads, expected 1", referrer.Digest.String(), len(spec.Layers))
+		}
+
+		payload, err := image.LayerByDigest(spec.Layers[0].Digest)
+		if err != nil {
+			return fmt.Errorf("could not retrieve signature payload: %w", err)
+		}
+
+		reader, err := payload.Compressed()
+		if err != nil {
+			return fmt.Errorf("could not read signature payload: %w", err)
+		}
+
+		payloadDesc := ocispec.Descriptor{
+			MediaType:    string(spec.Layers[0].MediaType),
+			ArtifactType: ArtifactTypeSignature,
+			Digest:       digest.Digest(spec.Layers[0].Digest.String()),
+			Size:         spec.Layers[0].Size,
+			Annotations:  spec.Layers[0].Annotations,
+		}
+
+		log.G(ctx).
+			WithField("subject", dgst.String()).
+			WithField("digest", referrer.Digest.String()).
+			Debug("pulling signature")
+
+		err = ocipack.handle.SaveReferrer(ctx, subject, payloadDesc, reader)
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("could not save signature: %w", err)
+		}
+	}
+
+	return nil
+}
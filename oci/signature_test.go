@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package oci_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"kraftkit.sh/oci"
+	"kraftkit.sh/oci/handler"
+)
+
+func TestManifestSignatureRoundTrip(t *testing.T) {
+	const ref = "unikraft.org/test:latest"
+
+	signingPub, signingKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("GenerateKey:", err)
+	}
+
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("GenerateKey:", err)
+	}
+
+	tests := []struct {
+		name    string
+		sign    bool
+		pub     ed25519.PublicKey
+		invalid bool
+	}{
+		{
+			name: "signed and verified with the matching key",
+			sign: true,
+			pub:  signingPub,
+		},
+		{
+			name:    "signed and verified with another key",
+			sign:    true,
+			pub:     otherPub,
+			invalid: true,
+		},
+		{
+			name:    "unsigned",
+			pub:     signingPub,
+			invalid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			handle, err := handler.NewDirectoryHandler(t.TempDir(), nil)
+			if err != nil {
+				t.Fatal("NewDirectoryHandler:", err)
+			}
+
+			var opts []oci.ManifestOption
+			if tt.sign {
+				opts = append(opts, oci.WithSigner(oci.NewSignerFromKey(signingKey)))
+			}
+
+			manifest, err := oci.NewManifest(ctx, handle, opts...)
+			if err != nil {
+				t.Fatal("NewManifest:", err)
+			}
+
+			manifest.SetOS(ctx, "kraftkit")
+			manifest.SetArchitecture(ctx, "x86_64")
+
+			desc, err := manifest.Save(ctx, ref, nil)
+			if err != nil {
+				t.Fatal("Save:", err)
+			}
+
+			signatures, err := handle.ListReferrers(ctx, desc.Digest, oci.ArtifactTypeSignature)
+			if err != nil {
+				t.Fatal("ListReferrers:", err)
+			}
+
+			if tt.sign && len(signatures) != 1 {
+				t.Fatalf("expected 1 signature to be attached, got %d", len(signatures))
+			} else if !tt.sign && len(signatures) != 0 {
+				t.Fatalf("expected no signature to be attached, got %d", len(signatures))
+			}
+
+			verifier, err := oci.NewVerifierFromPublicKey(tt.pub)
+			if err != nil {
+				t.Fatal("NewVerifierFromPublicKey:", err)
+			}
+
+			_, err = oci.NewManifestFromDigest(ctx, handle, desc.Digest, oci.WithVerifier(verifier))
+			if tt.invalid && !errors.Is(err, oci.ErrInvalidSignature) {
+				t.Errorf("expected ErrInvalidSignature, got %v", err)
+			} else if !tt.invalid && err != nil {
+				t.Errorf("expected the signature to be valid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSignerAndVerifierFromFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("GenerateKey:", err)
+	}
+
+	rawKey, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal("MarshalPKCS8PrivateKey:", err)
+	}
+
+	rawPub, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal("MarshalPKIXPublicKey:", err)
+	}
+
+	keyPath := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: rawKey}), 0o600); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+
+	pubPath := filepath.Join(dir, "key.pub")
+	if err := os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rawPub}), 0o644); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+
+	signer, err := oci.NewSignerFromFile(keyPath)
+	if err != nil {
+		t.Fatal("NewSignerFromFile:", err)
+	}
+
+	verifier, err := oci.NewVerifierFromFile(pubPath)
+	if err != nil {
+		t.Fatal("NewVerifierFromFile:", err)
+	}
+
+	desc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    digest.FromString("manifest"),
+	}
+
+	signature, err := signer(ctx, desc)
+	if err != nil {
+		t.Fatal("signer:", err)
+	}
+
+	if err := verifier(ctx, desc, signature); err != nil {
+		t.Errorf("expected the signature to be valid, got %v", err)
+	}
+
+	// A signature must not be accepted for another manifest.
+	desc.Digest = digest.FromString("other")
+	if err := verifier(ctx, desc, signature); err == nil {
+		t.Errorf("expected the signature to be invalid for another manifest")
+	}
+
+	if _, err := oci.NewSignerFromFile(pubPath); err == nil {
+		t.Errorf("expected a public key to be rejected as a signing key")
+	}
+}
@@ -0,0 +1,302 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package oci
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// elfLayout describes the offsets of the ELF header and section header fields
+// which are rewritten when stripping, which differ between ELF classes.
+type elfLayout struct {
+	// Offsets of the fields of the ELF header.
+	phoff, shoff, phentsize, shentsize, shnum, shstrndx int
+
+	// Offsets of the fields of a section header.
+	shOffset, shSize, shLink, shInfo int
+
+	// Size of the ELF header and of addresses and offsets.
+	ehsize, addrSize int
+}
+
+var (
+	elfLayout32 = elfLayout{
+		phoff: 0x1c, shoff: 0x20, phentsize: 0x2a, shentsize: 0x2e, shnum: 0x30, shstrndx: 0x32,
+		shOffset: 0x10, shSize: 0x14, shLink: 0x18, shInfo: 0x1c,
+		ehsize: 52, addrSize: 4,
+	}
+	elfLayout64 = elfLayout{
+		phoff: 0x20, shoff: 0x28, phentsize: 0x36, shentsize: 0x3a, shnum: 0x3c, shstrndx: 0x3e,
+		shOffset: 0x18, shSize: 0x20, shLink: 0x28, shInfo: 0x2c,
+		ehsize: 64, addrSize: 8,
+	}
+)
+
+// isDebugSection returns whether the section only contains debug information
+// or symbols which are not required to execute the binary.
+func isDebugSection(section *elf.Section) bool {
+	if section.Flags&elf.SHF_ALLOC != 0 {
+		return false
+	}
+
+	return strings.HasPrefix(section.Name, ".debug") ||
+		strings.HasPrefix(section.Name, ".zdebug") ||
+		section.Name == ".symtab" ||
+		section.Name == ".strtab"
+}
+
+// stripDebug returns a copy of the provided ELF binary without its debug
+// sections and symbol tables.  The loadable content of the binary is left in
+// place and the remaining non-loadable sections are packed after it, such that
+// the result only depends on the input and is therefore reproducible.  Input
+// which is not an ELF binary, or which has nothing to strip, is returned as-is.
+func stripDebug(in []byte) ([]byte, error) {
+	f, err := elf.NewFile(bytes.NewReader(in))
+	if err != nil {
+		return in, nil
+	}
+
+	defer f.Close()
+
+	layout := elfLayout64
+	if f.Class == elf.ELFCLASS32 {
+		layout = elfLayout32
+	}
+
+	order := f.ByteOrder
+	readAddr := func(b []byte) uint64 {
+		if layout.addrSize == 4 {
+			return uint64(order.Uint32(b))
+		}
+		return order.Uint64(b)
+	}
+	putAddr := func(b []byte, v uint64) {
+		if layout.addrSize == 4 {
+			order.PutUint32(b, uint32(v))
+		} else {
+			order.PutUint64(b, v)
+		}
+	}
+
+	shoff := readAddr(in[layout.shoff:])
+	shentsize := uint64(order.Uint16(in[layout.shentsize:]))
+	shnum := len(f.Sections)
+	shstrndx := int(order.Uint16(in[layout.shstrndx:]))
+
+	if shnum == 0 || shnum >= int(elf.SHN_LORESERVE) || shstrndx >= shnum {
+		return in, nil
+	}
+
+	if shoff+uint64(shnum)*shentsize > uint64(len(in)) {
+		return nil, fmt.Errorf("section header table exceeds file size")
+	}
+
+	headers := make([][]byte, shnum)
+	for i := range headers {
+		start := shoff + uint64(i)*shentsize
+		headers[i] = bytes.Clone(in[start : start+shentsize])
+	}
+
+	removed := make([]bool, shnum)
+	for i, section := range f.Sections {
+		removed[i] = i > 0 && i != shstrndx && isDebugSection(section)
+	}
+
+	// Sections which refer to a removed section, e.g. the relocations of a
+	// debug section, are removed as well until no more references remain.
+	// Sections which are referred to by a loadable section, e.g. the symbol
+	// table of the PLT relocations of a static binary, are retained instead,
+	// along with the sections which they refer to.
+	required := make([]bool, shnum)
+	for i, section := range f.Sections {
+		required[i] = section.Flags&elf.SHF_ALLOC != 0
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		for i, section := range f.Sections {
+			if i == 0 || removed[i] {
+				continue
+			}
+
+			refs := []uint32{order.Uint32(headers[i][layout.shLink:])}
+			if section.Type == elf.SHT_REL || section.Type == elf.SHT_RELA {
+				refs = append(refs, order.Uint32(headers[i][layout.shInfo:]))
+			}
+
+			for _, ref := range refs {
+				if ref == 0 || int(ref) >= shnum || !removed[ref] {
+					continue
+				}
+
+				if required[i] {
+					removed[ref] = false
+					required[ref] = true
+				} else {
+					removed[i] = true
+				}
+
+				changed = true
+			}
+		}
+	}
+
+	if !slices.Contains(removed, true) {
+		return in, nil
+	}
+
+	// Retain everything up to the end of the last loadable content in place.
+	end := uint64(layout.ehsize)
+
+	if len(f.Progs) > 0 {
+		phoff := readAddr(in[layout.phoff:])
+		phentsize := uint64(order.Uint16(in[layout.phentsize:]))
+		end = max(end, phoff+uint64(len(f.Progs))*phentsize)
+	}
+
+	for _, prog := range f.Progs {
+		end = max(end, prog.Off+prog.Filesz)
+	}
+
+	for i, section := range f.Sections {
+		if i == 0 || removed[i] || section.Type == elf.SHT_NOBITS || section.Flags&elf.SHF_ALLOC == 0 {
+			continue
+		}
+
+		end = max(end, readAddr(headers[i][layout.shOffset:])+readAddr(headers[i][layout.shSize:]))
+	}
+
+	if end > uint64(len(in)) {
+		return nil, fmt.Errorf("loadable content exceeds file size")
+	}
+
+	out := bytes.Clone(in[:end])
+
+	align := func(n uint64) {
+		if n <= 1 {
+			return
+		}
+		for uint64(len(out))%n != 0 {
+			out = append(out, 0)
+		}
+	}
+
+	// Pack the remaining sections which are located after the loadable content
+	// and compute the new index of each retained section.
+	index := make([]uint32, shnum)
+	next := uint32(0)
+
+	for i, section := range f.Sections {
+		if removed[i] {
+			continue
+		}
+
+		index[i] = next
+		next++
+
+		if i == 0 || section.Type == elf.SHT_NOBITS {
+			continue
+		}
+
+		offset := readAddr(headers[i][layout.shOffset:])
+		size := readAddr(headers[i][layout.shSize:])
+		if offset+size <= end {
+			continue
+		}
+
+		if offset+size > uint64(len(in)) {
+			return nil, fmt.Errorf("section %s exceeds file size", section.Name)
+		}
+
+		align(section.Addralign)
+		putAddr(headers[i][layout.shOffset:], uint64(len(out)))
+		out = append(out, in[offset:offset+size]...)
+	}
+
+	align(uint64(layout.addrSize))
+	newShoff := uint64(len(out))
+
+	for i, section := range f.Sections {
+		if removed[i] {
+			continue
+		}
+
+		header := headers[i]
+
+		if link := order.Uint32(header[layout.shLink:]); link > 0 && int(link) < shnum {
+			order.PutUint32(header[layout.shLink:], index[link])
+		}
+
+		if section.Type == elf.SHT_REL || section.Type == elf.SHT_RELA || section.Flags&elf.SHF_INFO_LINK != 0 {
+			if info := order.Uint32(header[layout.shInfo:]); info > 0 && int(info) < shnum {
+				order.PutUint32(header[layout.shInfo:], index[info])
+			}
+		}
+
+		out = append(out, header...)
+	}
+
+	putAddr(out[layout.shoff:], newShoff)
+	order.PutUint16(out[layout.shnum:], uint16(next))
+	order.PutUint16(out[layout.shstrndx:], uint16(index[shstrndx]))
+
+	return out, nil
+}
+
+// stripDebugFile writes a copy of the provided file whose debug sections have
+// been removed to a temporary file, which retains the permissions of the
+// original, and returns its path.  The path of the original file is returned
+// if it has nothing to strip.
+func stripDebugFile(src string) (string, error) {
+	in, err := os.ReadFile(src)
+	if err != nil {
+		return "", fmt.Errorf("could not read %s: %w", src, err)
+	}
+
+	out, err := stripDebug(in)
+	if err != nil {
+		return "", fmt.Errorf("could not strip %s: %w", src, err)
+	}
+
+	if bytes.Equal(in, out) {
+		return src, nil
+	}
+
+	fi, err := os.Stat(src)
+	if err != nil {
+		return "", err
+	}
+
+	stripped, err := os.CreateTemp("", "kraftkit-stripped*")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := stripped.Write(out); err != nil {
+		stripped.Close()
+		os.Remove(stripped.Name())
+		return "", err
+	}
+
+	if err := stripped.Close(); err != nil {
+		os.Remove(stripped.Name())
+		return "", err
+	}
+
+	// Retain the permissions of the original file as they are recorded in the
+	// layer's tarball.
+	if err := os.Chmod(stripped.Name(), fi.Mode().Perm()); err != nil {
+		os.Remove(stripped.Name())
+		return "", err
+	}
+
+	return stripped.Name(), nil
+}
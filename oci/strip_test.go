@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package oci
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"os"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// debugBinary returns the content of the test binary itself, which is an ELF
+// binary with debug sections on the platforms which use ELF.
+func debugBinary(t *testing.T) []byte {
+	t.Helper()
+
+	in, err := os.ReadFile(os.Args[0])
+	if err != nil {
+		t.Fatal("ReadFile:", err)
+	}
+
+	f, err := elf.NewFile(bytes.NewReader(in))
+	if err != nil {
+		t.Skip("test binary is not an ELF binary")
+	}
+
+	defer f.Close()
+
+	hasDebug := false
+	for _, section := range f.Sections {
+		if isDebugSection(section) {
+			hasDebug = true
+			break
+		}
+	}
+
+	if !hasDebug {
+		t.Skip("test binary has no debug sections")
+	}
+
+	return in
+}
+
+func TestStripDebug(t *testing.T) {
+	in := debugBinary(t)
+
+	out, err := stripDebug(in)
+	if err != nil {
+		t.Fatal("stripDebug:", err)
+	}
+
+	if len(out) >= len(in) {
+		t.Errorf("expected the stripped binary to be smaller, got %d bytes from %d", len(out), len(in))
+	}
+
+	original, err := elf.NewFile(bytes.NewReader(in))
+	if err != nil {
+		t.Fatal("could not parse original binary:", err)
+	}
+
+	defer original.Close()
+
+	stripped, err := elf.NewFile(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal("could not parse stripped binary:", err)
+	}
+
+	defer stripped.Close()
+
+	for _, section := range stripped.Sections {
+		if isDebugSection(section) {
+			t.Errorf("unexpected section in stripped binary: %s", section.Name)
+		}
+	}
+
+	// The loadable content must be unchanged.
+	if len(stripped.Progs) != len(original.Progs) {
+		t.Fatalf("expected %d program headers, got %d", len(original.Progs), len(stripped.Progs))
+	}
+
+	for i, prog := range original.Progs {
+		if prog.ProgHeader != stripped.Progs[i].ProgHeader {
+			t.Errorf("program header %d: expected %+v, got %+v", i, prog.ProgHeader, stripped.Progs[i].ProgHeader)
+		}
+	}
+
+	for _, section := range original.Sections {
+		if section.Flags&elf.SHF_ALLOC == 0 || section.Type == elf.SHT_NOBITS {
+			continue
+		}
+
+		other := stripped.Section(section.Name)
+		if other == nil {
+			t.Errorf("missing loadable section %s", section.Name)
+			continue
+		}
+
+		want, err := section.Data()
+		if err != nil {
+			t.Fatalf("could not read section %s: %v", section.Name, err)
+		}
+
+		got, err := other.Data()
+		if err != nil {
+			t.Fatalf("could not read stripped section %s: %v", section.Name, err)
+		}
+
+		if !bytes.Equal(want, got) {
+			t.Errorf("section %s differs after stripping", section.Name)
+		}
+	}
+}
+
+func TestStripDebugIsReproducible(t *testing.T) {
+	in := debugBinary(t)
+
+	first, err := stripDebug(in)
+	if err != nil {
+		t.Fatal("stripDebug:", err)
+	}
+
+	second, err := stripDebug(bytes.Clone(in))
+	if err != nil {
+		t.Fatal("stripDebug:", err)
+	}
+
+	if a, b := digest.FromBytes(first), digest.FromBytes(second); a != b {
+		t.Errorf("expected stable digest, got %s and %s", a, b)
+	}
+
+	// Stripping a stripped binary has no effect.
+	again, err := stripDebug(first)
+	if err != nil {
+		t.Fatal("stripDebug:", err)
+	}
+
+	if !bytes.Equal(first, again) {
+		t.Error("expected stripping a stripped binary to leave it untouched")
+	}
+}
+
+func TestStripDebugIgnoresNonELF(t *testing.T) {
+	in := []byte("#!/bin/sh\necho hello\n")
+
+	out, err := stripDebug(in)
+	if err != nil {
+		t.Fatal("stripDebug:", err)
+	}
+
+	if !bytes.Equal(in, out) {
+		t.Error("expected non-ELF content to be left untouched")
+	}
+}
+
+func TestNewLayerFromFileWithStripDebug(t *testing.T) {
+	ctx := context.Background()
+	in := debugBinary(t)
+
+	src := t.TempDir() + "/kernel"
+	if err := os.WriteFile(src, in, 0o755); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+
+	newLayer := func(opts ...LayerOption) *Layer {
+		t.Helper()
+
+		layer, err := NewLayerFromFile(ctx, ocispec.MediaTypeImageLayer, src, WellKnownKernelPath, opts...)
+		if err != nil {
+			t.Fatal("NewLayerFromFile:", err)
+		}
+
+		t.Cleanup(func() {
+			os.Remove(layer.tmp)
+		})
+
+		return layer
+	}
+
+	first := newLayer(WithStripDebug(), WithLayerAnnotation(AnnotationKernelPath, WellKnownKernelPath))
+	second := newLayer(WithStripDebug(), WithLayerAnnotation(AnnotationKernelPath, WellKnownKernelPath))
+	full := newLayer()
+
+	if first.blob.desc.Digest != second.blob.desc.Digest {
+		t.Errorf("expected stable digest, got %s and %s", first.blob.desc.Digest, second.blob.desc.Digest)
+	}
+
+	if first.blob.desc.Size >= full.blob.desc.Size {
+		t.Errorf("expected the stripped layer to be smaller, got %d bytes from %d", first.blob.desc.Size, full.blob.desc.Size)
+	}
+
+	if got := first.blob.desc.Annotations[AnnotationKernelPath]; got != WellKnownKernelPath {
+		t.Errorf("expected annotation %s to be retained, got '%s'", AnnotationKernelPath, got)
+	}
+
+	// The source file itself must not be modified.
+	if b, err := os.ReadFile(src); err != nil || !bytes.Equal(b, in) {
+		t.Errorf("expected the source file to be left untouched: %v", err)
+	}
+}
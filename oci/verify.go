@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2024, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package oci
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"kraftkit.sh/log"
+)
+
+// architectureMachines maps the architecture of an image to the ELF machine
+// types of the kernels which can be used with it.
+var architectureMachines = map[string][]elf.Machine{
+	"x86_64":  {elf.EM_X86_64},
+	"amd64":   {elf.EM_X86_64},
+	"arm":     {elf.EM_ARM},
+	"arm64":   {elf.EM_AARCH64},
+	"aarch64": {elf.EM_AARCH64},
+	"riscv64": {elf.EM_RISCV},
+}
+
+// Verify checks the manifest for inconsistencies which would result in a
+// broken image.  Currently, this ensures that the machine type of the kernel
+// matches the architecture of the image.  Kernels which are streamed from a
+// reader cannot be inspected and are not verified.
+func (manifest *Manifest) Verify(ctx context.Context) error {
+	manifest.mu.RLock()
+	defer manifest.mu.RUnlock()
+
+	return manifest.verifyLayers(ctx)
+}
+
+// verifyLayers implements Verify and must be called whilst holding the lock of
+// the manifest.
+func (manifest *Manifest) verifyLayers(ctx context.Context) error {
+	architecture := manifest.config.Architecture
+	if architecture == "" {
+		return nil
+	}
+
+	machines, ok := architectureMachines[architecture]
+	if !ok {
+		return nil
+	}
+
+	for _, layer := range manifest.layers {
+		if layer.blob == nil {
+			continue
+		}
+
+		if _, ok := layer.blob.desc.Annotations[AnnotationKernelPath]; !ok {
+			continue
+		}
+
+		if layer.blob.stream != nil || layer.blob.tmp == "" {
+			log.G(ctx).Debug("skipping verification of streamed kernel")
+			continue
+		}
+
+		machine, err := kernelMachine(layer.blob.tmp)
+		if err != nil {
+			return fmt.Errorf("could not inspect kernel: %w", err)
+		}
+
+		if machine != elf.EM_NONE && !slices.Contains(machines, machine) {
+			return fmt.Errorf("kernel machine type %s does not match image architecture %s", machine, architecture)
+		}
+	}
+
+	return nil
+}
+
+// kernelMachine returns the ELF machine type of the kernel which is stored in
+// the optionally gzipped tarball layer at the provided path, or EM_NONE if the
+// kernel is not an ELF binary.
+func kernelMachine(path string) (elf.Machine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return elf.EM_NONE, err
+	}
+
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gzr, err := gzip.NewReader(br)
+		if err != nil {
+			return elf.EM_NONE, err
+		}
+
+		defer gzr.Close()
+
+		r = gzr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return elf.EM_NONE, fmt.Errorf("layer does not contain a kernel")
+		} else if err != nil {
+			return elf.EM_NONE, err
+		}
+
+		if hdr.Typeflag == tar.TypeReg {
+			break
+		}
+	}
+
+	// The machine type is stored at offset 18 of the ELF header, following the
+	// 16-byte identification and the 2-byte object file type.
+	header := make([]byte, 20)
+	if _, err := io.ReadFull(tr, header); errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return elf.EM_NONE, nil
+	} else if err != nil {
+		return elf.EM_NONE, fmt.Errorf("could not read ELF header: %w", err)
+	}
+
+	// Kernels may also be provided in other formats, e.g. a compressed Linux
+	// boot image, whose machine type cannot be determined.
+	if !bytes.Equal(header[:len(elf.ELFMAG)], []byte(elf.ELFMAG)) {
+		return elf.EM_NONE, nil
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if elf.Data(header[elf.EI_DATA]) == elf.ELFDATA2MSB {
+		order = binary.BigEndian
+	}
+
+	return elf.Machine(order.Uint16(header[18:20])), nil
+}
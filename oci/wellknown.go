@@ -8,6 +8,8 @@ const (
 	WellKnownKernelPath      = "/unikraft/bin/kernel"
 	WellKnownKernelDbgPath   = "/unikraft/bin/kernel.dbg"
 	WellKnownInitrdPath      = "/unikraft/bin/initrd"
+	WellKnownDeviceTreePath  = "/unikraft/bin/dtb"
+	WellKnownInitrdDir       = "/unikraft/initrd"
 	WellKnownConfigPath      = "/unikraft/bin/config"
 	WellKnownKernelSourceDir = "/unikraft/src"
 	WellKnownAppSourceDir    = "/unikraft/app"
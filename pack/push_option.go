@@ -7,7 +7,9 @@ package pack
 // PushOptions contains the list of options which can be set whilst pushing a
 // package.
 type PushOptions struct {
-	onProgress func(progress float64)
+	onProgress      func(progress float64)
+	mountFrom       string
+	noChunkedUpload bool
 }
 
 // PushOption is an option function which is used to modify PushOptions.
@@ -27,6 +29,18 @@ func NewPushOptions(opts ...PushOption) (*PushOptions, error) {
 	return options, nil
 }
 
+// MountFrom returns the repository which is hinted as the source for
+// cross-repository blob mounting.
+func (opts *PushOptions) MountFrom() string {
+	return opts.mountFrom
+}
+
+// ChunkedUpload returns whether the package's blobs may be uploaded in
+// resumable chunks.
+func (opts *PushOptions) ChunkedUpload() bool {
+	return !opts.noChunkedUpload
+}
+
 // WithPushProgressFunc set an optional progress function which is used as a
 // callback during the transmission of the package and the host.
 func WithPushProgressFunc(onProgress func(progress float64)) PushOption {
@@ -35,3 +49,23 @@ func WithPushProgressFunc(onProgress func(progress float64)) PushOption {
 		return nil
 	}
 }
+
+// WithPushMountFrom hints a repository, e.g. `unikraft.org/base`, from which
+// blobs which are shared with the package (such as a common kernel) can be
+// mounted rather than re-uploaded, if the registry supports it.
+func WithPushMountFrom(repo string) PushOption {
+	return func(opts *PushOptions) error {
+		opts.mountFrom = repo
+		return nil
+	}
+}
+
+// WithPushChunkedUpload sets whether the package's blobs may be uploaded in
+// resumable chunks, which is the default for package managers which support
+// it.  It can be disabled for registries which do not support chunked uploads.
+func WithPushChunkedUpload(enabled bool) PushOption {
+	return func(opts *PushOptions) error {
+		opts.noChunkedUpload = !enabled
+		return nil
+	}
+}
@@ -9,6 +9,7 @@ package packmanager
 type PackOptions struct {
 	appSourceFiles                   bool
 	args                             []string
+	compressLayers                   bool
 	env                              []string
 	initrd                           string
 	kconfig                          bool
@@ -19,8 +20,10 @@ type PackOptions struct {
 	kernelVersion                    string
 	labels                           map[string]string
 	name                             string
+	onProgress                       func(progress float64)
 	output                           string
 	mergeStrategy                    MergeStrategy
+	workingDir                       string
 }
 
 // NewPackOptions returns an instantiated *NewPackOptions with default
@@ -42,6 +45,12 @@ func (popts *PackOptions) Args() []string {
 	return popts.args
 }
 
+// CompressLayers returns whether the layers of the package should be
+// compressed.
+func (popts *PackOptions) CompressLayers() bool {
+	return popts.compressLayers
+}
+
 // Env returns the environment variables to be passed to the kernel.
 func (popts *PackOptions) Env() []string {
 	return popts.env
@@ -93,6 +102,14 @@ func (popts *PackOptions) Output() string {
 	return popts.output
 }
 
+// OnProgress calls (if set) an embedded progress function which can be used to
+// update an external progress bar, for example.
+func (popts *PackOptions) OnProgress(progress float64) {
+	if popts.onProgress != nil {
+		popts.onProgress(progress)
+	}
+}
+
 // Labels returns the labels to be added to the package.
 func (popts *PackOptions) Labels() map[string]string {
 	return popts.labels
@@ -103,6 +120,11 @@ func (popts *PackOptions) MergeStrategy() MergeStrategy {
 	return popts.mergeStrategy
 }
 
+// WorkingDir returns the working directory of the application.
+func (popts *PackOptions) WorkingDir() string {
+	return popts.workingDir
+}
+
 // PackOption is an option function which is used to modify PackOptions.
 type PackOption func(*PackOptions)
 
@@ -120,6 +142,13 @@ func PackArgs(args ...string) PackOption {
 	}
 }
 
+// PackCompressLayers marks to gzip the layers of the package.
+func PackCompressLayers(compress bool) PackOption {
+	return func(popts *PackOptions) {
+		popts.compressLayers = compress
+	}
+}
+
 // PackKConfig marks to include the kconfig `.config` file into the package.
 func PackKConfig(kconfig bool) PackOption {
 	return func(popts *PackOptions) {
@@ -186,6 +215,14 @@ func PackOutput(output string) PackOption {
 	}
 }
 
+// PackProgressFunc sets an optional progress function which is used as a
+// callback during the saving of the package.
+func PackProgressFunc(onProgress func(progress float64)) PackOption {
+	return func(popts *PackOptions) {
+		popts.onProgress = onProgress
+	}
+}
+
 // PackMergeStrategy sets the mechanism to use when an existing package of the
 // same name exists.
 func PackMergeStrategy(strategy MergeStrategy) PackOption {
@@ -207,3 +244,11 @@ func PackLabels(labels map[string]string) PackOption {
 		popts.labels = labels
 	}
 }
+
+// PackWorkingDir sets the working directory of the application, e.g. as set by
+// its root filesystem.
+func PackWorkingDir(workdir string) PackOption {
+	return func(popts *PackOptions) {
+		popts.workingDir = workdir
+	}
+}
@@ -46,6 +46,9 @@ type Query struct {
 
 	// KConfig specifies the list of config options of the package
 	kConfig []string
+
+	// Annotations specifies the set of annotations the package must contain
+	annotations map[string]string
 }
 
 // Source specifies where the origin of the package
@@ -83,6 +86,12 @@ func (query *Query) KConfig() []string {
 	return query.kConfig
 }
 
+// Annotations specifies the set of annotations the package must contain.  A
+// key with an empty value only requires the annotation to be present.
+func (query *Query) Annotations() map[string]string {
+	return query.annotations
+}
+
 // Remote indicates whether the package manager should use remote manifests
 // when making its query.
 func (query *Query) Remote() bool {
@@ -134,6 +143,9 @@ func (query *Query) Fields() map[string]interface{} {
 	if len(query.kConfig) > 0 {
 		fields["kConfig"] = query.kConfig
 	}
+	if len(query.annotations) > 0 {
+		fields["annotations"] = query.annotations
+	}
 
 	return fields
 }
@@ -174,6 +186,15 @@ func WithKConfig(kConfig []string) QueryOption {
 	}
 }
 
+// WithAnnotationSelector sets the query parameter for the set of annotations
+// which the package must contain.  Each key must be present and, unless the
+// value is empty, its value must be equal to the one provided.
+func WithAnnotationSelector(annotations map[string]string) QueryOption {
+	return func(query *Query) {
+		query.annotations = annotations
+	}
+}
+
 // WithSource sets the query parameter for the origin source of the package.
 func WithSource(source string) QueryOption {
 	return func(query *Query) {
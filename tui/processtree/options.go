@@ -4,7 +4,10 @@
 // You may not use this file expect in compliance with the License.
 package processtree
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type ProcessTreeOption func(pt *ProcessTree) error
 
@@ -56,3 +59,16 @@ func WithHideError(hide bool) ProcessTreeOption {
 		return nil
 	}
 }
+
+// WithMaxConcurrency limits the number of items which run simultaneously in
+// parallel mode.  A value of 0 means unlimited.
+func WithMaxConcurrency(n int) ProcessTreeOption {
+	return func(pt *ProcessTree) error {
+		if n < 0 {
+			return fmt.Errorf("maximum concurrency cannot be negative")
+		}
+
+		pt.maxConc = n
+		return nil
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/LastPossum/kamino"
@@ -16,6 +18,7 @@ import (
 	"github.com/charmbracelet/bubbles/stopwatch"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/muesli/termenv"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/term"
 
 	"kraftkit.sh/iostreams"
@@ -62,6 +65,12 @@ type ProcessTreeItem struct {
 	err       error
 	ellipsis  string
 	hideError bool
+
+	// progress is reported by the process whilst the item is rendered,
+	// hence access to it is guarded.
+	progressMu sync.RWMutex
+	progress   float64
+	reporting  bool
 }
 
 type ProcessTree struct {
@@ -84,6 +93,10 @@ type ProcessTree struct {
 	hide      bool
 	hideError bool
 	timeout   time.Duration
+	maxConc   int
+	running   int
+	cancel    context.CancelFunc
+	cancelled bool
 }
 
 func NewProcessTree(ctx context.Context, opts []ProcessTreeOption, tree ...*ProcessTreeItem) (*ProcessTree, error) {
@@ -108,6 +121,11 @@ func NewProcessTree(ctx context.Context, opts []ProcessTreeOption, tree ...*Proc
 		}
 	}
 
+	// Processes run with a context which is cancelled when the user interrupts
+	// the tree such that they are not left running in the background.
+	pctx, cancel := context.WithCancel(ctx)
+	pt.cancel = cancel
+
 	total := 0
 
 	_ = pt.traverseTreeAndCall(tree, func(item *ProcessTreeItem) error {
@@ -117,11 +135,11 @@ func NewProcessTree(ctx context.Context, opts []ProcessTreeOption, tree ...*Proc
 		item.hideError = pt.hideError
 
 		if pt.norender {
-			item.ctx = pt.ctx
+			item.ctx = pctx
 			return nil
 		}
 
-		ictx := pt.ctx
+		ictx := pctx
 
 		logger, err := kamino.Clone(log.G(ictx),
 			kamino.WithZeroUnexported(),
@@ -189,6 +207,44 @@ func (pti *ProcessTreeItem) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
+// SetProgress updates the completion of the item, as a value between 0 and 1,
+// which is rendered alongside it whilst it is running.
+func (pti *ProcessTreeItem) SetProgress(progress float64) {
+	if progress < 0 {
+		return
+	} else if progress > 1.0 {
+		progress = 1.0
+	}
+
+	pti.progressMu.Lock()
+	defer pti.progressMu.Unlock()
+
+	pti.progress = progress
+	pti.reporting = true
+}
+
+// Progress returns the completion of the item, as a value between 0 and 1, and
+// whether the item has reported any.
+func (pti *ProcessTreeItem) Progress() (float64, bool) {
+	pti.progressMu.RLock()
+	defer pti.progressMu.RUnlock()
+
+	return pti.progress, pti.reporting
+}
+
+type progressContextKey struct{}
+
+// OnProgress returns a callback which the SpinnerProcess can use to report its
+// completion, as a value between 0 and 1, for the item it is running as.  The
+// returned callback is a no-op if the context does not belong to an item.
+func OnProgress(ctx context.Context) func(float64) {
+	if item, ok := ctx.Value(progressContextKey{}).(*ProcessTreeItem); ok {
+		return item.SetProgress
+	}
+
+	return func(float64) {}
+}
+
 func (pti *ProcessTreeItem) Fd() int {
 	return 0
 }
@@ -208,6 +264,8 @@ func (pt *ProcessTree) Start() error {
 		teaOpts = append(teaOpts, tea.WithInput(nil))
 	}
 
+	defer pt.cancel()
+
 	// Restore the old output for the IOStreams which is manipulated per process.
 	defer func() {
 		iostreams.G(pt.ctx).Out = pt.oldOut
@@ -215,7 +273,9 @@ func (pt *ProcessTree) Start() error {
 	}()
 
 	if pt.norender {
-		teaOpts = append(teaOpts, tea.WithoutRenderer())
+		// Interrupts are handled below such that processes are cancelled rather
+		// than abandoned when the program quits.
+		teaOpts = append(teaOpts, tea.WithoutRenderer(), tea.WithoutSignalHandler())
 	} else {
 		// Set this super early (even before bubbletea), as fast exiting processes
 		// may not have received the window size update and therefore pt.width is
@@ -225,6 +285,33 @@ func (pt *ProcessTree) Start() error {
 
 	tprog = tea.NewProgram(pt, teaOpts...)
 
+	// Without a renderer, keyboard input is not read and an interrupt must
+	// instead be caught as a signal to cancel the running processes.
+	if pt.norender {
+		prog := tprog
+		sigctx, stop := signal.NotifyContext(pt.ctx, os.Interrupt)
+		defer stop()
+
+		done := make(chan struct{})
+		defer close(done)
+
+		go func() {
+			select {
+			case <-done:
+				return
+			case <-sigctx.Done():
+			}
+
+			// Restore the default behaviour such that a subsequent interrupt
+			// terminates immediately.
+			stop()
+
+			if pt.ctx.Err() == nil {
+				prog.Send(tea.KeyMsg{Type: tea.KeyCtrlC})
+			}
+		}()
+	}
+
 	if _, err := tprog.Run(); err != nil {
 		return err
 	}
@@ -241,7 +328,7 @@ func (pt *ProcessTree) Init() tea.Cmd {
 	}
 
 	// Start all child processes
-	children := pt.getNextReadyChildren(pt.tree)
+	children := pt.schedule(pt.getNextReadyChildren(pt.tree))
 	for _, pti := range children {
 		pti := pti
 		pti.timeout = pt.timeout
@@ -253,6 +340,26 @@ func (pt *ProcessTree) Init() tea.Cmd {
 	return tea.Batch(cmds...)
 }
 
+// schedule returns the subset of the provided ready items which can be started
+// without exceeding the maximum concurrency and marks them as running.  Items
+// which are not returned remain pending and are scheduled again as running
+// items complete.
+func (pt *ProcessTree) schedule(items []*ProcessTreeItem) []*ProcessTreeItem {
+	if pt.maxConc > 0 && len(items) > pt.maxConc-pt.running {
+		items = items[:max(pt.maxConc-pt.running, 0)]
+	}
+
+	for _, item := range items {
+		// Mark the item as running immediately such that it is not returned again
+		// as a ready child before its process has started.
+		item.status = StatusRunning
+	}
+
+	pt.running += len(items)
+
+	return items
+}
+
 func (pt ProcessTree) getNextReadyChildren(tree []*ProcessTreeItem) []*ProcessTreeItem {
 	var items []*ProcessTreeItem
 
@@ -319,21 +426,63 @@ func (pt *ProcessTree) waitForProcessCmd(item *ProcessTreeItem) tea.Cmd {
 	return func() tea.Msg {
 		item := item // golang closures
 
+		// When the logger has been configured to emit JSON, the lifecycle of each
+		// item is logged as a structured event such that it can be consumed by
+		// machines rather than as human-readable text.
+		structured := false
 		if pt.norender {
+			_, structured = log.G(item.ctx).Formatter.(*logrus.JSONFormatter)
+		}
+
+		if structured {
+			log.G(item.ctx).WithFields(logrus.Fields{
+				"process": item.textLeft,
+				"status":  "running",
+			}).Info(item.textLeft)
+		} else if pt.norender {
 			log.G(item.ctx).Info(item.textLeft)
 		}
 
 		// Set the process to running
 		item.status = StatusRunning
-
-		if err := item.process(item.ctx); err != nil {
-			log.G(item.ctx).Error(err)
+		started := time.Now()
+
+		// Whether the tree was cancelled is determined via the process' own
+		// context, since the tree's state is owned by the update loop.
+		if err := item.process(context.WithValue(item.ctx, progressContextKey{}, item)); err != nil && item.ctx.Err() != nil {
+			if structured {
+				log.G(item.ctx).WithFields(logrus.Fields{
+					"process":    item.textLeft,
+					"status":     "cancelled",
+					"elapsed_ms": time.Since(started).Milliseconds(),
+				}).Warn(item.textLeft)
+			} else {
+				log.G(item.ctx).Warn("cancelled")
+			}
+			item.status = StatusFailed
+		} else if err != nil {
+			if structured {
+				log.G(item.ctx).WithFields(logrus.Fields{
+					"process":    item.textLeft,
+					"status":     "failed",
+					"elapsed_ms": time.Since(started).Milliseconds(),
+				}).WithError(err).Error(item.textLeft)
+			} else {
+				log.G(item.ctx).Error(err)
+			}
 			item.status = StatusFailed
 			pt.err = err
 			if pt.failFast {
 				pt.quitting = true
 			}
 		} else {
+			if structured {
+				log.G(item.ctx).WithFields(logrus.Fields{
+					"process":    item.textLeft,
+					"status":     "success",
+					"elapsed_ms": time.Since(started).Milliseconds(),
+				}).Info(item.textLeft)
+			}
 			item.status = StatusSuccess
 		}
 
@@ -35,10 +35,22 @@ func (pt *ProcessTree) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
-		case "ctrl+c":
-			pt.quitting = true
-			pt.err = fmt.Errorf("force quit")
-			return pt, tea.Quit
+		case "ctrl+c", "q":
+			// A second interrupt, or one whilst nothing is running, quits
+			// immediately without waiting for the processes to return.
+			if pt.cancelled || pt.running == 0 {
+				pt.quitting = true
+				pt.err = fmt.Errorf("force quit")
+				pt.cancel()
+				return pt, tea.Quit
+			}
+
+			// Otherwise cancel all running processes and wait for them to return
+			// before quitting.
+			pt.cancelled = true
+			pt.err = fmt.Errorf("cancelled")
+			pt.cancel()
+			return pt, tea.Batch(cmds...)
 		}
 
 	case spinner.TickMsg:
@@ -71,6 +83,7 @@ func (pt *ProcessTree) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case processExitMsg:
 		cmds = append(cmds, msg.timer.Stop())
+		pt.running--
 
 		if msg.status == StatusSuccess ||
 			msg.status == StatusFailed ||
@@ -79,7 +92,7 @@ func (pt *ProcessTree) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		// No more processes then exit
-		if pt.total == pt.finished {
+		if pt.total == pt.finished || (pt.cancelled && pt.running == 0) {
 			pt.quitting = true
 			cmds = append(cmds, tea.Quit)
 		} else {
@@ -90,10 +103,13 @@ func (pt *ProcessTree) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return nil
 			})
 
-			children := pt.getNextReadyChildren(pt.tree)
-			for _, pti := range children {
-				pti := pti
-				cmds = append(cmds, pt.waitForProcessCmd(pti))
+			// Do not start any further processes once cancelled.
+			if !pt.cancelled {
+				children := pt.schedule(pt.getNextReadyChildren(pt.tree))
+				for _, pti := range children {
+					pti := pti
+					cmds = append(cmds, pt.waitForProcessCmd(pti))
+				}
 			}
 
 			cmds = append(cmds, waitForProcessExit(pt.channel))
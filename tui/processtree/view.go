@@ -5,6 +5,7 @@
 package processtree
 
 import (
+	"fmt"
 	"strconv"
 
 	"github.com/charmbracelet/lipgloss"
@@ -118,6 +119,9 @@ func (stm ProcessTree) printItem(pti *ProcessTreeItem, offset uint) string {
 	}
 
 	textRight := ""
+	if progress, ok := pti.Progress(); ok && pti.status == StatusRunning {
+		textRight += tui.TextLightGray(fmt.Sprintf("%3.0f%%", progress*100)) + " "
+	}
 	if len(pti.textRight) > 0 {
 		switch pti.status {
 		case StatusSuccess:
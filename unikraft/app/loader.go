@@ -80,6 +80,10 @@ func NewApplicationFromInterface(ctx context.Context, iface map[string]interface
 		app.outDir = popts.RelativePath(outdir)
 	}
 
+	if popts.outDir != "" {
+		app.outDir = popts.RelativePath(popts.outDir)
+	}
+
 	if err := Transform(ctx, getSection(iface, "unikraft"), &app.unikraft); err != nil {
 		return nil, err
 	}
@@ -78,6 +78,9 @@ func NewProjectFromOptions(ctx context.Context, opts ...ProjectOption) (Applicat
 
 	name, _ := popts.GetProjectName()
 	outdir := unikraft.BuildDir
+	if popts.outDir != "" {
+		outdir = popts.outDir
+	}
 
 	iface := popts.kraftfile.config
 	if iface == nil {
@@ -48,6 +48,7 @@ type ProjectOptions struct {
 	name              string
 	workdir           string
 	kraftfile         *Kraftfile
+	outDir            string
 	kconfig           kconfig.KeyValueMap
 	skipValidation    bool
 	skipInterpolation bool
@@ -210,6 +211,15 @@ func WithProjectWorkdir(workdir string) ProjectOption {
 	}
 }
 
+// WithProjectOutDir defines ProjectOptions' output directory, overriding the
+// default build directory and any set by the kraft file
+func WithProjectOutDir(outDir string) ProjectOption {
+	return func(popts *ProjectOptions) error {
+		popts.outDir = outDir
+		return nil
+	}
+}
+
 // WithProjectConfig defines a key=value set of variables used for kraft file
 // interpolation as well as with Unikraft's build system
 func WithProjectConfig(config []string) ProjectOption {
@@ -228,7 +238,8 @@ func WithProjectKraftfile(file string) ProjectOption {
 	}
 }
 
-// WithProjectKraftfile adds a kraft file to the project
+// WithProjectKraftfileFromBytes adds the contents of a kraft file to the
+// project, e.g. for a Kraftfile which was generated and never written to disk.
 func WithProjectKraftfileFromBytes(content []byte) ProjectOption {
 	return func(popts *ProjectOptions) error {
 		return popts.AddKraftfileFromBytes(content)